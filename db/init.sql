@@ -36,6 +36,7 @@ SET default_table_access_method = heap;
 -- Name: items; Type: TABLE; Schema: public; Owner: postgres
 SET search_path = public;
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
 
 CREATE TABLE public.items (
@@ -43,16 +44,157 @@ CREATE TABLE public.items (
     name character varying(255) NOT NULL,
     description text,
     price numeric(10,2) NOT NULL,
+    currency character(3) DEFAULT 'IDR'::bpchar NOT NULL,
     stock integer NOT NULL,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    tax_class character varying(32) DEFAULT 'standard'::character varying NOT NULL,
+    weight_grams integer DEFAULT 0 NOT NULL,
+    search_vector tsvector GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple'::regconfig, COALESCE(name, ''::character varying)::text), 'A'::"char") ||
+        setweight(to_tsvector('simple'::regconfig, COALESCE(description, ''::text)), 'B'::"char")
+    ) STORED,
     CONSTRAINT items_price_check CHECK ((price >= (0)::numeric)),
-    CONSTRAINT items_stock_check CHECK ((stock >= 0))
+    CONSTRAINT items_stock_check CHECK ((stock >= 0)),
+    CONSTRAINT items_weight_grams_check CHECK ((weight_grams >= 0))
 );
 
 
 ALTER TABLE public.items OWNER TO postgres;
 
+--
+-- Name: categories; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.categories (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    parent_id uuid,
+    name character varying(255) NOT NULL,
+    slug character varying(255) NOT NULL,
+    description text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT categories_parent_check CHECK ((parent_id IS NULL OR parent_id <> id))
+);
+
+
+ALTER TABLE public.categories OWNER TO postgres;
+
+--
+-- Name: item_categories; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.item_categories (
+    item_id uuid NOT NULL,
+    category_id uuid NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.item_categories OWNER TO postgres;
+
+--
+-- Name: item_variants; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.item_variants (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    item_id uuid NOT NULL,
+    sku character varying(64) NOT NULL,
+    options jsonb DEFAULT '{}'::jsonb NOT NULL,
+    price numeric(10,2),
+    stock integer NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT item_variants_price_check CHECK ((price >= (0)::numeric)),
+    CONSTRAINT item_variants_stock_check CHECK ((stock >= 0))
+);
+
+
+ALTER TABLE public.item_variants OWNER TO postgres;
+
+--
+-- Name: stock_movements; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.stock_movements (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    item_id uuid NOT NULL,
+    variant_id uuid,
+    quantity_delta integer NOT NULL,
+    balance_after integer NOT NULL,
+    reason character varying(32) NOT NULL,
+    reference_type character varying(32),
+    reference_id uuid,
+    actor_id character varying(64),
+    note text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT stock_movements_quantity_delta_check CHECK ((quantity_delta <> 0))
+);
+
+
+ALTER TABLE public.stock_movements OWNER TO postgres;
+
+--
+-- Name: stock_reservations; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.stock_reservations (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    status character varying(16) DEFAULT 'active'::character varying NOT NULL,
+    reference_type character varying(32),
+    reference_id uuid,
+    actor_id character varying(64),
+    expires_at timestamp with time zone NOT NULL,
+    confirmed_at timestamp with time zone,
+    released_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT stock_reservations_status_check CHECK (((status)::text = ANY ((ARRAY['active'::character varying, 'confirmed'::character varying, 'released'::character varying, 'expired'::character varying])::text[])))
+);
+
+
+ALTER TABLE public.stock_reservations OWNER TO postgres;
+
+--
+-- Name: stock_reservation_items; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.stock_reservation_items (
+    reservation_id uuid NOT NULL,
+    item_id uuid NOT NULL,
+    variant_id uuid,
+    quantity integer NOT NULL,
+    drawn_quantity integer DEFAULT 0 NOT NULL,
+    CONSTRAINT stock_reservation_items_drawn_quantity_check CHECK (((drawn_quantity >= 0) AND (drawn_quantity <= quantity))),
+    CONSTRAINT stock_reservation_items_quantity_check CHECK ((quantity > 0))
+);
+
+
+ALTER TABLE public.stock_reservation_items OWNER TO postgres;
+
+--
+-- Name: stock_commands; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.stock_commands (
+    key character varying(128) NOT NULL,
+    kind character varying(16) DEFAULT 'decrement'::character varying NOT NULL,
+    item_id uuid,
+    variant_id uuid,
+    quantity integer,
+    status character varying(16) NOT NULL,
+    reference_type character varying(32),
+    reference_id uuid,
+    reservation_id uuid,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT stock_commands_kind_check CHECK (((kind)::text = ANY ((ARRAY['decrement'::character varying, 'increment'::character varying])::text[]))),
+    CONSTRAINT stock_commands_status_check CHECK (((status)::text = ANY ((ARRAY['applied'::character varying, 'restored'::character varying, 'cancelled'::character varying])::text[])))
+);
+
+
+ALTER TABLE public.stock_commands OWNER TO postgres;
+
 --
 -- TOC entry 219 (class 1259 OID 61653)
 -- Name: purchase_items; Type: TABLE; Schema: public; Owner: postgres
@@ -62,9 +204,24 @@ CREATE TABLE public.purchase_items (
     id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
     purchase_id uuid NOT NULL,
     item_id uuid NOT NULL,
+    variant_id uuid,
+    item_name character varying(255),
+    sku character varying(64),
     quantity integer NOT NULL,
-    price_at_purchase numeric(10,2) NOT NULL,
-    CONSTRAINT purchase_items_quantity_check CHECK ((quantity > 0))
+    cancelled_quantity integer DEFAULT 0 NOT NULL,
+    returned_quantity integer DEFAULT 0 NOT NULL,
+    price_at_purchase numeric(14,2) NOT NULL,
+    original_price numeric(10,2) NOT NULL,
+    original_currency character(3) DEFAULT 'IDR'::bpchar NOT NULL,
+    exchange_rate numeric(20,10) DEFAULT 1 NOT NULL,
+    discount_amount numeric(14,2) DEFAULT 0 NOT NULL,
+    tax_class character varying(32) DEFAULT 'standard'::character varying NOT NULL,
+    tax_rate numeric(7,4) DEFAULT 0 NOT NULL,
+    net_amount numeric(14,2) DEFAULT 0 NOT NULL,
+    tax_amount numeric(14,2) DEFAULT 0 NOT NULL,
+    CONSTRAINT purchase_items_quantity_check CHECK ((quantity > 0)),
+    CONSTRAINT purchase_items_cancelled_quantity_check CHECK (((cancelled_quantity >= 0) AND (cancelled_quantity <= quantity))),
+    CONSTRAINT purchase_items_returned_quantity_check CHECK (((returned_quantity >= 0) AND ((cancelled_quantity + returned_quantity) <= quantity)))
 );
 
 
@@ -78,100 +235,1419 @@ ALTER TABLE public.purchase_items OWNER TO postgres;
 CREATE TABLE public.purchases (
     id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
     user_id uuid NOT NULL,
-    total_amount numeric(10,2) NOT NULL,
-    created_at timestamp with time zone DEFAULT now() NOT NULL
+    total_amount numeric(14,2) NOT NULL,
+    currency character(3) DEFAULT 'IDR'::bpchar NOT NULL,
+    status character varying(16) DEFAULT 'pending'::character varying NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    paid_at timestamp with time zone,
+    fulfilled_at timestamp with time zone,
+    delivered_at timestamp with time zone,
+    cancelled_at timestamp with time zone,
+    refunded_at timestamp with time zone,
+    discount_amount numeric(14,2) DEFAULT 0 NOT NULL,
+    coupon_code character varying(64),
+    promotion_id uuid,
+    subtotal_amount numeric(14,2) DEFAULT 0 NOT NULL,
+    tax_amount numeric(14,2) DEFAULT 0 NOT NULL,
+    tax_mode character varying(16) DEFAULT 'inclusive'::character varying NOT NULL,
+    shipping_method character varying(32),
+    shipping_zone character varying(32),
+    shipping_weight_grams integer DEFAULT 0 NOT NULL,
+    shipping_fee numeric(14,2) DEFAULT 0 NOT NULL,
+    shipping_address jsonb,
+    invoice_number character varying(32),
+    CONSTRAINT purchases_shipping_fee_check CHECK ((shipping_fee >= (0)::numeric)),
+    CONSTRAINT purchases_tax_mode_check CHECK (((tax_mode)::text = ANY ((ARRAY['inclusive'::character varying, 'exclusive'::character varying])::text[]))),
+    CONSTRAINT purchases_status_check CHECK (((status)::text = ANY ((ARRAY['pending'::character varying, 'paid'::character varying, 'fulfilled'::character varying, 'delivered'::character varying, 'cancelled'::character varying, 'refunded'::character varying])::text[])))
 );
 
 
 ALTER TABLE public.purchases OWNER TO postgres;
 
 --
--- TOC entry 216 (class 1259 OID 61617)
--- Name: users; Type: TABLE; Schema: public; Owner: postgres
+-- Name: invoice_sequences; Type: TABLE; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.users (
+CREATE TABLE public.invoice_sequences (
+    year integer NOT NULL,
+    last_number bigint DEFAULT 0 NOT NULL,
+    CONSTRAINT invoice_sequences_last_number_check CHECK ((last_number >= 0))
+);
+
+
+ALTER TABLE public.invoice_sequences OWNER TO postgres;
+
+--
+-- Name: purchase_status_history; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.purchase_status_history (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    purchase_id uuid NOT NULL,
+    from_status character varying(16),
+    to_status character varying(16) NOT NULL,
+    actor_id character varying(64),
+    note text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.purchase_status_history OWNER TO postgres;
+
+--
+-- Name: purchase_cancellations; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.purchase_cancellations (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    purchase_id uuid NOT NULL,
+    reason text NOT NULL,
+    actor_id character varying(64),
+    amount numeric(14,2) NOT NULL,
+    stock_restored_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.purchase_cancellations OWNER TO postgres;
+
+--
+-- Name: purchase_cancellation_items; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.purchase_cancellation_items (
+    cancellation_id uuid NOT NULL,
+    purchase_item_id uuid NOT NULL,
+    quantity integer NOT NULL,
+    CONSTRAINT purchase_cancellation_items_quantity_check CHECK ((quantity > 0))
+);
+
+
+ALTER TABLE public.purchase_cancellation_items OWNER TO postgres;
+
+--
+-- Name: purchase_returns; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.purchase_returns (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    purchase_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    status character varying(16) DEFAULT 'requested'::character varying NOT NULL,
+    reason text NOT NULL,
+    decision_note text,
+    decided_by character varying(64),
+    decided_at timestamp with time zone,
+    stock_restored_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT purchase_returns_status_check CHECK (((status)::text = ANY ((ARRAY['requested'::character varying, 'approved'::character varying, 'rejected'::character varying])::text[])))
+);
+
+
+ALTER TABLE public.purchase_returns OWNER TO postgres;
+
+--
+-- Name: purchase_return_items; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.purchase_return_items (
+    return_id uuid NOT NULL,
+    purchase_item_id uuid NOT NULL,
+    quantity integer NOT NULL,
+    CONSTRAINT purchase_return_items_quantity_check CHECK ((quantity > 0))
+);
+
+
+ALTER TABLE public.purchase_return_items OWNER TO postgres;
+
+--
+-- Name: refunds; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.refunds (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    return_id uuid,
+    cancellation_id uuid,
+    purchase_id uuid NOT NULL,
+    amount numeric(14,2) NOT NULL,
+    currency character(3) NOT NULL,
+    status character varying(16) DEFAULT 'pending'::character varying NOT NULL,
+    failure_reason text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT refunds_amount_check CHECK ((amount >= (0)::numeric)),
+    CONSTRAINT refunds_source_check CHECK (((return_id IS NULL) <> (cancellation_id IS NULL))),
+    CONSTRAINT refunds_status_check CHECK (((status)::text = ANY ((ARRAY['pending'::character varying, 'succeeded'::character varying, 'failed'::character varying])::text[])))
+);
+
+
+ALTER TABLE public.refunds OWNER TO postgres;
+
+--
+-- Name: promotions; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.promotions (
     id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    code character varying(64) NOT NULL,
     name character varying(255) NOT NULL,
-    email character varying(255) NOT NULL,
-    password_hash character varying(255) NOT NULL,
+    type character varying(16) NOT NULL,
+    value numeric(14,2) DEFAULT 0 NOT NULL,
+    currency character(3),
+    buy_quantity integer DEFAULT 0 NOT NULL,
+    get_quantity integer DEFAULT 0 NOT NULL,
+    min_spend numeric(14,2),
+    max_uses integer,
+    max_uses_per_user integer,
+    starts_at timestamp with time zone DEFAULT now() NOT NULL,
+    ends_at timestamp with time zone,
+    active boolean DEFAULT true NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT promotions_type_check CHECK (((type)::text = ANY ((ARRAY['percentage'::character varying, 'fixed_amount'::character varying, 'buy_x_get_y'::character varying])::text[]))),
+    CONSTRAINT promotions_value_check CHECK ((value >= (0)::numeric)),
+    CONSTRAINT promotions_window_check CHECK (((ends_at IS NULL) OR (ends_at > starts_at)))
+);
+
+
+ALTER TABLE public.promotions OWNER TO postgres;
+
+--
+-- Name: promotion_items; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.promotion_items (
+    promotion_id uuid NOT NULL,
+    item_id uuid NOT NULL
+);
+
+
+ALTER TABLE public.promotion_items OWNER TO postgres;
+
+--
+-- Name: promotion_categories; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.promotion_categories (
+    promotion_id uuid NOT NULL,
+    category_id uuid NOT NULL
+);
+
+
+ALTER TABLE public.promotion_categories OWNER TO postgres;
+
+--
+-- Name: carts; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.carts (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    user_id uuid,
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     updated_at timestamp with time zone DEFAULT now() NOT NULL
 );
 
 
-ALTER TABLE public.users OWNER TO postgres;
+ALTER TABLE public.carts OWNER TO postgres;
 
 --
--- TOC entry 4727 (class 2606 OID 61640)
--- Name: items items_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: cart_items; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.items
-    ADD CONSTRAINT items_pkey PRIMARY KEY (id);
+CREATE TABLE public.cart_items (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    cart_id uuid NOT NULL,
+    item_id uuid NOT NULL,
+    variant_id uuid,
+    quantity integer NOT NULL,
+    price_when_added numeric(14,2) NOT NULL,
+    currency_when_added character(3) NOT NULL,
+    added_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT cart_items_quantity_check CHECK ((quantity > 0))
+);
+
 
+ALTER TABLE public.cart_items OWNER TO postgres;
 
 --
--- TOC entry 4731 (class 2606 OID 61659)
--- Name: purchase_items purchase_items_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: purchase_sagas; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.purchase_items
-    ADD CONSTRAINT purchase_items_pkey PRIMARY KEY (id);
+CREATE TABLE public.purchase_sagas (
+    purchase_id uuid NOT NULL,
+    status character varying(16) DEFAULT 'pending'::character varying NOT NULL,
+    completed_steps integer DEFAULT 0 NOT NULL,
+    reservation_id uuid,
+    failure_reason text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT purchase_sagas_status_check CHECK (((status)::text = ANY ((ARRAY['pending'::character varying, 'compensating'::character varying, 'completed'::character varying, 'failed'::character varying])::text[])))
+);
 
 
+ALTER TABLE public.purchase_sagas OWNER TO postgres;
+
 --
--- TOC entry 4729 (class 2606 OID 61647)
--- Name: purchases purchases_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: payments; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.purchases
-    ADD CONSTRAINT purchases_pkey PRIMARY KEY (id);
+CREATE TABLE public.payments (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    purchase_id uuid NOT NULL,
+    provider character varying(32) NOT NULL,
+    reference character varying(255),
+    amount numeric(14,2) NOT NULL,
+    currency character(3) NOT NULL,
+    status character varying(16) DEFAULT 'pending'::character varying NOT NULL,
+    failure_reason text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT payments_amount_check CHECK ((amount >= (0)::numeric)),
+    CONSTRAINT payments_status_check CHECK (((status)::text = ANY ((ARRAY['pending'::character varying, 'authorized'::character varying, 'captured'::character varying, 'declined'::character varying, 'voided'::character varying, 'refunded'::character varying])::text[])))
+);
 
 
+ALTER TABLE public.payments OWNER TO postgres;
+
 --
--- TOC entry 4723 (class 2606 OID 61628)
--- Name: users users_email_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: idempotency_keys; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.users
-    ADD CONSTRAINT users_email_key UNIQUE (email);
+CREATE TABLE public.idempotency_keys (
+    scope character varying(255) NOT NULL,
+    key character varying(255) NOT NULL,
+    request_hash character(64) NOT NULL,
+    status_code integer,
+    content_type character varying(255),
+    response_body bytea,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
 
+ALTER TABLE public.idempotency_keys OWNER TO postgres;
 
 --
--- TOC entry 4725 (class 2606 OID 61626)
--- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: exchange_rates; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.users
-    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+CREATE TABLE public.exchange_rates (
+    base_currency character(3) NOT NULL,
+    quote_currency character(3) NOT NULL,
+    rate numeric(20,10) NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT exchange_rates_rate_check CHECK ((rate > (0)::numeric))
+);
 
 
+ALTER TABLE public.exchange_rates OWNER TO postgres;
+
 --
--- TOC entry 4733 (class 2606 OID 61665)
--- Name: purchase_items purchase_items_item_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: tax_rates; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.purchase_items
-    ADD CONSTRAINT purchase_items_item_id_fkey FOREIGN KEY (item_id) REFERENCES public.items(id);
+CREATE TABLE public.tax_rates (
+    tax_class character varying(32) NOT NULL,
+    name character varying(255) NOT NULL,
+    rate numeric(7,4) NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT tax_rates_rate_check CHECK ((rate >= (0)::numeric))
+);
+
 
+ALTER TABLE public.tax_rates OWNER TO postgres;
 
 --
--- TOC entry 4734 (class 2606 OID 61660)
--- Name: purchase_items purchase_items_purchase_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: shipping_methods; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.purchase_items
-    ADD CONSTRAINT purchase_items_purchase_id_fkey FOREIGN KEY (purchase_id) REFERENCES public.purchases(id) ON DELETE CASCADE;
+CREATE TABLE public.shipping_methods (
+    code character varying(32) NOT NULL,
+    name character varying(255) NOT NULL,
+    currency character(3) DEFAULT 'IDR'::bpchar NOT NULL,
+    active boolean DEFAULT true NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
 
+ALTER TABLE public.shipping_methods OWNER TO postgres;
 
 --
--- TOC entry 4732 (class 2606 OID 61648)
--- Name: purchases purchases_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: shipping_zones; Type: TABLE; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.purchases
-    ADD CONSTRAINT purchases_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
+CREATE TABLE public.shipping_zones (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    method_code character varying(32) NOT NULL,
+    zone character varying(32) NOT NULL,
+    country_code character varying(2) NOT NULL,
+    province character varying(128)
+);
+
+
+ALTER TABLE public.shipping_zones OWNER TO postgres;
+
+--
+-- Name: shipping_rates; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.shipping_rates (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    method_code character varying(32) NOT NULL,
+    zone character varying(32) NOT NULL,
+    max_weight_grams integer,
+    fee numeric(14,2) NOT NULL,
+    CONSTRAINT shipping_rates_max_weight_grams_check CHECK ((max_weight_grams > 0)),
+    CONSTRAINT shipping_rates_fee_check CHECK ((fee >= (0)::numeric))
+);
+
+
+ALTER TABLE public.shipping_rates OWNER TO postgres;
+
+--
+-- Name: sales_item_buckets; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.sales_item_buckets (
+    bucket_start timestamp with time zone NOT NULL,
+    item_id uuid NOT NULL,
+    currency character(3) NOT NULL,
+    item_name character varying(255) NOT NULL,
+    quantity integer NOT NULL,
+    revenue numeric(14,2) NOT NULL
+);
+
+
+ALTER TABLE public.sales_item_buckets OWNER TO postgres;
+
+--
+-- Name: sales_customer_buckets; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.sales_customer_buckets (
+    bucket_start timestamp with time zone NOT NULL,
+    user_id uuid NOT NULL,
+    currency character(3) NOT NULL,
+    orders integer NOT NULL,
+    units integer NOT NULL,
+    revenue numeric(14,2) NOT NULL
+);
+
+
+ALTER TABLE public.sales_customer_buckets OWNER TO postgres;
+
+--
+-- Name: stock_turnover_buckets; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.stock_turnover_buckets (
+    bucket_start timestamp with time zone NOT NULL,
+    item_id uuid NOT NULL,
+    units_sold integer NOT NULL,
+    net_delta integer NOT NULL
+);
+
+
+ALTER TABLE public.stock_turnover_buckets OWNER TO postgres;
+
+--
+-- Name: report_refresh_state; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.report_refresh_state (
+    name character varying(32) NOT NULL,
+    refreshed_through timestamp with time zone
+);
+
+
+ALTER TABLE public.report_refresh_state OWNER TO postgres;
+
+--
+-- TOC entry 216 (class 1259 OID 61617)
+-- Name: users; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.users (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    name character varying(255) NOT NULL,
+    email character varying(255) NOT NULL,
+    password_hash character varying(255) NOT NULL,
+    role character varying(16) DEFAULT 'customer'::character varying NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT users_role_check CHECK (((role)::text = ANY ((ARRAY['customer'::character varying, 'staff'::character varying, 'admin'::character varying])::text[])))
+);
+
+
+ALTER TABLE public.users OWNER TO postgres;
+
+--
+-- Name: user_addresses; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.user_addresses (
+    id uuid DEFAULT public.uuid_generate_v4() NOT NULL,
+    user_id uuid NOT NULL,
+    label character varying(64),
+    recipient_name character varying(255) NOT NULL,
+    phone character varying(32) NOT NULL,
+    line1 character varying(255) NOT NULL,
+    line2 character varying(255),
+    city character varying(128) NOT NULL,
+    province character varying(128),
+    postal_code character varying(16) NOT NULL,
+    country_code character(2) NOT NULL,
+    is_default boolean DEFAULT false NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+ALTER TABLE public.user_addresses OWNER TO postgres;
+
+--
+-- TOC entry 4727 (class 2606 OID 61640)
+-- Name: items items_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.items
+    ADD CONSTRAINT items_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: categories categories_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.categories
+    ADD CONSTRAINT categories_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: categories categories_slug_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.categories
+    ADD CONSTRAINT categories_slug_key UNIQUE (slug);
+
+
+--
+-- Name: item_categories item_categories_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.item_categories
+    ADD CONSTRAINT item_categories_pkey PRIMARY KEY (item_id, category_id);
+
+
+--
+-- Name: item_variants item_variants_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.item_variants
+    ADD CONSTRAINT item_variants_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: item_variants item_variants_sku_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.item_variants
+    ADD CONSTRAINT item_variants_sku_key UNIQUE (sku);
+
+
+--
+-- Name: stock_movements stock_movements_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_movements
+    ADD CONSTRAINT stock_movements_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: stock_reservations stock_reservations_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_reservations
+    ADD CONSTRAINT stock_reservations_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: stock_commands stock_commands_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_commands
+    ADD CONSTRAINT stock_commands_pkey PRIMARY KEY (key);
+
+
+--
+-- Name: purchase_sagas purchase_sagas_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_sagas
+    ADD CONSTRAINT purchase_sagas_pkey PRIMARY KEY (purchase_id);
+
+
+--
+-- Name: payments payments_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.payments
+    ADD CONSTRAINT payments_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: payments payments_purchase_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.payments
+    ADD CONSTRAINT payments_purchase_id_key UNIQUE (purchase_id);
+
+
+--
+-- Name: purchase_status_history purchase_status_history_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_status_history
+    ADD CONSTRAINT purchase_status_history_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: purchase_cancellations purchase_cancellations_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_cancellations
+    ADD CONSTRAINT purchase_cancellations_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: purchase_cancellation_items purchase_cancellation_items_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_cancellation_items
+    ADD CONSTRAINT purchase_cancellation_items_pkey PRIMARY KEY (cancellation_id, purchase_item_id);
+
+
+--
+-- Name: purchase_returns purchase_returns_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_returns
+    ADD CONSTRAINT purchase_returns_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: purchase_return_items purchase_return_items_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_return_items
+    ADD CONSTRAINT purchase_return_items_pkey PRIMARY KEY (return_id, purchase_item_id);
+
+
+--
+-- Name: refunds refunds_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.refunds
+    ADD CONSTRAINT refunds_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: refunds refunds_return_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.refunds
+    ADD CONSTRAINT refunds_return_id_key UNIQUE (return_id);
+
+
+--
+-- Name: refunds refunds_cancellation_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.refunds
+    ADD CONSTRAINT refunds_cancellation_id_key UNIQUE (cancellation_id);
+
+
+--
+-- Name: promotions promotions_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.promotions
+    ADD CONSTRAINT promotions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: promotions promotions_code_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.promotions
+    ADD CONSTRAINT promotions_code_key UNIQUE (code);
+
+
+--
+-- Name: promotion_items promotion_items_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.promotion_items
+    ADD CONSTRAINT promotion_items_pkey PRIMARY KEY (promotion_id, item_id);
+
+
+--
+-- Name: promotion_categories promotion_categories_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.promotion_categories
+    ADD CONSTRAINT promotion_categories_pkey PRIMARY KEY (promotion_id, category_id);
+
+
+--
+-- Name: carts carts_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.carts
+    ADD CONSTRAINT carts_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: carts carts_user_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.carts
+    ADD CONSTRAINT carts_user_id_key UNIQUE (user_id);
+
+
+--
+-- Name: cart_items cart_items_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.cart_items
+    ADD CONSTRAINT cart_items_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: idempotency_keys idempotency_keys_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.idempotency_keys
+    ADD CONSTRAINT idempotency_keys_pkey PRIMARY KEY (scope, key);
+
+
+--
+-- TOC entry 4731 (class 2606 OID 61659)
+-- Name: purchase_items purchase_items_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_items
+    ADD CONSTRAINT purchase_items_pkey PRIMARY KEY (id);
+
+
+--
+-- TOC entry 4729 (class 2606 OID 61647)
+-- Name: purchases purchases_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchases
+    ADD CONSTRAINT purchases_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: exchange_rates exchange_rates_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.exchange_rates
+    ADD CONSTRAINT exchange_rates_pkey PRIMARY KEY (base_currency, quote_currency);
+
+
+--
+-- Name: tax_rates tax_rates_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tax_rates
+    ADD CONSTRAINT tax_rates_pkey PRIMARY KEY (tax_class);
+
+
+--
+-- Name: invoice_sequences invoice_sequences_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.invoice_sequences
+    ADD CONSTRAINT invoice_sequences_pkey PRIMARY KEY (year);
+
+
+--
+-- Name: purchases purchases_invoice_number_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchases
+    ADD CONSTRAINT purchases_invoice_number_key UNIQUE (invoice_number);
+
+
+--
+-- Name: shipping_methods shipping_methods_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.shipping_methods
+    ADD CONSTRAINT shipping_methods_pkey PRIMARY KEY (code);
+
+
+--
+-- Name: shipping_zones shipping_zones_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.shipping_zones
+    ADD CONSTRAINT shipping_zones_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: shipping_rates shipping_rates_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.shipping_rates
+    ADD CONSTRAINT shipping_rates_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: sales_item_buckets sales_item_buckets_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.sales_item_buckets
+    ADD CONSTRAINT sales_item_buckets_pkey PRIMARY KEY (bucket_start, item_id, currency);
+
+
+--
+-- Name: sales_customer_buckets sales_customer_buckets_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.sales_customer_buckets
+    ADD CONSTRAINT sales_customer_buckets_pkey PRIMARY KEY (bucket_start, user_id, currency);
+
+
+--
+-- Name: stock_turnover_buckets stock_turnover_buckets_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_turnover_buckets
+    ADD CONSTRAINT stock_turnover_buckets_pkey PRIMARY KEY (bucket_start, item_id);
+
+
+--
+-- Name: report_refresh_state report_refresh_state_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.report_refresh_state
+    ADD CONSTRAINT report_refresh_state_pkey PRIMARY KEY (name);
+
+
+--
+-- TOC entry 4723 (class 2606 OID 61628)
+-- Name: users users_email_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_email_key UNIQUE (email);
+
+
+--
+-- TOC entry 4725 (class 2606 OID 61626)
+-- Name: users users_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.users
+    ADD CONSTRAINT users_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: user_addresses user_addresses_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.user_addresses
+    ADD CONSTRAINT user_addresses_pkey PRIMARY KEY (id);
+
+
+--
+-- TOC entry 4734 (class 2606 OID 61660)
+-- Name: purchase_items purchase_items_purchase_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_items
+    ADD CONSTRAINT purchase_items_purchase_id_fkey FOREIGN KEY (purchase_id) REFERENCES public.purchases(id) ON DELETE CASCADE;
+
+
+--
+-- TOC entry 4732 (class 2606 OID 61648)
+-- Name: purchases purchases_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchases
+    ADD CONSTRAINT purchases_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
+
+
+--
+-- Name: items_created_at_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX items_created_at_id_idx ON public.items USING btree (created_at, id);
+
+
+--
+-- Name: items_updated_at_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX items_updated_at_id_idx ON public.items USING btree (updated_at, id);
+
+
+--
+-- Name: items_price_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX items_price_id_idx ON public.items USING btree (price, id);
+
+
+--
+-- Name: items_name_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX items_name_id_idx ON public.items USING btree (name, id);
+
+
+--
+-- Name: items_search_vector_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX items_search_vector_idx ON public.items USING gin (search_vector);
+
+
+--
+-- Name: items_name_trgm_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX items_name_trgm_idx ON public.items USING gin (name public.gin_trgm_ops);
+
+
+--
+-- Name: items_description_trgm_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX items_description_trgm_idx ON public.items USING gin (description public.gin_trgm_ops);
+
+
+--
+-- Name: categories categories_parent_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.categories
+    ADD CONSTRAINT categories_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES public.categories(id) ON DELETE RESTRICT;
+
+
+--
+-- Name: item_categories item_categories_item_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.item_categories
+    ADD CONSTRAINT item_categories_item_id_fkey FOREIGN KEY (item_id) REFERENCES public.items(id) ON DELETE CASCADE;
+
+
+--
+-- Name: item_categories item_categories_category_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.item_categories
+    ADD CONSTRAINT item_categories_category_id_fkey FOREIGN KEY (category_id) REFERENCES public.categories(id) ON DELETE CASCADE;
+
+
+--
+-- Name: categories_parent_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX categories_parent_id_idx ON public.categories USING btree (parent_id);
+
+
+--
+-- Name: item_categories_category_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX item_categories_category_id_idx ON public.item_categories USING btree (category_id);
+
+
+--
+-- Name: item_variants item_variants_item_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.item_variants
+    ADD CONSTRAINT item_variants_item_id_fkey FOREIGN KEY (item_id) REFERENCES public.items(id) ON DELETE CASCADE;
+
+
+--
+-- Name: item_variants_item_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX item_variants_item_id_idx ON public.item_variants USING btree (item_id);
+
+
+--
+-- Name: stock_movements stock_movements_item_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_movements
+    ADD CONSTRAINT stock_movements_item_id_fkey FOREIGN KEY (item_id) REFERENCES public.items(id) ON DELETE CASCADE;
+
+
+--
+-- Name: stock_movements stock_movements_variant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_movements
+    ADD CONSTRAINT stock_movements_variant_id_fkey FOREIGN KEY (variant_id) REFERENCES public.item_variants(id) ON DELETE CASCADE;
+
+
+--
+-- Name: stock_movements_item_id_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX stock_movements_item_id_created_at_idx ON public.stock_movements USING btree (item_id, created_at DESC);
+
+
+--
+-- Name: stock_movements_reference_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX stock_movements_reference_idx ON public.stock_movements USING btree (reference_type, reference_id);
+
+
+--
+-- Name: stock_reservation_items stock_reservation_items_reservation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_reservation_items
+    ADD CONSTRAINT stock_reservation_items_reservation_id_fkey FOREIGN KEY (reservation_id) REFERENCES public.stock_reservations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: stock_reservation_items stock_reservation_items_item_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_reservation_items
+    ADD CONSTRAINT stock_reservation_items_item_id_fkey FOREIGN KEY (item_id) REFERENCES public.items(id) ON DELETE CASCADE;
+
+
+--
+-- Name: stock_reservation_items stock_reservation_items_variant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_reservation_items
+    ADD CONSTRAINT stock_reservation_items_variant_id_fkey FOREIGN KEY (variant_id) REFERENCES public.item_variants(id) ON DELETE CASCADE;
+
+
+--
+-- Name: stock_reservation_items_reservation_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX stock_reservation_items_reservation_id_idx ON public.stock_reservation_items USING btree (reservation_id);
+
+
+--
+-- Name: stock_reservations_active_expires_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX stock_reservations_active_expires_at_idx ON public.stock_reservations USING btree (expires_at) WHERE ((status)::text = 'active'::text);
+
+
+--
+-- Name: stock_commands stock_commands_item_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_commands
+    ADD CONSTRAINT stock_commands_item_id_fkey FOREIGN KEY (item_id) REFERENCES public.items(id) ON DELETE CASCADE;
+
+
+--
+-- Name: stock_commands stock_commands_variant_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.stock_commands
+    ADD CONSTRAINT stock_commands_variant_id_fkey FOREIGN KEY (variant_id) REFERENCES public.item_variants(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_sagas purchase_sagas_purchase_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_sagas
+    ADD CONSTRAINT purchase_sagas_purchase_id_fkey FOREIGN KEY (purchase_id) REFERENCES public.purchases(id) ON DELETE CASCADE;
+
+
+--
+-- Name: payments payments_purchase_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.payments
+    ADD CONSTRAINT payments_purchase_id_fkey FOREIGN KEY (purchase_id) REFERENCES public.purchases(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_status_history purchase_status_history_purchase_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_status_history
+    ADD CONSTRAINT purchase_status_history_purchase_id_fkey FOREIGN KEY (purchase_id) REFERENCES public.purchases(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_cancellations purchase_cancellations_purchase_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_cancellations
+    ADD CONSTRAINT purchase_cancellations_purchase_id_fkey FOREIGN KEY (purchase_id) REFERENCES public.purchases(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_cancellation_items purchase_cancellation_items_cancellation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_cancellation_items
+    ADD CONSTRAINT purchase_cancellation_items_cancellation_id_fkey FOREIGN KEY (cancellation_id) REFERENCES public.purchase_cancellations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_cancellation_items purchase_cancellation_items_purchase_item_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_cancellation_items
+    ADD CONSTRAINT purchase_cancellation_items_purchase_item_id_fkey FOREIGN KEY (purchase_item_id) REFERENCES public.purchase_items(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_cancellations_purchase_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_cancellations_purchase_id_idx ON public.purchase_cancellations USING btree (purchase_id);
+
+
+--
+-- Name: purchase_cancellations_unrestored_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_cancellations_unrestored_idx ON public.purchase_cancellations USING btree (created_at) WHERE (stock_restored_at IS NULL);
+
+
+--
+-- Name: purchase_returns purchase_returns_purchase_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_returns
+    ADD CONSTRAINT purchase_returns_purchase_id_fkey FOREIGN KEY (purchase_id) REFERENCES public.purchases(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_return_items purchase_return_items_return_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_return_items
+    ADD CONSTRAINT purchase_return_items_return_id_fkey FOREIGN KEY (return_id) REFERENCES public.purchase_returns(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_return_items purchase_return_items_purchase_item_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchase_return_items
+    ADD CONSTRAINT purchase_return_items_purchase_item_id_fkey FOREIGN KEY (purchase_item_id) REFERENCES public.purchase_items(id) ON DELETE CASCADE;
+
+
+--
+-- Name: refunds refunds_return_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.refunds
+    ADD CONSTRAINT refunds_return_id_fkey FOREIGN KEY (return_id) REFERENCES public.purchase_returns(id) ON DELETE CASCADE;
+
+
+--
+-- Name: refunds refunds_cancellation_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.refunds
+    ADD CONSTRAINT refunds_cancellation_id_fkey FOREIGN KEY (cancellation_id) REFERENCES public.purchase_cancellations(id) ON DELETE CASCADE;
+
+
+--
+-- Name: refunds refunds_purchase_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.refunds
+    ADD CONSTRAINT refunds_purchase_id_fkey FOREIGN KEY (purchase_id) REFERENCES public.purchases(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchase_returns_purchase_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_returns_purchase_id_idx ON public.purchase_returns USING btree (purchase_id);
+
+
+--
+-- Name: purchase_returns_status_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_returns_status_created_at_idx ON public.purchase_returns USING btree (status, created_at);
+
+
+--
+-- Name: purchase_returns_unrestored_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_returns_unrestored_idx ON public.purchase_returns USING btree (decided_at) WHERE (((status)::text = 'approved'::text) AND (stock_restored_at IS NULL));
+
+
+--
+-- Name: purchase_return_items_purchase_item_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_return_items_purchase_item_id_idx ON public.purchase_return_items USING btree (purchase_item_id);
+
+
+--
+-- Name: promotion_items promotion_items_promotion_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.promotion_items
+    ADD CONSTRAINT promotion_items_promotion_id_fkey FOREIGN KEY (promotion_id) REFERENCES public.promotions(id) ON DELETE CASCADE;
+
+
+--
+-- Name: promotion_categories promotion_categories_promotion_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.promotion_categories
+    ADD CONSTRAINT promotion_categories_promotion_id_fkey FOREIGN KEY (promotion_id) REFERENCES public.promotions(id) ON DELETE CASCADE;
+
+
+--
+-- Name: purchases purchases_promotion_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.purchases
+    ADD CONSTRAINT purchases_promotion_id_fkey FOREIGN KEY (promotion_id) REFERENCES public.promotions(id) ON DELETE SET NULL;
+
+
+--
+-- Name: purchases_promotion_id_user_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchases_promotion_id_user_id_idx ON public.purchases USING btree (promotion_id, user_id) WHERE (promotion_id IS NOT NULL);
+
+
+--
+-- Name: cart_items cart_items_cart_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.cart_items
+    ADD CONSTRAINT cart_items_cart_id_fkey FOREIGN KEY (cart_id) REFERENCES public.carts(id) ON DELETE CASCADE;
+
+
+--
+-- Name: user_addresses user_addresses_user_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.user_addresses
+    ADD CONSTRAINT user_addresses_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
+--
+-- Name: shipping_zones shipping_zones_method_code_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.shipping_zones
+    ADD CONSTRAINT shipping_zones_method_code_fkey FOREIGN KEY (method_code) REFERENCES public.shipping_methods(code) ON DELETE CASCADE;
+
+
+--
+-- Name: shipping_rates shipping_rates_method_code_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.shipping_rates
+    ADD CONSTRAINT shipping_rates_method_code_fkey FOREIGN KEY (method_code) REFERENCES public.shipping_methods(code) ON DELETE CASCADE;
+
+
+--
+-- Name: cart_items_cart_item_variant_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX cart_items_cart_item_variant_idx ON public.cart_items USING btree (cart_id, item_id, COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'::uuid));
+
+
+--
+-- Name: purchase_status_history_purchase_id_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_status_history_purchase_id_created_at_idx ON public.purchase_status_history USING btree (purchase_id, created_at);
+
+
+--
+-- Name: purchases_user_id_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchases_user_id_created_at_idx ON public.purchases USING btree (user_id, created_at DESC);
+
+
+--
+-- Name: purchase_items_purchase_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_items_purchase_id_idx ON public.purchase_items USING btree (purchase_id);
+
+
+--
+-- Name: purchase_sagas_unfinished_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_sagas_unfinished_idx ON public.purchase_sagas USING btree (updated_at) WHERE ((status)::text = ANY ((ARRAY['pending'::character varying, 'compensating'::character varying])::text[]));
+
+
+--
+-- Name: payments_status_updated_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX payments_status_updated_at_idx ON public.payments USING btree (status, updated_at);
+
+
+--
+-- Name: refunds_pending_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX refunds_pending_idx ON public.refunds USING btree (updated_at) WHERE ((status)::text = 'pending'::text);
+
+
+--
+-- Name: idempotency_keys_expires_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX idempotency_keys_expires_at_idx ON public.idempotency_keys USING btree (expires_at);
+
+
+--
+-- Name: user_addresses_user_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX user_addresses_user_id_idx ON public.user_addresses USING btree (user_id, created_at DESC);
+
+
+--
+-- Name: user_addresses_default_key; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX user_addresses_default_key ON public.user_addresses USING btree (user_id) WHERE is_default;
+
+
+--
+-- Name: shipping_zones_method_code_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE UNIQUE INDEX shipping_zones_method_code_idx ON public.shipping_zones USING btree (method_code, country_code, COALESCE(province, ''::character varying));
+
+
+--
+-- Name: shipping_rates_method_code_zone_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX shipping_rates_method_code_zone_idx ON public.shipping_rates USING btree (method_code, zone);
+
+
+--
+-- Name: purchases_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchases_created_at_idx ON public.purchases USING btree (created_at);
+
+
+--
+-- Name: purchase_status_history_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_status_history_created_at_idx ON public.purchase_status_history USING btree (created_at);
+
+
+--
+-- Name: purchase_sagas_updated_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_sagas_updated_at_idx ON public.purchase_sagas USING btree (updated_at);
+
+
+--
+-- Name: purchase_cancellations_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_cancellations_created_at_idx ON public.purchase_cancellations USING btree (created_at);
+
+
+--
+-- Name: purchase_returns_decided_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX purchase_returns_decided_at_idx ON public.purchase_returns USING btree (decided_at);
+
+
+--
+-- Name: stock_movements_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX stock_movements_created_at_idx ON public.stock_movements USING btree (created_at);
+
+
+--
+-- Data for Name: exchange_rates; Type: TABLE DATA; Schema: public; Owner: postgres
+--
+
+INSERT INTO public.exchange_rates (base_currency, quote_currency, rate) VALUES ('USD', 'IDR', 16250.0000000000);
+
+
+--
+-- Data for Name: tax_rates; Type: TABLE DATA; Schema: public; Owner: postgres
+--
+
+INSERT INTO public.tax_rates (tax_class, name, rate) VALUES ('standard', 'PPN', 0.1100);
+INSERT INTO public.tax_rates (tax_class, name, rate) VALUES ('exempt', 'Tax exempt', 0.0000);
+
+
+--
+-- Data for Name: shipping_methods; Type: TABLE DATA; Schema: public; Owner: postgres
+--
+
+INSERT INTO public.shipping_methods (code, name, currency) VALUES ('regular', 'Regular', 'IDR');
+INSERT INTO public.shipping_methods (code, name, currency) VALUES ('express', 'Express', 'IDR');
+INSERT INTO public.shipping_methods (code, name, currency) VALUES ('international', 'International', 'USD');
+
+
+--
+-- Data for Name: shipping_zones; Type: TABLE DATA; Schema: public; Owner: postgres
+--
+
+INSERT INTO public.shipping_zones (method_code, zone, country_code, province) VALUES ('regular', 'jakarta', 'ID', 'DKI Jakarta');
+INSERT INTO public.shipping_zones (method_code, zone, country_code, province) VALUES ('regular', 'domestic', 'ID', NULL);
+INSERT INTO public.shipping_zones (method_code, zone, country_code, province) VALUES ('express', 'jakarta', 'ID', 'DKI Jakarta');
+INSERT INTO public.shipping_zones (method_code, zone, country_code, province) VALUES ('express', 'domestic', 'ID', NULL);
+INSERT INTO public.shipping_zones (method_code, zone, country_code, province) VALUES ('international', 'asean', 'SG', NULL);
+INSERT INTO public.shipping_zones (method_code, zone, country_code, province) VALUES ('international', 'asean', 'MY', NULL);
+INSERT INTO public.shipping_zones (method_code, zone, country_code, province) VALUES ('international', 'world', '*', NULL);
+
+
+--
+-- Data for Name: shipping_rates; Type: TABLE DATA; Schema: public; Owner: postgres
+--
+
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('regular', 'jakarta', 1000, 9000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('regular', 'jakarta', 5000, 18000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('regular', 'jakarta', 30000, 45000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('regular', 'domestic', 1000, 15000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('regular', 'domestic', 5000, 35000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('regular', 'domestic', 30000, 90000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('express', 'jakarta', 1000, 20000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('express', 'jakarta', 5000, 40000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('express', 'domestic', 1000, 35000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('express', 'domestic', 5000, 75000.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('international', 'asean', 2000, 15.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('international', 'asean', NULL, 40.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('international', 'world', 2000, 30.00);
+INSERT INTO public.shipping_rates (method_code, zone, max_weight_grams, fee) VALUES ('international', 'world', NULL, 80.00);
 
 
 -- Completed on 2025-06-28 17:55:15
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +13,17 @@ type Config struct {
 	DBUrl     string
 	AppPort	  string
 	JWTSecret string
+
+	// ReservationTTL is how long a stock reservation holds stock when the
+	// request does not ask for a TTL.
+	ReservationTTL time.Duration
+	// ReservationReaperInterval is how often expired reservations are released.
+	ReservationReaperInterval time.Duration
+	// IdempotencyKeyTTL is how long an Idempotency-Key is remembered.
+	IdempotencyKeyTTL time.Duration
+	// AnalyticsRefreshInterval is how often the stock turnover summaries are
+	// brought up to date.
+	AnalyticsRefreshInterval time.Duration
 }
 
 var (
@@ -35,6 +47,12 @@ func LoadConfig() *Config {
 			DBUrl:     getEnv("DB_URL"),
 			AppPort:   getEnv("APP_PORT"),
 			JWTSecret: getEnv("JWT_SECRET"),
+
+			ReservationTTL:            getDurationOrDefault("RESERVATION_TTL", 10*time.Minute),
+			ReservationReaperInterval: getDurationOrDefault("RESERVATION_REAPER_INTERVAL", 30*time.Second),
+			IdempotencyKeyTTL:         getDurationOrDefault("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+
+			AnalyticsRefreshInterval: getDurationOrDefault("ANALYTICS_REFRESH_INTERVAL", 5*time.Minute),
 		}
 	})
 	return config
@@ -53,3 +71,16 @@ func getEnv(key string) string {
 	}
 	return value
 }
+
+// getDurationOrDefault reads an optional duration such as "90s" or "10m".
+func getDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Fatalf("Environment variable %s must be a positive duration, got %q", key, value)
+	}
+	return duration
+}
@@ -1,21 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"shop-crud/item-service/config"
 	"shop-crud/item-service/modules/handlers"
 	"shop-crud/item-service/modules/repositories"
 	"shop-crud/item-service/modules/usecases"
+	"shop-crud/item-service/pkg/idempotency"
 	 authmiddle"shop-crud/item-service/middleware"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
+// idempotencyPurgeInterval is how often expired Idempotency-Keys are deleted.
+const idempotencyPurgeInterval = time.Hour
+
 type CustomValidator struct {
 	validator *validator.Validate
 }
@@ -49,10 +55,49 @@ func main() {
 
 	v1 := e.Group("/api/v1")
 
+	authMiddleware := authmiddle.JWTAuthMiddleware(jwtSecret)
+
 	itemRepo := repositories.NewItemRepository(config.DBPool)
-	itemUsecase := usecases.NewItemUsecase(itemRepo)
+	categoryRepo := repositories.NewCategoryRepository(config.DBPool)
+	variantRepo := repositories.NewVariantRepository(config.DBPool)
+	stockRepo := repositories.NewStockRepository(config.DBPool)
+	itemUsecase := usecases.NewItemUsecase(itemRepo, categoryRepo, variantRepo, stockRepo)
+	categoryUsecase := usecases.NewCategoryUsecase(categoryRepo, itemRepo)
+	variantUsecase := usecases.NewVariantUsecase(variantRepo, itemRepo, stockRepo)
+	stockCommandRepo := repositories.NewStockCommandRepository(config.DBPool)
+	reservationRepo := repositories.NewReservationRepository(config.DBPool)
+	stockUsecase := usecases.NewStockUsecase(stockRepo, stockCommandRepo, reservationRepo, itemRepo, variantRepo)
+	cfg := config.GetConfig()
+	reservationUsecase := usecases.NewReservationUsecase(reservationRepo, itemRepo, variantRepo, cfg.ReservationTTL)
+	reportRepo := repositories.NewReportRepository(config.DBPool)
+	reportUsecase := usecases.NewReportUsecase(reportRepo)
+
+	idempotencyStore := idempotency.NewPostgresStore(config.DBPool)
+	idempotencyMiddleware := idempotency.Middleware(idempotency.Config{
+		Store: idempotencyStore,
+		TTL:   cfg.IdempotencyKeyTTL,
+		Scope: authmiddle.SubjectFromContext,
+	})
+
 	itemHandler := handlers.NewItemHandler(itemUsecase)
-	itemHandler.RegisterRoutes(v1, authmiddle.JWTAuthMiddleware(jwtSecret))
+	itemHandler.RegisterRoutes(v1, authMiddleware)
+	categoryHandler := handlers.NewCategoryHandler(categoryUsecase, itemUsecase)
+	categoryHandler.RegisterRoutes(v1, authMiddleware)
+	variantHandler := handlers.NewVariantHandler(variantUsecase)
+	variantHandler.RegisterRoutes(v1, authMiddleware)
+	stockHandler := handlers.NewStockHandler(stockUsecase)
+	stockHandler.RegisterRoutes(v1, authMiddleware, idempotencyMiddleware)
+	reservationHandler := handlers.NewReservationHandler(reservationUsecase)
+	reservationHandler.RegisterRoutes(v1, authMiddleware, idempotencyMiddleware)
+	reportHandler := handlers.NewReportHandler(reportUsecase)
+	reportHandler.RegisterRoutes(v1, authMiddleware)
+
+	// Give back stock held by checkouts that never completed.
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+	go usecases.RunReservationReaper(reaperCtx, reservationUsecase, cfg.ReservationReaperInterval)
+	go idempotency.RunPurge(reaperCtx, idempotencyStore, idempotencyPurgeInterval)
+	go usecases.RunReportRefresh(reaperCtx, reportUsecase, cfg.AnalyticsRefreshInterval)
 
 	addr := fmt.Sprintf(":%s", appPort)
 	log.Printf("✅ Item service berjalan di port %s", appPort)
@@ -4,6 +4,7 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -18,6 +19,37 @@ var (
 	ErrInvalidJWT = echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired JWT")
 )
 
+// Roles a user can hold, carried in the token's "role" claim.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+// RoleService is held by the short-lived tokens other services sign to call
+// this one. Users cannot be given it.
+const RoleService = "service"
+
+// Permissions checked by this service.
+const (
+	// PermWriteCatalog allows creating, changing and deleting items,
+	// variants and categories.
+	PermWriteCatalog = "catalog:write"
+	// PermManageStock allows adjusting stock and reading the stock ledger.
+	PermManageStock = "stock:manage"
+	// PermStockCommands allows taking and giving back stock with stock
+	// commands, as purchase-service does for purchases.
+	PermStockCommands = "stock:commands"
+	PermReadReports   = "reports:read"
+)
+
+// rolePermissions lists what each role may do in this service.
+var rolePermissions = map[string][]string{
+	RoleStaff:   {PermWriteCatalog, PermManageStock},
+	RoleAdmin:   {PermWriteCatalog, PermManageStock, PermStockCommands, PermReadReports},
+	RoleService: {PermStockCommands},
+}
+
 // JWTAuthMiddleware returns an Echo middleware that validates JWT tokens.
 func JWTAuthMiddleware(jwtSecret string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -71,3 +103,67 @@ func GetUserFromContext(c echo.Context) (jwt.MapClaims, bool) {
 	claims, ok := user.(jwt.MapClaims)
 	return claims, ok
 }
+
+// SubjectFromContext returns the "sub" claim of the authenticated user, or an
+// empty string when the request is not authenticated.
+func SubjectFromContext(c echo.Context) string {
+	claims, ok := GetUserFromContext(c)
+	if !ok {
+		return ""
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// RoleFromContext returns the role claim of the authenticated user. Tokens
+// without one, such as those issued before roles existed, act as a customer.
+// It returns an empty string when the request is not authenticated.
+func RoleFromContext(c echo.Context) string {
+	claims, ok := GetUserFromContext(c)
+	if !ok {
+		return ""
+	}
+	role, _ := claims["role"].(string)
+	if role == "" {
+		return RoleCustomer
+	}
+	return role
+}
+
+// HasPermission reports whether the authenticated user's role grants
+// permission in this service.
+func HasPermission(c echo.Context, permission string) bool {
+	return slices.Contains(rolePermissions[RoleFromContext(c)], permission)
+}
+
+// RequireRole returns a middleware that lets a request through only when the
+// authenticated user has one of roles. It must run after JWTAuthMiddleware.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !slices.Contains(roles, RoleFromContext(c)) {
+				return forbidden(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// forbidden refuses a request the caller's role does not allow.
+func forbidden(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+}
+
+// RequirePermission returns a middleware that lets a request through only
+// when the authenticated user's role grants permission. It must run after
+// JWTAuthMiddleware.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !HasPermission(c, permission) {
+				return forbidden(c)
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"shop-crud/item-service/middleware"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type CategoryHandler struct {
+	categoryUsecase usecases.CategoryUsecase
+	itemUsecase     usecases.ItemUsecase
+}
+
+func NewCategoryHandler(categoryUsecase usecases.CategoryUsecase, itemUsecase usecases.ItemUsecase) *CategoryHandler {
+	return &CategoryHandler{
+		categoryUsecase: categoryUsecase,
+		itemUsecase:     itemUsecase,
+	}
+}
+
+func (h *CategoryHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	categoryGroup := router.Group("/categories")
+
+	categoryGroup.GET("", h.GetCategoryTree)
+	categoryGroup.GET("/:id", h.GetCategoryByID)
+	categoryGroup.GET("/:id/items", h.GetCategoryItems)
+
+	writeCatalog := middleware.RequirePermission(middleware.PermWriteCatalog)
+	categoryGroup.POST("", h.CreateCategory, authMiddleware, writeCatalog)
+	categoryGroup.PUT("/:id", h.UpdateCategory, authMiddleware, writeCatalog)
+	categoryGroup.DELETE("/:id", h.DeleteCategory, authMiddleware, writeCatalog)
+
+	router.PUT("/items/:id/categories", h.AssignItemCategories, authMiddleware, writeCatalog)
+}
+
+func (h *CategoryHandler) CreateCategory(c echo.Context) error {
+	var req models.CreateCategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	category, err := h.categoryUsecase.CreateCategory(c.Request().Context(), req)
+	if err != nil {
+		return h.categoryError(c, err, "Failed to create category")
+	}
+	return c.JSON(http.StatusCreated, category)
+}
+
+func (h *CategoryHandler) GetCategoryTree(c echo.Context) error {
+	tree, err := h.categoryUsecase.GetCategoryTree(c.Request().Context())
+	if err != nil {
+		c.Logger().Errorf("Error getting category tree: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve categories"})
+	}
+	return c.JSON(http.StatusOK, tree)
+}
+
+func (h *CategoryHandler) GetCategoryByID(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid category ID"})
+	}
+
+	category, err := h.categoryUsecase.GetCategoryByID(c.Request().Context(), id)
+	if err != nil {
+		return h.categoryError(c, err, "Failed to retrieve category")
+	}
+	return c.JSON(http.StatusOK, category)
+}
+
+// GetCategoryItems lists the items of a category and all of its subcategories,
+// accepting the same query parameters as GET /items.
+func (h *CategoryHandler) GetCategoryItems(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid category ID"})
+	}
+
+	var query models.ItemQuery
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid query parameters"})
+	}
+	if err := c.Validate(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	query.CategoryID = &id
+	query.IncludeDescendants = true
+
+	items, err := h.itemUsecase.GetAllItems(c.Request().Context(), query)
+	if err != nil {
+		if errors.Is(err, usecases.ErrInvalidCursor) || errors.Is(err, usecases.ErrInvalidRange) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return h.categoryError(c, err, "Failed to retrieve items")
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
+func (h *CategoryHandler) UpdateCategory(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid category ID"})
+	}
+
+	var req models.UpdateCategoryRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	category, err := h.categoryUsecase.UpdateCategory(c.Request().Context(), id, req)
+	if err != nil {
+		return h.categoryError(c, err, "Failed to update category")
+	}
+	return c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) DeleteCategory(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid category ID"})
+	}
+
+	if err := h.categoryUsecase.DeleteCategory(c.Request().Context(), id); err != nil {
+		return h.categoryError(c, err, "Failed to delete category")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *CategoryHandler) AssignItemCategories(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+	}
+
+	var req models.AssignCategoriesRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	categories, err := h.categoryUsecase.AssignItemCategories(c.Request().Context(), id, req)
+	if err != nil {
+		return h.categoryError(c, err, "Failed to assign categories")
+	}
+	return c.JSON(http.StatusOK, categories)
+}
+
+// categoryError maps category usecase errors onto HTTP responses.
+func (h *CategoryHandler) categoryError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, usecases.ErrCategoryNotFound), errors.Is(err, usecases.ErrItemNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecases.ErrParentNotFound), errors.Is(err, usecases.ErrCategoryCycle):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecases.ErrCategoryHasChildren), errors.Is(err, usecases.ErrSlugExists):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	c.Logger().Errorf("%s: %v", message, err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"shop-crud/item-service/middleware"
 	"shop-crud/item-service/modules/models"
 	"shop-crud/item-service/modules/usecases"
 
@@ -22,11 +24,14 @@ func (h *ItemHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.Mid
 	itemGroup := router.Group("/items")
 
 	itemGroup.GET("", h.GetAllItems)
+	itemGroup.GET("/search", h.SearchItems)
 	itemGroup.GET("/:id", h.GetItemByID)
+	itemGroup.POST("/batch", h.GetItemsByIDs)
 
-	itemGroup.POST("", h.CreateItem, authMiddleware)
-	itemGroup.PUT("/:id", h.UpdateItem, authMiddleware)
-	itemGroup.DELETE("/:id", h.DeleteItem, authMiddleware)
+	writeCatalog := middleware.RequirePermission(middleware.PermWriteCatalog)
+	itemGroup.POST("", h.CreateItem, authMiddleware, writeCatalog)
+	itemGroup.PUT("/:id", h.UpdateItem, authMiddleware, writeCatalog)
+	itemGroup.DELETE("/:id", h.DeleteItem, authMiddleware, writeCatalog)
 }
 
 func (h *ItemHandler) CreateItem(c echo.Context) error {
@@ -38,7 +43,7 @@ func (h *ItemHandler) CreateItem(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	item, err := h.itemUsecase.CreateItem(c.Request().Context(), req)
+	item, err := h.itemUsecase.CreateItem(c.Request().Context(), req, actorID(c))
 	if err != nil {
 		c.Logger().Errorf("Error creating item: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create item"})
@@ -48,14 +53,45 @@ func (h *ItemHandler) CreateItem(c echo.Context) error {
 }
 
 func (h *ItemHandler) GetAllItems(c echo.Context) error {
-	items, err := h.itemUsecase.GetAllItems(c.Request().Context())
+	var query models.ItemQuery
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid query parameters"})
+	}
+	if err := c.Validate(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	items, err := h.itemUsecase.GetAllItems(c.Request().Context(), query)
 	if err != nil {
+		if errors.Is(err, usecases.ErrInvalidCursor) || errors.Is(err, usecases.ErrInvalidRange) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, usecases.ErrCategoryNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
 		c.Logger().Errorf("Error getting all items: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve items"})
 	}
 	return c.JSON(http.StatusOK, items)
 }
 
+func (h *ItemHandler) SearchItems(c echo.Context) error {
+	var query models.ItemSearchQuery
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid query parameters"})
+	}
+	if err := c.Validate(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	results, err := h.itemUsecase.SearchItems(c.Request().Context(), query)
+	if err != nil {
+		c.Logger().Errorf("Error searching items: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to search items"})
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
 func (h *ItemHandler) GetItemByID(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -64,7 +100,7 @@ func (h *ItemHandler) GetItemByID(c echo.Context) error {
 
 	item, err := h.itemUsecase.GetItemByID(c.Request().Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Item not found"})
 		}
 		c.Logger().Errorf("Error getting item by id: %v", err)
@@ -73,6 +109,25 @@ func (h *ItemHandler) GetItemByID(c echo.Context) error {
 	return c.JSON(http.StatusOK, item)
 }
 
+// GetItemsByIDs looks up several items at once. It is a POST so that long ID
+// lists do not run into URL length limits.
+func (h *ItemHandler) GetItemsByIDs(c echo.Context) error {
+	var req models.BatchItemsRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	items, err := h.itemUsecase.GetItemsByIDs(c.Request().Context(), req.IDs)
+	if err != nil {
+		c.Logger().Errorf("Error getting items by ids: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve items"})
+	}
+	return c.JSON(http.StatusOK, items)
+}
+
 func (h *ItemHandler) UpdateItem(c echo.Context) error {
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -87,9 +142,9 @@ func (h *ItemHandler) UpdateItem(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	item, err := h.itemUsecase.UpdateItem(c.Request().Context(), id, req)
+	item, err := h.itemUsecase.UpdateItem(c.Request().Context(), id, req, actorID(c))
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Item not found"})
 		}
 		c.Logger().Errorf("Error updating item: %v", err)
@@ -106,7 +161,7 @@ func (h *ItemHandler) DeleteItem(c echo.Context) error {
 
 	err = h.itemUsecase.DeleteItem(c.Request().Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "Item not found"})
 		}
 		c.Logger().Errorf("Error deleting item: %v", err)
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"shop-crud/item-service/middleware"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/usecases"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ReportHandler struct {
+	reportUsecase usecases.ReportUsecase
+}
+
+func NewReportHandler(reportUsecase usecases.ReportUsecase) *ReportHandler {
+	return &ReportHandler{reportUsecase: reportUsecase}
+}
+
+// RegisterRoutes exposes stock reports to admins.
+func (h *ReportHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	reportGroup := router.Group("/reports", authMiddleware, middleware.RequirePermission(middleware.PermReadReports))
+	reportGroup.GET("/stock-turnover", h.GetStockTurnover)
+	reportGroup.POST("/stock-turnover/refresh", h.RefreshStockTurnover)
+}
+
+// GetStockTurnover writes the report as JSON, or as a CSV download when
+// format=csv.
+func (h *ReportHandler) GetStockTurnover(c echo.Context) error {
+	var query models.StockTurnoverQuery
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid query parameters"})
+	}
+	if err := c.Validate(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	report, err := h.reportUsecase.StockTurnover(c.Request().Context(), query)
+	if err != nil {
+		if errors.Is(err, usecases.ErrInvalidRange) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error getting stock turnover report: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get report"})
+	}
+	if query.Format != "csv" {
+		return c.JSON(http.StatusOK, report)
+	}
+
+	var body bytes.Buffer
+	if err := csv.NewWriter(&body).WriteAll(report.CSV()); err != nil {
+		c.Logger().Errorf("Error writing stock turnover report: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get report"})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="stock-turnover.csv"`)
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", body.Bytes())
+}
+
+func (h *ReportHandler) RefreshStockTurnover(c echo.Context) error {
+	refreshed, err := h.reportUsecase.Refresh(c.Request().Context())
+	if err != nil {
+		c.Logger().Errorf("Error refreshing stock turnover report: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh report"})
+	}
+	return c.JSON(http.StatusOK, refreshed)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"shop-crud/item-service/middleware"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type ReservationHandler struct {
+	reservationUsecase usecases.ReservationUsecase
+}
+
+func NewReservationHandler(reservationUsecase usecases.ReservationUsecase) *ReservationHandler {
+	return &ReservationHandler{reservationUsecase: reservationUsecase}
+}
+
+func (h *ReservationHandler) RegisterRoutes(router *echo.Group, authMiddleware, idempotencyMiddleware echo.MiddlewareFunc) {
+	// Reservations take stock like stock commands do, so they are for the
+	// same callers.
+	reservationGroup := router.Group("/reservations", authMiddleware, middleware.RequirePermission(middleware.PermStockCommands))
+
+	reservationGroup.POST("", h.CreateReservation, idempotencyMiddleware)
+	reservationGroup.GET("/:id", h.GetReservation)
+	reservationGroup.POST("/:id/confirm", h.ConfirmReservation)
+	reservationGroup.POST("/:id/release", h.ReleaseReservation)
+}
+
+func (h *ReservationHandler) CreateReservation(c echo.Context) error {
+	var req models.CreateReservationRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	reservation, err := h.reservationUsecase.Reserve(c.Request().Context(), req, actorID(c))
+	if err != nil {
+		return h.reservationError(c, err, "Failed to reserve stock")
+	}
+	return c.JSON(http.StatusCreated, reservation)
+}
+
+func (h *ReservationHandler) GetReservation(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid reservation ID"})
+	}
+
+	reservation, err := h.reservationUsecase.GetReservation(c.Request().Context(), id)
+	if err != nil {
+		return h.reservationError(c, err, "Failed to retrieve reservation")
+	}
+	return c.JSON(http.StatusOK, reservation)
+}
+
+func (h *ReservationHandler) ConfirmReservation(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid reservation ID"})
+	}
+
+	// The body is optional; an empty one confirms without a reference.
+	var req models.ConfirmReservationRequest
+	if c.Request().ContentLength != 0 {
+		if err := c.Bind(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
+		if err := c.Validate(&req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	}
+
+	reservation, err := h.reservationUsecase.ConfirmReservation(c.Request().Context(), id, req)
+	if err != nil {
+		return h.reservationError(c, err, "Failed to confirm reservation")
+	}
+	return c.JSON(http.StatusOK, reservation)
+}
+
+func (h *ReservationHandler) ReleaseReservation(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid reservation ID"})
+	}
+
+	reservation, err := h.reservationUsecase.ReleaseReservation(c.Request().Context(), id)
+	if err != nil {
+		return h.reservationError(c, err, "Failed to release reservation")
+	}
+	return c.JSON(http.StatusOK, reservation)
+}
+
+// reservationError maps reservation usecase errors onto HTTP responses.
+func (h *ReservationHandler) reservationError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, usecases.ErrReservationNotFound), errors.Is(err, usecases.ErrItemNotFound), errors.Is(err, usecases.ErrVariantNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecases.ErrInsufficientStock), errors.Is(err, usecases.ErrReservationNotActive):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecases.ErrReservationExpired):
+		return c.JSON(http.StatusGone, map[string]string{"error": err.Error()})
+	}
+	c.Logger().Errorf("%s: %v", message, err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
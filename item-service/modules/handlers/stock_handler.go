@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"shop-crud/item-service/middleware"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type StockHandler struct {
+	stockUsecase usecases.StockUsecase
+}
+
+func NewStockHandler(stockUsecase usecases.StockUsecase) *StockHandler {
+	return &StockHandler{stockUsecase: stockUsecase}
+}
+
+func (h *StockHandler) RegisterRoutes(router *echo.Group, authMiddleware, idempotencyMiddleware echo.MiddlewareFunc) {
+	stockGroup := router.Group("/items/:id")
+
+	manageStock := middleware.RequirePermission(middleware.PermManageStock)
+	stockGroup.POST("/stock-adjustments", h.AdjustStock, authMiddleware, manageStock, idempotencyMiddleware)
+	stockGroup.GET("/stock-movements", h.GetStockMovements, authMiddleware, manageStock)
+	stockGroup.GET("/stock-reconciliation", h.ReconcileStock, authMiddleware, manageStock)
+
+	commandGroup := router.Group("/stock-commands", authMiddleware, middleware.RequirePermission(middleware.PermStockCommands))
+	commandGroup.POST("/decrement", h.DecrementStock)
+	commandGroup.POST("/increment", h.IncrementStock)
+	commandGroup.POST("/:key/restore", h.RestoreStock)
+}
+
+func (h *StockHandler) AdjustStock(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+	}
+
+	var req models.StockAdjustmentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	movement, err := h.stockUsecase.AdjustStock(c.Request().Context(), itemID, req, actorID(c))
+	if err != nil {
+		return h.stockError(c, err, "Failed to adjust stock")
+	}
+	return c.JSON(http.StatusCreated, movement)
+}
+
+func (h *StockHandler) GetStockMovements(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+	}
+
+	var query models.StockMovementQuery
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid query parameters"})
+	}
+	if err := c.Validate(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	movements, err := h.stockUsecase.GetStockMovements(c.Request().Context(), itemID, query)
+	if err != nil {
+		return h.stockError(c, err, "Failed to retrieve stock movements")
+	}
+	return c.JSON(http.StatusOK, movements)
+}
+
+func (h *StockHandler) ReconcileStock(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+	}
+
+	reconciliation, err := h.stockUsecase.ReconcileStock(c.Request().Context(), itemID)
+	if err != nil {
+		return h.stockError(c, err, "Failed to reconcile stock")
+	}
+	return c.JSON(http.StatusOK, reconciliation)
+}
+
+// DecrementStock is the command other services use to take stock. It answers
+// 201 when stock was taken and 200 when the key was already applied.
+func (h *StockHandler) DecrementStock(c echo.Context) error {
+	var req models.DecrementStockRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	command, created, err := h.stockUsecase.DecrementStock(c.Request().Context(), req, actorID(c))
+	if err != nil {
+		return h.stockError(c, err, "Failed to decrement stock")
+	}
+	if created {
+		return c.JSON(http.StatusCreated, command)
+	}
+	return c.JSON(http.StatusOK, command)
+}
+
+func (h *StockHandler) RestoreStock(c echo.Context) error {
+	key := c.Param("key")
+	if key == "" || len(key) > 128 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid command key"})
+	}
+
+	command, err := h.stockUsecase.RestoreStock(c.Request().Context(), key, actorID(c))
+	if err != nil {
+		return h.stockError(c, err, "Failed to restore stock")
+	}
+	return c.JSON(http.StatusOK, command)
+}
+
+// IncrementStock is the command other services use to give stock back. It
+// answers 201 when stock was added and 200 when the key was already applied.
+func (h *StockHandler) IncrementStock(c echo.Context) error {
+	var req models.IncrementStockRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	response, created, err := h.stockUsecase.IncrementStock(c.Request().Context(), req, actorID(c))
+	if err != nil {
+		return h.stockError(c, err, "Failed to increment stock")
+	}
+	if created {
+		return c.JSON(http.StatusCreated, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// stockError maps stock usecase errors onto HTTP responses.
+func (h *StockHandler) stockError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, usecases.ErrItemNotFound), errors.Is(err, usecases.ErrVariantNotFound), errors.Is(err, usecases.ErrReservationNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecases.ErrInsufficientStock), errors.Is(err, usecases.ErrStockCommandMismatch):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecases.ErrStockCommandCancelled), errors.Is(err, usecases.ErrReservationExpired), errors.Is(err, usecases.ErrReservationNotActive):
+		return c.JSON(http.StatusGone, map[string]string{"error": err.Error()})
+	}
+	c.Logger().Errorf("%s: %v", message, err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
+
+// actorID returns the subject of the caller's JWT, recorded as the actor of
+// the changes it makes.
+func actorID(c echo.Context) string {
+	claims, ok := middleware.GetUserFromContext(c)
+	if !ok {
+		return ""
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"shop-crud/item-service/middleware"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type VariantHandler struct {
+	variantUsecase usecases.VariantUsecase
+}
+
+func NewVariantHandler(variantUsecase usecases.VariantUsecase) *VariantHandler {
+	return &VariantHandler{variantUsecase: variantUsecase}
+}
+
+func (h *VariantHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	variantGroup := router.Group("/items/:id/variants")
+
+	variantGroup.GET("", h.GetVariants)
+
+	writeCatalog := middleware.RequirePermission(middleware.PermWriteCatalog)
+	variantGroup.POST("", h.CreateVariant, authMiddleware, writeCatalog)
+	variantGroup.PUT("/:variant_id", h.UpdateVariant, authMiddleware, writeCatalog)
+	variantGroup.DELETE("/:variant_id", h.DeleteVariant, authMiddleware, writeCatalog)
+}
+
+func (h *VariantHandler) CreateVariant(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+	}
+
+	var req models.CreateVariantRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	variant, err := h.variantUsecase.CreateVariant(c.Request().Context(), itemID, req, actorID(c))
+	if err != nil {
+		return h.variantError(c, err, "Failed to create variant")
+	}
+	return c.JSON(http.StatusCreated, variant)
+}
+
+func (h *VariantHandler) GetVariants(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+	}
+
+	variants, err := h.variantUsecase.GetVariants(c.Request().Context(), itemID)
+	if err != nil {
+		return h.variantError(c, err, "Failed to retrieve variants")
+	}
+	return c.JSON(http.StatusOK, variants)
+}
+
+func (h *VariantHandler) UpdateVariant(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+	}
+	variantID, err := uuid.Parse(c.Param("variant_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid variant ID"})
+	}
+
+	var req models.UpdateVariantRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	variant, err := h.variantUsecase.UpdateVariant(c.Request().Context(), itemID, variantID, req, actorID(c))
+	if err != nil {
+		return h.variantError(c, err, "Failed to update variant")
+	}
+	return c.JSON(http.StatusOK, variant)
+}
+
+func (h *VariantHandler) DeleteVariant(c echo.Context) error {
+	itemID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid item ID"})
+	}
+	variantID, err := uuid.Parse(c.Param("variant_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid variant ID"})
+	}
+
+	if err := h.variantUsecase.DeleteVariant(c.Request().Context(), itemID, variantID); err != nil {
+		return h.variantError(c, err, "Failed to delete variant")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// variantError maps variant usecase errors onto HTTP responses.
+func (h *VariantHandler) variantError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, usecases.ErrItemNotFound), errors.Is(err, usecases.ErrVariantNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecases.ErrSKUExists), errors.Is(err, usecases.ErrVariantInUse):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	c.Logger().Errorf("%s: %v", message, err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
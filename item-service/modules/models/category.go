@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Category is a node in the product taxonomy. Root categories have no parent.
+type Category struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	ParentID    *uuid.UUID `db:"parent_id" json:"parent_id"`
+	Name        string     `db:"name" json:"name"`
+	Slug        string     `db:"slug" json:"slug"`
+	Description string     `db:"description" json:"description"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+	Children    []Category `json:"children,omitempty"`
+}
+
+// CategoryRef is the short form of a category used in breadcrumbs.
+type CategoryRef struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
+}
+
+// ItemCategory is a category assigned to an item, with its path from the root.
+type ItemCategory struct {
+	CategoryRef
+	Breadcrumbs []CategoryRef `json:"breadcrumbs"`
+}
+
+// CategoryDetailResponse is returned by GET /categories/:id.
+type CategoryDetailResponse struct {
+	Category
+	Breadcrumbs []CategoryRef `json:"breadcrumbs"`
+}
+
+type CreateCategoryRequest struct {
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Name        string     `json:"name" validate:"required,min=2,max=255"`
+	Slug        string     `json:"slug" validate:"omitempty,max=255"`
+	Description string     `json:"description"`
+}
+
+type UpdateCategoryRequest struct {
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Name        string     `json:"name" validate:"required,min=2,max=255"`
+	Slug        string     `json:"slug" validate:"omitempty,max=255"`
+	Description string     `json:"description"`
+}
+
+// AssignCategoriesRequest replaces the full set of categories of an item.
+// An empty list removes the item from every category.
+type AssignCategoriesRequest struct {
+	CategoryIDs []uuid.UUID `json:"category_ids" validate:"max=50"`
+}
@@ -1,31 +1,139 @@
 package models
 
 import (
+	"shop-crud/item-service/pkg/money"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Item struct {
-	ID          uuid.UUID `db:"id" json:"id"`
-	Name        string    `db:"name" json:"name"`
-	Description string    `db:"description" json:"description"`
-	Price       float64   `db:"price" json:"price"`
-	Stock       int       `db:"stock" json:"stock"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	ID          uuid.UUID    `db:"id" json:"id"`
+	Name        string       `db:"name" json:"name"`
+	Description string       `db:"description" json:"description"`
+	Price       money.Amount `db:"price" json:"price"`
+	Currency    string       `db:"currency" json:"currency"`
+	Stock       int          `db:"stock" json:"stock"`
+	CreatedAt   time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time    `db:"updated_at" json:"updated_at"`
+	// TaxClass picks the tax rate purchase-service charges on the item.
+	TaxClass string `db:"tax_class" json:"tax_class"`
+	// WeightGrams is the shipping weight of one unit.
+	WeightGrams int `db:"weight_grams" json:"weight_grams"`
+
+	// Categories are only filled in on single item lookups, Variants on
+	// single and batch lookups, and CategoryIDs on batch lookups. CategoryIDs
+	// holds the item's categories and all of their ancestors, so callers can
+	// tell whether an item falls anywhere under a category.
+	Categories  []ItemCategory `json:"categories,omitempty"`
+	Variants    []ItemVariant  `json:"variants,omitempty"`
+	CategoryIDs []uuid.UUID    `json:"category_ids,omitempty"`
 }
 
 type CreateItemRequest struct {
-	Name        string  `json:"name" validate:"required,min=3"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" validate:"required,gte=0"`
-	Stock       int     `json:"stock" validate:"required,gte=0"`
+	Name        string       `json:"name" validate:"required,min=3"`
+	Description string       `json:"description"`
+	Price       money.Amount `json:"price" validate:"required,gte=0"`
+	Currency    string       `json:"currency" validate:"omitempty,iso4217"`
+	Stock       int          `json:"stock" validate:"required,gte=0"`
+	// TaxClass defaults to "standard".
+	TaxClass string `json:"tax_class" validate:"omitempty,max=32"`
+	// WeightGrams is the shipping weight of one unit.
+	WeightGrams int `json:"weight_grams" validate:"gte=0"`
+}
+
+// BatchItemsRequest looks up several items at once.
+type BatchItemsRequest struct {
+	IDs []uuid.UUID `json:"ids" validate:"required,min=1,max=100"`
+}
+
+// BatchItemsResponse holds the items found, in request order, and the IDs of
+// those that do not exist.
+type BatchItemsResponse struct {
+	Data    []Item      `json:"data"`
+	Missing []uuid.UUID `json:"missing"`
 }
 
 type UpdateItemRequest struct {
-	Name        string  `json:"name" validate:"required,min=3"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" validate:"required,gte=0"`
-	Stock       int     `json:"stock" validate:"required,gte=0"`
+	Name        string       `json:"name" validate:"required,min=3"`
+	Description string       `json:"description"`
+	Price       money.Amount `json:"price" validate:"required,gte=0"`
+	Currency    string       `json:"currency" validate:"omitempty,iso4217"`
+	Stock       int          `json:"stock" validate:"required,gte=0"`
+	// TaxClass defaults to "standard".
+	TaxClass string `json:"tax_class" validate:"omitempty,max=32"`
+	// WeightGrams is the shipping weight of one unit.
+	WeightGrams int `json:"weight_grams" validate:"gte=0"`
+}
+
+// ItemQuery describes the filters, sorting and paging applied when listing items.
+// A non-empty Cursor takes precedence over Offset.
+type ItemQuery struct {
+	Limit       int           `query:"limit" validate:"omitempty,min=1,max=100"`
+	Offset      int           `query:"offset" validate:"omitempty,min=0"`
+	Cursor      string        `query:"cursor"`
+	Name        string        `query:"name" validate:"omitempty,max=255"`
+	MinPrice    *money.Amount `query:"min_price" validate:"omitempty,gte=0"`
+	MaxPrice    *money.Amount `query:"max_price" validate:"omitempty,gte=0"`
+	InStock     bool          `query:"in_stock"`
+	CreatedFrom *time.Time    `query:"created_from"`
+	CreatedTo   *time.Time    `query:"created_to"`
+	UpdatedFrom *time.Time    `query:"updated_from"`
+	UpdatedTo   *time.Time    `query:"updated_to"`
+	SortBy      string        `query:"sort_by" validate:"omitempty,oneof=name price stock created_at updated_at"`
+	SortOrder   string        `query:"sort_order" validate:"omitempty,oneof=asc desc"`
+	CategoryID  *uuid.UUID    `query:"category_id"`
+	// IncludeDescendants widens the category filter to every subcategory.
+	IncludeDescendants bool `query:"include_descendants"`
+
+	// After is the decoded Cursor, filled in by the usecase.
+	After *ItemCursor `query:"-"`
+	// CategoryIDs is the resolved category filter, filled in by the usecase.
+	CategoryIDs []uuid.UUID `query:"-"`
+}
+
+// ItemCursor marks the last row of a page for keyset pagination. It is only
+// valid for the sort it was issued for.
+type ItemCursor struct {
+	SortBy    string    `json:"s"`
+	SortOrder string    `json:"o"`
+	Value     string    `json:"v"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// ItemListResponse is the paging envelope returned by GET /items.
+type ItemListResponse struct {
+	Data       []Item `json:"data"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ItemSearchQuery holds the parameters of GET /items/search.
+type ItemSearchQuery struct {
+	Q      string `query:"q" validate:"required,min=2,max=100"`
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=50"`
+	Offset int    `query:"offset" validate:"omitempty,min=0"`
+}
+
+// ItemSearchResult is an item matched by a search, with its relevance and
+// highlighted snippets. Matched terms are wrapped in <mark></mark>.
+type ItemSearchResult struct {
+	Item
+	Rank       float64        `json:"rank"`
+	Highlights ItemHighlights `json:"highlights"`
+}
+
+type ItemHighlights struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ItemSearchResponse is the envelope returned by GET /items/search.
+type ItemSearchResponse struct {
+	Query  string             `json:"query"`
+	Data   []ItemSearchResult `json:"data"`
+	Limit  int                `json:"limit"`
+	Offset int                `json:"offset"`
 }
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockTurnoverQuery selects the days From to To, inclusive, in TimeZone.
+type StockTurnoverQuery struct {
+	From     string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To       string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	TimeZone string `query:"tz" validate:"omitempty,ne=Local,timezone"`
+	Limit    int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Format   string `query:"format" validate:"omitempty,oneof=json csv"`
+}
+
+// StockTurnover is how often an item's stock sold through over a range. An
+// item's stock includes its variants'. UnitsSold counts units taken by
+// purchases less those given back; manual adjustments are left out.
+// Turnover is UnitsSold over the average of the opening and closing stock,
+// and null when that average is zero.
+type StockTurnover struct {
+	ItemID       uuid.UUID `json:"item_id"`
+	Name         string    `json:"name"`
+	UnitsSold    int       `json:"units_sold"`
+	OpeningStock int       `json:"opening_stock"`
+	ClosingStock int       `json:"closing_stock"`
+	AverageStock float64   `json:"average_stock"`
+	Turnover     *float64  `json:"turnover"`
+}
+
+// StockTurnoverReport lists the items with the highest turnover first. Stock
+// moved after RefreshedAt is not in it yet.
+type StockTurnoverReport struct {
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	TimeZone    string          `json:"tz"`
+	RefreshedAt *time.Time      `json:"refreshed_at"`
+	Items       []StockTurnover `json:"items"`
+}
+
+// StockReportRefreshResponse reports how many 15-minute buckets a refresh
+// rebuilt.
+type StockReportRefreshResponse struct {
+	Buckets     int       `json:"buckets"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+// CSV returns the report as rows, a header first.
+func (r *StockTurnoverReport) CSV() [][]string {
+	rows := [][]string{{"item_id", "name", "units_sold", "opening_stock", "closing_stock", "average_stock", "turnover"}}
+	for _, item := range r.Items {
+		turnover := ""
+		if item.Turnover != nil {
+			turnover = strconv.FormatFloat(*item.Turnover, 'f', 4, 64)
+		}
+		rows = append(rows, []string{item.ItemID.String(), item.Name, strconv.Itoa(item.UnitsSold),
+			strconv.Itoa(item.OpeningStock), strconv.Itoa(item.ClosingStock),
+			strconv.FormatFloat(item.AverageStock, 'f', 1, 64), turnover})
+	}
+	return rows
+}
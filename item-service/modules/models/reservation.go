@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reservation statuses. Only active reservations hold stock; a reservation
+// leaves the active state exactly once.
+const (
+	ReservationActive    = "active"
+	ReservationConfirmed = "confirmed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation holds stock for a checkout until it is confirmed, released, or
+// expires. The held units are taken out of stock when the reservation is made
+// and put back when it is released or expires, less any units stock commands
+// have drawn from it.
+type Reservation struct {
+	ID            uuid.UUID         `db:"id" json:"id"`
+	Status        string            `db:"status" json:"status"`
+	ReferenceType string            `db:"reference_type" json:"reference_type,omitempty"`
+	ReferenceID   *uuid.UUID        `db:"reference_id" json:"reference_id,omitempty"`
+	ActorID       string            `db:"actor_id" json:"actor_id,omitempty"`
+	ExpiresAt     time.Time         `db:"expires_at" json:"expires_at"`
+	ConfirmedAt   *time.Time        `db:"confirmed_at" json:"confirmed_at,omitempty"`
+	ReleasedAt    *time.Time        `db:"released_at" json:"released_at,omitempty"`
+	CreatedAt     time.Time         `db:"created_at" json:"created_at"`
+	Items         []ReservationItem `json:"items"`
+}
+
+// ReservationItem is one held line. DrawnQuantity counts the units decrement
+// commands have taken from the hold; only the rest is given back on release.
+type ReservationItem struct {
+	ItemID        uuid.UUID  `db:"item_id" json:"item_id"`
+	VariantID     *uuid.UUID `db:"variant_id" json:"variant_id,omitempty"`
+	Quantity      int        `db:"quantity" json:"quantity"`
+	DrawnQuantity int        `db:"drawn_quantity" json:"drawn_quantity"`
+}
+
+type CreateReservationRequest struct {
+	Items      []ReservationItemRequest `json:"items" validate:"required,min=1,max=50,dive"`
+	TTLSeconds int                      `json:"ttl_seconds" validate:"omitempty,min=30,max=3600"`
+}
+
+type ReservationItemRequest struct {
+	ItemID    uuid.UUID  `json:"item_id" validate:"required"`
+	VariantID *uuid.UUID `json:"variant_id"`
+	Quantity  int        `json:"quantity" validate:"required,min=1"`
+}
+
+// ConfirmReservationRequest names what the reserved stock was used for,
+// e.g. reference_type "purchase" and the purchase ID.
+type ConfirmReservationRequest struct {
+	ReferenceType string     `json:"reference_type" validate:"omitempty,max=32"`
+	ReferenceID   *uuid.UUID `json:"reference_id"`
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons recorded on stock movements.
+const (
+	StockReasonInitial    = "initial_stock"
+	StockReasonRestock    = "restock"
+	StockReasonAdjustment = "manual_adjustment"
+	StockReasonCorrection = "correction"
+	StockReasonDamage     = "damage"
+	StockReasonLoss       = "loss"
+	StockReasonReserved   = "reserved"
+	StockReasonUnreserved = "reservation_released"
+	StockReasonDecrement  = "decrement"
+	StockReasonRestore    = "restore"
+	StockReasonIncrement  = "increment"
+)
+
+// Reference types linking a stock movement to what caused it.
+const (
+	StockReferenceManual      = "manual"
+	StockReferenceReservation = "reservation"
+)
+
+// StockMovement is one entry of the append-only stock ledger. The stock of an
+// item (or variant) is the sum of QuantityDelta over its movements.
+type StockMovement struct {
+	ID            uuid.UUID  `db:"id" json:"id"`
+	ItemID        uuid.UUID  `db:"item_id" json:"item_id"`
+	VariantID     *uuid.UUID `db:"variant_id" json:"variant_id,omitempty"`
+	QuantityDelta int        `db:"quantity_delta" json:"quantity_delta"`
+	BalanceAfter  int        `db:"balance_after" json:"balance_after"`
+	Reason        string     `db:"reason" json:"reason"`
+	ReferenceType string     `db:"reference_type" json:"reference_type,omitempty"`
+	ReferenceID   *uuid.UUID `db:"reference_id" json:"reference_id,omitempty"`
+	ActorID       string     `db:"actor_id" json:"actor_id,omitempty"`
+	Note          string     `db:"note" json:"note,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+}
+
+// StockAdjustmentRequest is a manual change of stock, e.g. after a stock take.
+type StockAdjustmentRequest struct {
+	VariantID     *uuid.UUID `json:"variant_id"`
+	QuantityDelta int        `json:"quantity_delta" validate:"required,ne=0"`
+	Reason        string     `json:"reason" validate:"omitempty,oneof=manual_adjustment restock correction damage loss"`
+	Note          string     `json:"note" validate:"max=500"`
+}
+
+type StockMovementQuery struct {
+	VariantID *uuid.UUID `query:"variant_id"`
+	Limit     int        `query:"limit" validate:"omitempty,min=1,max=100"`
+	Offset    int        `query:"offset" validate:"omitempty,min=0"`
+}
+
+type StockMovementListResponse struct {
+	Data   []StockMovement `json:"data"`
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
+
+// StockBalance compares the stored stock of an item or variant with the
+// balance derived from its ledger.
+type StockBalance struct {
+	VariantID     *uuid.UUID `json:"variant_id,omitempty"`
+	Stock         int        `json:"stock"`
+	LedgerBalance int        `json:"ledger_balance"`
+	Difference    int        `json:"difference"`
+	Consistent    bool       `json:"consistent"`
+}
+
+type StockReconciliationResponse struct {
+	ItemID     uuid.UUID      `json:"item_id"`
+	Item       StockBalance   `json:"item"`
+	Variants   []StockBalance `json:"variants"`
+	Consistent bool           `json:"consistent"`
+}
+
+// Stock command statuses. A restore for a key that was never decremented
+// records the key as cancelled so that a late decrement cannot apply.
+const (
+	StockCommandApplied   = "applied"
+	StockCommandRestored  = "restored"
+	StockCommandCancelled = "cancelled"
+)
+
+// Stock command kinds. Only decrements can be restored.
+const (
+	StockCommandDecrement = "decrement"
+	StockCommandIncrement = "increment"
+)
+
+// StockCommand is an idempotent stock change issued by another service,
+// identified by a key chosen by the caller.
+type StockCommand struct {
+	Key           string     `db:"key" json:"key"`
+	Kind          string     `db:"kind" json:"kind"`
+	ItemID        *uuid.UUID `db:"item_id" json:"item_id,omitempty"`
+	VariantID     *uuid.UUID `db:"variant_id" json:"variant_id,omitempty"`
+	Quantity      int        `db:"quantity" json:"quantity"`
+	Status        string     `db:"status" json:"status"`
+	ReferenceType string     `db:"reference_type" json:"reference_type,omitempty"`
+	ReferenceID   *uuid.UUID `db:"reference_id" json:"reference_id,omitempty"`
+	ReservationID *uuid.UUID `db:"reservation_id" json:"reservation_id,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// DecrementStockRequest takes stock for a command. With a ReservationID the
+// units are drawn from that reservation's hold, which must still be active,
+// instead of from the stock that is available to everyone.
+type DecrementStockRequest struct {
+	Key           string     `json:"key" validate:"required,max=128"`
+	ItemID        uuid.UUID  `json:"item_id" validate:"required"`
+	VariantID     *uuid.UUID `json:"variant_id"`
+	Quantity      int        `json:"quantity" validate:"required,min=1"`
+	ReferenceType string     `json:"reference_type" validate:"omitempty,max=32"`
+	ReferenceID   *uuid.UUID `json:"reference_id"`
+	ReservationID *uuid.UUID `json:"reservation_id"`
+}
+
+type StockCommandLine struct {
+	ItemID    uuid.UUID  `json:"item_id" validate:"required"`
+	VariantID *uuid.UUID `json:"variant_id"`
+	Quantity  int        `json:"quantity" validate:"required,min=1"`
+}
+
+// IncrementStockRequest gives stock back for several lines at once, e.g. when
+// an order is cancelled. Line i is stored as the command "<key>/<i>".
+type IncrementStockRequest struct {
+	Key           string             `json:"key" validate:"required,max=120"`
+	Lines         []StockCommandLine `json:"lines" validate:"required,min=1,max=50,dive"`
+	ReferenceType string             `json:"reference_type" validate:"omitempty,max=32"`
+	ReferenceID   *uuid.UUID         `json:"reference_id"`
+}
+
+type IncrementStockResponse struct {
+	Key      string         `json:"key"`
+	Commands []StockCommand `json:"commands"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"shop-crud/item-service/pkg/money"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ItemVariant is a sellable version of an item, such as one size and colour of
+// a T-shirt. A nil Price means the variant is sold at the item's price.
+type ItemVariant struct {
+	ID        uuid.UUID         `db:"id" json:"id"`
+	ItemID    uuid.UUID         `db:"item_id" json:"item_id"`
+	SKU       string            `db:"sku" json:"sku"`
+	Options   map[string]string `db:"options" json:"options"`
+	Price     *money.Amount     `db:"price" json:"price"`
+	Stock     int               `db:"stock" json:"stock"`
+	CreatedAt time.Time         `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time         `db:"updated_at" json:"updated_at"`
+}
+
+type CreateVariantRequest struct {
+	SKU     string            `json:"sku" validate:"required,max=64"`
+	Options map[string]string `json:"options" validate:"required,min=1,dive,keys,required,max=50,endkeys,required,max=100"`
+	Price   *money.Amount     `json:"price" validate:"omitempty,gte=0"`
+	Stock   int               `json:"stock" validate:"gte=0"`
+}
+
+type UpdateVariantRequest struct {
+	SKU     string            `json:"sku" validate:"required,max=64"`
+	Options map[string]string `json:"options" validate:"required,min=1,dive,keys,required,max=50,endkeys,required,max=100"`
+	Price   *money.Amount     `json:"price" validate:"omitempty,gte=0"`
+	Stock   int               `json:"stock" validate:"gte=0"`
+}
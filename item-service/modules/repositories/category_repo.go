@@ -0,0 +1,251 @@
+package repositories
+
+import (
+	"context"
+	"shop-crud/item-service/modules/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CategoryRepository interface {
+	Create(ctx context.Context, category *models.Category) error
+	FindAll(ctx context.Context) ([]models.Category, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.Category, error)
+	FindPath(ctx context.Context, id uuid.UUID) ([]models.CategoryRef, error)
+	FindDescendantIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+	FindByItemID(ctx context.Context, itemID uuid.UUID) ([]models.Category, error)
+	FindIDsByItemIDs(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error)
+	CountChildren(ctx context.Context, id uuid.UUID) (int, error)
+	CountExisting(ctx context.Context, ids []uuid.UUID) (int, error)
+	Update(ctx context.Context, category *models.Category) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ReplaceItemCategories(ctx context.Context, itemID uuid.UUID, categoryIDs []uuid.UUID) error
+}
+
+type categoryRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewCategoryRepository(db *pgxpool.Pool) CategoryRepository {
+	return &categoryRepository{db: db}
+}
+
+func (r *categoryRepository) Create(ctx context.Context, category *models.Category) error {
+	query := `INSERT INTO categories (id, parent_id, name, slug, description, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.Exec(ctx, query, category.ID, category.ParentID, category.Name, category.Slug, category.Description, category.CreatedAt, category.UpdatedAt)
+	return err
+}
+
+func (r *categoryRepository) FindAll(ctx context.Context) ([]models.Category, error) {
+	query := `SELECT id, parent_id, name, slug, COALESCE(description, ''), created_at, updated_at FROM categories ORDER BY name`
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return scanCategories(rows)
+}
+
+func (r *categoryRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Category, error) {
+	var category models.Category
+	query := `SELECT id, parent_id, name, slug, COALESCE(description, ''), created_at, updated_at FROM categories WHERE id = $1`
+
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&category.ID,
+		&category.ParentID,
+		&category.Name,
+		&category.Slug,
+		&category.Description,
+		&category.CreatedAt,
+		&category.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// FindPath returns the ancestors of a category ordered from the root down to
+// and including the category itself.
+func (r *categoryRepository) FindPath(ctx context.Context, id uuid.UUID) ([]models.CategoryRef, error) {
+	query := `WITH RECURSIVE path AS (
+			SELECT id, parent_id, name, slug, 0 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, c.name, c.slug, p.depth + 1
+			FROM categories c JOIN path p ON c.id = p.parent_id
+		)
+		SELECT id, name, slug FROM path ORDER BY depth DESC`
+
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	path := []models.CategoryRef{}
+	for rows.Next() {
+		var ref models.CategoryRef
+		if err := rows.Scan(&ref.ID, &ref.Name, &ref.Slug); err != nil {
+			return nil, err
+		}
+		path = append(path, ref)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return path, nil
+}
+
+// FindDescendantIDs returns the category itself and every category below it.
+func (r *categoryRepository) FindDescendantIDs(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	query := `WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT id FROM tree`
+
+	rows, err := r.db.Query(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var descendantID uuid.UUID
+		if err := rows.Scan(&descendantID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, descendantID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (r *categoryRepository) FindByItemID(ctx context.Context, itemID uuid.UUID) ([]models.Category, error) {
+	query := `SELECT c.id, c.parent_id, c.name, c.slug, COALESCE(c.description, ''), c.created_at, c.updated_at
+			  FROM categories c JOIN item_categories ic ON ic.category_id = c.id
+			  WHERE ic.item_id = $1 ORDER BY c.name`
+	rows, err := r.db.Query(ctx, query, itemID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCategories(rows)
+}
+
+// FindIDsByItemIDs returns, per item, the categories it is assigned to and
+// all of their ancestors. Items without categories are left out.
+func (r *categoryRepository) FindIDsByItemIDs(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID][]uuid.UUID, error) {
+	query := `WITH RECURSIVE tree AS (
+			SELECT ic.item_id, c.id, c.parent_id FROM item_categories ic JOIN categories c ON c.id = ic.category_id
+			WHERE ic.item_id = ANY($1)
+			UNION
+			SELECT t.item_id, c.id, c.parent_id FROM categories c JOIN tree t ON c.id = t.parent_id
+		)
+		SELECT DISTINCT item_id, id FROM tree`
+
+	rows, err := r.db.Query(ctx, query, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[uuid.UUID][]uuid.UUID)
+	for rows.Next() {
+		var itemID, categoryID uuid.UUID
+		if err := rows.Scan(&itemID, &categoryID); err != nil {
+			return nil, err
+		}
+		ids[itemID] = append(ids[itemID], categoryID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (r *categoryRepository) CountChildren(ctx context.Context, id uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM categories WHERE parent_id = $1`, id).Scan(&count)
+	return count, err
+}
+
+func (r *categoryRepository) CountExisting(ctx context.Context, ids []uuid.UUID) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM categories WHERE id = ANY($1)`, ids).Scan(&count)
+	return count, err
+}
+
+func (r *categoryRepository) Update(ctx context.Context, category *models.Category) error {
+	query := `UPDATE categories SET parent_id = $1, name = $2, slug = $3, description = $4, updated_at = $5 WHERE id = $6`
+	_, err := r.db.Exec(ctx, query, category.ParentID, category.Name, category.Slug, category.Description, category.UpdatedAt, category.ID)
+	return err
+}
+
+func (r *categoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `DELETE FROM categories WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// ReplaceItemCategories swaps the categories of an item in a single transaction.
+func (r *categoryRepository) ReplaceItemCategories(ctx context.Context, itemID uuid.UUID, categoryIDs []uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, `DELETE FROM item_categories WHERE item_id = $1`, itemID); err != nil {
+		return err
+	}
+
+	insertQuery := `INSERT INTO item_categories (item_id, category_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	for _, categoryID := range categoryIDs {
+		if _, err = tx.Exec(ctx, insertQuery, itemID, categoryID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func scanCategories(rows pgx.Rows) ([]models.Category, error) {
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var category models.Category
+		err := rows.Scan(
+			&category.ID,
+			&category.ParentID,
+			&category.Name,
+			&category.Slug,
+			&category.Description,
+			&category.CreatedAt,
+			&category.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
@@ -2,7 +2,9 @@ package repositories
 
 import (
 	"context"
+	"fmt"
 	"shop-crud/item-service/modules/models"
+	"strings"
 
 	"github.com/google/uuid"
 
@@ -10,9 +12,11 @@ import (
 )
 
 type ItemRepository interface {
-	Create(ctx context.Context, item *models.Item) error
-	FindAll(ctx context.Context) ([]models.Item, error)
+	Create(ctx context.Context, item *models.Item, actorID string) error
+	FindAll(ctx context.Context, query models.ItemQuery) ([]models.Item, int, error)
 	FindByID(ctx context.Context, id uuid.UUID) (*models.Item, error)
+	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Item, error)
+	Search(ctx context.Context, query models.ItemSearchQuery) ([]models.ItemSearchResult, error)
 	Update(ctx context.Context, item *models.Item) error
 	Delete(ctx context.Context, id uuid.UUID) error
 }
@@ -25,23 +29,128 @@ func NewItemRepository(db *pgxpool.Pool) ItemRepository {
 	return &itemRepository{db: db}
 }
 
-func (r *itemRepository) Create(ctx context.Context, item *models.Item) error {
-	query := `INSERT INTO items (id, name, description, price, stock, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := r.db.Exec(ctx, query, item.ID, item.Name, item.Description, item.Price, item.Stock, item.CreatedAt, item.UpdatedAt)
-	return err
+// Create inserts the item and records its opening stock in the ledger.
+func (r *itemRepository) Create(ctx context.Context, item *models.Item, actorID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO items (id, name, description, price, currency, stock, created_at, updated_at, tax_class, weight_grams)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err = tx.Exec(ctx, query, item.ID, item.Name, item.Description, item.Price, item.Currency, item.Stock, item.CreatedAt, item.UpdatedAt, item.TaxClass, item.WeightGrams)
+	if err != nil {
+		return err
+	}
+
+	if item.Stock != 0 {
+		err = insertStockMovement(ctx, tx, &models.StockMovement{
+			ItemID:        item.ID,
+			QuantityDelta: item.Stock,
+			BalanceAfter:  item.Stock,
+			Reason:        models.StockReasonInitial,
+			ActorID:       actorID,
+			CreatedAt:     item.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r *itemRepository) FindAll(ctx context.Context) ([]models.Item, error) {
-	var items []models.Item
-	query := `SELECT id, name, description, price, stock, created_at, updated_at FROM items ORDER BY created_at DESC`
+// itemSortColumns whitelists the columns GET /items may be sorted by, together
+// with the SQL type used to compare cursor values against them.
+var itemSortColumns = map[string]string{
+	"name":       "text",
+	"price":      "numeric",
+	"stock":      "integer",
+	"created_at": "timestamptz",
+	"updated_at": "timestamptz",
+}
+
+// FindAll returns one page of items matching the query, plus the total number of
+// matching items ignoring paging. The query is expected to be normalised by the usecase.
+func (r *itemRepository) FindAll(ctx context.Context, query models.ItemQuery) ([]models.Item, int, error) {
+	sortType, ok := itemSortColumns[query.SortBy]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported sort field %q", query.SortBy)
+	}
+	direction, comparator := "ASC", ">"
+	if query.SortOrder == "desc" {
+		direction, comparator = "DESC", "<"
+	}
+
+	var conditions []string
+	var args []interface{}
+	addArg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if query.Name != "" {
+		conditions = append(conditions, "name ILIKE '%' || "+addArg(escapeLike(query.Name))+" || '%'")
+	}
+	if query.MinPrice != nil {
+		conditions = append(conditions, "price >= "+addArg(*query.MinPrice))
+	}
+	if query.MaxPrice != nil {
+		conditions = append(conditions, "price <= "+addArg(*query.MaxPrice))
+	}
+	if query.InStock {
+		conditions = append(conditions, "stock > 0")
+	}
+	if query.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+addArg(*query.CreatedFrom))
+	}
+	if query.CreatedTo != nil {
+		conditions = append(conditions, "created_at <= "+addArg(*query.CreatedTo))
+	}
+	if query.UpdatedFrom != nil {
+		conditions = append(conditions, "updated_at >= "+addArg(*query.UpdatedFrom))
+	}
+	if query.UpdatedTo != nil {
+		conditions = append(conditions, "updated_at <= "+addArg(*query.UpdatedTo))
+	}
+	if len(query.CategoryIDs) > 0 {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM item_categories ic WHERE ic.item_id = items.id AND ic.category_id = ANY("+addArg(query.CategoryIDs)+"))")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*) FROM items`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if query.After != nil {
+		keyset := fmt.Sprintf("(%s, id) %s (%s::%s, %s)",
+			query.SortBy, comparator, addArg(query.After.Value), sortType, addArg(query.After.ID))
+		if where == "" {
+			where = " WHERE " + keyset
+		} else {
+			where += " AND " + keyset
+		}
+	}
 
-	rows, err := r.db.Query(ctx, query)
+	sqlQuery := `SELECT id, name, description, price, currency, stock, created_at, updated_at, tax_class, weight_grams FROM items` + where +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s", query.SortBy, direction, direction, addArg(query.Limit))
+	if query.After == nil && query.Offset > 0 {
+		sqlQuery += " OFFSET " + addArg(query.Offset)
+	}
+
+	rows, err := r.db.Query(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
+	items := []models.Item{}
 	for rows.Next() {
 		var item models.Item
 		err := rows.Scan(
@@ -49,35 +158,46 @@ func (r *itemRepository) FindAll(ctx context.Context) ([]models.Item, error) {
 			&item.Name,
 			&item.Description,
 			&item.Price,
+			&item.Currency,
 			&item.Stock,
 			&item.CreatedAt,
 			&item.UpdatedAt,
+			&item.TaxClass,
+			&item.WeightGrams,
 		)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		items = append(items, item)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return items, nil
+	return items, total, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user supplied search term.
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
 }
 
 func (r *itemRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Item, error) {
 	var item models.Item
-	query := `SELECT id, name, description, price, stock, created_at, updated_at FROM items WHERE id = $1`
+	query := `SELECT id, name, description, price, currency, stock, created_at, updated_at, tax_class, weight_grams FROM items WHERE id = $1`
 	
 	err := r.db.QueryRow(ctx, query, id).Scan(
 		&item.ID,
 		&item.Name,
 		&item.Description,
 		&item.Price,
+		&item.Currency,
 		&item.Stock,
 		&item.CreatedAt,
 		&item.UpdatedAt,
+		&item.TaxClass,
+		&item.WeightGrams,
 	)
 
 	if err != nil {
@@ -87,9 +207,99 @@ func (r *itemRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.It
 	return &item, nil
 }
 
+// FindByIDs returns the items with the given IDs that exist, in no
+// particular order.
+func (r *itemRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]models.Item, error) {
+	query := `SELECT id, name, description, price, currency, stock, created_at, updated_at, tax_class, weight_grams FROM items WHERE id = ANY($1)`
+
+	rows, err := r.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.Item{}
+	for rows.Next() {
+		var item models.Item
+		err := rows.Scan(
+			&item.ID,
+			&item.Name,
+			&item.Description,
+			&item.Price,
+			&item.Currency,
+			&item.Stock,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+			&item.TaxClass,
+			&item.WeightGrams,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Search matches items by full-text search over name and description, falling
+// back to trigram similarity so that misspelled terms still find results.
+func (r *itemRepository) Search(ctx context.Context, query models.ItemSearchQuery) ([]models.ItemSearchResult, error) {
+	sqlQuery := `WITH q AS (SELECT websearch_to_tsquery('simple', $1) AS tsq)
+		SELECT i.id, i.name, COALESCE(i.description, ''), i.price, i.currency, i.stock, i.created_at, i.updated_at, i.tax_class, i.weight_grams,
+			ts_rank_cd(i.search_vector, q.tsq) + similarity(i.name, $1) AS rank,
+			ts_headline('simple', i.name, q.tsq, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('simple', COALESCE(i.description, ''), q.tsq, 'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10')
+		FROM items i, q
+		WHERE i.search_vector @@ q.tsq OR i.name % $1 OR $1 <% i.name OR $1 <% COALESCE(i.description, '')
+		ORDER BY rank DESC, i.id
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.Query(ctx, sqlQuery, query.Q, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []models.ItemSearchResult{}
+	for rows.Next() {
+		var res models.ItemSearchResult
+		err := rows.Scan(
+			&res.ID,
+			&res.Name,
+			&res.Description,
+			&res.Price,
+			&res.Currency,
+			&res.Stock,
+			&res.CreatedAt,
+			&res.UpdatedAt,
+			&res.TaxClass,
+			&res.WeightGrams,
+			&res.Rank,
+			&res.Highlights.Name,
+			&res.Highlights.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Update saves the item's details. Stock only changes through the ledger.
 func (r *itemRepository) Update(ctx context.Context, item *models.Item) error {
-	query := `UPDATE items SET name = $1, description = $2, price = $3, stock = $4, updated_at = $5 WHERE id = $6`
-	_, err := r.db.Exec(ctx, query, item.Name, item.Description, item.Price, item.Stock, item.UpdatedAt, item.ID)
+	query := `UPDATE items SET name = $1, description = $2, price = $3, currency = $4, updated_at = $5, tax_class = $7, weight_grams = $8 WHERE id = $6`
+	_, err := r.db.Exec(ctx, query, item.Name, item.Description, item.Price, item.Currency, item.UpdatedAt, item.ID, item.TaxClass, item.WeightGrams)
 	return err
 }
 
@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"shop-crud/item-service/modules/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// stockTurnoverRefreshName keys the stock turnover summaries in
+// report_refresh_state.
+const stockTurnoverRefreshName = "stock_turnover"
+
+// stockBucket is the width of a summary bucket. Every time zone's offset is
+// a multiple of it, so buckets add up to whole local days.
+const stockBucket = `interval '15 minutes'`
+
+type ReportRepository interface {
+	RefreshStockTurnover(ctx context.Context, overlap time.Duration) (int, time.Time, error)
+	StockTurnoverRefreshedAt(ctx context.Context) (*time.Time, error)
+	StockTurnover(ctx context.Context, from, to time.Time, limit int) ([]models.StockTurnover, error)
+}
+
+type reportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReportRepository(db *pgxpool.Pool) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+// RefreshStockTurnover rebuilds the stock summaries for every bucket that got
+// movements since the last refresh, less overlap to catch movements whose
+// transaction committed late. The ledger is append-only, so no other bucket
+// can have changed. The first refresh rebuilds everything. It returns the
+// number of buckets rebuilt and the time the summaries are now complete up to.
+func (r *reportRepository) RefreshStockTurnover(ctx context.Context, overlap time.Duration) (int, time.Time, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `INSERT INTO report_refresh_state (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, stockTurnoverRefreshName)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var last *time.Time
+	var now time.Time
+	err = tx.QueryRow(ctx, `SELECT refreshed_through, now() FROM report_refresh_state WHERE name = $1 FOR UPDATE`, stockTurnoverRefreshName).
+		Scan(&last, &now)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var since time.Time
+	if last != nil {
+		since = last.Add(-overlap)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE TEMP TABLE dirty_stock_buckets (bucket_start timestamptz PRIMARY KEY) ON COMMIT DROP`)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	dirtyQuery := `INSERT INTO dirty_stock_buckets (bucket_start)
+				   SELECT DISTINCT date_bin(` + stockBucket + `, created_at, TIMESTAMPTZ '2000-01-01 00:00:00+00')
+				   FROM stock_movements
+				   WHERE created_at >= $1`
+	tag, err := tx.Exec(ctx, dirtyQuery, since)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	buckets := int(tag.RowsAffected())
+
+	_, err = tx.Exec(ctx, `DELETE FROM stock_turnover_buckets WHERE bucket_start IN (SELECT bucket_start FROM dirty_stock_buckets)`)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	// Stock taken by reservations and purchases and given back by them is
+	// what sold; the rest of the ledger is stock coming and going by hand.
+	rebuildQuery := `INSERT INTO stock_turnover_buckets (bucket_start, item_id, units_sold, net_delta)
+					 SELECT b.bucket_start, m.item_id,
+							-COALESCE(sum(m.quantity_delta) FILTER (WHERE m.reason IN ($1, $2, $3, $4, $5)), 0),
+							sum(m.quantity_delta)
+					 FROM dirty_stock_buckets b
+					 JOIN stock_movements m ON m.created_at >= b.bucket_start AND m.created_at < b.bucket_start + ` + stockBucket + `
+					 GROUP BY b.bucket_start, m.item_id`
+	_, err = tx.Exec(ctx, rebuildQuery, models.StockReasonReserved, models.StockReasonUnreserved, models.StockReasonDecrement,
+		models.StockReasonRestore, models.StockReasonIncrement)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE report_refresh_state SET refreshed_through = $2 WHERE name = $1`, stockTurnoverRefreshName, now)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, time.Time{}, err
+	}
+	return buckets, now, nil
+}
+
+// StockTurnoverRefreshedAt returns when the stock summaries were last
+// refreshed, or nil if they never were.
+func (r *reportRepository) StockTurnoverRefreshedAt(ctx context.Context) (*time.Time, error) {
+	var refreshedAt *time.Time
+	err := r.db.QueryRow(ctx, `SELECT refreshed_through FROM report_refresh_state WHERE name = $1`, stockTurnoverRefreshName).Scan(&refreshedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return refreshedAt, err
+}
+
+// StockTurnover returns the limit items with the highest turnover from from
+// up to but not including to, rounded to four places. Opening and closing
+// stock are the ledger's balance at either end.
+func (r *reportRepository) StockTurnover(ctx context.Context, from, to time.Time, limit int) ([]models.StockTurnover, error) {
+	query := `WITH stock AS (
+				  SELECT item_id,
+						 COALESCE(sum(units_sold) FILTER (WHERE bucket_start >= $1), 0) AS sold,
+						 COALESCE(sum(net_delta) FILTER (WHERE bucket_start < $1), 0) AS opening,
+						 sum(net_delta) AS closing
+				  FROM stock_turnover_buckets
+				  WHERE bucket_start < $2
+				  GROUP BY item_id
+			  )
+			  SELECT s.item_id, i.name, s.sold, s.opening, s.closing,
+					 ((s.opening + s.closing) / 2.0)::float8,
+					 CASE WHEN s.opening + s.closing > 0 THEN round(s.sold / ((s.opening + s.closing) / 2.0), 4)::float8 END AS turnover
+			  FROM stock s
+			  JOIN items i ON i.id = s.item_id
+			  ORDER BY turnover DESC NULLS LAST, s.sold DESC, s.item_id
+			  LIMIT $3`
+
+	rows, err := r.db.Query(ctx, query, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.StockTurnover{}
+	for rows.Next() {
+		var item models.StockTurnover
+		if err := rows.Scan(&item.ItemID, &item.Name, &item.UnitsSold, &item.OpeningStock, &item.ClosingStock,
+			&item.AverageStock, &item.Turnover); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
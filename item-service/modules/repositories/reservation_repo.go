@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"context"
+	"shop-crud/item-service/modules/models"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReservationRepository interface {
+	Create(ctx context.Context, reservation *models.Reservation) error
+	FindByID(ctx context.Context, id uuid.UUID) (*models.Reservation, error)
+	Confirm(ctx context.Context, reservation *models.Reservation) error
+	Release(ctx context.Context, id uuid.UUID, status string) error
+	FindExpiredIDs(ctx context.Context, limit int) ([]uuid.UUID, error)
+}
+
+type reservationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReservationRepository(db *pgxpool.Pool) ReservationRepository {
+	return &reservationRepository{db: db}
+}
+
+// Create stores the reservation and takes the reserved units out of stock in
+// one transaction, so either every line is held or none is. It returns
+// pgx.ErrNoRows when a line does not have enough stock.
+func (r *reservationRepository) Create(ctx context.Context, reservation *models.Reservation) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO stock_reservations (id, status, actor_id, expires_at, created_at) VALUES ($1, $2, NULLIF($3, ''), $4, $5)`
+	_, err = tx.Exec(ctx, query, reservation.ID, reservation.Status, reservation.ActorID, reservation.ExpiresAt, reservation.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// Lock rows in a fixed order so concurrent reservations cannot deadlock.
+	lines := append([]models.ReservationItem(nil), reservation.Items...)
+	sort.Slice(lines, func(i, j int) bool {
+		return reservationLineKey(lines[i]) < reservationLineKey(lines[j])
+	})
+
+	lineQuery := `INSERT INTO stock_reservation_items (reservation_id, item_id, variant_id, quantity) VALUES ($1, $2, $3, $4)`
+	for _, line := range lines {
+		_, err = tx.Exec(ctx, lineQuery, reservation.ID, line.ItemID, line.VariantID, line.Quantity)
+		if err != nil {
+			return err
+		}
+
+		err = applyStockMovement(ctx, tx, &models.StockMovement{
+			ItemID:        line.ItemID,
+			VariantID:     line.VariantID,
+			QuantityDelta: -line.Quantity,
+			Reason:        models.StockReasonReserved,
+			ReferenceType: models.StockReferenceReservation,
+			ReferenceID:   &reservation.ID,
+			ActorID:       reservation.ActorID,
+			CreatedAt:     reservation.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *reservationRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.Reservation, error) {
+	var reservation models.Reservation
+	query := `SELECT id, status, COALESCE(reference_type, ''), reference_id, COALESCE(actor_id, ''), expires_at, confirmed_at, released_at, created_at
+			  FROM stock_reservations WHERE id = $1`
+
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&reservation.ID,
+		&reservation.Status,
+		&reservation.ReferenceType,
+		&reservation.ReferenceID,
+		&reservation.ActorID,
+		&reservation.ExpiresAt,
+		&reservation.ConfirmedAt,
+		&reservation.ReleasedAt,
+		&reservation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT item_id, variant_id, quantity, drawn_quantity FROM stock_reservation_items WHERE reservation_id = $1 ORDER BY item_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservation.Items = []models.ReservationItem{}
+	for rows.Next() {
+		var line models.ReservationItem
+		if err := rows.Scan(&line.ItemID, &line.VariantID, &line.Quantity, &line.DrawnQuantity); err != nil {
+			return nil, err
+		}
+		reservation.Items = append(reservation.Items, line)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &reservation, nil
+}
+
+// Confirm marks an active, unexpired reservation as confirmed. The held stock
+// stays taken. It returns pgx.ErrNoRows when the reservation is not active or
+// has expired.
+func (r *reservationRepository) Confirm(ctx context.Context, reservation *models.Reservation) error {
+	query := `UPDATE stock_reservations SET status = $2, reference_type = NULLIF($3, ''), reference_id = $4, confirmed_at = $5
+			  WHERE id = $1 AND status = $6 AND expires_at > $5`
+	now := time.Now()
+	result, err := r.db.Exec(ctx, query, reservation.ID, models.ReservationConfirmed, reservation.ReferenceType, reservation.ReferenceID,
+		now, models.ReservationActive)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	reservation.Status = models.ReservationConfirmed
+	reservation.ConfirmedAt = &now
+	return nil
+}
+
+// Release ends an active reservation with the given status (released or
+// expired) and puts its undrawn units back into stock; units drawn by stock
+// commands are theirs to restore. It returns pgx.ErrNoRows when the
+// reservation is not active, so stock is only ever restored once.
+func (r *reservationRepository) Release(ctx context.Context, id uuid.UUID, status string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var actorID string
+	query := `UPDATE stock_reservations SET status = $2, released_at = NOW() WHERE id = $1 AND status = $3 RETURNING COALESCE(actor_id, '')`
+	if err := tx.QueryRow(ctx, query, id, status, models.ReservationActive).Scan(&actorID); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT item_id, variant_id, quantity - drawn_quantity FROM stock_reservation_items
+							   WHERE reservation_id = $1 AND drawn_quantity < quantity ORDER BY item_id, variant_id`, id)
+	if err != nil {
+		return err
+	}
+	lines, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.ReservationItem, error) {
+		var line models.ReservationItem
+		err := row.Scan(&line.ItemID, &line.VariantID, &line.Quantity)
+		return line, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		err = applyStockMovement(ctx, tx, &models.StockMovement{
+			ItemID:        line.ItemID,
+			VariantID:     line.VariantID,
+			QuantityDelta: line.Quantity,
+			Reason:        models.StockReasonUnreserved,
+			ReferenceType: models.StockReferenceReservation,
+			ReferenceID:   &id,
+			ActorID:       actorID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func reservationLineKey(line models.ReservationItem) string {
+	if line.VariantID == nil {
+		return line.ItemID.String()
+	}
+	return line.ItemID.String() + "/" + line.VariantID.String()
+}
+
+// FindExpiredIDs returns active reservations whose hold has run out, oldest first.
+func (r *reservationRepository) FindExpiredIDs(ctx context.Context, limit int) ([]uuid.UUID, error) {
+	query := `SELECT id FROM stock_reservations WHERE status = $1 AND expires_at <= NOW() ORDER BY expires_at LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, models.ReservationActive, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}
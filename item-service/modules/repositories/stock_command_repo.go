@@ -0,0 +1,302 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"shop-crud/item-service/modules/models"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type StockCommandRepository interface {
+	Decrement(ctx context.Context, command *models.StockCommand, actorID string) (bool, error)
+	Restore(ctx context.Context, key string, actorID string) (*models.StockCommand, error)
+	Increment(ctx context.Context, commands []models.StockCommand, actorID string) (bool, error)
+}
+
+type stockCommandRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewStockCommandRepository(db *pgxpool.Pool) StockCommandRepository {
+	return &stockCommandRepository{db: db}
+}
+
+const stockCommandColumns = `key, kind, item_id, variant_id, COALESCE(quantity, 0), status, COALESCE(reference_type, ''), reference_id, reservation_id,
+	created_at, updated_at`
+
+// Decrement records the command and takes its quantity out of stock in one
+// transaction. A command naming a reservation draws the units from its hold
+// instead, leaving stock as it is since the hold already took them. When the
+// key has been seen before, nothing changes, command is overwritten with the
+// stored command and false is returned. It returns pgx.ErrNoRows when the
+// stock is not sufficient, or the reservation no longer holds the units.
+func (r *stockCommandRepository) Decrement(ctx context.Context, command *models.StockCommand, actorID string) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	command.Kind = models.StockCommandDecrement
+	inserted, err := insertStockCommand(ctx, tx, command, now)
+	if err != nil {
+		return false, err
+	}
+	if !inserted {
+		existing, err := findStockCommand(ctx, tx, command.Key)
+		if err != nil {
+			return false, err
+		}
+		*command = *existing
+		return false, nil
+	}
+
+	if command.ReservationID != nil {
+		err = drawReservedStock(ctx, tx, command)
+	} else {
+		err = applyStockMovement(ctx, tx, &models.StockMovement{
+			ItemID:        *command.ItemID,
+			VariantID:     command.VariantID,
+			QuantityDelta: -command.Quantity,
+			Reason:        models.StockReasonDecrement,
+			ReferenceType: command.ReferenceType,
+			ReferenceID:   command.ReferenceID,
+			ActorID:       actorID,
+			CreatedAt:     now,
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	command.Status = models.StockCommandApplied
+	command.CreatedAt, command.UpdatedAt = now, now
+	return true, nil
+}
+
+// Restore undoes the decrement with the given key, at most once. A key that
+// was never decremented is recorded as cancelled so that the decrement is
+// refused if it arrives later. Units drawn from a reservation go back to
+// stock, not to the hold, which only ever gives back what was not drawn.
+func (r *stockCommandRepository) Restore(ctx context.Context, key string, actorID string) (*models.StockCommand, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tombstone := `INSERT INTO stock_commands (key, status, created_at, updated_at) VALUES ($1, $2, NOW(), NOW()) ON CONFLICT (key) DO NOTHING`
+	result, err := tx.Exec(ctx, tombstone, key, models.StockCommandCancelled)
+	if err != nil {
+		return nil, err
+	}
+
+	command, err := findStockCommand(ctx, tx, key)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected() == 0 && command.Status == models.StockCommandApplied && command.Kind == models.StockCommandDecrement {
+		err = applyStockMovement(ctx, tx, &models.StockMovement{
+			ItemID:        *command.ItemID,
+			VariantID:     command.VariantID,
+			QuantityDelta: command.Quantity,
+			Reason:        models.StockReasonRestore,
+			ReferenceType: command.ReferenceType,
+			ReferenceID:   command.ReferenceID,
+			ActorID:       actorID,
+		})
+		// The item or variant was deleted since; there is nothing to restore to.
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+
+		update := `UPDATE stock_commands SET status = $2, updated_at = NOW() WHERE key = $1 RETURNING updated_at`
+		if err := tx.QueryRow(ctx, update, key, models.StockCommandRestored).Scan(&command.UpdatedAt); err != nil {
+			return nil, err
+		}
+		command.Status = models.StockCommandRestored
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// Increment records the commands and adds their quantities to stock in one
+// transaction, so either every line is given back or none is. When the first
+// key has been seen before, nothing changes, the commands found under the
+// keys replace commands and false is returned. Lines whose item or variant
+// has been deleted are recorded without changing any stock.
+func (r *stockCommandRepository) Increment(ctx context.Context, commands []models.StockCommand, actorID string) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	for i := range commands {
+		commands[i].Kind = models.StockCommandIncrement
+		inserted, err := insertStockCommand(ctx, tx, &commands[i], now)
+		if err != nil {
+			return false, err
+		}
+		if !inserted {
+			// Read outside this transaction, which may already have
+			// inserted some of the keys.
+			tx.Rollback(ctx)
+			if err := r.findStockCommandsByKey(ctx, commands); err != nil {
+				return false, err
+			}
+			return false, nil
+		}
+	}
+
+	// Lock rows in a fixed order so concurrent increments cannot deadlock.
+	lines := append([]models.StockCommand(nil), commands...)
+	sort.Slice(lines, func(i, j int) bool {
+		return stockCommandLineKey(lines[i]) < stockCommandLineKey(lines[j])
+	})
+	for _, line := range lines {
+		err = applyStockMovement(ctx, tx, &models.StockMovement{
+			ItemID:        *line.ItemID,
+			VariantID:     line.VariantID,
+			QuantityDelta: line.Quantity,
+			Reason:        models.StockReasonIncrement,
+			ReferenceType: line.ReferenceType,
+			ReferenceID:   line.ReferenceID,
+			ActorID:       actorID,
+			CreatedAt:     now,
+		})
+		// Adding stock only fails this way when the target was deleted.
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return false, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	for i := range commands {
+		commands[i].Status = models.StockCommandApplied
+		commands[i].CreatedAt, commands[i].UpdatedAt = now, now
+	}
+	return true, nil
+}
+
+// insertStockCommand records command as applied unless its key exists, in
+// which case it returns false.
+func insertStockCommand(ctx context.Context, tx pgx.Tx, command *models.StockCommand, now time.Time) (bool, error) {
+	query := `INSERT INTO stock_commands (key, kind, item_id, variant_id, quantity, status, reference_type, reference_id, reservation_id, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $10)
+			  ON CONFLICT (key) DO NOTHING`
+	result, err := tx.Exec(ctx, query, command.Key, command.Kind, command.ItemID, command.VariantID, command.Quantity, models.StockCommandApplied,
+		command.ReferenceType, command.ReferenceID, command.ReservationID, now)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// drawReservedStock moves the command's units out of a line of its
+// reservation that still has that many undrawn. Locking the reservation first
+// serializes draws with each other and with its release, so the reservation
+// must still be active and unexpired when the draw commits. It returns
+// pgx.ErrNoRows when it is not, or when no line holds enough.
+func drawReservedStock(ctx context.Context, tx pgx.Tx, command *models.StockCommand) error {
+	query := `UPDATE stock_reservation_items SET drawn_quantity = drawn_quantity + $4
+			  WHERE ctid = (
+			      SELECT i.ctid FROM stock_reservation_items i
+			      JOIN stock_reservations r ON r.id = i.reservation_id
+			      WHERE i.reservation_id = $1 AND i.item_id = $2 AND i.variant_id IS NOT DISTINCT FROM $3
+			        AND i.quantity - i.drawn_quantity >= $4 AND r.status = $5 AND r.expires_at > NOW()
+			      LIMIT 1
+			      FOR UPDATE OF r
+			  )`
+	result, err := tx.Exec(ctx, query, command.ReservationID, command.ItemID, command.VariantID, command.Quantity, models.ReservationActive)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func stockCommandLineKey(command models.StockCommand) string {
+	if command.VariantID == nil {
+		return command.ItemID.String()
+	}
+	return command.ItemID.String() + "/" + command.VariantID.String()
+}
+
+// findStockCommandsByKey replaces each command with the one stored under its
+// key. Commands whose key is not stored are left with an empty Status.
+func (r *stockCommandRepository) findStockCommandsByKey(ctx context.Context, commands []models.StockCommand) error {
+	keys := make([]string, len(commands))
+	for i := range commands {
+		keys[i] = commands[i].Key
+	}
+
+	query := `SELECT ` + stockCommandColumns + ` FROM stock_commands WHERE key = ANY($1)`
+	rows, err := r.db.Query(ctx, query, keys)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	stored := make(map[string]models.StockCommand, len(keys))
+	for rows.Next() {
+		command, err := scanStockCommand(rows)
+		if err != nil {
+			return err
+		}
+		stored[command.Key] = *command
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		commands[i] = models.StockCommand{Key: key}
+		if command, ok := stored[key]; ok {
+			commands[i] = command
+		}
+	}
+	return nil
+}
+
+func findStockCommand(ctx context.Context, tx pgx.Tx, key string) (*models.StockCommand, error) {
+	query := `SELECT ` + stockCommandColumns + ` FROM stock_commands WHERE key = $1 FOR UPDATE`
+	return scanStockCommand(tx.QueryRow(ctx, query, key))
+}
+
+func scanStockCommand(row pgx.Row) (*models.StockCommand, error) {
+	var command models.StockCommand
+	err := row.Scan(
+		&command.Key,
+		&command.Kind,
+		&command.ItemID,
+		&command.VariantID,
+		&command.Quantity,
+		&command.Status,
+		&command.ReferenceType,
+		&command.ReferenceID,
+		&command.ReservationID,
+		&command.CreatedAt,
+		&command.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &command, nil
+}
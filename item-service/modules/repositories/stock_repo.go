@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"shop-crud/item-service/modules/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type StockRepository interface {
+	Apply(ctx context.Context, movement *models.StockMovement) error
+	SetLevel(ctx context.Context, movement *models.StockMovement, target int) error
+	FindMovements(ctx context.Context, itemID uuid.UUID, query models.StockMovementQuery) ([]models.StockMovement, int, error)
+	FindBalances(ctx context.Context, itemID uuid.UUID) ([]models.StockBalance, error)
+}
+
+type stockRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewStockRepository(db *pgxpool.Pool) StockRepository {
+	return &stockRepository{db: db}
+}
+
+// applyStockMovement changes the stock of an item, or of one of its variants
+// when movement.VariantID is set, and appends the movement to the ledger
+// within tx. It returns pgx.ErrNoRows when the item or variant does not exist
+// or the change would make the stock negative.
+func applyStockMovement(ctx context.Context, tx pgx.Tx, movement *models.StockMovement) error {
+	var err error
+	if movement.VariantID != nil {
+		query := `UPDATE item_variants SET stock = stock + $1 WHERE id = $2 AND item_id = $3 AND stock + $1 >= 0 RETURNING stock`
+		err = tx.QueryRow(ctx, query, movement.QuantityDelta, *movement.VariantID, movement.ItemID).Scan(&movement.BalanceAfter)
+	} else {
+		query := `UPDATE items SET stock = stock + $1 WHERE id = $2 AND stock + $1 >= 0 RETURNING stock`
+		err = tx.QueryRow(ctx, query, movement.QuantityDelta, movement.ItemID).Scan(&movement.BalanceAfter)
+	}
+	if err != nil {
+		return err
+	}
+	return insertStockMovement(ctx, tx, movement)
+}
+
+func insertStockMovement(ctx context.Context, tx pgx.Tx, movement *models.StockMovement) error {
+	if movement.ID == uuid.Nil {
+		movement.ID = uuid.New()
+	}
+	if movement.CreatedAt.IsZero() {
+		movement.CreatedAt = time.Now()
+	}
+
+	query := `INSERT INTO stock_movements (id, item_id, variant_id, quantity_delta, balance_after, reason, reference_type, reference_id, actor_id, note, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, NULLIF($9, ''), NULLIF($10, ''), $11)`
+	_, err := tx.Exec(ctx, query, movement.ID, movement.ItemID, movement.VariantID, movement.QuantityDelta, movement.BalanceAfter,
+		movement.Reason, movement.ReferenceType, movement.ReferenceID, movement.ActorID, movement.Note, movement.CreatedAt)
+	return err
+}
+
+func (r *stockRepository) Apply(ctx context.Context, movement *models.StockMovement) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := applyStockMovement(ctx, tx, movement); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SetLevel brings the stock to target by recording the difference as a
+// movement. The current stock is read under a row lock so that concurrent
+// purchases are not overwritten. Nothing is recorded when the stock already
+// equals target.
+func (r *stockRepository) SetLevel(ctx context.Context, movement *models.StockMovement, target int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var current int
+	if movement.VariantID != nil {
+		query := `SELECT stock FROM item_variants WHERE id = $1 AND item_id = $2 FOR UPDATE`
+		err = tx.QueryRow(ctx, query, *movement.VariantID, movement.ItemID).Scan(&current)
+	} else {
+		query := `SELECT stock FROM items WHERE id = $1 FOR UPDATE`
+		err = tx.QueryRow(ctx, query, movement.ItemID).Scan(&current)
+	}
+	if err != nil {
+		return err
+	}
+
+	movement.QuantityDelta = target - current
+	if movement.QuantityDelta == 0 {
+		movement.BalanceAfter = current
+		return nil
+	}
+	if err := applyStockMovement(ctx, tx, movement); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *stockRepository) FindMovements(ctx context.Context, itemID uuid.UUID, query models.StockMovementQuery) ([]models.StockMovement, int, error) {
+	conditions := []string{"item_id = $1"}
+	args := []interface{}{itemID}
+	if query.VariantID != nil {
+		args = append(args, *query.VariantID)
+		conditions = append(conditions, fmt.Sprintf("variant_id = $%d", len(args)))
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM stock_movements"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, query.Limit, query.Offset)
+	listQuery := `SELECT id, item_id, variant_id, quantity_delta, balance_after, reason, COALESCE(reference_type, ''), reference_id,
+				  COALESCE(actor_id, ''), COALESCE(note, ''), created_at
+				  FROM stock_movements` + where + fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.Query(ctx, listQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var movement models.StockMovement
+		err := rows.Scan(
+			&movement.ID,
+			&movement.ItemID,
+			&movement.VariantID,
+			&movement.QuantityDelta,
+			&movement.BalanceAfter,
+			&movement.Reason,
+			&movement.ReferenceType,
+			&movement.ReferenceID,
+			&movement.ActorID,
+			&movement.Note,
+			&movement.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		movements = append(movements, movement)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return movements, total, nil
+}
+
+// FindBalances returns the stored stock next to the ledger balance, first for
+// the item itself and then for each of its variants.
+func (r *stockRepository) FindBalances(ctx context.Context, itemID uuid.UUID) ([]models.StockBalance, error) {
+	query := `SELECT NULL::uuid, i.stock,
+					 COALESCE((SELECT SUM(m.quantity_delta) FROM stock_movements m WHERE m.item_id = i.id AND m.variant_id IS NULL), 0)
+			  FROM items i WHERE i.id = $1
+			  UNION ALL
+			  SELECT * FROM (
+				  SELECT v.id, v.stock,
+						 COALESCE((SELECT SUM(m.quantity_delta) FROM stock_movements m WHERE m.variant_id = v.id), 0)
+				  FROM item_variants v WHERE v.item_id = $1 ORDER BY v.sku
+			  ) variants`
+
+	rows, err := r.db.Query(ctx, query, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.StockBalance
+	for rows.Next() {
+		var balance models.StockBalance
+		if err := rows.Scan(&balance.VariantID, &balance.Stock, &balance.LedgerBalance); err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(balances) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return balances, nil
+}
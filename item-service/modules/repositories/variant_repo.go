@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"shop-crud/item-service/modules/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type VariantRepository interface {
+	Create(ctx context.Context, variant *models.ItemVariant, actorID string) error
+	FindByItemID(ctx context.Context, itemID uuid.UUID) ([]models.ItemVariant, error)
+	FindByItemIDs(ctx context.Context, itemIDs []uuid.UUID) ([]models.ItemVariant, error)
+	FindByID(ctx context.Context, itemID, id uuid.UUID) (*models.ItemVariant, error)
+	Update(ctx context.Context, variant *models.ItemVariant) error
+	Delete(ctx context.Context, itemID, id uuid.UUID) error
+}
+
+type variantRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewVariantRepository(db *pgxpool.Pool) VariantRepository {
+	return &variantRepository{db: db}
+}
+
+// Create inserts the variant and records its opening stock in the ledger.
+func (r *variantRepository) Create(ctx context.Context, variant *models.ItemVariant, actorID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO item_variants (id, item_id, sku, options, price, stock, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.Exec(ctx, query, variant.ID, variant.ItemID, variant.SKU, variant.Options, variant.Price, variant.Stock, variant.CreatedAt, variant.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if variant.Stock != 0 {
+		err = insertStockMovement(ctx, tx, &models.StockMovement{
+			ItemID:        variant.ItemID,
+			VariantID:     &variant.ID,
+			QuantityDelta: variant.Stock,
+			BalanceAfter:  variant.Stock,
+			Reason:        models.StockReasonInitial,
+			ActorID:       actorID,
+			CreatedAt:     variant.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *variantRepository) FindByItemID(ctx context.Context, itemID uuid.UUID) ([]models.ItemVariant, error) {
+	return r.find(ctx, `item_id = $1`, itemID)
+}
+
+// FindByItemIDs returns the variants of several items, ordered by SKU.
+func (r *variantRepository) FindByItemIDs(ctx context.Context, itemIDs []uuid.UUID) ([]models.ItemVariant, error) {
+	return r.find(ctx, `item_id = ANY($1)`, itemIDs)
+}
+
+func (r *variantRepository) find(ctx context.Context, condition string, arg interface{}) ([]models.ItemVariant, error) {
+	query := `SELECT id, item_id, sku, options, price, stock, created_at, updated_at FROM item_variants WHERE ` + condition + ` ORDER BY sku`
+
+	rows, err := r.db.Query(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []models.ItemVariant{}
+	for rows.Next() {
+		var variant models.ItemVariant
+		err := rows.Scan(
+			&variant.ID,
+			&variant.ItemID,
+			&variant.SKU,
+			&variant.Options,
+			&variant.Price,
+			&variant.Stock,
+			&variant.CreatedAt,
+			&variant.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
+}
+
+func (r *variantRepository) FindByID(ctx context.Context, itemID, id uuid.UUID) (*models.ItemVariant, error) {
+	var variant models.ItemVariant
+	query := `SELECT id, item_id, sku, options, price, stock, created_at, updated_at FROM item_variants WHERE id = $1 AND item_id = $2`
+
+	err := r.db.QueryRow(ctx, query, id, itemID).Scan(
+		&variant.ID,
+		&variant.ItemID,
+		&variant.SKU,
+		&variant.Options,
+		&variant.Price,
+		&variant.Stock,
+		&variant.CreatedAt,
+		&variant.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &variant, nil
+}
+
+// Update saves the variant's details. Stock only changes through the ledger.
+func (r *variantRepository) Update(ctx context.Context, variant *models.ItemVariant) error {
+	query := `UPDATE item_variants SET sku = $1, options = $2, price = $3, updated_at = $4 WHERE id = $5 AND item_id = $6`
+	_, err := r.db.Exec(ctx, query, variant.SKU, variant.Options, variant.Price, variant.UpdatedAt, variant.ID, variant.ItemID)
+	return err
+}
+
+func (r *variantRepository) Delete(ctx context.Context, itemID, id uuid.UUID) error {
+	query := `DELETE FROM item_variants WHERE id = $1 AND item_id = $2`
+	_, err := r.db.Exec(ctx, query, id, itemID)
+	return err
+}
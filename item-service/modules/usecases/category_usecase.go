@@ -0,0 +1,277 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/repositories"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrParentNotFound      = errors.New("parent category not found")
+	ErrCategoryCycle       = errors.New("a category cannot be moved under itself or one of its descendants")
+	ErrCategoryHasChildren = errors.New("category still has subcategories")
+	ErrSlugExists          = errors.New("category slug already exists")
+	ErrItemNotFound        = errors.New("item not found")
+)
+
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+type CategoryUsecase interface {
+	CreateCategory(ctx context.Context, req models.CreateCategoryRequest) (*models.Category, error)
+	GetCategoryTree(ctx context.Context) ([]models.Category, error)
+	GetCategoryByID(ctx context.Context, id uuid.UUID) (*models.CategoryDetailResponse, error)
+	UpdateCategory(ctx context.Context, id uuid.UUID, req models.UpdateCategoryRequest) (*models.Category, error)
+	DeleteCategory(ctx context.Context, id uuid.UUID) error
+	AssignItemCategories(ctx context.Context, itemID uuid.UUID, req models.AssignCategoriesRequest) ([]models.ItemCategory, error)
+}
+
+type categoryUsecase struct {
+	categoryRepo repositories.CategoryRepository
+	itemRepo     repositories.ItemRepository
+}
+
+func NewCategoryUsecase(categoryRepo repositories.CategoryRepository, itemRepo repositories.ItemRepository) CategoryUsecase {
+	return &categoryUsecase{
+		categoryRepo: categoryRepo,
+		itemRepo:     itemRepo,
+	}
+}
+
+func (u *categoryUsecase) CreateCategory(ctx context.Context, req models.CreateCategoryRequest) (*models.Category, error) {
+	if req.ParentID != nil {
+		if _, err := u.findCategory(ctx, *req.ParentID); err != nil {
+			if errors.Is(err, ErrCategoryNotFound) {
+				return nil, ErrParentNotFound
+			}
+			return nil, err
+		}
+	}
+
+	newCategory := &models.Category{
+		ID:          uuid.New(),
+		ParentID:    req.ParentID,
+		Name:        req.Name,
+		Slug:        slugify(req.Slug, req.Name),
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := u.categoryRepo.Create(ctx, newCategory); err != nil {
+		return nil, mapCategoryWriteError(err)
+	}
+	return newCategory, nil
+}
+
+// GetCategoryTree returns the root categories with their subcategories nested.
+func (u *categoryUsecase) GetCategoryTree(ctx context.Context) ([]models.Category, error) {
+	categories, err := u.categoryRepo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	childrenOf := make(map[uuid.UUID][]models.Category)
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+		childrenOf[*category.ParentID] = append(childrenOf[*category.ParentID], category)
+	}
+
+	var attach func(nodes []models.Category) []models.Category
+	attach = func(nodes []models.Category) []models.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(childrenOf[nodes[i].ID])
+		}
+		return nodes
+	}
+
+	tree := attach(roots)
+	if tree == nil {
+		tree = []models.Category{}
+	}
+	return tree, nil
+}
+
+func (u *categoryUsecase) GetCategoryByID(ctx context.Context, id uuid.UUID) (*models.CategoryDetailResponse, error) {
+	category, err := u.findCategory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := u.GetCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	category.Children = findSubtree(tree, id)
+
+	breadcrumbs, err := u.categoryRepo.FindPath(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CategoryDetailResponse{
+		Category:    *category,
+		Breadcrumbs: breadcrumbs,
+	}, nil
+}
+
+func (u *categoryUsecase) UpdateCategory(ctx context.Context, id uuid.UUID, req models.UpdateCategoryRequest) (*models.Category, error) {
+	existingCategory, err := u.findCategory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ParentID != nil {
+		if _, err := u.findCategory(ctx, *req.ParentID); err != nil {
+			if errors.Is(err, ErrCategoryNotFound) {
+				return nil, ErrParentNotFound
+			}
+			return nil, err
+		}
+
+		// The new parent must not sit inside the subtree being moved.
+		descendantIDs, err := u.categoryRepo.FindDescendantIDs(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, descendantID := range descendantIDs {
+			if descendantID == *req.ParentID {
+				return nil, ErrCategoryCycle
+			}
+		}
+	}
+
+	existingCategory.ParentID = req.ParentID
+	existingCategory.Name = req.Name
+	existingCategory.Slug = slugify(req.Slug, req.Name)
+	existingCategory.Description = req.Description
+	existingCategory.UpdatedAt = time.Now()
+
+	if err := u.categoryRepo.Update(ctx, existingCategory); err != nil {
+		return nil, mapCategoryWriteError(err)
+	}
+	return existingCategory, nil
+}
+
+func (u *categoryUsecase) DeleteCategory(ctx context.Context, id uuid.UUID) error {
+	if _, err := u.findCategory(ctx, id); err != nil {
+		return err
+	}
+
+	children, err := u.categoryRepo.CountChildren(ctx, id)
+	if err != nil {
+		return err
+	}
+	if children > 0 {
+		return ErrCategoryHasChildren
+	}
+	return u.categoryRepo.Delete(ctx, id)
+}
+
+func (u *categoryUsecase) AssignItemCategories(ctx context.Context, itemID uuid.UUID, req models.AssignCategoriesRequest) ([]models.ItemCategory, error) {
+	if _, err := u.itemRepo.FindByID(ctx, itemID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrItemNotFound
+		}
+		return nil, err
+	}
+
+	categoryIDs := uniqueIDs(req.CategoryIDs)
+	if len(categoryIDs) > 0 {
+		existing, err := u.categoryRepo.CountExisting(ctx, categoryIDs)
+		if err != nil {
+			return nil, err
+		}
+		if existing != len(categoryIDs) {
+			return nil, ErrCategoryNotFound
+		}
+	}
+
+	if err := u.categoryRepo.ReplaceItemCategories(ctx, itemID, categoryIDs); err != nil {
+		return nil, err
+	}
+	return itemCategoriesWithBreadcrumbs(ctx, u.categoryRepo, itemID)
+}
+
+func (u *categoryUsecase) findCategory(ctx context.Context, id uuid.UUID) (*models.Category, error) {
+	category, err := u.categoryRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return category, nil
+}
+
+// itemCategoriesWithBreadcrumbs loads the categories of an item along with the
+// path from the root to each of them.
+func itemCategoriesWithBreadcrumbs(ctx context.Context, categoryRepo repositories.CategoryRepository, itemID uuid.UUID) ([]models.ItemCategory, error) {
+	categories, err := categoryRepo.FindByItemID(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	itemCategories := []models.ItemCategory{}
+	for _, category := range categories {
+		path, err := categoryRepo.FindPath(ctx, category.ID)
+		if err != nil {
+			return nil, err
+		}
+		itemCategories = append(itemCategories, models.ItemCategory{
+			CategoryRef: models.CategoryRef{ID: category.ID, Name: category.Name, Slug: category.Slug},
+			Breadcrumbs: path,
+		})
+	}
+	return itemCategories, nil
+}
+
+func findSubtree(nodes []models.Category, id uuid.UUID) []models.Category {
+	for _, node := range nodes {
+		if node.ID == id {
+			return node.Children
+		}
+		if children := findSubtree(node.Children, id); children != nil {
+			return children
+		}
+	}
+	return nil
+}
+
+func slugify(slug, name string) string {
+	if slug == "" {
+		slug = name
+	}
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(slug), "-"), "-")
+}
+
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	var unique []uuid.UUID
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func mapCategoryWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrSlugExists
+	}
+	return err
+}
@@ -2,55 +2,227 @@ package usecases
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"shop-crud/item-service/modules/models"
 	"shop-crud/item-service/modules/repositories"
+	"shop-crud/item-service/pkg/money"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid or expired cursor")
+	ErrInvalidRange  = errors.New("range lower bound is greater than its upper bound")
+)
+
+const (
+	defaultItemPageSize = 20
+	defaultItemSortBy   = "created_at"
+	defaultItemSortDir  = "desc"
+	defaultSearchLimit  = 10
 )
 
 type ItemUsecase interface {
-	CreateItem(ctx context.Context, req models.CreateItemRequest) (*models.Item, error)
-	GetAllItems(ctx context.Context) ([]models.Item, error)
+	CreateItem(ctx context.Context, req models.CreateItemRequest, actorID string) (*models.Item, error)
+	GetAllItems(ctx context.Context, query models.ItemQuery) (*models.ItemListResponse, error)
 	GetItemByID(ctx context.Context, id uuid.UUID) (*models.Item, error)
-	UpdateItem(ctx context.Context, id uuid.UUID, req models.UpdateItemRequest) (*models.Item, error)
+	GetItemsByIDs(ctx context.Context, ids []uuid.UUID) (*models.BatchItemsResponse, error)
+	SearchItems(ctx context.Context, query models.ItemSearchQuery) (*models.ItemSearchResponse, error)
+	UpdateItem(ctx context.Context, id uuid.UUID, req models.UpdateItemRequest, actorID string) (*models.Item, error)
 	DeleteItem(ctx context.Context, id uuid.UUID) error
 }
 
 type itemUsecase struct {
-	itemRepo repositories.ItemRepository
+	itemRepo     repositories.ItemRepository
+	categoryRepo repositories.CategoryRepository
+	variantRepo  repositories.VariantRepository
+	stockRepo    repositories.StockRepository
 }
 
-func NewItemUsecase(itemRepo repositories.ItemRepository) ItemUsecase {
-	return &itemUsecase{itemRepo: itemRepo}
+func NewItemUsecase(itemRepo repositories.ItemRepository, categoryRepo repositories.CategoryRepository, variantRepo repositories.VariantRepository, stockRepo repositories.StockRepository) ItemUsecase {
+	return &itemUsecase{
+		itemRepo:     itemRepo,
+		categoryRepo: categoryRepo,
+		variantRepo:  variantRepo,
+		stockRepo:    stockRepo,
+	}
 }
 
-func (u *itemUsecase) CreateItem(ctx context.Context, req models.CreateItemRequest) (*models.Item, error) {
+func (u *itemUsecase) CreateItem(ctx context.Context, req models.CreateItemRequest, actorID string) (*models.Item, error) {
 	newItem := &models.Item{
 		ID:          uuid.New(),
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
+		Currency:    currencyOrDefault(req.Currency),
+		TaxClass:    taxClassOrDefault(req.TaxClass),
+		WeightGrams: req.WeightGrams,
 		Stock:       req.Stock,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	err := u.itemRepo.Create(ctx, newItem)
+	err := u.itemRepo.Create(ctx, newItem, actorID)
 	if err != nil {
 		return nil, err
 	}
 	return newItem, nil
 }
 
-func (u *itemUsecase) GetAllItems(ctx context.Context) ([]models.Item, error) {
-	return u.itemRepo.FindAll(ctx)
+func (u *itemUsecase) GetAllItems(ctx context.Context, query models.ItemQuery) (*models.ItemListResponse, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultItemPageSize
+	}
+	if query.SortBy == "" {
+		query.SortBy = defaultItemSortBy
+	}
+	if query.SortOrder == "" {
+		query.SortOrder = defaultItemSortDir
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return nil, ErrInvalidRange
+	}
+	if query.CreatedFrom != nil && query.CreatedTo != nil && query.CreatedFrom.After(*query.CreatedTo) {
+		return nil, ErrInvalidRange
+	}
+	if query.UpdatedFrom != nil && query.UpdatedTo != nil && query.UpdatedFrom.After(*query.UpdatedTo) {
+		return nil, ErrInvalidRange
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeItemCursor(query.Cursor)
+		if err != nil || cursor.SortBy != query.SortBy || cursor.SortOrder != query.SortOrder {
+			return nil, ErrInvalidCursor
+		}
+		query.After = cursor
+		query.Offset = 0
+	}
+	if query.CategoryID != nil {
+		if _, err := u.categoryRepo.FindByID(ctx, *query.CategoryID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, ErrCategoryNotFound
+			}
+			return nil, err
+		}
+		query.CategoryIDs = []uuid.UUID{*query.CategoryID}
+		if query.IncludeDescendants {
+			descendantIDs, err := u.categoryRepo.FindDescendantIDs(ctx, *query.CategoryID)
+			if err != nil {
+				return nil, err
+			}
+			query.CategoryIDs = descendantIDs
+		}
+	}
+
+	// Fetch one extra row to find out whether another page follows.
+	pageSize := query.Limit
+	query.Limit = pageSize + 1
+	items, total, err := u.itemRepo.FindAll(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &models.ItemListResponse{
+		Data:   items,
+		Total:  total,
+		Limit:  pageSize,
+		Offset: query.Offset,
+	}
+	if len(items) > pageSize {
+		res.Data = items[:pageSize]
+		res.NextCursor = encodeItemCursor(query.SortBy, query.SortOrder, res.Data[pageSize-1])
+	}
+	return res, nil
 }
 
 func (u *itemUsecase) GetItemByID(ctx context.Context, id uuid.UUID) (*models.Item, error) {
-	return u.itemRepo.FindByID(ctx, id)
+	item, err := u.itemRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Categories, err = itemCategoriesWithBreadcrumbs(ctx, u.categoryRepo, id)
+	if err != nil {
+		return nil, err
+	}
+	item.Variants, err = u.variantRepo.FindByItemID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
 }
 
-func (u *itemUsecase) UpdateItem(ctx context.Context, id uuid.UUID, req models.UpdateItemRequest) (*models.Item, error) {
+// GetItemsByIDs looks up several items with their variants and category IDs
+// in three queries. IDs that do not exist are listed as missing rather than
+// failing the batch.
+func (u *itemUsecase) GetItemsByIDs(ctx context.Context, ids []uuid.UUID) (*models.BatchItemsResponse, error) {
+	items, err := u.itemRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	itemIDs := make([]uuid.UUID, len(items))
+	for i := range items {
+		itemIDs[i] = items[i].ID
+	}
+	variants, err := u.variantRepo.FindByItemIDs(ctx, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]*models.Item, len(items))
+	for i := range items {
+		byID[items[i].ID] = &items[i]
+	}
+	for _, variant := range variants {
+		byID[variant.ItemID].Variants = append(byID[variant.ItemID].Variants, variant)
+	}
+	categoryIDs, err := u.categoryRepo.FindIDsByItemIDs(ctx, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	for itemID, ids := range categoryIDs {
+		byID[itemID].CategoryIDs = ids
+	}
+
+	res := &models.BatchItemsResponse{Data: []models.Item{}, Missing: []uuid.UUID{}}
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if item, ok := byID[id]; ok {
+			res.Data = append(res.Data, *item)
+		} else {
+			res.Missing = append(res.Missing, id)
+		}
+	}
+	return res, nil
+}
+
+func (u *itemUsecase) SearchItems(ctx context.Context, query models.ItemSearchQuery) (*models.ItemSearchResponse, error) {
+	query.Q = strings.TrimSpace(query.Q)
+	if query.Limit == 0 {
+		query.Limit = defaultSearchLimit
+	}
+
+	results, err := u.itemRepo.Search(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ItemSearchResponse{
+		Query:  query.Q,
+		Data:   results,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+func (u *itemUsecase) UpdateItem(ctx context.Context, id uuid.UUID, req models.UpdateItemRequest, actorID string) (*models.Item, error) {
 	existingItem, err := u.itemRepo.FindByID(ctx, id)
 	if err != nil {
 		return nil, err 
@@ -60,13 +232,28 @@ func (u *itemUsecase) UpdateItem(ctx context.Context, id uuid.UUID, req models.U
 	existingItem.Name = req.Name
 	existingItem.Description = req.Description
 	existingItem.Price = req.Price
-	existingItem.Stock = req.Stock
+	existingItem.Currency = currencyOrDefault(req.Currency)
+	existingItem.TaxClass = taxClassOrDefault(req.TaxClass)
+	existingItem.WeightGrams = req.WeightGrams
 	existingItem.UpdatedAt = time.Now()
 
 	err = u.itemRepo.Update(ctx, existingItem)
 	if err != nil {
 		return nil, err
 	}
+
+	// A stock value in the request is recorded as a correction to the ledger
+	// rather than overwriting whatever purchases have done in the meantime.
+	movement := &models.StockMovement{
+		ItemID:        id,
+		Reason:        models.StockReasonCorrection,
+		ReferenceType: models.StockReferenceManual,
+		ActorID:       actorID,
+	}
+	if err := u.stockRepo.SetLevel(ctx, movement, req.Stock); err != nil {
+		return nil, err
+	}
+	existingItem.Stock = movement.BalanceAfter
 	return existingItem, nil
 }
 
@@ -76,4 +263,52 @@ func (u *itemUsecase) DeleteItem(ctx context.Context, id uuid.UUID) error {
 		return err
 	}
 	return u.itemRepo.Delete(ctx, id)
-}
\ No newline at end of file
+}
+
+// encodeItemCursor builds an opaque cursor pointing just past the given item.
+func encodeItemCursor(sortBy, sortOrder string, item models.Item) string {
+	cursor := models.ItemCursor{SortBy: sortBy, SortOrder: sortOrder, ID: item.ID}
+	switch sortBy {
+	case "name":
+		cursor.Value = item.Name
+	case "price":
+		cursor.Value = item.Price.String()
+	case "stock":
+		cursor.Value = strconv.Itoa(item.Stock)
+	case "updated_at":
+		cursor.Value = item.UpdatedAt.Format(time.RFC3339Nano)
+	default:
+		cursor.Value = item.CreatedAt.Format(time.RFC3339Nano)
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeItemCursor(token string) (*models.ItemCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var cursor models.ItemCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// defaultTaxClass is the tax class of items that do not name one.
+const defaultTaxClass = "standard"
+
+func taxClassOrDefault(taxClass string) string {
+	if taxClass == "" {
+		return defaultTaxClass
+	}
+	return taxClass
+}
+
+func currencyOrDefault(currency string) string {
+	if currency == "" {
+		return money.DefaultCurrency
+	}
+	return currency
+}
@@ -0,0 +1,118 @@
+package usecases
+
+import (
+	"context"
+	"log"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/repositories"
+	"time"
+)
+
+const (
+	// defaultReportDays is how many days, ending today, a report covers when
+	// it is not given a range.
+	defaultReportDays      = 30
+	defaultStockReportSize = 10
+	// reportRefreshOverlap is how far before the last refresh movements are
+	// looked for again, to catch those whose transaction was still open.
+	reportRefreshOverlap = 5 * time.Minute
+)
+
+const reportDate = "2006-01-02"
+
+type ReportUsecase interface {
+	StockTurnover(ctx context.Context, query models.StockTurnoverQuery) (*models.StockTurnoverReport, error)
+	Refresh(ctx context.Context) (*models.StockReportRefreshResponse, error)
+}
+
+type reportUsecase struct {
+	reportRepo repositories.ReportRepository
+}
+
+func NewReportUsecase(reportRepo repositories.ReportRepository) ReportUsecase {
+	return &reportUsecase{reportRepo: reportRepo}
+}
+
+// StockTurnover reports the items whose stock sold through fastest over the
+// days the query asks for, in its time zone. Without a range it covers the
+// last defaultReportDays days, today included.
+func (u *reportUsecase) StockTurnover(ctx context.Context, query models.StockTurnoverQuery) (*models.StockTurnoverReport, error) {
+	tz := query.TimeZone
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultStockReportSize
+	}
+
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if query.To != "" {
+		if to, err = time.ParseInLocation(reportDate, query.To, loc); err != nil {
+			return nil, err
+		}
+	}
+	from := to.AddDate(0, 0, 1-defaultReportDays)
+	if query.From != "" {
+		if from, err = time.ParseInLocation(reportDate, query.From, loc); err != nil {
+			return nil, err
+		}
+	}
+	if from.After(to) {
+		return nil, ErrInvalidRange
+	}
+
+	refreshedAt, err := u.reportRepo.StockTurnoverRefreshedAt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	items, err := u.reportRepo.StockTurnover(ctx, from, to.AddDate(0, 0, 1), limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.StockTurnoverReport{
+		From:        from.Format(reportDate),
+		To:          to.Format(reportDate),
+		TimeZone:    tz,
+		RefreshedAt: refreshedAt,
+		Items:       items,
+	}, nil
+}
+
+// Refresh brings the stock summaries up to date now rather than at the next
+// scheduled refresh.
+func (u *reportUsecase) Refresh(ctx context.Context) (*models.StockReportRefreshResponse, error) {
+	buckets, refreshedAt, err := u.reportRepo.RefreshStockTurnover(ctx, reportRefreshOverlap)
+	if err != nil {
+		return nil, err
+	}
+	return &models.StockReportRefreshResponse{Buckets: buckets, RefreshedAt: refreshedAt}, nil
+}
+
+// RunReportRefresh brings the stock summaries up to date every interval until
+// ctx is cancelled, starting straight away so reports have data after a
+// deploy.
+func RunReportRefresh(ctx context.Context, reportUsecase ReportUsecase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshed, err := reportUsecase.Refresh(ctx)
+		if err != nil {
+			log.Printf("report refresh: %v", err)
+		} else if refreshed.Buckets > 0 {
+			log.Printf("report refresh: rebuilt %d buckets", refreshed.Buckets)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
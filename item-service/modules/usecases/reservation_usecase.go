@@ -0,0 +1,218 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrReservationNotFound  = errors.New("reservation not found")
+	ErrReservationExpired   = errors.New("reservation has expired")
+	ErrReservationNotActive = errors.New("reservation is no longer active")
+)
+
+// reaperBatchSize bounds how many expired reservations one reaper pass releases.
+const reaperBatchSize = 100
+
+type ReservationUsecase interface {
+	Reserve(ctx context.Context, req models.CreateReservationRequest, actorID string) (*models.Reservation, error)
+	GetReservation(ctx context.Context, id uuid.UUID) (*models.Reservation, error)
+	ConfirmReservation(ctx context.Context, id uuid.UUID, req models.ConfirmReservationRequest) (*models.Reservation, error)
+	ReleaseReservation(ctx context.Context, id uuid.UUID) (*models.Reservation, error)
+	ReleaseExpired(ctx context.Context) (int, error)
+}
+
+type reservationUsecase struct {
+	reservationRepo repositories.ReservationRepository
+	itemRepo        repositories.ItemRepository
+	variantRepo     repositories.VariantRepository
+	defaultTTL      time.Duration
+}
+
+func NewReservationUsecase(reservationRepo repositories.ReservationRepository, itemRepo repositories.ItemRepository, variantRepo repositories.VariantRepository, defaultTTL time.Duration) ReservationUsecase {
+	return &reservationUsecase{
+		reservationRepo: reservationRepo,
+		itemRepo:        itemRepo,
+		variantRepo:     variantRepo,
+		defaultTTL:      defaultTTL,
+	}
+}
+
+func (u *reservationUsecase) Reserve(ctx context.Context, req models.CreateReservationRequest, actorID string) (*models.Reservation, error) {
+	lines := make([]models.ReservationItem, 0, len(req.Items))
+	for _, line := range req.Items {
+		if err := ensureStockTarget(ctx, u.itemRepo, u.variantRepo, line.ItemID, line.VariantID); err != nil {
+			return nil, err
+		}
+		lines = append(lines, models.ReservationItem{
+			ItemID:    line.ItemID,
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+		})
+	}
+
+	ttl := u.defaultTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	now := time.Now()
+	reservation := &models.Reservation{
+		ID:        uuid.New(),
+		Status:    models.ReservationActive,
+		ActorID:   actorID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		Items:     lines,
+	}
+
+	if err := u.reservationRepo.Create(ctx, reservation); err != nil {
+		// Every line was checked above, so no row means not enough stock.
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInsufficientStock
+		}
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (u *reservationUsecase) GetReservation(ctx context.Context, id uuid.UUID) (*models.Reservation, error) {
+	reservation, err := u.reservationRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrReservationNotFound
+		}
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ConfirmReservation turns the hold into a final sale. Confirming a
+// reservation that is already confirmed returns it unchanged, so callers may
+// retry safely.
+func (u *reservationUsecase) ConfirmReservation(ctx context.Context, id uuid.UUID, req models.ConfirmReservationRequest) (*models.Reservation, error) {
+	reservation, err := u.GetReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	switch reservation.Status {
+	case models.ReservationConfirmed:
+		return reservation, nil
+	case models.ReservationExpired:
+		return nil, ErrReservationExpired
+	case models.ReservationReleased:
+		return nil, ErrReservationNotActive
+	}
+
+	reservation.ReferenceType = req.ReferenceType
+	reservation.ReferenceID = req.ReferenceID
+	err = u.reservationRepo.Confirm(ctx, reservation)
+	if err == nil {
+		return reservation, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	// The reservation changed underneath us or its hold ran out before the
+	// reaper got to it. Re-read it to report the right outcome.
+	current, err := u.GetReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch current.Status {
+	case models.ReservationConfirmed:
+		return current, nil
+	case models.ReservationActive:
+		if err := u.release(ctx, id, models.ReservationExpired); err != nil {
+			return nil, err
+		}
+		return nil, ErrReservationExpired
+	case models.ReservationExpired:
+		return nil, ErrReservationExpired
+	}
+	return nil, ErrReservationNotActive
+}
+
+// ReleaseReservation gives the held stock back. Releasing a reservation that
+// has already been released or has expired is a no-op.
+func (u *reservationUsecase) ReleaseReservation(ctx context.Context, id uuid.UUID) (*models.Reservation, error) {
+	if _, err := u.GetReservation(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := u.release(ctx, id, models.ReservationReleased); err != nil {
+		return nil, err
+	}
+
+	reservation, err := u.GetReservation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Status == models.ReservationConfirmed {
+		return nil, ErrReservationNotActive
+	}
+	return reservation, nil
+}
+
+// ReleaseExpired releases active reservations whose hold has run out and
+// returns how many it released.
+func (u *reservationUsecase) ReleaseExpired(ctx context.Context) (int, error) {
+	ids, err := u.reservationRepo.FindExpiredIDs(ctx, reaperBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, id := range ids {
+		err := u.reservationRepo.Release(ctx, id, models.ReservationExpired)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Confirmed or released since it was listed.
+			continue
+		}
+		if err != nil {
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// release ends an active reservation, treating one that is no longer active
+// as already done.
+func (u *reservationUsecase) release(ctx context.Context, id uuid.UUID, status string) error {
+	err := u.reservationRepo.Release(ctx, id, status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// RunReservationReaper releases expired reservations every interval until ctx
+// is cancelled.
+func RunReservationReaper(ctx context.Context, reservationUsecase ReservationUsecase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			released, err := reservationUsecase.ReleaseExpired(ctx)
+			if err != nil {
+				log.Printf("reservation reaper: %v", err)
+				continue
+			}
+			if released > 0 {
+				log.Printf("reservation reaper: released %d expired reservations", released)
+			}
+		}
+	}
+}
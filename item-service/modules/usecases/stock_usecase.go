@@ -0,0 +1,259 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInsufficientStock     = errors.New("adjustment would make stock negative")
+	ErrStockCommandCancelled = errors.New("stock command has been restored or cancelled")
+	ErrStockCommandMismatch  = errors.New("stock command key was already used for a different command")
+)
+
+const defaultMovementPageSize = 50
+
+type StockUsecase interface {
+	AdjustStock(ctx context.Context, itemID uuid.UUID, req models.StockAdjustmentRequest, actorID string) (*models.StockMovement, error)
+	GetStockMovements(ctx context.Context, itemID uuid.UUID, query models.StockMovementQuery) (*models.StockMovementListResponse, error)
+	ReconcileStock(ctx context.Context, itemID uuid.UUID) (*models.StockReconciliationResponse, error)
+	DecrementStock(ctx context.Context, req models.DecrementStockRequest, actorID string) (*models.StockCommand, bool, error)
+	RestoreStock(ctx context.Context, key string, actorID string) (*models.StockCommand, error)
+	IncrementStock(ctx context.Context, req models.IncrementStockRequest, actorID string) (*models.IncrementStockResponse, bool, error)
+}
+
+type stockUsecase struct {
+	stockRepo       repositories.StockRepository
+	commandRepo     repositories.StockCommandRepository
+	reservationRepo repositories.ReservationRepository
+	itemRepo        repositories.ItemRepository
+	variantRepo     repositories.VariantRepository
+}
+
+func NewStockUsecase(stockRepo repositories.StockRepository, commandRepo repositories.StockCommandRepository, reservationRepo repositories.ReservationRepository, itemRepo repositories.ItemRepository, variantRepo repositories.VariantRepository) StockUsecase {
+	return &stockUsecase{
+		stockRepo:       stockRepo,
+		commandRepo:     commandRepo,
+		reservationRepo: reservationRepo,
+		itemRepo:        itemRepo,
+		variantRepo:     variantRepo,
+	}
+}
+
+func (u *stockUsecase) AdjustStock(ctx context.Context, itemID uuid.UUID, req models.StockAdjustmentRequest, actorID string) (*models.StockMovement, error) {
+	if err := ensureStockTarget(ctx, u.itemRepo, u.variantRepo, itemID, req.VariantID); err != nil {
+		return nil, err
+	}
+
+	reason := req.Reason
+	if reason == "" {
+		reason = models.StockReasonAdjustment
+	}
+	movement := &models.StockMovement{
+		ItemID:        itemID,
+		VariantID:     req.VariantID,
+		QuantityDelta: req.QuantityDelta,
+		Reason:        reason,
+		ReferenceType: models.StockReferenceManual,
+		ActorID:       actorID,
+		Note:          req.Note,
+	}
+	if err := u.stockRepo.Apply(ctx, movement); err != nil {
+		// The target exists, so no row means the stock check failed.
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInsufficientStock
+		}
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (u *stockUsecase) GetStockMovements(ctx context.Context, itemID uuid.UUID, query models.StockMovementQuery) (*models.StockMovementListResponse, error) {
+	if err := ensureStockTarget(ctx, u.itemRepo, u.variantRepo, itemID, query.VariantID); err != nil {
+		return nil, err
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultMovementPageSize
+	}
+
+	movements, total, err := u.stockRepo.FindMovements(ctx, itemID, query)
+	if err != nil {
+		return nil, err
+	}
+	return &models.StockMovementListResponse{
+		Data:   movements,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}, nil
+}
+
+// ReconcileStock compares the stock stored on the item and its variants with
+// the balances derived from the ledger.
+func (u *stockUsecase) ReconcileStock(ctx context.Context, itemID uuid.UUID) (*models.StockReconciliationResponse, error) {
+	balances, err := u.stockRepo.FindBalances(ctx, itemID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrItemNotFound
+		}
+		return nil, err
+	}
+
+	response := &models.StockReconciliationResponse{
+		ItemID:     itemID,
+		Variants:   []models.StockBalance{},
+		Consistent: true,
+	}
+	for i := range balances {
+		balance := &balances[i]
+		balance.Difference = balance.Stock - balance.LedgerBalance
+		balance.Consistent = balance.Difference == 0
+		response.Consistent = response.Consistent && balance.Consistent
+	}
+	response.Item = balances[0]
+	response.Variants = append(response.Variants, balances[1:]...)
+	return response, nil
+}
+
+// DecrementStock takes stock on behalf of another service, from the
+// reservation the request names if any. Repeating a command with the same key
+// returns the stored command and false instead of taking stock again.
+func (u *stockUsecase) DecrementStock(ctx context.Context, req models.DecrementStockRequest, actorID string) (*models.StockCommand, bool, error) {
+	if err := ensureStockTarget(ctx, u.itemRepo, u.variantRepo, req.ItemID, req.VariantID); err != nil {
+		return nil, false, err
+	}
+
+	command := &models.StockCommand{
+		Key:           req.Key,
+		ItemID:        &req.ItemID,
+		VariantID:     req.VariantID,
+		Quantity:      req.Quantity,
+		ReferenceType: req.ReferenceType,
+		ReferenceID:   req.ReferenceID,
+		ReservationID: req.ReservationID,
+	}
+	created, err := u.commandRepo.Decrement(ctx, command, actorID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			if req.ReservationID != nil {
+				return nil, false, u.reservationDrawError(ctx, *req.ReservationID)
+			}
+			return nil, false, ErrInsufficientStock
+		}
+		return nil, false, err
+	}
+	if created {
+		return command, true, nil
+	}
+
+	if command.Status != models.StockCommandApplied {
+		return nil, false, ErrStockCommandCancelled
+	}
+	if command.Kind != models.StockCommandDecrement || *command.ItemID != req.ItemID || !sameVariant(command.VariantID, req.VariantID) ||
+		command.Quantity != req.Quantity || !sameVariant(command.ReservationID, req.ReservationID) {
+		return nil, false, ErrStockCommandMismatch
+	}
+	return command, false, nil
+}
+
+// reservationDrawError explains why a decrement could not draw from the
+// reservation: it is gone, its hold ended, or it holds fewer units of the
+// line than were asked for.
+func (u *stockUsecase) reservationDrawError(ctx context.Context, reservationID uuid.UUID) error {
+	reservation, err := u.reservationRepo.FindByID(ctx, reservationID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrReservationNotFound
+		}
+		return err
+	}
+	switch {
+	case reservation.Status == models.ReservationExpired,
+		reservation.Status == models.ReservationActive && !reservation.ExpiresAt.After(time.Now()):
+		return ErrReservationExpired
+	case reservation.Status != models.ReservationActive:
+		return ErrReservationNotActive
+	}
+	return ErrInsufficientStock
+}
+
+// RestoreStock compensates the decrement with the given key. It is safe to
+// call more than once, and before the decrement has arrived.
+func (u *stockUsecase) RestoreStock(ctx context.Context, key string, actorID string) (*models.StockCommand, error) {
+	command, err := u.commandRepo.Restore(ctx, key, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if command.Kind != models.StockCommandDecrement {
+		return nil, ErrStockCommandMismatch
+	}
+	return command, nil
+}
+
+// IncrementStock gives stock back for every line of the request at once.
+// Repeating a request with the same key returns the stored commands and false
+// instead of adding stock again.
+func (u *stockUsecase) IncrementStock(ctx context.Context, req models.IncrementStockRequest, actorID string) (*models.IncrementStockResponse, bool, error) {
+	commands := make([]models.StockCommand, len(req.Lines))
+	for i, line := range req.Lines {
+		itemID := line.ItemID
+		commands[i] = models.StockCommand{
+			Key:           fmt.Sprintf("%s/%d", req.Key, i),
+			ItemID:        &itemID,
+			VariantID:     line.VariantID,
+			Quantity:      line.Quantity,
+			ReferenceType: req.ReferenceType,
+			ReferenceID:   req.ReferenceID,
+		}
+	}
+
+	created, err := u.commandRepo.Increment(ctx, commands, actorID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		for i, command := range commands {
+			line := req.Lines[i]
+			if command.Kind != models.StockCommandIncrement || command.ItemID == nil || *command.ItemID != line.ItemID ||
+				!sameVariant(command.VariantID, line.VariantID) || command.Quantity != line.Quantity {
+				return nil, false, ErrStockCommandMismatch
+			}
+		}
+	}
+	return &models.IncrementStockResponse{Key: req.Key, Commands: commands}, created, nil
+}
+
+func sameVariant(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ensureStockTarget checks that the item, and the variant when one is given,
+// exist.
+func ensureStockTarget(ctx context.Context, itemRepo repositories.ItemRepository, variantRepo repositories.VariantRepository, itemID uuid.UUID, variantID *uuid.UUID) error {
+	if _, err := itemRepo.FindByID(ctx, itemID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrItemNotFound
+		}
+		return err
+	}
+	if variantID == nil {
+		return nil
+	}
+	if _, err := variantRepo.FindByID(ctx, itemID, *variantID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrVariantNotFound
+		}
+		return err
+	}
+	return nil
+}
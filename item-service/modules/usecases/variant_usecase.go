@@ -0,0 +1,138 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"shop-crud/item-service/modules/models"
+	"shop-crud/item-service/modules/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrSKUExists       = errors.New("sku already exists")
+	ErrVariantInUse    = errors.New("variant is referenced by existing purchases")
+)
+
+type VariantUsecase interface {
+	CreateVariant(ctx context.Context, itemID uuid.UUID, req models.CreateVariantRequest, actorID string) (*models.ItemVariant, error)
+	GetVariants(ctx context.Context, itemID uuid.UUID) ([]models.ItemVariant, error)
+	UpdateVariant(ctx context.Context, itemID, variantID uuid.UUID, req models.UpdateVariantRequest, actorID string) (*models.ItemVariant, error)
+	DeleteVariant(ctx context.Context, itemID, variantID uuid.UUID) error
+}
+
+type variantUsecase struct {
+	variantRepo repositories.VariantRepository
+	itemRepo    repositories.ItemRepository
+	stockRepo   repositories.StockRepository
+}
+
+func NewVariantUsecase(variantRepo repositories.VariantRepository, itemRepo repositories.ItemRepository, stockRepo repositories.StockRepository) VariantUsecase {
+	return &variantUsecase{
+		variantRepo: variantRepo,
+		itemRepo:    itemRepo,
+		stockRepo:   stockRepo,
+	}
+}
+
+func (u *variantUsecase) CreateVariant(ctx context.Context, itemID uuid.UUID, req models.CreateVariantRequest, actorID string) (*models.ItemVariant, error) {
+	if err := u.ensureItem(ctx, itemID); err != nil {
+		return nil, err
+	}
+
+	newVariant := &models.ItemVariant{
+		ID:        uuid.New(),
+		ItemID:    itemID,
+		SKU:       req.SKU,
+		Options:   req.Options,
+		Price:     req.Price,
+		Stock:     req.Stock,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := u.variantRepo.Create(ctx, newVariant, actorID); err != nil {
+		return nil, mapVariantWriteError(err)
+	}
+	return newVariant, nil
+}
+
+func (u *variantUsecase) GetVariants(ctx context.Context, itemID uuid.UUID) ([]models.ItemVariant, error) {
+	if err := u.ensureItem(ctx, itemID); err != nil {
+		return nil, err
+	}
+	return u.variantRepo.FindByItemID(ctx, itemID)
+}
+
+func (u *variantUsecase) UpdateVariant(ctx context.Context, itemID, variantID uuid.UUID, req models.UpdateVariantRequest, actorID string) (*models.ItemVariant, error) {
+	existingVariant, err := u.findVariant(ctx, itemID, variantID)
+	if err != nil {
+		return nil, err
+	}
+
+	existingVariant.SKU = req.SKU
+	existingVariant.Options = req.Options
+	existingVariant.Price = req.Price
+	existingVariant.UpdatedAt = time.Now()
+
+	if err := u.variantRepo.Update(ctx, existingVariant); err != nil {
+		return nil, mapVariantWriteError(err)
+	}
+
+	movement := &models.StockMovement{
+		ItemID:        itemID,
+		VariantID:     &variantID,
+		Reason:        models.StockReasonCorrection,
+		ReferenceType: models.StockReferenceManual,
+		ActorID:       actorID,
+	}
+	if err := u.stockRepo.SetLevel(ctx, movement, req.Stock); err != nil {
+		return nil, err
+	}
+	existingVariant.Stock = movement.BalanceAfter
+	return existingVariant, nil
+}
+
+func (u *variantUsecase) DeleteVariant(ctx context.Context, itemID, variantID uuid.UUID) error {
+	if _, err := u.findVariant(ctx, itemID, variantID); err != nil {
+		return err
+	}
+	return mapVariantWriteError(u.variantRepo.Delete(ctx, itemID, variantID))
+}
+
+func (u *variantUsecase) ensureItem(ctx context.Context, itemID uuid.UUID) error {
+	if _, err := u.itemRepo.FindByID(ctx, itemID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrItemNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (u *variantUsecase) findVariant(ctx context.Context, itemID, variantID uuid.UUID) (*models.ItemVariant, error) {
+	variant, err := u.variantRepo.FindByID(ctx, itemID, variantID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrVariantNotFound
+		}
+		return nil, err
+	}
+	return variant, nil
+}
+
+func mapVariantWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "23505":
+			return ErrSKUExists
+		case "23503":
+			return ErrVariantInUse
+		}
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+// Package idempotency makes POST endpoints safe to retry. A client sends an
+// Idempotency-Key header; the first request with a key runs normally and its
+// response is stored, and any later request with the same key gets that
+// response back instead of running again. It is shared by item-service and
+// purchase-service.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// HeaderKey is the request header carrying the client's key.
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed is set on responses served from a stored record.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+)
+
+// ErrNotFound is returned by Store.Find when no live record exists.
+var ErrNotFound = errors.New("idempotency record not found")
+
+// Record is a key claimed by a request. StatusCode is 0 while the request is
+// still being handled.
+type Record struct {
+	Scope       string
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Store keeps idempotency records.
+type Store interface {
+	// Claim records key as in progress for a request with the given hash. It
+	// returns true when the key was free or had expired, and otherwise false
+	// together with the record that holds it.
+	Claim(ctx context.Context, scope, key, requestHash string, expiresAt time.Time) (*Record, bool, error)
+	// Complete stores the response of the request that claimed key.
+	Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error
+	// Release forgets key so that the request can be tried again.
+	Release(ctx context.Context, scope, key string) error
+	// DeleteExpired removes records whose window has passed.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// Config configures Middleware.
+type Config struct {
+	Store Store
+	// TTL is how long a key is remembered after its first use.
+	TTL time.Duration
+	// Scope returns the namespace of the caller, typically the user ID, so
+	// that keys chosen by different callers never collide.
+	Scope func(c echo.Context) string
+}
+
+// Middleware replays the stored response for a repeated Idempotency-Key. A
+// key reused with a different request is rejected with 422, and a key whose
+// first request is still running with 409. Requests without the header pass
+// through unchanged. Responses with a 5xx status are not stored, so the
+// client may retry them with the same key.
+func Middleware(config Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderKey)
+			if key == "" {
+				return next(c)
+			}
+			if len(key) > maxKeyLength {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Idempotency-Key must be at most 255 characters"})
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := c.Request().Context()
+			scope := config.Scope(c)
+			hash := requestHash(c.Request(), body)
+
+			existing, claimed, err := config.Store.Claim(ctx, scope, key, hash, time.Now().Add(config.TTL))
+			if err != nil {
+				c.Logger().Errorf("Failed to claim idempotency key: %v", err)
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to process idempotency key"})
+			}
+			if !claimed {
+				return replay(c, existing, hash)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			// The outcome is stored even if the client has gone away, since
+			// that is exactly when it will retry.
+			storeCtx := context.WithoutCancel(ctx)
+			completed := false
+			defer func() {
+				if !completed {
+					if err := config.Store.Release(storeCtx, scope, key); err != nil {
+						log.Printf("idempotency: release key %q: %v", key, err)
+					}
+				}
+			}()
+
+			if err := next(c); err != nil {
+				// Let Echo write the error response; the key is released so
+				// the request can be retried.
+				return err
+			}
+
+			status := c.Response().Status
+			if status >= http.StatusInternalServerError {
+				return nil
+			}
+			contentType := c.Response().Header().Get(echo.HeaderContentType)
+			if err := config.Store.Complete(storeCtx, scope, key, status, contentType, recorder.body.Bytes()); err != nil {
+				log.Printf("idempotency: store response for key %q: %v", key, err)
+				return nil
+			}
+			completed = true
+			return nil
+		}
+	}
+}
+
+// RunPurge deletes expired records every interval until ctx is cancelled.
+func RunPurge(ctx context.Context, store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.DeleteExpired(ctx); err != nil {
+				log.Printf("idempotency purge: %v", err)
+			}
+		}
+	}
+}
+
+func replay(c echo.Context, record *Record, hash string) error {
+	if record.RequestHash != hash {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "Idempotency-Key was already used for a different request"})
+	}
+	if record.StatusCode == 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "A request with this Idempotency-Key is still being processed"})
+	}
+
+	c.Response().Header().Set(HeaderReplayed, "true")
+	contentType := record.ContentType
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+	return c.Blob(record.StatusCode, contentType, record.Body)
+}
+
+// requestHash identifies a request by its method, path and body. The path is
+// included so that one key cannot be replayed against another endpoint.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	h.Write([]byte{0})
+	io.WriteString(h, r.URL.Path)
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copies the response body while it is written.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,103 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type postgresStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPostgresStore returns a Store backed by the idempotency_keys table.
+func NewPostgresStore(db *pgxpool.Pool) Store {
+	return &postgresStore{db: db}
+}
+
+// Claim inserts the key as in progress. An expired record is taken over in
+// the same statement, so two requests can never both claim one key.
+func (s *postgresStore) Claim(ctx context.Context, scope, key, requestHash string, expiresAt time.Time) (*Record, bool, error) {
+	now := time.Now()
+	query := `INSERT INTO idempotency_keys (scope, key, request_hash, created_at, expires_at)
+			  VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (scope, key) DO UPDATE SET
+				  request_hash = EXCLUDED.request_hash, status_code = NULL, content_type = NULL, response_body = NULL,
+				  created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+			  WHERE idempotency_keys.expires_at <= $4`
+	result, err := s.db.Exec(ctx, query, scope, key, requestHash, now, expiresAt)
+	if err != nil {
+		return nil, false, err
+	}
+	if result.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+
+	record, err := s.find(ctx, scope, key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Purged between the two statements; the caller may simply retry.
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+func (s *postgresStore) Complete(ctx context.Context, scope, key string, statusCode int, contentType string, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = $3, content_type = NULLIF($4, ''), response_body = $5
+			  WHERE scope = $1 AND key = $2 AND status_code IS NULL`
+	result, err := s.db.Exec(ctx, query, scope, key, statusCode, contentType, body)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *postgresStore) Release(ctx context.Context, scope, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL`
+	_, err := s.db.Exec(ctx, query, scope, key)
+	return err
+}
+
+func (s *postgresStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (s *postgresStore) find(ctx context.Context, scope, key string) (*Record, error) {
+	var record Record
+	var statusCode *int
+	var contentType *string
+	query := `SELECT scope, key, request_hash, status_code, content_type, response_body, created_at, expires_at
+			  FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	err := s.db.QueryRow(ctx, query, scope, key).Scan(
+		&record.Scope,
+		&record.Key,
+		&record.RequestHash,
+		&statusCode,
+		&contentType,
+		&record.Body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != nil {
+		record.StatusCode = *statusCode
+	}
+	if contentType != nil {
+		record.ContentType = *contentType
+	}
+	return &record, nil
+}
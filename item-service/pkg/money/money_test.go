@@ -0,0 +1,323 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		err  error
+	}{
+		{in: "1500", want: 150000},
+		{in: "1500.5", want: 150050},
+		{in: "1500.50", want: 150050},
+		{in: "0.01", want: 1},
+		{in: ".5", want: 50},
+		{in: "-3.5", want: -350},
+		{in: "+2", want: 200},
+		{in: " 7.25 ", want: 725},
+		// Trailing zeros past the second place are exact, so they are kept.
+		{in: "1.2500", want: 125},
+		{in: "1.005", err: ErrTooPrecise},
+		{in: "", err: ErrInvalidAmount},
+		{in: "-", err: ErrInvalidAmount},
+		{in: "1.", err: ErrInvalidAmount},
+		{in: "1,50", err: ErrInvalidAmount},
+		{in: "1e3", err: ErrInvalidAmount},
+		{in: "abc", err: ErrInvalidAmount},
+		{in: "99999999999999999999", err: ErrInvalidAmount},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Parse(%q) error = %v, want %v", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got.Minor() != tt.want {
+			t.Errorf("Parse(%q) = %d minor units, want %d", tt.in, got.Minor(), tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		minor int64
+		want  string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{150050, "1500.50"},
+		{-5, "-0.05"},
+		{-350, "-3.50"},
+	}
+	for _, tt := range tests {
+		if got := FromMinor(tt.minor).String(); got != tt.want {
+			t.Errorf("FromMinor(%d).String() = %q, want %q", tt.minor, got, tt.want)
+		}
+	}
+}
+
+// TestArithmetic checks that sums and products stay exact where float64
+// would not: ten times 0.10 is 1.00, and 0.10 + 0.20 is 0.30.
+func TestArithmetic(t *testing.T) {
+	dime := MustParse("0.10")
+	if got := dime.Mul(10); got != FromUnits(1) {
+		t.Errorf("0.10 * 10 = %s, want 1.00", got)
+	}
+	if got := dime.Add(MustParse("0.20")); got != MustParse("0.30") {
+		t.Errorf("0.10 + 0.20 = %s, want 0.30", got)
+	}
+	if got := FromUnits(5).Sub(MustParse("5.01")); got != FromMinor(-1) || !got.IsNegative() {
+		t.Errorf("5.00 - 5.01 = %s, want -0.01", got)
+	}
+	if got := MustParse("19.99").Mul(0); !got.IsZero() {
+		t.Errorf("19.99 * 0 = %s, want 0.00", got)
+	}
+
+	var cents []Amount
+	for i := 0; i < 1000; i++ {
+		cents = append(cents, dime)
+	}
+	if got := Sum(cents...); got != FromUnits(100) {
+		t.Errorf("sum of 1000 * 0.10 = %s, want 100.00", got)
+	}
+	if got := Sum(); got != 0 {
+		t.Errorf("empty sum = %s, want 0.00", got)
+	}
+}
+
+// TestLargeTotals checks totals well beyond what float64 holds to the cent.
+func TestLargeTotals(t *testing.T) {
+	price := MustParse("123456789012.34")
+	total := price.Mul(1000)
+	if got, want := total.String(), "123456789012340.00"; got != want {
+		t.Errorf("123456789012.34 * 1000 = %s, want %s", got, want)
+	}
+	if got := total.Add(FromMinor(1)).String(); got != "123456789012340.01" {
+		t.Errorf("adding one cent = %s, want 123456789012340.01", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Price Amount `json:"price"`
+	}{MustParse("1500.5")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"price":1500.50}`; got != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+
+	for _, in := range []string{`1500.50`, `"1500.50"`, `1500.5`} {
+		var a Amount
+		if err := json.Unmarshal([]byte(in), &a); err != nil {
+			t.Errorf("Unmarshal(%s): %v", in, err)
+			continue
+		}
+		if a != FromMinor(150050) {
+			t.Errorf("Unmarshal(%s) = %s, want 1500.50", in, a)
+		}
+	}
+
+	a := FromMinor(42)
+	if err := json.Unmarshal([]byte(`null`), &a); err != nil || a != FromMinor(42) {
+		t.Errorf("Unmarshal(null) = %s, %v; want the amount unchanged", a, err)
+	}
+	if err := json.Unmarshal([]byte(`0.001`), &a); !errors.Is(err, ErrTooPrecise) {
+		t.Errorf("Unmarshal(0.001) error = %v, want %v", err, ErrTooPrecise)
+	}
+}
+
+// TestScanNumeric checks that numeric columns with more than two places are
+// rounded half away from zero.
+func TestScanNumeric(t *testing.T) {
+	tests := []struct {
+		digits int64
+		exp    int32
+		want   int64
+	}{
+		{150050, -2, 150050},
+		{15, 2, 150000},
+		{1005, -3, 101},
+		{1004, -3, 100},
+		{-1005, -3, -101},
+		{-1004, -3, -100},
+		{12345, -4, 123},
+		{12350, -4, 124},
+	}
+	for _, tt := range tests {
+		var a Amount
+		n := pgtype.Numeric{Int: big.NewInt(tt.digits), Exp: tt.exp, Valid: true}
+		if err := a.ScanNumeric(n); err != nil {
+			t.Errorf("ScanNumeric(%de%d): %v", tt.digits, tt.exp, err)
+			continue
+		}
+		if a.Minor() != tt.want {
+			t.Errorf("ScanNumeric(%de%d) = %d minor units, want %d", tt.digits, tt.exp, a.Minor(), tt.want)
+		}
+	}
+
+	var a Amount
+	if err := a.ScanNumeric(pgtype.Numeric{}); err == nil {
+		t.Error("ScanNumeric(NULL) succeeded")
+	}
+	if err := a.ScanNumeric(pgtype.Numeric{NaN: true, Valid: true}); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("ScanNumeric(NaN) error = %v, want %v", err, ErrInvalidAmount)
+	}
+	huge := pgtype.Numeric{Int: big.NewInt(math.MaxInt64), Exp: 0, Valid: true}
+	if err := a.ScanNumeric(huge); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("ScanNumeric(out of range) error = %v, want %v", err, ErrInvalidAmount)
+	}
+
+	value, err := FromMinor(-350).NumericValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ScanNumeric(value); err != nil || a != FromMinor(-350) {
+		t.Errorf("round trip of -3.50 = %s, %v", a, err)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	if _, err := ParseRate("0"); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("ParseRate(0) error = %v, want %v", err, ErrInvalidRate)
+	}
+	if _, err := ParseRate("-1.5"); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("ParseRate(-1.5) error = %v, want %v", err, ErrInvalidRate)
+	}
+	if _, err := ParseRate("x"); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("ParseRate(x) error = %v, want %v", err, ErrInvalidRate)
+	}
+
+	zero, err := ParseNonNegativeRate("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !zero.IsZero() || zero.IsValid() {
+		t.Errorf("tax rate 0: IsZero = %v, IsValid = %v", zero.IsZero(), zero.IsValid())
+	}
+	if _, err := ParseNonNegativeRate("-0.11"); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("ParseNonNegativeRate(-0.11) error = %v, want %v", err, ErrInvalidRate)
+	}
+	if (Rate{}).IsValid() || !(Rate{}).IsZero() {
+		t.Error("zero Rate should be zero and not a valid exchange rate")
+	}
+}
+
+// TestRateConvert checks conversion rounding half away from zero to the
+// nearest minor unit.
+func TestRateConvert(t *testing.T) {
+	tests := []struct {
+		rate   string
+		amount string
+		want   string
+	}{
+		{"16250.5", "1.00", "16250.50"},
+		{"0.0000615", "100000.00", "6.15"},
+		// 0.11 * 1.05 = 0.1155.
+		{"0.11", "1.05", "0.12"},
+		// 0.5 * 0.05 = 0.025, exactly half a cent.
+		{"0.5", "0.05", "0.03"},
+		{"0.5", "-0.05", "-0.03"},
+		// 0.4 * 0.03 = 0.012 rounds down.
+		{"0.4", "0.03", "0.01"},
+	}
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rate.Convert(MustParse(tt.amount)); got != MustParse(tt.want) {
+			t.Errorf("%s at %s = %s, want %s", tt.amount, tt.rate, got, tt.want)
+		}
+	}
+
+	if got := OneRate().Convert(MustParse("12.34")); got != MustParse("12.34") {
+		t.Errorf("OneRate converted 12.34 to %s", got)
+	}
+}
+
+func TestRateArithmetic(t *testing.T) {
+	usdEUR, _ := ParseRate("0.92")
+	idrEUR, _ := ParseRate("0.0000566")
+	usdIDR := usdEUR.Div(idrEUR)
+	if got := usdIDR.Round().String(); got != "16254.4169611307" {
+		t.Errorf("USD->IDR = %s, want 16254.4169611307", got)
+	}
+	if got := usdEUR.Inverse().Inverse().String(); got != "0.92" {
+		t.Errorf("inverse of inverse = %s, want 0.92", got)
+	}
+	if got := usdEUR.Inverse().RoundTo(4).String(); got != "1.087" {
+		t.Errorf("EUR->USD to 4 places = %s, want 1.087", got)
+	}
+
+	tax, _ := ParseNonNegativeRate("0.11")
+	if got := tax.Percent(); got != "11%" {
+		t.Errorf("Percent = %q, want 11%%", got)
+	}
+	if got := (Rate{}).String(); got != "0" {
+		t.Errorf("zero Rate String = %q, want 0", got)
+	}
+}
+
+func TestRateJSONAndNumeric(t *testing.T) {
+	rate, _ := ParseRate("16250.5")
+	data, err := json.Marshal(rate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"16250.5"` {
+		t.Errorf("Marshal = %s, want \"16250.5\"", data)
+	}
+	var decoded Rate
+	if err := json.Unmarshal([]byte(`0.0000615`), &decoded); err != nil || decoded.String() != "0.0000615" {
+		t.Errorf("Unmarshal(0.0000615) = %s, %v", decoded, err)
+	}
+	if err := json.Unmarshal([]byte(`"-0.5"`), &decoded); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("Unmarshal(\"-0.5\") error = %v, want %v", err, ErrInvalidRate)
+	}
+
+	// A tax-exempt line's zero rate reads back as zero.
+	exempt, _ := ParseNonNegativeRate("0")
+	data, err = json.Marshal(exempt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roundTrip Rate
+	if err := json.Unmarshal(data, &roundTrip); err != nil {
+		t.Fatalf("Unmarshal(%s): %v", data, err)
+	}
+	if !roundTrip.IsZero() || roundTrip.String() != "0" {
+		t.Errorf("zero rate round trip = %s, want 0", roundTrip)
+	}
+
+	value, err := rate.NumericValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scanned Rate
+	if err := scanned.ScanNumeric(value); err != nil {
+		t.Fatal(err)
+	}
+	if scanned.Rat().Cmp(rate.Rat()) != 0 {
+		t.Errorf("numeric round trip = %s, want %s", scanned, rate)
+	}
+	if _, err := (Rate{}).NumericValue(); !errors.Is(err, ErrInvalidRate) {
+		t.Errorf("NumericValue of zero Rate error = %v, want %v", err, ErrInvalidRate)
+	}
+}
@@ -0,0 +1,176 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RateScale is the number of decimal places kept when a rate is stored,
+// matching numeric(_,10) columns.
+const RateScale = 10
+
+// DefaultCurrency is the currency of prices that do not name one.
+const DefaultCurrency = "IDR"
+
+var ErrInvalidRate = errors.New("invalid rate")
+
+// Rate is an exact rate. As an exchange rate, one unit of the source
+// currency buys Rate units of the target currency, and it must be positive.
+// As a tax rate it is a fraction of the net price, e.g. 0.11 for 11%, and may
+// be zero. The zero value is not a valid exchange rate.
+type Rate struct {
+	r *big.Rat
+}
+
+// OneRate is the identity rate used when both currencies are the same.
+func OneRate() Rate {
+	return Rate{r: big.NewRat(1, 1)}
+}
+
+// ParseRate reads a positive decimal string such as "16250.5" or "0.0000615".
+func ParseRate(s string) (Rate, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+	return Rate{r: r}, nil
+}
+
+// ParseNonNegativeRate reads a decimal string that may be zero, such as a tax
+// rate of "0.11" or "0".
+func ParseNonNegativeRate(s string) (Rate, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() < 0 {
+		return Rate{}, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+	return Rate{r: r}, nil
+}
+
+func (r Rate) IsValid() bool {
+	return r.r != nil && r.r.Sign() > 0
+}
+
+func (r Rate) IsZero() bool {
+	return r.r == nil || r.r.Sign() == 0
+}
+
+// Rat returns the rate as a fraction. The zero value gives 0.
+func (r Rate) Rat() *big.Rat {
+	if r.r == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(r.r)
+}
+
+// Inverse returns the rate for converting in the opposite direction.
+func (r Rate) Inverse() Rate {
+	return Rate{r: new(big.Rat).Inv(r.r)}
+}
+
+// Div returns r / other, e.g. the USD->IDR rate from USD->EUR and IDR->EUR.
+func (r Rate) Div(other Rate) Rate {
+	return Rate{r: new(big.Rat).Quo(r.r, other.r)}
+}
+
+// Round returns the rate rounded to RateScale decimal places, i.e. exactly the
+// value a numeric(_,10) column will store.
+func (r Rate) Round() Rate {
+	return r.RoundTo(RateScale)
+}
+
+// RoundTo returns the rate rounded to places decimal places, for columns that
+// keep fewer than RateScale.
+func (r Rate) RoundTo(places int) Rate {
+	rounded, _ := new(big.Rat).SetString(r.Rat().FloatString(places))
+	return Rate{r: rounded}
+}
+
+// Convert applies the rate to an amount, rounding half away from zero to the
+// nearest minor unit.
+func (r Rate) Convert(a Amount) Amount {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(a)), r.r)
+	num, den := product.Num(), product.Denom()
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(den) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+	}
+	return Amount(quotient.Int64())
+}
+
+// String formats the rate with up to RateScale decimal places.
+func (r Rate) String() string {
+	if r.r == nil {
+		return "0"
+	}
+	s := r.r.FloatString(RateScale)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// Percent formats the rate as a percentage, e.g. "11%" for 0.11.
+func (r Rate) Percent() string {
+	return Rate{r: new(big.Rat).Mul(r.Rat(), big.NewRat(100, 1))}.String() + "%"
+}
+
+// MarshalJSON encodes the rate as a JSON string so no precision is lost.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(r.String())), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string. Zero is accepted
+// so that tax-exempt rates, which marshal to "0", read back; exchange rates
+// must be checked with IsValid.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParseNonNegativeRate(text)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner.
+func (r *Rate) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid || n.NaN || n.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("%w: NULL or non-finite numeric", ErrInvalidRate)
+	}
+	value := new(big.Rat).SetInt(n.Int)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs32(n.Exp))), nil))
+	if n.Exp >= 0 {
+		value.Mul(value, scale)
+	} else {
+		value.Quo(value, scale)
+	}
+	*r = Rate{r: value}
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer, rounding to RateScale places.
+func (r Rate) NumericValue() (pgtype.Numeric, error) {
+	if r.r == nil {
+		return pgtype.Numeric{}, ErrInvalidRate
+	}
+	var n pgtype.Numeric
+	if err := n.Scan(r.r.FloatString(RateScale)); err != nil {
+		return pgtype.Numeric{}, err
+	}
+	return n, nil
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
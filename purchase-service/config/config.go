@@ -3,7 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +14,73 @@ type Config struct {
 	DBUrl     string
 	AppPort	  string
 	JWTSecret string
+
+	// DefaultCurrency is charged when a purchase does not ask for a currency
+	// and its items are priced in more than one.
+	DefaultCurrency string
+	// ExchangeRateSource selects the ExchangeRateProvider: "db" or "file".
+	ExchangeRateSource string
+	ExchangeRateFile   string
+	// TaxRuleSource selects the tax RuleProvider: "db" or "file".
+	// TaxPricingMode says whether catalog prices include tax ("inclusive")
+	// or have it added ("exclusive"); TaxRounding rounds tax per "line" or
+	// per "invoice".
+	TaxRuleSource  string
+	TaxRuleFile    string
+	TaxPricingMode string
+	TaxRounding    string
+	// ShippingMethodSource selects the shipping MethodProvider: "db" or
+	// "file".
+	ShippingMethodSource string
+	ShippingMethodFile   string
+
+	// SagaRecoveryInterval is how often unfinished purchase sagas are
+	// checked; SagaStaleAfter is how long one may go without progress before
+	// it is compensated.
+	SagaRecoveryInterval time.Duration
+	SagaStaleAfter       time.Duration
+	// CancellationWindow is how long after a purchase its owner may cancel it.
+	CancellationWindow time.Duration
+	// IdempotencyKeyTTL is how long an Idempotency-Key is remembered.
+	IdempotencyKeyTTL time.Duration
+
+	// PaymentGateway names the payment gateway purchases are charged
+	// through; only "fake" exists. It sends its webhooks, signed with
+	// PaymentWebhookSecret, to PaymentWebhookURL. Payments left unsettled
+	// for PaymentStaleAfter are reconciled every PaymentReconcileInterval.
+	PaymentGateway           string
+	PaymentWebhookURL        string
+	PaymentWebhookSecret     string
+	PaymentReconcileInterval time.Duration
+	PaymentStaleAfter        time.Duration
+
+	// ItemServiceURL is the base URL of item-service's API. Each call to it
+	// gives up after ItemServiceTimeout; reads are retried up to
+	// ItemServiceMaxRetries times, starting ItemServiceRetryBackoff apart.
+	ItemServiceURL          string
+	ItemServiceTimeout      time.Duration
+	ItemServiceMaxRetries   int
+	ItemServiceRetryBackoff time.Duration
+	// After ItemServiceBreakerThreshold consecutive failures, calls to
+	// item-service fail fast for ItemServiceBreakerCooldown.
+	ItemServiceBreakerThreshold int
+	ItemServiceBreakerCooldown  time.Duration
+
+	// UserServiceURL is the base URL of user-service's API, where shipping
+	// addresses are read; each call gives up after UserServiceTimeout.
+	UserServiceURL     string
+	UserServiceTimeout time.Duration
+
+	// The seller named on invoices. InvoiceSellerAddress is one line with
+	// "|" between the lines it is printed on.
+	InvoiceSellerName    string
+	InvoiceSellerAddress string
+	InvoiceSellerEmail   string
+	InvoiceSellerTaxID   string
+
+	// AnalyticsRefreshInterval is how often the sales report summaries are
+	// brought up to date.
+	AnalyticsRefreshInterval time.Duration
 }
 
 var (
@@ -35,6 +104,45 @@ func LoadConfig() *Config {
 			DBUrl:     getEnv("DB_URL"),
 			AppPort:   getEnv("APP_PORT"),
 			JWTSecret: getEnv("JWT_SECRET"),
+
+			DefaultCurrency:    getEnvOrDefault("DEFAULT_CURRENCY", "IDR"),
+			ExchangeRateSource: getEnvOrDefault("EXCHANGE_RATE_SOURCE", "db"),
+			ExchangeRateFile:   getEnvOrDefault("EXCHANGE_RATE_FILE", "config/exchange_rates.json"),
+			TaxRuleSource:      getEnvOrDefault("TAX_RULE_SOURCE", "db"),
+			TaxRuleFile:        getEnvOrDefault("TAX_RULE_FILE", "config/tax_rules.json"),
+			TaxPricingMode:     getEnvOrDefault("TAX_PRICING_MODE", "inclusive"),
+			TaxRounding:        getEnvOrDefault("TAX_ROUNDING", "line"),
+
+			ShippingMethodSource: getEnvOrDefault("SHIPPING_METHOD_SOURCE", "db"),
+			ShippingMethodFile:   getEnvOrDefault("SHIPPING_METHOD_FILE", "config/shipping_methods.json"),
+
+			SagaRecoveryInterval: getDurationOrDefault("SAGA_RECOVERY_INTERVAL", 30*time.Second),
+			SagaStaleAfter:       getDurationOrDefault("SAGA_STALE_AFTER", 2*time.Minute),
+			CancellationWindow:   getDurationOrDefault("CANCELLATION_WINDOW", time.Hour),
+			IdempotencyKeyTTL:    getDurationOrDefault("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+
+			PaymentGateway:           getEnvOrDefault("PAYMENT_GATEWAY", "fake"),
+			PaymentWebhookURL:        getEnvOrDefault("PAYMENT_WEBHOOK_URL", ""),
+			PaymentWebhookSecret:     getEnvOrDefault("PAYMENT_WEBHOOK_SECRET", ""),
+			PaymentReconcileInterval: getDurationOrDefault("PAYMENT_RECONCILE_INTERVAL", time.Minute),
+			PaymentStaleAfter:        getDurationOrDefault("PAYMENT_STALE_AFTER", 5*time.Minute),
+
+			ItemServiceURL:              getEnvOrDefault("ITEM_SERVICE_URL", "http://item-service:5001/api/v1"),
+			ItemServiceTimeout:          getDurationOrDefault("ITEM_SERVICE_TIMEOUT", 5*time.Second),
+			ItemServiceMaxRetries:       getIntOrDefault("ITEM_SERVICE_MAX_RETRIES", 2),
+			ItemServiceRetryBackoff:     getDurationOrDefault("ITEM_SERVICE_RETRY_BACKOFF", 100*time.Millisecond),
+			ItemServiceBreakerThreshold: getIntOrDefault("ITEM_SERVICE_BREAKER_THRESHOLD", 5),
+			ItemServiceBreakerCooldown:  getDurationOrDefault("ITEM_SERVICE_BREAKER_COOLDOWN", 30*time.Second),
+
+			UserServiceURL:     getEnvOrDefault("USER_SERVICE_URL", "http://user-service:5000/api/v1"),
+			UserServiceTimeout: getDurationOrDefault("USER_SERVICE_TIMEOUT", 5*time.Second),
+
+			InvoiceSellerName:    getEnvOrDefault("INVOICE_SELLER_NAME", "shop-crud"),
+			InvoiceSellerAddress: getEnvOrDefault("INVOICE_SELLER_ADDRESS", ""),
+			InvoiceSellerEmail:   getEnvOrDefault("INVOICE_SELLER_EMAIL", ""),
+			InvoiceSellerTaxID:   getEnvOrDefault("INVOICE_SELLER_TAX_ID", ""),
+
+			AnalyticsRefreshInterval: getDurationOrDefault("ANALYTICS_REFRESH_INTERVAL", 5*time.Minute),
 		}
 	})
 	return config
@@ -53,3 +161,37 @@ func getEnv(key string) string {
 	}
 	return value
 }
+
+// getEnvOrDefault retrieves an optional environment variable, falling back to a default.
+func getEnvOrDefault(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getIntOrDefault reads an optional non-negative integer.
+func getIntOrDefault(key string, fallback int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Fatalf("Environment variable %s must be a non-negative integer, got %q", key, value)
+	}
+	return n
+}
+
+// getDurationOrDefault reads an optional duration such as "90s" or "10m".
+func getDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists || value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Fatalf("Environment variable %s must be a positive duration, got %q", key, value)
+	}
+	return duration
+}
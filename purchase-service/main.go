@@ -1,24 +1,35 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
-	"purchase-service/config" 
+	"purchase-service/config"
 
 	"purchase-service/modules/handlers"
 	"purchase-service/modules/repositories"
 	"purchase-service/modules/usecases"
 
-	authmiddle "purchase-service/middleware"
-	"purchase-service/modules/clients"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	authmiddle "purchase-service/middleware"
+	"purchase-service/modules/clients"
+	"purchase-service/modules/invoices"
+	"purchase-service/modules/payments"
+	"purchase-service/modules/rates"
+	"purchase-service/modules/shipping"
+	"purchase-service/modules/tax"
+	"shop-crud/item-service/pkg/idempotency"
 )
 
+// idempotencyPurgeInterval is how often expired Idempotency-Keys are deleted.
+const idempotencyPurgeInterval = time.Hour
+
 type CustomValidator struct {
 	validator *validator.Validate
 }
@@ -55,14 +66,91 @@ func main() {
 
 	// Init repo & usecase dengan shared DB
 	purchaseRepo := repositories.NewPurchaseRepository(config.DBPool)
-	
-	itemClient := clients.NewItemClient("http://item-service:5001/api/v1")
-	purchaseUsecase := usecases.NewPurchaseUsecase(purchaseRepo, itemClient)
+
+	cfg := config.GetConfig()
+	itemClient := clients.NewItemClient(clients.ItemClientConfig{
+		BaseURL:          cfg.ItemServiceURL,
+		JWTSecret:        jwtSecret,
+		Timeout:          cfg.ItemServiceTimeout,
+		MaxRetries:       cfg.ItemServiceMaxRetries,
+		RetryBackoff:     cfg.ItemServiceRetryBackoff,
+		BreakerThreshold: cfg.ItemServiceBreakerThreshold,
+		BreakerCooldown:  cfg.ItemServiceBreakerCooldown,
+	})
+	rateProvider, err := rates.NewProvider(cfg.ExchangeRateSource, cfg.ExchangeRateFile, config.DBPool)
+	if err != nil {
+		log.Fatalf("❌ Gagal menyiapkan exchange rate provider: %v", err)
+	}
+	taxProvider, err := tax.NewProvider(cfg.TaxRuleSource, cfg.TaxRuleFile, config.DBPool, cfg.TaxPricingMode, cfg.TaxRounding)
+	if err != nil {
+		log.Fatalf("❌ Gagal menyiapkan tax rule provider: %v", err)
+	}
+	shippingProvider, err := shipping.NewProvider(cfg.ShippingMethodSource, cfg.ShippingMethodFile, config.DBPool)
+	if err != nil {
+		log.Fatalf("❌ Gagal menyiapkan shipping method provider: %v", err)
+	}
+	userClient := clients.NewUserClient(cfg.UserServiceURL, jwtSecret, cfg.UserServiceTimeout)
+	sagaRepo := repositories.NewSagaRepository(config.DBPool)
+	purchaseSaga := usecases.NewPurchaseSaga(sagaRepo, purchaseRepo, itemClient)
+	cancellationRepo := repositories.NewCancellationRepository(config.DBPool)
+	returnRepo := repositories.NewReturnRepository(config.DBPool)
+	promotionRepo := repositories.NewPromotionRepository(config.DBPool)
+	gateway, err := payments.NewGateway(cfg.PaymentGateway, cfg.PaymentWebhookURL, cfg.PaymentWebhookSecret)
+	if err != nil {
+		log.Fatalf("❌ Gagal menyiapkan payment gateway: %v", err)
+	}
+	paymentRepo := repositories.NewPaymentRepository(config.DBPool)
+	refundRepo := repositories.NewRefundRepository(config.DBPool)
+	paymentUsecase := usecases.NewPaymentUsecase(paymentRepo, refundRepo, purchaseRepo, sagaRepo, purchaseSaga, gateway, cfg.PaymentGateway, cfg.PaymentWebhookSecret)
+	purchaseUsecase := usecases.NewPurchaseUsecase(purchaseRepo, cancellationRepo, returnRepo, promotionRepo, itemClient, userClient, purchaseSaga, paymentUsecase, rateProvider, taxProvider, shippingProvider, cfg.DefaultCurrency)
+	cancellationUsecase := usecases.NewCancellationUsecase(purchaseRepo, cancellationRepo, itemClient, paymentUsecase, cfg.CancellationWindow)
+	returnUsecase := usecases.NewReturnUsecase(purchaseRepo, returnRepo, itemClient, paymentUsecase)
+	cartRepo := repositories.NewCartRepository(config.DBPool)
+	cartUsecase := usecases.NewCartUsecase(cartRepo, itemClient, purchaseUsecase)
+	promotionUsecase := usecases.NewPromotionUsecase(promotionRepo)
+	invoiceUsecase := usecases.NewInvoiceUsecase(purchaseUsecase, invoices.Party{
+		Name:    cfg.InvoiceSellerName,
+		Address: invoices.AddressLines(cfg.InvoiceSellerAddress),
+		Email:   cfg.InvoiceSellerEmail,
+		TaxID:   cfg.InvoiceSellerTaxID,
+	})
+	reportRepo := repositories.NewReportRepository(config.DBPool)
+	reportUsecase := usecases.NewReportUsecase(reportRepo, cfg.DefaultCurrency)
 
 	// Handler
 	purchaseHandler := handlers.NewPurchaseHandler(purchaseUsecase)
-	purchaseHandler.RegisterRoutes(v1, authmiddle.JWTAuthMiddleware(jwtSecret))
+	idempotencyStore := idempotency.NewPostgresStore(config.DBPool)
+	idempotencyMiddleware := idempotency.Middleware(idempotency.Config{
+		Store: idempotencyStore,
+		TTL:   cfg.IdempotencyKeyTTL,
+		Scope: authmiddle.SubjectFromContext,
+	})
+	authMiddleware := authmiddle.JWTAuthMiddleware(jwtSecret)
+	purchaseHandler.RegisterRoutes(v1, authMiddleware, idempotencyMiddleware)
+	cancellationHandler := handlers.NewCancellationHandler(cancellationUsecase)
+	cancellationHandler.RegisterRoutes(v1, authMiddleware, idempotencyMiddleware)
+	returnHandler := handlers.NewReturnHandler(returnUsecase)
+	returnHandler.RegisterRoutes(v1, authMiddleware, idempotencyMiddleware)
+	cartHandler := handlers.NewCartHandler(cartUsecase)
+	cartHandler.RegisterRoutes(v1, authMiddleware, authmiddle.OptionalJWTAuthMiddleware(jwtSecret), idempotencyMiddleware)
+	promotionHandler := handlers.NewPromotionHandler(promotionUsecase)
+	promotionHandler.RegisterRoutes(v1, authMiddleware)
+	paymentHandler := handlers.NewPaymentHandler(paymentUsecase)
+	paymentHandler.RegisterRoutes(v1, authMiddleware)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceUsecase)
+	invoiceHandler.RegisterRoutes(v1, authMiddleware)
+	reportHandler := handlers.NewReportHandler(reportUsecase)
+	reportHandler.RegisterRoutes(v1, authMiddleware)
 
+	// Give back stock taken by purchases whose saga never finished.
+	recoveryCtx, stopRecovery := context.WithCancel(context.Background())
+	defer stopRecovery()
+	go usecases.RunSagaRecovery(recoveryCtx, purchaseSaga, cfg.SagaRecoveryInterval, cfg.SagaStaleAfter)
+	go usecases.RunCancellationRestock(recoveryCtx, cancellationUsecase, cfg.SagaRecoveryInterval, cfg.SagaStaleAfter)
+	go usecases.RunReturnRestock(recoveryCtx, returnUsecase, cfg.SagaRecoveryInterval, cfg.SagaStaleAfter)
+	go usecases.RunPaymentReconciliation(recoveryCtx, paymentUsecase, cfg.PaymentReconcileInterval, cfg.PaymentStaleAfter)
+	go idempotency.RunPurge(recoveryCtx, idempotencyStore, idempotencyPurgeInterval)
+	go usecases.RunReportRefresh(recoveryCtx, reportUsecase, cfg.AnalyticsRefreshInterval)
 
 	// Start server
 	addr := fmt.Sprintf(":%s", appPort)
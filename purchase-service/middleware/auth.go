@@ -4,6 +4,7 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -18,6 +19,28 @@ var (
 	ErrInvalidJWT = echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired JWT")
 )
 
+// Roles a user can hold, carried in the token's "role" claim.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+// Permissions checked by this service.
+const (
+	// PermManageOrders allows seeing and cancelling anyone's purchases,
+	// moving them through their statuses, and reviewing returns.
+	PermManageOrders     = "orders:manage"
+	PermManagePromotions = "promotions:manage"
+	PermReadReports      = "reports:read"
+)
+
+// rolePermissions lists what each role may do in this service.
+var rolePermissions = map[string][]string{
+	RoleStaff: {PermManageOrders},
+	RoleAdmin: {PermManageOrders, PermManagePromotions, PermReadReports},
+}
+
 // JWTAuthMiddleware returns an Echo middleware that validates JWT tokens.
 func JWTAuthMiddleware(jwtSecret string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -71,3 +94,82 @@ func GetUserFromContext(c echo.Context) (jwt.MapClaims, bool) {
 	claims, ok := user.(jwt.MapClaims)
 	return claims, ok
 }
+
+// SubjectFromContext returns the "sub" claim of the authenticated user, or an
+// empty string when the request is not authenticated.
+func SubjectFromContext(c echo.Context) string {
+	claims, ok := GetUserFromContext(c)
+	if !ok {
+		return ""
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// RoleFromContext returns the role claim of the authenticated user. Tokens
+// without one, such as those issued before roles existed, act as a customer.
+// It returns an empty string when the request is not authenticated.
+func RoleFromContext(c echo.Context) string {
+	claims, ok := GetUserFromContext(c)
+	if !ok {
+		return ""
+	}
+	role, _ := claims["role"].(string)
+	if role == "" {
+		return RoleCustomer
+	}
+	return role
+}
+
+// HasPermission reports whether the authenticated user's role grants
+// permission in this service.
+func HasPermission(c echo.Context, permission string) bool {
+	return slices.Contains(rolePermissions[RoleFromContext(c)], permission)
+}
+
+// RequireRole returns a middleware that lets a request through only when the
+// authenticated user has one of roles. It must run after JWTAuthMiddleware.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !slices.Contains(roles, RoleFromContext(c)) {
+				return forbidden(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// forbidden refuses a request the caller's role does not allow.
+func forbidden(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+}
+
+// RequirePermission returns a middleware that lets a request through only
+// when the authenticated user's role grants permission. It must run after
+// JWTAuthMiddleware.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !HasPermission(c, permission) {
+				return forbidden(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// OptionalJWTAuthMiddleware validates a token like JWTAuthMiddleware when the
+// request carries one, and lets requests without one through anonymously.
+func OptionalJWTAuthMiddleware(jwtSecret string) echo.MiddlewareFunc {
+	required := JWTAuthMiddleware(jwtSecret)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		authenticated := required(next)
+		return func(c echo.Context) error {
+			if c.Request().Header.Get("Authorization") == "" {
+				return next(c)
+			}
+			return authenticated(c)
+		}
+	}
+}
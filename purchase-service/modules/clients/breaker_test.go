@@ -0,0 +1,128 @@
+package clients
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testBreaker returns a breaker whose clock only moves when the test says so.
+func testBreaker(threshold int, cooldown time.Duration) (*circuitBreaker, func(time.Duration)) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(threshold, cooldown)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b, _ := testBreaker(3, time.Minute)
+	for i := 0; i < 2; i++ {
+		if !b.allow() {
+			t.Fatalf("call %d refused before the threshold", i+1)
+		}
+		b.failure()
+	}
+	if !b.allow() {
+		t.Fatal("third call refused before it failed")
+	}
+	b.failure()
+	if b.allow() {
+		t.Fatal("breaker let a call through after 3 consecutive failures")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b, _ := testBreaker(2, time.Minute)
+	b.allow()
+	b.failure()
+	b.allow()
+	b.success()
+	b.allow()
+	b.failure()
+	if !b.allow() {
+		t.Fatal("breaker opened on failures that were not consecutive")
+	}
+}
+
+func TestBreakerHalfOpenLetsOneProbeThrough(t *testing.T) {
+	b, advance := testBreaker(1, time.Minute)
+	b.allow()
+	b.failure()
+
+	advance(59 * time.Second)
+	if b.allow() {
+		t.Fatal("breaker let a call through during its cooldown")
+	}
+	advance(time.Second)
+
+	// However many callers race for it, only one probe goes out.
+	var allowed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.allow() {
+				atomic.AddInt32(&allowed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 1 {
+		t.Fatalf("%d probes let through, want 1", allowed)
+	}
+
+	b.success()
+	if !b.allow() {
+		t.Fatal("breaker stayed open after a successful probe")
+	}
+}
+
+func TestBreakerFailedProbeReopens(t *testing.T) {
+	b, advance := testBreaker(5, time.Minute)
+	for i := 0; i < 5; i++ {
+		b.allow()
+		b.failure()
+	}
+	advance(time.Minute)
+	if !b.allow() {
+		t.Fatal("no probe after the cooldown")
+	}
+	// A single failed probe is enough, whatever the threshold.
+	b.failure()
+
+	advance(59 * time.Second)
+	if b.allow() {
+		t.Fatal("breaker let a call through right after a failed probe")
+	}
+	advance(time.Second)
+	if !b.allow() {
+		t.Fatal("no new probe after another cooldown")
+	}
+}
+
+func TestBreakerAbandonedProbeIsRetried(t *testing.T) {
+	b, advance := testBreaker(1, time.Minute)
+	b.allow()
+	b.failure()
+	advance(time.Minute)
+
+	if !b.allow() {
+		t.Fatal("no probe after the cooldown")
+	}
+	b.abandon()
+	if !b.allow() {
+		t.Fatal("next call was not let through to replace the abandoned probe")
+	}
+}
+
+func TestBreakerWithoutThresholdNeverOpens(t *testing.T) {
+	b, _ := testBreaker(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		if !b.allow() {
+			t.Fatalf("call %d refused by a breaker without threshold", i+1)
+		}
+		b.failure()
+	}
+}
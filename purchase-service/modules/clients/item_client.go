@@ -1,65 +1,455 @@
 package clients
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"shop-crud/item-service/pkg/money"
 )
 
+// serviceSubject is the JWT subject purchase-service acts as when it calls
+// item-service on its own behalf, with the role item-service lets send stock
+// commands.
+const (
+	serviceSubject = "purchase-service"
+	serviceRole    = "service"
+)
+
+var (
+	ErrItemNotFound        = errors.New("item not found")
+	ErrInsufficientStock   = errors.New("insufficient stock")
+	ErrStockCommandClosed  = errors.New("stock command was already restored or cancelled, or its reservation ended")
+	ErrReservationNotFound = errors.New("reservation not found")
+	ErrReservationExpired  = errors.New("reservation has expired")
+	ErrReservationInactive = errors.New("reservation is no longer active")
+	// ErrCircuitOpen is returned without calling item-service while it is
+	// considered down.
+	ErrCircuitOpen = errors.New("item-service is unavailable: circuit breaker open")
+)
+
+// StatusError reports a response from item-service that the calling
+// operation does not expect.
+type StatusError struct {
+	Op         string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("item-service %s: unexpected status %d", e.Op, e.StatusCode)
+}
+
+// Temporary reports whether the same request may succeed later.
+func (e *StatusError) Temporary() bool {
+	return retryableStatus(e.StatusCode)
+}
+
 type ItemResponse struct {
-	ID    uuid.UUID `json:"id"`
-	Name  string    `json:"name"`
-	Price float64   `json:"price"`
-	Stock int       `json:"stock"`
+	ID       uuid.UUID         `json:"id"`
+	Name     string            `json:"name"`
+	Price    money.Amount      `json:"price"`
+	Currency string            `json:"currency"`
+	Stock    int               `json:"stock"`
+	Variants []VariantResponse `json:"variants"`
+	// CategoryIDs holds the item's categories and all their ancestors.
+	CategoryIDs []uuid.UUID `json:"category_ids"`
+	// TaxClass picks the item's tax rate; empty means tax.DefaultClass.
+	TaxClass string `json:"tax_class"`
+	// WeightGrams is the shipping weight of one unit.
+	WeightGrams int `json:"weight_grams"`
+}
+
+// VariantResponse is a variant of an item. A nil Price means the variant is
+// sold at the item's price.
+type VariantResponse struct {
+	ID      uuid.UUID         `json:"id"`
+	SKU     string            `json:"sku"`
+	Options map[string]string `json:"options"`
+	Price   *money.Amount     `json:"price"`
+	Stock   int               `json:"stock"`
+}
+
+// FindVariant returns the variant with the given ID, or nil if the item has none.
+func (i *ItemResponse) FindVariant(id uuid.UUID) *VariantResponse {
+	for idx := range i.Variants {
+		if i.Variants[idx].ID == id {
+			return &i.Variants[idx]
+		}
+	}
+	return nil
+}
+
+// ReservationLine is one item, or variant of an item, to hold stock for.
+type ReservationLine struct {
+	ItemID    uuid.UUID  `json:"item_id"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity  int        `json:"quantity"`
+}
+
+type ReservationResponse struct {
+	ID        uuid.UUID         `json:"id"`
+	Status    string            `json:"status"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Items     []ReservationLine `json:"items"`
+}
+
+// StockCommand asks item-service to take stock. Key identifies the command so
+// that retries never take stock twice and the decrement can be compensated.
+// With a ReservationID the units are drawn from that reservation's hold.
+type StockCommand struct {
+	Key           string     `json:"key"`
+	ItemID        uuid.UUID  `json:"item_id"`
+	VariantID     *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity      int        `json:"quantity"`
+	ReferenceType string     `json:"reference_type,omitempty"`
+	ReferenceID   *uuid.UUID `json:"reference_id,omitempty"`
+	ReservationID *uuid.UUID `json:"reservation_id,omitempty"`
+}
+
+type StockLine struct {
+	ItemID    uuid.UUID  `json:"item_id"`
+	VariantID *uuid.UUID `json:"variant_id,omitempty"`
+	Quantity  int        `json:"quantity"`
+}
+
+// StockIncrement asks item-service to give stock back for several lines in
+// one transaction. Retries with the same Key never add stock twice.
+type StockIncrement struct {
+	Key           string      `json:"key"`
+	Lines         []StockLine `json:"lines"`
+	ReferenceType string      `json:"reference_type,omitempty"`
+	ReferenceID   *uuid.UUID  `json:"reference_id,omitempty"`
 }
 
 type ItemClient interface {
 	GetItemByID(ctx context.Context, itemID uuid.UUID) (*ItemResponse, error)
+	GetItemsByIDs(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID]*ItemResponse, error)
+	ReserveStock(ctx context.Context, lines []ReservationLine) (*ReservationResponse, error)
+	ConfirmReservation(ctx context.Context, reservationID uuid.UUID, referenceType string, referenceID uuid.UUID) error
+	ReleaseReservation(ctx context.Context, reservationID uuid.UUID) error
+	DecrementStock(ctx context.Context, command StockCommand) error
+	RestoreStock(ctx context.Context, key string) error
+	IncrementStock(ctx context.Context, increment StockIncrement) error
+}
+
+// ItemClientConfig configures how purchase-service reaches item-service.
+type ItemClientConfig struct {
+	BaseURL   string
+	JWTSecret string
+	// Timeout bounds each attempt of a call.
+	Timeout time.Duration
+	// MaxRetries is how many times a read is retried after a network error
+	// or a 5xx response, waiting RetryBackoff, then twice as long, and so on.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// After BreakerThreshold consecutive failures, calls fail fast with
+	// ErrCircuitOpen for BreakerCooldown before a single probe is let through.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
 }
 
+// maxRetryBackoff caps the wait between retries, however many there are.
+const maxRetryBackoff = 30 * time.Second
+
 type itemClient struct {
-	baseURL string
-	client  *http.Client
+	baseURL      string
+	jwtSecret    string
+	client       *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	breaker      *circuitBreaker
 }
 
-func NewItemClient(baseURL string) ItemClient {
+// NewItemClient returns a client for item-service. Negative retry settings
+// are treated as zero.
+func NewItemClient(cfg ItemClientConfig) ItemClient {
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff < 0 {
+		cfg.RetryBackoff = 0
+	}
 	return &itemClient{
-		baseURL: baseURL,
+		baseURL:   strings.TrimSuffix(cfg.BaseURL, "/"),
+		jwtSecret: cfg.JWTSecret,
 		client: &http.Client{
-			Timeout:   5 * time.Second,
-			Transport: otelhttp.NewTransport(http.DefaultTransport), 
+			Timeout:   cfg.Timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
 		},
+		maxRetries:   cfg.MaxRetries,
+		retryBackoff: cfg.RetryBackoff,
+		breaker:      newCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
 	}
 }
 
 func (c *itemClient) GetItemByID(ctx context.Context, itemID uuid.UUID) (*ItemResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/items/%s", c.baseURL, itemID), nil)
+	var item ItemResponse
+	status, err := c.send(ctx, http.MethodGet, "/items/"+itemID.String(), nil, &item, true)
 	if err != nil {
 		return nil, err
 	}
+	switch status {
+	case http.StatusOK:
+		return &item, nil
+	case http.StatusNotFound:
+		return nil, ErrItemNotFound
+	}
+	return nil, &StatusError{Op: "get item", StatusCode: status}
+}
 
-	resp, err := c.client.Do(req)
+// maxBatchItems is the most IDs item-service accepts in one batch lookup.
+const maxBatchItems = 100
+
+// GetItemsByIDs looks items up in batches. Items that do not exist are left
+// out of the result rather than failing the lookup.
+func (c *itemClient) GetItemsByIDs(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID]*ItemResponse, error) {
+	items := make(map[uuid.UUID]*ItemResponse, len(itemIDs))
+	for start := 0; start < len(itemIDs); start += maxBatchItems {
+		end := start + maxBatchItems
+		if end > len(itemIDs) {
+			end = len(itemIDs)
+		}
+
+		var batch struct {
+			Data []ItemResponse `json:"data"`
+		}
+		// The lookup only reads, so it is retried like a GET.
+		status, err := c.send(ctx, http.MethodPost, "/items/batch", map[string][]uuid.UUID{"ids": itemIDs[start:end]}, &batch, true)
+		if err != nil {
+			return nil, err
+		}
+		if status != http.StatusOK {
+			return nil, &StatusError{Op: "get items", StatusCode: status}
+		}
+		for i := range batch.Data {
+			items[batch.Data[i].ID] = &batch.Data[i]
+		}
+	}
+	return items, nil
+}
+
+// ReserveStock holds stock for every line at once. It fails with
+// ErrInsufficientStock, without holding anything, when a line cannot be met.
+func (c *itemClient) ReserveStock(ctx context.Context, lines []ReservationLine) (*ReservationResponse, error) {
+	var reservation ReservationResponse
+	status, err := c.send(ctx, http.MethodPost, "/reservations", map[string][]ReservationLine{"items": lines}, &reservation, false)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	switch status {
+	case http.StatusCreated:
+		return &reservation, nil
+	case http.StatusNotFound:
+		return nil, ErrItemNotFound
+	case http.StatusConflict:
+		return nil, ErrInsufficientStock
+	}
+	return nil, &StatusError{Op: "reserve stock", StatusCode: status}
+}
+
+// ConfirmReservation makes a reservation final, recording what it was used
+// for. Confirming an already confirmed reservation succeeds.
+func (c *itemClient) ConfirmReservation(ctx context.Context, reservationID uuid.UUID, referenceType string, referenceID uuid.UUID) error {
+	body := map[string]interface{}{"reference_type": referenceType, "reference_id": referenceID}
+	status, err := c.send(ctx, http.MethodPost, "/reservations/"+reservationID.String()+"/confirm", body, nil, false)
+	if err != nil {
+		return err
+	}
+	return reservationStatusError(status, "confirm reservation")
+}
+
+// ReleaseReservation gives held stock back. Releasing a reservation that is
+// already released or expired succeeds; releasing a confirmed one fails with
+// ErrReservationInactive.
+func (c *itemClient) ReleaseReservation(ctx context.Context, reservationID uuid.UUID) error {
+	status, err := c.send(ctx, http.MethodPost, "/reservations/"+reservationID.String()+"/release", nil, nil, false)
+	if err != nil {
+		return err
+	}
+	return reservationStatusError(status, "release reservation")
+}
+
+func reservationStatusError(status int, op string) error {
+	switch status {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrReservationNotFound
+	case http.StatusGone:
+		return ErrReservationExpired
+	case http.StatusConflict:
+		return ErrReservationInactive
+	}
+	return &StatusError{Op: op, StatusCode: status}
+}
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("item not found")
+// DecrementStock takes stock through item-service. Sending a command with a
+// key that was already applied succeeds without taking stock again. A command
+// drawing from a reservation that no longer holds its units fails with
+// ErrInsufficientStock, or ErrStockCommandClosed when the hold has ended.
+func (c *itemClient) DecrementStock(ctx context.Context, command StockCommand) error {
+	status, err := c.send(ctx, http.MethodPost, "/stock-commands/decrement", command, nil, false)
+	if err != nil {
+		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get item: %s", resp.Status)
+	switch status {
+	case http.StatusCreated, http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrItemNotFound
+	case http.StatusConflict:
+		return ErrInsufficientStock
+	case http.StatusGone:
+		return ErrStockCommandClosed
 	}
+	return &StatusError{Op: "decrement stock", StatusCode: status}
+}
 
-	var item ItemResponse
-	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
-		return nil, err
+// RestoreStock compensates the decrement with the given key. It succeeds when
+// the decrement was never applied or was already restored.
+func (c *itemClient) RestoreStock(ctx context.Context, key string) error {
+	status, err := c.send(ctx, http.MethodPost, "/stock-commands/"+url.PathEscape(key)+"/restore", nil, nil, false)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return &StatusError{Op: "restore stock", StatusCode: status}
 	}
+	return nil
+}
+
+// IncrementStock gives stock back through item-service. Sending an increment
+// with a key that was already applied succeeds without adding stock again.
+func (c *itemClient) IncrementStock(ctx context.Context, increment StockIncrement) error {
+	status, err := c.send(ctx, http.MethodPost, "/stock-commands/increment", increment, nil, false)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusCreated && status != http.StatusOK {
+		return &StatusError{Op: "increment stock", StatusCode: status}
+	}
+	return nil
+}
 
-	return &item, nil
+// send calls an item-service endpoint, with body encoded as JSON when it is
+// not nil, and decodes a successful response into out when it is not nil. It
+// returns the response status code. Reads (idempotent) are retried with
+// exponential backoff after network errors and 5xx responses; commands are
+// sent once and left to their callers to retry. Every call goes through the
+// circuit breaker.
+func (c *itemClient) send(ctx context.Context, method, path string, body, out interface{}, idempotent bool) (int, error) {
+	var encoded []byte
+	if body != nil {
+		var err error
+		if encoded, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+
+	attempts := 1
+	if idempotent {
+		attempts += c.maxRetries
+	}
+	backoff := c.retryBackoff
+	for attempt := 1; ; attempt++ {
+		status, err := c.attempt(ctx, method, path, encoded, out)
+		if err == nil && !retryableStatus(status) {
+			return status, nil
+		}
+		if attempt >= attempts || errors.Is(err, ErrCircuitOpen) || ctx.Err() != nil {
+			return status, err
+		}
+
+		// Full jitter keeps clients that failed together from retrying together.
+		wait := time.Duration(rand.Int63n(int64(backoff) + 1))
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes a single call through the circuit breaker.
+func (c *itemClient) attempt(ctx context.Context, method, path string, encoded []byte, out interface{}) (int, error) {
+	if !c.breaker.allow() {
+		return 0, ErrCircuitOpen
+	}
+
+	var payload io.Reader
+	if encoded != nil {
+		payload = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, payload)
+	if err != nil {
+		c.breaker.abandon()
+		return 0, err
+	}
+	if encoded != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	token, err := c.serviceToken()
+	if err != nil {
+		c.breaker.abandon()
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.breaker.abandon()
+		} else {
+			c.breaker.failure()
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if retryableStatus(resp.StatusCode) {
+		c.breaker.failure()
+		return resp.StatusCode, nil
+	}
+	c.breaker.success()
+
+	if out != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return 0, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// retryableStatus reports whether a response status means item-service could
+// not handle the request right now.
+func retryableStatus(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// serviceToken signs a short-lived token with the secret shared by all
+// services, identifying purchase-service as the caller.
+func (c *itemClient) serviceToken() (string, error) {
+	claims := jwt.MapClaims{
+		"sub":  serviceSubject,
+		"role": serviceRole,
+		"iat":  time.Now().Unix(),
+		"exp":  time.Now().Add(time.Minute).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(c.jwtSecret))
 }
@@ -0,0 +1,288 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testJWTSecret = "test-secret"
+
+// testItemService serves every request with handler and counts the requests.
+func testItemService(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func testItemClient(baseURL string, configure func(*ItemClientConfig)) ItemClient {
+	cfg := ItemClientConfig{
+		BaseURL:          baseURL,
+		JWTSecret:        testJWTSecret,
+		Timeout:          time.Second,
+		MaxRetries:       2,
+		RetryBackoff:     time.Millisecond,
+		BreakerThreshold: 100,
+		BreakerCooldown:  time.Minute,
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+	return NewItemClient(cfg)
+}
+
+func statusHandler(status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}
+}
+
+func testStockCommand() StockCommand {
+	return StockCommand{Key: "purchase:1:1", ItemID: uuid.New(), Quantity: 1}
+}
+
+func TestGetItemRetriesServerErrors(t *testing.T) {
+	itemID := uuid.New()
+	var failures int32 = 2
+	server, calls := testItemService(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "` + itemID.String() + `", "name": "Mug", "price": 12.50}`))
+	})
+
+	item, err := testItemClient(server.URL, nil).GetItemByID(context.Background(), itemID)
+	if err != nil {
+		t.Fatalf("GetItemByID: %v", err)
+	}
+	if item.ID != itemID || item.Price.String() != "12.50" {
+		t.Errorf("item = %+v", item)
+	}
+	if *calls != 3 {
+		t.Errorf("%d calls, want 3", *calls)
+	}
+}
+
+func TestGetItemGivesUpAfterMaxRetries(t *testing.T) {
+	server, calls := testItemService(t, statusHandler(http.StatusInternalServerError))
+
+	_, err := testItemClient(server.URL, nil).GetItemByID(context.Background(), uuid.New())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError || !statusErr.Temporary() {
+		t.Fatalf("err = %v, want a temporary StatusError with status 500", err)
+	}
+	if *calls != 3 {
+		t.Errorf("%d calls, want 1 plus 2 retries", *calls)
+	}
+}
+
+func TestGetItemRetriesNetworkErrors(t *testing.T) {
+	server, calls := testItemService(t, statusHandler(http.StatusOK))
+	url := server.URL
+	server.Close()
+
+	_, err := testItemClient(url, nil).GetItemByID(context.Background(), uuid.New())
+	if err == nil {
+		t.Fatal("GetItemByID succeeded against a closed server")
+	}
+	if *calls != 0 {
+		t.Errorf("%d calls reached a closed server", *calls)
+	}
+}
+
+func TestCommandsAreNotRetried(t *testing.T) {
+	server, calls := testItemService(t, statusHandler(http.StatusBadGateway))
+
+	err := testItemClient(server.URL, nil).DecrementStock(context.Background(), testStockCommand())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Op != "decrement stock" || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("err = %v, want a StatusError for decrement stock with status 502", err)
+	}
+	if *calls != 1 {
+		t.Errorf("%d calls, want 1", *calls)
+	}
+}
+
+func TestNegativeRetrySettingsAreClamped(t *testing.T) {
+	server, calls := testItemService(t, statusHandler(http.StatusInternalServerError))
+	client := testItemClient(server.URL, func(cfg *ItemClientConfig) {
+		cfg.MaxRetries = -1
+		cfg.RetryBackoff = -time.Second
+	})
+
+	if _, err := client.GetItemByID(context.Background(), uuid.New()); err == nil {
+		t.Fatal("GetItemByID succeeded against a failing server")
+	}
+	if *calls != 1 {
+		t.Errorf("%d calls, want 1", *calls)
+	}
+
+	client = testItemClient(server.URL, func(cfg *ItemClientConfig) { cfg.RetryBackoff = -time.Second })
+	if _, err := client.GetItemByID(context.Background(), uuid.New()); err == nil {
+		t.Fatal("GetItemByID succeeded against a failing server")
+	}
+}
+
+func TestStatusMapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		call   func(ItemClient) error
+		want   error
+	}{
+		{"get item not found", http.StatusNotFound, func(c ItemClient) error {
+			_, err := c.GetItemByID(context.Background(), uuid.New())
+			return err
+		}, ErrItemNotFound},
+		{"decrement applied", http.StatusCreated, func(c ItemClient) error {
+			return c.DecrementStock(context.Background(), testStockCommand())
+		}, nil},
+		{"decrement repeated", http.StatusOK, func(c ItemClient) error {
+			return c.DecrementStock(context.Background(), testStockCommand())
+		}, nil},
+		{"decrement item not found", http.StatusNotFound, func(c ItemClient) error {
+			return c.DecrementStock(context.Background(), testStockCommand())
+		}, ErrItemNotFound},
+		{"decrement out of stock", http.StatusConflict, func(c ItemClient) error {
+			return c.DecrementStock(context.Background(), testStockCommand())
+		}, ErrInsufficientStock},
+		{"decrement after restore", http.StatusGone, func(c ItemClient) error {
+			return c.DecrementStock(context.Background(), testStockCommand())
+		}, ErrStockCommandClosed},
+		{"restore", http.StatusOK, func(c ItemClient) error {
+			return c.RestoreStock(context.Background(), "purchase:1:1")
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := testItemService(t, statusHandler(tt.status))
+			err := tt.call(testItemClient(server.URL, nil))
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnexpectedStatusIsStatusError(t *testing.T) {
+	tests := []struct {
+		op   string
+		call func(ItemClient) error
+	}{
+		{"get item", func(c ItemClient) error {
+			_, err := c.GetItemByID(context.Background(), uuid.New())
+			return err
+		}},
+		{"get items", func(c ItemClient) error {
+			_, err := c.GetItemsByIDs(context.Background(), []uuid.UUID{uuid.New()})
+			return err
+		}},
+		{"restore stock", func(c ItemClient) error {
+			return c.RestoreStock(context.Background(), "purchase:1:1")
+		}},
+		{"increment stock", func(c ItemClient) error {
+			return c.IncrementStock(context.Background(), StockIncrement{Key: "cancellation:1"})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.op, func(t *testing.T) {
+			server, _ := testItemService(t, statusHandler(http.StatusTeapot))
+			err := tt.call(testItemClient(server.URL, nil))
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("err = %v, want a StatusError", err)
+			}
+			if statusErr.Op != tt.op || statusErr.StatusCode != http.StatusTeapot || statusErr.Temporary() {
+				t.Errorf("err = %+v, want a permanent StatusError for %s with status 418", statusErr, tt.op)
+			}
+		})
+	}
+}
+
+func TestCircuitOpensAfterConsecutiveFailures(t *testing.T) {
+	server, calls := testItemService(t, statusHandler(http.StatusServiceUnavailable))
+	client := testItemClient(server.URL, func(cfg *ItemClientConfig) {
+		cfg.MaxRetries = 0
+		cfg.BreakerThreshold = 2
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetItemByID(context.Background(), uuid.New()); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("call %d failed fast before the threshold", i+1)
+		}
+	}
+	_, err := client.GetItemByID(context.Background(), uuid.New())
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if *calls != 2 {
+		t.Errorf("%d calls reached item-service, want 2", *calls)
+	}
+}
+
+func TestRetriesStopAtOpenCircuit(t *testing.T) {
+	server, calls := testItemService(t, statusHandler(http.StatusServiceUnavailable))
+	client := testItemClient(server.URL, func(cfg *ItemClientConfig) {
+		cfg.MaxRetries = 5
+		cfg.BreakerThreshold = 2
+	})
+
+	_, err := client.GetItemByID(context.Background(), uuid.New())
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if *calls != 2 {
+		t.Errorf("%d calls reached item-service, want 2", *calls)
+	}
+}
+
+func TestClientErrorsDoNotOpenCircuit(t *testing.T) {
+	server, _ := testItemService(t, statusHandler(http.StatusNotFound))
+	client := testItemClient(server.URL, func(cfg *ItemClientConfig) { cfg.BreakerThreshold = 1 })
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetItemByID(context.Background(), uuid.New()); !errors.Is(err, ErrItemNotFound) {
+			t.Fatalf("call %d: err = %v, want ErrItemNotFound", i+1, err)
+		}
+	}
+}
+
+func TestRequestsCarryServiceToken(t *testing.T) {
+	var header atomic.Value
+	server, _ := testItemService(t, func(w http.ResponseWriter, r *http.Request) {
+		header.Store(r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	if err := testItemClient(server.URL, nil).DecrementStock(context.Background(), testStockCommand()); err != nil {
+		t.Fatalf("DecrementStock: %v", err)
+	}
+	raw, _ := header.Load().(string)
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(raw, "Bearer "), claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecret), nil
+	})
+	if err != nil {
+		t.Fatalf("parsing service token %q: %v", raw, err)
+	}
+	if claims["sub"] != serviceSubject || claims["role"] != serviceRole {
+		t.Errorf("claims = %v, want sub %s and role %s", claims, serviceSubject, serviceRole)
+	}
+}
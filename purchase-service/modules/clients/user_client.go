@@ -0,0 +1,103 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var (
+	ErrAddressNotFound = errors.New("address not found")
+	// ErrUserServiceDown is returned when user-service cannot be reached or
+	// fails to answer.
+	ErrUserServiceDown = errors.New("user-service is unavailable")
+)
+
+// AddressResponse is an address from a user's address book.
+type AddressResponse struct {
+	ID            uuid.UUID `json:"id"`
+	RecipientName string    `json:"recipient_name"`
+	Phone         string    `json:"phone"`
+	Line1         string    `json:"line1"`
+	Line2         string    `json:"line2"`
+	City          string    `json:"city"`
+	Province      string    `json:"province"`
+	PostalCode    string    `json:"postal_code"`
+	CountryCode   string    `json:"country_code"`
+}
+
+type UserClient interface {
+	GetAddress(ctx context.Context, userID, addressID uuid.UUID) (*AddressResponse, error)
+}
+
+type userClient struct {
+	baseURL   string
+	jwtSecret string
+	client    *http.Client
+}
+
+// NewUserClient returns a client for user-service's API at baseURL, giving
+// each call timeout.
+func NewUserClient(baseURL, jwtSecret string, timeout time.Duration) UserClient {
+	return &userClient{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		jwtSecret: jwtSecret,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+// GetAddress reads an address from the user's own address book, so an
+// address of anyone else is ErrAddressNotFound.
+func (c *userClient) GetAddress(ctx context.Context, userID, addressID uuid.UUID) (*AddressResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/users/me/addresses/"+addressID.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.userToken(userID)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUserServiceDown, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		var address AddressResponse
+		if err := json.NewDecoder(resp.Body).Decode(&address); err != nil {
+			return nil, err
+		}
+		return &address, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrAddressNotFound
+	case retryableStatus(resp.StatusCode):
+		return nil, fmt.Errorf("%w: status %d", ErrUserServiceDown, resp.StatusCode)
+	}
+	return nil, fmt.Errorf("user-service get address: unexpected status %d", resp.StatusCode)
+}
+
+// userToken signs a short-lived token with the secret shared by all services
+// that lets purchase-service act as the user.
+func (c *userClient) userToken(userID uuid.UUID) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": userID.String(),
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(c.jwtSecret))
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"purchase-service/middleware"
+	purchaseModels "purchase-service/modules/models"
+	purchaseUsecases "purchase-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type CancellationHandler struct {
+	cancellationUsecase purchaseUsecases.CancellationUsecase
+}
+
+func NewCancellationHandler(cancellationUsecase purchaseUsecases.CancellationUsecase) *CancellationHandler {
+	return &CancellationHandler{cancellationUsecase: cancellationUsecase}
+}
+
+func (h *CancellationHandler) RegisterRoutes(router *echo.Group, authMiddleware, idempotencyMiddleware echo.MiddlewareFunc) {
+	router.POST("/purchases/:id/cancel", h.CancelPurchase, authMiddleware, idempotencyMiddleware)
+}
+
+func (h *CancellationHandler) CancelPurchase(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	purchaseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid purchase ID"})
+	}
+
+	var req purchaseModels.CancelPurchaseRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	cancellation, err := h.cancellationUsecase.CancelPurchase(c.Request().Context(), purchaseID, userID, middleware.HasPermission(c, middleware.PermManageOrders), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, purchaseUsecases.ErrPurchaseNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, purchaseUsecases.ErrPurchaseItemNotFound):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, purchaseUsecases.ErrCancellationWindowClosed):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		case errors.Is(err, purchaseUsecases.ErrAlreadyCancelled), errors.Is(err, purchaseUsecases.ErrInvalidTransition),
+			errors.Is(err, purchaseUsecases.ErrCancelQuantityExceeded), errors.Is(err, purchaseUsecases.ErrCancellationConflict),
+			errors.Is(err, purchaseUsecases.ErrPurchaseNotCompleted):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error cancelling purchase: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to cancel purchase"})
+	}
+
+	return c.JSON(http.StatusCreated, cancellation)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"purchase-service/middleware"
+	purchaseModels "purchase-service/modules/models"
+	purchaseUsecases "purchase-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// cartTokenHeader carries a guest cart's ID between requests.
+const cartTokenHeader = "X-Cart-Token"
+
+type CartHandler struct {
+	cartUsecase purchaseUsecases.CartUsecase
+}
+
+func NewCartHandler(cartUsecase purchaseUsecases.CartUsecase) *CartHandler {
+	return &CartHandler{cartUsecase: cartUsecase}
+}
+
+// RegisterRoutes lets guests use the cart through optionalAuthMiddleware, and
+// requires a signed-in shopper to check out.
+func (h *CartHandler) RegisterRoutes(router *echo.Group, authMiddleware, optionalAuthMiddleware, idempotencyMiddleware echo.MiddlewareFunc) {
+	router.GET("/cart", h.GetCart, optionalAuthMiddleware)
+	router.POST("/cart/items", h.AddItem, optionalAuthMiddleware)
+	router.PUT("/cart/items/:id", h.UpdateItem, optionalAuthMiddleware)
+	router.DELETE("/cart/items/:id", h.RemoveItem, optionalAuthMiddleware)
+	router.POST("/cart/checkout", h.Checkout, authMiddleware, idempotencyMiddleware)
+}
+
+func (h *CartHandler) GetCart(c echo.Context) error {
+	owner, err := cartOwner(c)
+	if err != nil {
+		return ownerError(c, err)
+	}
+	cart, err := h.cartUsecase.GetCart(c.Request().Context(), owner)
+	return h.respond(c, owner, cart, err)
+}
+
+func (h *CartHandler) AddItem(c echo.Context) error {
+	owner, err := cartOwner(c)
+	if err != nil {
+		return ownerError(c, err)
+	}
+	var req purchaseModels.AddCartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	cart, err := h.cartUsecase.AddItem(c.Request().Context(), owner, req)
+	return h.respond(c, owner, cart, err)
+}
+
+func (h *CartHandler) UpdateItem(c echo.Context) error {
+	owner, err := cartOwner(c)
+	if err != nil {
+		return ownerError(c, err)
+	}
+	lineID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid cart line ID"})
+	}
+	var req purchaseModels.UpdateCartItemRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	cart, err := h.cartUsecase.UpdateItem(c.Request().Context(), owner, lineID, req)
+	return h.respond(c, owner, cart, err)
+}
+
+func (h *CartHandler) RemoveItem(c echo.Context) error {
+	owner, err := cartOwner(c)
+	if err != nil {
+		return ownerError(c, err)
+	}
+	lineID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid cart line ID"})
+	}
+
+	cart, err := h.cartUsecase.RemoveItem(c.Request().Context(), owner, lineID)
+	return h.respond(c, owner, cart, err)
+}
+
+func (h *CartHandler) Checkout(c echo.Context) error {
+	owner, err := cartOwner(c)
+	if err != nil {
+		return ownerError(c, err)
+	}
+	var req purchaseModels.CheckoutRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	purchase, err := h.cartUsecase.Checkout(c.Request().Context(), owner, req)
+	if err != nil {
+		return cartError(c, err, "Failed to check out cart")
+	}
+	return c.JSON(http.StatusCreated, purchase)
+}
+
+// respond writes the priced cart, handing guests their cart token so they
+// can come back to it.
+func (h *CartHandler) respond(c echo.Context, owner purchaseUsecases.CartOwner, cart *purchaseModels.CartView, err error) error {
+	if err != nil {
+		return cartError(c, err, "Failed to process cart")
+	}
+	if owner.UserID == nil {
+		c.Response().Header().Set(cartTokenHeader, cart.CartID.String())
+	}
+	return c.JSON(http.StatusOK, cart)
+}
+
+var (
+	errInvalidSubject   = errors.New("Invalid user ID in token")
+	errInvalidCartToken = errors.New("Invalid cart token")
+)
+
+// cartOwner reads the shopper from the token, if any, and the guest cart
+// from X-Cart-Token.
+func cartOwner(c echo.Context) (purchaseUsecases.CartOwner, error) {
+	var owner purchaseUsecases.CartOwner
+	if subject := middleware.SubjectFromContext(c); subject != "" {
+		userID, err := uuid.Parse(subject)
+		if err != nil {
+			return owner, errInvalidSubject
+		}
+		owner.UserID = &userID
+	}
+	if token := c.Request().Header.Get(cartTokenHeader); token != "" {
+		cartID, err := uuid.Parse(token)
+		if err != nil {
+			return owner, errInvalidCartToken
+		}
+		owner.GuestCartID = &cartID
+	}
+	return owner, nil
+}
+
+func ownerError(c echo.Context, err error) error {
+	if errors.Is(err, errInvalidSubject) {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+func cartError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, purchaseUsecases.ErrCartNotFound), errors.Is(err, purchaseUsecases.ErrCartLineNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, purchaseUsecases.ErrCartEmpty), errors.Is(err, purchaseUsecases.ErrCartLineUnavailable),
+		errors.Is(err, purchaseUsecases.ErrCartStockUnavailable), errors.Is(err, purchaseUsecases.ErrCartPriceChanged):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	if status := checkoutStatus(err); status != 0 {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	c.Logger().Errorf("Cart error: %v", err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
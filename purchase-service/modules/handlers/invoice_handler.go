@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"purchase-service/middleware"
+	"purchase-service/modules/invoices"
+	purchaseUsecases "purchase-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type InvoiceHandler struct {
+	invoiceUsecase purchaseUsecases.InvoiceUsecase
+}
+
+func NewInvoiceHandler(invoiceUsecase purchaseUsecases.InvoiceUsecase) *InvoiceHandler {
+	return &InvoiceHandler{invoiceUsecase: invoiceUsecase}
+}
+
+// RegisterRoutes exposes a purchase's invoice to its owner and to admins, as
+// a PDF download or as HTML for email.
+func (h *InvoiceHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	router.GET("/purchases/:id/invoice.pdf", h.GetInvoicePDF, authMiddleware)
+	router.GET("/purchases/:id/invoice.html", h.GetInvoiceHTML, authMiddleware)
+}
+
+func (h *InvoiceHandler) GetInvoicePDF(c echo.Context) error {
+	return h.render(c, "application/pdf", ".pdf", "attachment", invoices.RenderPDF)
+}
+
+func (h *InvoiceHandler) GetInvoiceHTML(c echo.Context) error {
+	return h.render(c, echo.MIMETextHTMLCharsetUTF8, ".html", "inline", invoices.RenderHTML)
+}
+
+// render draws up the invoice of the purchase in the path and writes it with
+// renderer. The document is rendered in full before anything is sent, so a
+// failure still gets a JSON error.
+func (h *InvoiceHandler) render(c echo.Context, contentType, ext, disposition string, renderer func(io.Writer, *invoices.Invoice) error) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	purchaseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid purchase ID"})
+	}
+
+	invoice, err := h.invoiceUsecase.GetInvoice(c.Request().Context(), purchaseID, userID, middleware.HasPermission(c, middleware.PermManageOrders))
+	if err != nil {
+		if errors.Is(err, purchaseUsecases.ErrPurchaseNotFound) || errors.Is(err, purchaseUsecases.ErrInvoiceNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error getting invoice: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get invoice"})
+	}
+
+	var document bytes.Buffer
+	if err := renderer(&document, invoice); err != nil {
+		c.Logger().Errorf("Error rendering invoice %s: %v", invoice.Number, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to render invoice"})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		mime.FormatMediaType(disposition, map[string]string{"filename": invoice.FileName(ext)}))
+	return c.Blob(http.StatusOK, contentType, document.Bytes())
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"purchase-service/middleware"
+	"purchase-service/modules/payments"
+	purchaseUsecases "purchase-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type PaymentHandler struct {
+	paymentUsecase purchaseUsecases.PaymentUsecase
+}
+
+func NewPaymentHandler(paymentUsecase purchaseUsecases.PaymentUsecase) *PaymentHandler {
+	return &PaymentHandler{paymentUsecase: paymentUsecase}
+}
+
+// RegisterRoutes exposes a purchase's payment to its owner. The gateway's
+// webhook carries no token; it is authenticated by its signature instead.
+func (h *PaymentHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	router.GET("/purchases/:id/payment", h.GetPurchasePayment, authMiddleware)
+	router.POST("/payments/webhook", h.Webhook)
+}
+
+// GetPurchasePayment returns the payment of one of the caller's purchases,
+// or anyone's for admins.
+func (h *PaymentHandler) GetPurchasePayment(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	purchaseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid purchase ID"})
+	}
+
+	payment, err := h.paymentUsecase.GetPurchasePayment(c.Request().Context(), purchaseID, userID, middleware.HasPermission(c, middleware.PermManageOrders))
+	if err != nil {
+		if errors.Is(err, purchaseUsecases.ErrPurchaseNotFound) || errors.Is(err, purchaseUsecases.ErrPaymentNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error getting payment: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get payment"})
+	}
+	return c.JSON(http.StatusOK, payment)
+}
+
+// Webhook applies a payment gateway callback. The signature covers the raw
+// body, so it is read before anything parses it.
+func (h *PaymentHandler) Webhook(c echo.Context) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	err = h.paymentUsecase.HandleWebhook(c.Request().Context(), body,
+		c.Request().Header.Get(payments.TimestampHeader), c.Request().Header.Get(payments.SignatureHeader))
+	if err != nil {
+		if errors.Is(err, purchaseUsecases.ErrInvalidWebhook) {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error handling payment webhook: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to handle webhook"})
+	}
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"purchase-service/middleware"
+	purchaseModels "purchase-service/modules/models"
+	purchaseUsecases "purchase-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type PromotionHandler struct {
+	promotionUsecase purchaseUsecases.PromotionUsecase
+}
+
+func NewPromotionHandler(promotionUsecase purchaseUsecases.PromotionUsecase) *PromotionHandler {
+	return &PromotionHandler{promotionUsecase: promotionUsecase}
+}
+
+// RegisterRoutes exposes promotion management to admins.
+func (h *PromotionHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	promotionGroup := router.Group("/promotions", authMiddleware, middleware.RequirePermission(middleware.PermManagePromotions))
+	promotionGroup.POST("", h.CreatePromotion)
+	promotionGroup.GET("", h.ListPromotions)
+	promotionGroup.GET("/:id", h.GetPromotion)
+	promotionGroup.PUT("/:id", h.UpdatePromotion)
+	promotionGroup.DELETE("/:id", h.DeletePromotion)
+}
+
+func (h *PromotionHandler) CreatePromotion(c echo.Context) error {
+	var req purchaseModels.PromotionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	promotion, err := h.promotionUsecase.CreatePromotion(c.Request().Context(), req)
+	if err != nil {
+		return promotionError(c, err, "Failed to create promotion")
+	}
+	return c.JSON(http.StatusCreated, promotion)
+}
+
+func (h *PromotionHandler) ListPromotions(c echo.Context) error {
+	promotions, err := h.promotionUsecase.ListPromotions(c.Request().Context())
+	if err != nil {
+		return promotionError(c, err, "Failed to list promotions")
+	}
+	return c.JSON(http.StatusOK, promotions)
+}
+
+func (h *PromotionHandler) GetPromotion(c echo.Context) error {
+	promotionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid promotion ID"})
+	}
+
+	promotion, err := h.promotionUsecase.GetPromotion(c.Request().Context(), promotionID)
+	if err != nil {
+		return promotionError(c, err, "Failed to get promotion")
+	}
+	return c.JSON(http.StatusOK, promotion)
+}
+
+func (h *PromotionHandler) UpdatePromotion(c echo.Context) error {
+	promotionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid promotion ID"})
+	}
+	var req purchaseModels.PromotionRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	promotion, err := h.promotionUsecase.UpdatePromotion(c.Request().Context(), promotionID, req)
+	if err != nil {
+		return promotionError(c, err, "Failed to update promotion")
+	}
+	return c.JSON(http.StatusOK, promotion)
+}
+
+func (h *PromotionHandler) DeletePromotion(c echo.Context) error {
+	promotionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid promotion ID"})
+	}
+
+	if err := h.promotionUsecase.DeletePromotion(c.Request().Context(), promotionID); err != nil {
+		return promotionError(c, err, "Failed to delete promotion")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func promotionError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, purchaseUsecases.ErrPromotionNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, purchaseUsecases.ErrInvalidPromotion):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, purchaseUsecases.ErrPromotionCodeExists):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	c.Logger().Errorf("Promotion error: %v", err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
+
+// isCouponRejected reports whether a purchase failed because its coupon
+// cannot be used for it.
+func isCouponRejected(err error) bool {
+	return errors.Is(err, purchaseUsecases.ErrCouponInvalid) || errors.Is(err, purchaseUsecases.ErrCouponExpired) ||
+		errors.Is(err, purchaseUsecases.ErrCouponMinSpend) || errors.Is(err, purchaseUsecases.ErrCouponNotApplicable)
+}
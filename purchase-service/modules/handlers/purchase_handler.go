@@ -19,11 +19,15 @@ func NewPurchaseHandler(purchaseUsecase purchaseUsecases.PurchaseUsecase) *Purch
 	return &PurchaseHandler{purchaseUsecase: purchaseUsecase}
 }
 
-func (h *PurchaseHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+// RegisterRoutes protects every purchase endpoint with authMiddleware and makes
+// purchase creation retryable with idempotencyMiddleware.
+func (h *PurchaseHandler) RegisterRoutes(router *echo.Group, authMiddleware, idempotencyMiddleware echo.MiddlewareFunc) {
 	purchaseGroup := router.Group("/purchases", authMiddleware) // Semua endpoint di sini terproteksi
 	{
-		purchaseGroup.POST("", h.CreatePurchase)
+		purchaseGroup.POST("", h.CreatePurchase, idempotencyMiddleware)
 		purchaseGroup.GET("", h.GetHistory) 
+		purchaseGroup.GET("/:id", h.GetPurchase)
+		purchaseGroup.POST("/:id/status", h.UpdateStatus, middleware.RequirePermission(middleware.PermManageOrders))
 	}
 }
 
@@ -47,8 +51,8 @@ func (h *PurchaseHandler) CreatePurchase(c echo.Context) error {
 
 	purchase, err := h.purchaseUsecase.CreatePurchase(c.Request().Context(), userID, req)
 	if err != nil {
-		if errors.Is(err, purchaseUsecases.ErrItemNotFound) || errors.Is(err, purchaseUsecases.ErrStockNotSufficient) {
-			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		if status := checkoutStatus(err); status != 0 {
+			return c.JSON(status, map[string]string{"error": err.Error()})
 		}
 		c.Logger().Errorf("Error creating purchase: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create purchase"})
@@ -68,11 +72,110 @@ func (h *PurchaseHandler) GetHistory(c echo.Context) error {
 		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
 	}
 
-	history, err := h.purchaseUsecase.GetPurchaseHistory(c.Request().Context(), userID)
+	var query purchaseModels.PurchaseQuery
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid query parameters"})
+	}
+	if err := c.Validate(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	history, err := h.purchaseUsecase.GetPurchaseHistory(c.Request().Context(), userID, query)
 	if err != nil {
+		if errors.Is(err, purchaseUsecases.ErrInvalidCursor) || errors.Is(err, purchaseUsecases.ErrInvalidRange) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 		c.Logger().Errorf("Error getting purchase history: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get purchase history"})
 	}
 
 	return c.JSON(http.StatusOK, history)
 }
+
+// GetPurchase returns one of the caller's purchases, or anyone's for admins.
+func (h *PurchaseHandler) GetPurchase(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	purchaseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid purchase ID"})
+	}
+
+	purchase, err := h.purchaseUsecase.GetPurchase(c.Request().Context(), purchaseID, userID, middleware.HasPermission(c, middleware.PermManageOrders))
+	if err != nil {
+		if errors.Is(err, purchaseUsecases.ErrPurchaseNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error getting purchase: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get purchase"})
+	}
+
+	return c.JSON(http.StatusOK, purchase)
+}
+
+// UpdateStatus moves a purchase to another status. Only staff and admins may
+// do this.
+func (h *PurchaseHandler) UpdateStatus(c echo.Context) error {
+	purchaseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid purchase ID"})
+	}
+
+	var req purchaseModels.UpdatePurchaseStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	purchase, err := h.purchaseUsecase.UpdatePurchaseStatus(c.Request().Context(), purchaseID, req, middleware.SubjectFromContext(c))
+	if err != nil {
+		if errors.Is(err, purchaseUsecases.ErrPurchaseNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, purchaseUsecases.ErrUseCancelEndpoint) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, purchaseUsecases.ErrInvalidTransition) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error updating purchase status: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update purchase status"})
+	}
+
+	return c.JSON(http.StatusOK, purchase)
+}
+
+// checkoutStatus maps the errors placing a purchase can fail with, whether
+// directly or by checking out a cart, to their HTTP status. It returns 0 for
+// any other error.
+func checkoutStatus(err error) int {
+	switch {
+	case errors.Is(err, purchaseUsecases.ErrVariantRequired), errors.Is(err, purchaseUsecases.ErrCurrencyNotSupported),
+		isCouponRejected(err), isShippingRejected(err):
+		return http.StatusBadRequest
+	case errors.Is(err, purchaseUsecases.ErrItemNotFound), errors.Is(err, purchaseUsecases.ErrStockNotSufficient),
+		errors.Is(err, purchaseUsecases.ErrVariantNotFound), errors.Is(err, purchaseUsecases.ErrPurchaseAborted),
+		errors.Is(err, purchaseUsecases.ErrCouponUsedUp), errors.Is(err, purchaseUsecases.ErrReservationLost):
+		return http.StatusConflict
+	case errors.Is(err, purchaseUsecases.ErrItemServiceDown), errors.Is(err, purchaseUsecases.ErrUserServiceDown):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, purchaseUsecases.ErrPaymentDeclined):
+		return http.StatusPaymentRequired
+	case errors.Is(err, purchaseUsecases.ErrPaymentUnavailable):
+		return http.StatusBadGateway
+	case errors.Is(err, purchaseUsecases.ErrTaxClassNotSupported):
+		return http.StatusUnprocessableEntity
+	}
+	return 0
+}
+
+// isShippingRejected reports whether a purchase failed because it cannot be
+// shipped as asked.
+func isShippingRejected(err error) bool {
+	return errors.Is(err, purchaseUsecases.ErrAddressNotFound) || errors.Is(err, purchaseUsecases.ErrUnknownShippingMethod) ||
+		errors.Is(err, purchaseUsecases.ErrShippingUnavailable)
+}
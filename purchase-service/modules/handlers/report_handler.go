@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"purchase-service/middleware"
+	purchaseModels "purchase-service/modules/models"
+	purchaseUsecases "purchase-service/modules/usecases"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ReportHandler struct {
+	reportUsecase purchaseUsecases.ReportUsecase
+}
+
+func NewReportHandler(reportUsecase purchaseUsecases.ReportUsecase) *ReportHandler {
+	return &ReportHandler{reportUsecase: reportUsecase}
+}
+
+// RegisterRoutes exposes sales reports to admins.
+func (h *ReportHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	reportGroup := router.Group("/reports", authMiddleware, middleware.RequirePermission(middleware.PermReadReports))
+	reportGroup.GET("/revenue", h.GetRevenue)
+	reportGroup.GET("/top-items", h.GetTopItems)
+	reportGroup.GET("/average-order-value", h.GetAverageOrderValue)
+	reportGroup.GET("/repeat-customers", h.GetRepeatCustomers)
+	reportGroup.POST("/refresh", h.RefreshReports)
+}
+
+// csvReport is a report that can also be downloaded as CSV.
+type csvReport interface {
+	CSV() [][]string
+}
+
+func (h *ReportHandler) GetRevenue(c echo.Context) error {
+	return h.report(c, "revenue", func(query purchaseModels.ReportQuery) (csvReport, error) {
+		return h.reportUsecase.Revenue(c.Request().Context(), query)
+	})
+}
+
+func (h *ReportHandler) GetTopItems(c echo.Context) error {
+	return h.report(c, "top-items", func(query purchaseModels.ReportQuery) (csvReport, error) {
+		return h.reportUsecase.TopItems(c.Request().Context(), query)
+	})
+}
+
+func (h *ReportHandler) GetAverageOrderValue(c echo.Context) error {
+	return h.report(c, "average-order-value", func(query purchaseModels.ReportQuery) (csvReport, error) {
+		return h.reportUsecase.AverageOrderValue(c.Request().Context(), query)
+	})
+}
+
+func (h *ReportHandler) GetRepeatCustomers(c echo.Context) error {
+	return h.report(c, "repeat-customers", func(query purchaseModels.ReportQuery) (csvReport, error) {
+		return h.reportUsecase.RepeatCustomers(c.Request().Context(), query)
+	})
+}
+
+func (h *ReportHandler) RefreshReports(c echo.Context) error {
+	refreshed, err := h.reportUsecase.Refresh(c.Request().Context())
+	if err != nil {
+		c.Logger().Errorf("Error refreshing reports: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to refresh reports"})
+	}
+	return c.JSON(http.StatusOK, refreshed)
+}
+
+// report reads the query, runs the report and writes it as JSON, or as a CSV
+// download named after the report when format=csv.
+func (h *ReportHandler) report(c echo.Context, name string, run func(purchaseModels.ReportQuery) (csvReport, error)) error {
+	var query purchaseModels.ReportQuery
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid query parameters"})
+	}
+	if err := c.Validate(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	report, err := run(query)
+	if err != nil {
+		if errors.Is(err, purchaseUsecases.ErrInvalidRange) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error getting %s report: %v", name, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get report"})
+	}
+	if query.Format != "csv" {
+		return c.JSON(http.StatusOK, report)
+	}
+
+	var body bytes.Buffer
+	if err := csv.NewWriter(&body).WriteAll(report.CSV()); err != nil {
+		c.Logger().Errorf("Error writing %s report: %v", name, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get report"})
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+name+`.csv"`)
+	return c.Blob(http.StatusOK, "text/csv; charset=utf-8", body.Bytes())
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"purchase-service/middleware"
+	purchaseModels "purchase-service/modules/models"
+	purchaseUsecases "purchase-service/modules/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type ReturnHandler struct {
+	returnUsecase purchaseUsecases.ReturnUsecase
+}
+
+func NewReturnHandler(returnUsecase purchaseUsecases.ReturnUsecase) *ReturnHandler {
+	return &ReturnHandler{returnUsecase: returnUsecase}
+}
+
+// RegisterRoutes exposes returns to purchase owners under their purchase and
+// the review queue to staff and admins under /returns.
+func (h *ReturnHandler) RegisterRoutes(router *echo.Group, authMiddleware, idempotencyMiddleware echo.MiddlewareFunc) {
+	router.POST("/purchases/:id/returns", h.RequestReturn, authMiddleware, idempotencyMiddleware)
+	router.GET("/purchases/:id/returns", h.ListPurchaseReturns, authMiddleware)
+	manageOrders := middleware.RequirePermission(middleware.PermManageOrders)
+	router.GET("/returns", h.ListReturns, authMiddleware, manageOrders)
+	router.POST("/returns/:id/approve", h.ApproveReturn, authMiddleware, manageOrders)
+	router.POST("/returns/:id/reject", h.RejectReturn, authMiddleware, manageOrders)
+}
+
+func (h *ReturnHandler) RequestReturn(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	purchaseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid purchase ID"})
+	}
+
+	var req purchaseModels.CreateReturnRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	purchaseReturn, err := h.returnUsecase.RequestReturn(c.Request().Context(), purchaseID, userID, middleware.HasPermission(c, middleware.PermManageOrders), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, purchaseUsecases.ErrPurchaseNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, purchaseUsecases.ErrPurchaseItemNotFound):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		case errors.Is(err, purchaseUsecases.ErrReturnNotAllowed), errors.Is(err, purchaseUsecases.ErrReturnQuantityExceeded),
+			errors.Is(err, purchaseUsecases.ErrReturnConflict):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error requesting return: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to request return"})
+	}
+
+	return c.JSON(http.StatusCreated, purchaseReturn)
+}
+
+func (h *ReturnHandler) ListPurchaseReturns(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	purchaseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid purchase ID"})
+	}
+
+	returns, err := h.returnUsecase.ListPurchaseReturns(c.Request().Context(), purchaseID, userID, middleware.HasPermission(c, middleware.PermManageOrders))
+	if err != nil {
+		if errors.Is(err, purchaseUsecases.ErrPurchaseNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error listing returns: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list returns"})
+	}
+
+	return c.JSON(http.StatusOK, returns)
+}
+
+func (h *ReturnHandler) ListReturns(c echo.Context) error {
+	var query purchaseModels.ReturnQuery
+	if err := c.Bind(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid query parameters"})
+	}
+	if err := c.Validate(&query); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	returns, err := h.returnUsecase.ListReturns(c.Request().Context(), query)
+	if err != nil {
+		c.Logger().Errorf("Error listing returns: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list returns"})
+	}
+
+	return c.JSON(http.StatusOK, returns)
+}
+
+func (h *ReturnHandler) ApproveReturn(c echo.Context) error {
+	return h.review(c, h.returnUsecase.ApproveReturn)
+}
+
+func (h *ReturnHandler) RejectReturn(c echo.Context) error {
+	return h.review(c, h.returnUsecase.RejectReturn)
+}
+
+// review runs an admin decision on a requested return.
+func (h *ReturnHandler) review(c echo.Context, decide func(ctx context.Context, returnID uuid.UUID, actorID string, req purchaseModels.ReviewReturnRequest) (*purchaseModels.PurchaseReturn, error)) error {
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid return ID"})
+	}
+
+	var req purchaseModels.ReviewReturnRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	purchaseReturn, err := decide(c.Request().Context(), returnID, middleware.SubjectFromContext(c), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, purchaseUsecases.ErrReturnNotFound), errors.Is(err, purchaseUsecases.ErrPurchaseNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, purchaseUsecases.ErrReturnAlreadyDecided):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error reviewing return: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to review return"})
+	}
+
+	return c.JSON(http.StatusOK, purchaseReturn)
+}
@@ -0,0 +1,101 @@
+package invoices
+
+import (
+	"html/template"
+	"io"
+)
+
+// RenderHTML writes the invoice as a standalone HTML page for email. It uses
+// tables and inline styles only, since mail clients drop style sheets.
+func RenderHTML(w io.Writer, inv *Invoice) error {
+	return htmlTemplate.Execute(w, htmlInvoice{
+		Invoice:     inv,
+		IssuedOn:    issuedOn(inv),
+		SellerLines: partyLines(inv.Seller),
+		BuyerLines:  partyLines(inv.Buyer),
+		Totals:      totalRows(inv),
+	})
+}
+
+// htmlInvoice is the data htmlTemplate is executed with.
+type htmlInvoice struct {
+	*Invoice
+	IssuedOn    string
+	SellerLines []string
+	BuyerLines  []string
+	Totals      []totalRow
+}
+
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"last": func(i int, rows []totalRow) bool { return i == len(rows)-1 },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+</head>
+<body style="margin:0;padding:24px;background:#f4f4f4;font-family:Helvetica,Arial,sans-serif;font-size:14px;color:#222;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:720px;margin:0 auto;background:#fff;border-collapse:collapse;">
+<tr><td style="padding:24px;">
+  <table role="presentation" width="100%" cellpadding="0" cellspacing="0">
+    <tr>
+      <td valign="top" style="font-size:20px;font-weight:bold;">{{.Seller.Name}}</td>
+      <td valign="top" align="right" style="font-size:22px;font-weight:bold;">INVOICE</td>
+    </tr>
+    <tr>
+      <td valign="top" style="padding-top:8px;font-size:12px;color:#555;">{{range .SellerLines}}{{.}}<br>{{end}}</td>
+      <td valign="top" align="right" style="padding-top:8px;font-size:12px;">
+        <strong>Invoice number</strong> {{.Number}}<br>
+        <strong>Date</strong> {{.IssuedOn}}<br>
+        <strong>Purchase</strong> {{.PurchaseID}}<br>
+        <strong>Status</strong> {{.Status}}
+      </td>
+    </tr>
+  </table>
+
+  <p style="margin:24px 0 4px;font-weight:bold;">Bill to</p>
+  <p style="margin:0;font-size:13px;"><strong>{{.Buyer.Name}}</strong><br>{{range .BuyerLines}}{{.}}<br>{{end}}</p>
+
+  <p style="margin:24px 0 4px;font-size:12px;color:#555;text-align:right;">Amounts in {{.Currency}}</p>
+  <table width="100%" cellpadding="6" cellspacing="0" style="border-collapse:collapse;font-size:13px;">
+    <tr style="background:#ebebeb;">
+      <th align="left">Description</th>
+      <th align="right">Qty</th>
+      <th align="right">Unit price</th>
+      <th align="right">Discount</th>
+      <th align="right">Tax rate</th>
+      <th align="right">Tax</th>
+      <th align="right">Amount</th>
+    </tr>
+    {{- range .Lines}}
+    <tr style="border-bottom:1px solid #ddd;">
+      <td>{{.Description}}{{if .SKU}} <span style="color:#777;">({{.SKU}})</span>{{end}}</td>
+      <td align="right">{{.Quantity}}</td>
+      <td align="right">{{.UnitPrice}}</td>
+      <td align="right">{{.Discount}}</td>
+      <td align="right">{{.TaxRate}}</td>
+      <td align="right">{{.Tax}}</td>
+      <td align="right">{{.Amount}}</td>
+    </tr>
+    {{- end}}
+  </table>
+
+  <table cellpadding="6" cellspacing="0" align="right" style="margin-top:16px;border-collapse:collapse;font-size:13px;">
+    {{- $totals := .Totals}}
+    {{- range $i, $row := .Totals}}
+    {{- if last $i $totals}}
+    <tr style="border-top:2px solid #222;font-weight:bold;"><td>{{$row.Label}}</td><td align="right">{{$.Currency}} {{$row.Amount}}</td></tr>
+    {{- else}}
+    <tr><td>{{$row.Label}}</td><td align="right">{{$.Currency}} {{$row.Amount}}</td></tr>
+    {{- end}}
+    {{- end}}
+  </table>
+  <div style="clear:both;"></div>
+  {{- if .TaxIncluded}}
+  <p style="margin-top:24px;font-size:12px;color:#555;font-style:italic;">Prices include tax.</p>
+  {{- end}}
+</td></tr>
+</table>
+</body>
+</html>
+`))
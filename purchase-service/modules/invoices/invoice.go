@@ -0,0 +1,147 @@
+package invoices
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"shop-crud/item-service/pkg/money"
+)
+
+// NumberPrefix starts every invoice number.
+const NumberPrefix = "INV"
+
+// Number formats the seq-th invoice of year, e.g. INV-2025-000042. Numbers
+// restart at 1 each year.
+func Number(year int, seq int64) string {
+	return fmt.Sprintf("%s-%d-%06d", NumberPrefix, year, seq)
+}
+
+// Party is the seller or the buyer named on an invoice. Address holds one
+// line of the postal address per entry.
+type Party struct {
+	Name    string
+	Address []string
+	Phone   string
+	Email   string
+	TaxID   string
+}
+
+// Line is one purchase line on an invoice. Amount is what the line cost
+// after Discount, tax included: Net plus Tax.
+type Line struct {
+	Description string
+	SKU         string
+	Quantity    int
+	UnitPrice   money.Amount
+	Discount    money.Amount
+	TaxRate     string
+	Net         money.Amount
+	Tax         money.Amount
+	Amount      money.Amount
+}
+
+// TaxLine is the tax charged at one rate over the whole invoice.
+type TaxLine struct {
+	Label  string
+	Amount money.Amount
+}
+
+// Invoice is everything an invoice shows, in Currency. LinesTotal less
+// Discount is the lines' cost; Subtotal is that before tax and Tax the tax
+// on it, broken down in Taxes. Total adds Shipping to both.
+type Invoice struct {
+	Number     string
+	IssuedAt   time.Time
+	PurchaseID string
+	Status     string
+	Currency   string
+	Seller     Party
+	Buyer      Party
+
+	Lines      []Line
+	LinesTotal money.Amount
+	Discount   money.Amount
+	CouponCode string
+	Subtotal   money.Amount
+	// TaxIncluded says the prices already included the tax.
+	TaxIncluded    bool
+	Taxes          []TaxLine
+	Tax            money.Amount
+	ShippingMethod string
+	Shipping       money.Amount
+	Total          money.Amount
+}
+
+// AddressLines splits an address written on one line, such as an
+// environment variable, into lines at each "|".
+func AddressLines(address string) []string {
+	var lines []string
+	for _, line := range strings.Split(address, "|") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// FileName is the name the invoice is downloaded under, with ext such as
+// ".pdf".
+func (inv *Invoice) FileName(ext string) string {
+	return inv.Number + ext
+}
+
+// formatAmount prints an amount with its currency, e.g. "IDR 150000.00".
+func formatAmount(currency string, amount money.Amount) string {
+	return currency + " " + amount.String()
+}
+
+// issuedOn is the date an invoice is dated by.
+func issuedOn(inv *Invoice) string {
+	return inv.IssuedAt.Format("2 January 2006")
+}
+
+type totalRow struct {
+	Label  string
+	Amount money.Amount
+}
+
+// totalRows lists the rows under the line table, ending with the total.
+// Discount and shipping rows are left out when there is none.
+func totalRows(inv *Invoice) []totalRow {
+	rows := []totalRow{{"Lines", inv.LinesTotal}}
+	if !inv.Discount.IsZero() {
+		label := "Discount"
+		if inv.CouponCode != "" {
+			label += " (" + inv.CouponCode + ")"
+		}
+		rows = append(rows, totalRow{label, money.FromMinor(-inv.Discount.Minor())})
+	}
+	rows = append(rows, totalRow{"Subtotal excluding tax", inv.Subtotal})
+	for _, tax := range inv.Taxes {
+		rows = append(rows, totalRow{tax.Label, tax.Amount})
+	}
+	if inv.ShippingMethod != "" || !inv.Shipping.IsZero() {
+		label := "Shipping"
+		if inv.ShippingMethod != "" {
+			label += " (" + inv.ShippingMethod + ")"
+		}
+		rows = append(rows, totalRow{label, inv.Shipping})
+	}
+	return append(rows, totalRow{"Total", inv.Total})
+}
+
+// partyLines lists a party's address and contact details, one per line.
+func partyLines(party Party) []string {
+	lines := append([]string(nil), party.Address...)
+	if party.Phone != "" {
+		lines = append(lines, "Phone: "+party.Phone)
+	}
+	if party.Email != "" {
+		lines = append(lines, party.Email)
+	}
+	if party.TaxID != "" {
+		lines = append(lines, "Tax ID: "+party.TaxID)
+	}
+	return lines
+}
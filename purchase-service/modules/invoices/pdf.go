@@ -0,0 +1,195 @@
+package invoices
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Page layout in millimetres on A4 portrait.
+const (
+	pdfMargin     = 15.0
+	pdfLineHeight = 5.0
+	pdfRowHeight  = 6.0
+)
+
+// pdfColumns are the widths of the line table's columns, adding up to the
+// width between the margins.
+var pdfColumns = []struct {
+	title string
+	width float64
+	align string
+}{
+	{"Description", 58, "L"},
+	{"Qty", 12, "R"},
+	{"Unit price", 26, "R"},
+	{"Discount", 22, "R"},
+	{"Tax rate", 14, "R"},
+	{"Tax", 22, "R"},
+	{"Amount", 26, "R"},
+}
+
+// RenderPDF writes the invoice as an A4 PDF. Text is set in the PDF core
+// fonts, so characters outside Windows-1252 are not shown.
+func RenderPDF(w io.Writer, inv *Invoice) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.SetTitle("Invoice "+inv.Number, true)
+	pdf.SetAuthor(inv.Seller.Name, true)
+	pdf.SetCreationDate(inv.IssuedAt)
+	pdf.AliasNbPages("")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pdfMargin)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, pdfLineHeight, tr(inv.Number+" - page "+strconv.Itoa(pdf.PageNo())+" of {nb}"), "", 0, "C", false, 0, "")
+	})
+	pdf.AddPage()
+
+	writeHeader(pdf, tr, inv)
+	writeLines(pdf, tr, inv)
+	writeTotals(pdf, tr, inv)
+
+	return pdf.Output(w)
+}
+
+// writeHeader prints the seller, the invoice's number and date, and the
+// buyer.
+func writeHeader(pdf *gofpdf.Fpdf, tr func(string) string, inv *Invoice) {
+	pageWidth, _ := pdf.GetPageSize()
+	half := (pageWidth - 2*pdfMargin) / 2
+
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(half, 8, tr(inv.Seller.Name), "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(half, 8, "INVOICE", "", 1, "R", false, 0, "")
+
+	top := pdf.GetY()
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range partyLines(inv.Seller) {
+		pdf.CellFormat(half, pdfLineHeight, tr(line), "", 2, "L", false, 0, "")
+	}
+	sellerBottom := pdf.GetY()
+
+	pdf.SetXY(pdfMargin+half, top)
+	details := [][2]string{
+		{"Invoice number", inv.Number},
+		{"Date", issuedOn(inv)},
+		{"Purchase", inv.PurchaseID},
+		{"Status", inv.Status},
+	}
+	for _, detail := range details {
+		pdf.SetX(pdfMargin + half)
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(half*0.3, pdfLineHeight, detail[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(half*0.7, pdfLineHeight, tr(detail[1]), "", 1, "R", false, 0, "")
+	}
+	if pdf.GetY() < sellerBottom {
+		pdf.SetY(sellerBottom)
+	}
+
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, pdfLineHeight, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(0, pdfLineHeight, tr(inv.Buyer.Name), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range partyLines(inv.Buyer) {
+		pdf.CellFormat(0, pdfLineHeight, tr(line), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+}
+
+// writeLines prints the line table, starting a new page with the table's
+// header again whenever a line would run into the bottom margin.
+func writeLines(pdf *gofpdf.Fpdf, tr func(string) string, inv *Invoice) {
+	_, pageHeight := pdf.GetPageSize()
+	writeTableHeader(pdf, inv.Currency)
+
+	pdf.SetFont("Helvetica", "", 9)
+	for _, line := range inv.Lines {
+		description := line.Description
+		if line.SKU != "" {
+			description += " (" + line.SKU + ")"
+		}
+		wrapped := pdf.SplitText(tr(description), pdfColumns[0].width-2)
+		height := float64(len(wrapped)) * pdfLineHeight
+		if height < pdfRowHeight {
+			height = pdfRowHeight
+		}
+		if pdf.GetY()+height > pageHeight-2*pdfMargin {
+			pdf.AddPage()
+			writeTableHeader(pdf, inv.Currency)
+			pdf.SetFont("Helvetica", "", 9)
+		}
+
+		x, y := pdf.GetX(), pdf.GetY()
+		pdf.MultiCell(pdfColumns[0].width, height/float64(len(wrapped)), strings.Join(wrapped, "\n"), "B", "L", false)
+		pdf.SetXY(x+pdfColumns[0].width, y)
+		cells := []string{
+			strconv.Itoa(line.Quantity),
+			line.UnitPrice.String(),
+			line.Discount.String(),
+			line.TaxRate,
+			line.Tax.String(),
+			line.Amount.String(),
+		}
+		for i, cell := range cells {
+			column := pdfColumns[i+1]
+			pdf.CellFormat(column.width, height, cell, "B", 0, column.align, false, 0, "")
+		}
+		pdf.SetXY(x, y+height)
+	}
+	pdf.Ln(4)
+}
+
+func writeTableHeader(pdf *gofpdf.Fpdf, currency string) {
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.CellFormat(0, pdfLineHeight, "Amounts in "+currency, "", 1, "R", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for _, column := range pdfColumns {
+		pdf.CellFormat(column.width, pdfRowHeight, column.title, "B", 0, column.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// writeTotals prints the discount, tax, shipping and total under the lines.
+func writeTotals(pdf *gofpdf.Fpdf, tr func(string) string, inv *Invoice) {
+	_, pageHeight := pdf.GetPageSize()
+	rows := totalRows(inv)
+	if pdf.GetY()+float64(len(rows)+2)*pdfRowHeight > pageHeight-2*pdfMargin {
+		pdf.AddPage()
+	}
+
+	pageWidth, _ := pdf.GetPageSize()
+	labelWidth, valueWidth := 60.0, 36.0
+	left := pageWidth - pdfMargin - labelWidth - valueWidth
+	for i, row := range rows {
+		last := i == len(rows)-1
+		style, border := "", ""
+		if last {
+			style, border = "B", "T"
+		}
+		pdf.SetX(left)
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.CellFormat(labelWidth, pdfRowHeight, tr(row.Label), border, 0, "L", false, 0, "")
+		pdf.CellFormat(valueWidth, pdfRowHeight, formatAmount(inv.Currency, row.Amount), border, 1, "R", false, 0, "")
+	}
+
+	if inv.TaxIncluded {
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.CellFormat(0, pdfLineHeight, "Prices include tax.", "", 1, "L", false, 0, "")
+	}
+}
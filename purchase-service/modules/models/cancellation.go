@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// PurchaseCancellation cancels some or all units of a purchase. Its stock is
+// given back to item-service after it is recorded; StockRestoredAt stays nil
+// until that has happened. Cancelling a paid purchase grants Refund.
+type PurchaseCancellation struct {
+	ID              uuid.UUID                  `db:"id" json:"id"`
+	PurchaseID      uuid.UUID                  `db:"purchase_id" json:"purchase_id"`
+	Reason          string                     `db:"reason" json:"reason"`
+	ActorID         string                     `db:"actor_id" json:"actor_id,omitempty"`
+	Amount          money.Amount               `db:"amount" json:"amount"`
+	StockRestoredAt *time.Time                 `db:"stock_restored_at" json:"stock_restored_at,omitempty"`
+	CreatedAt       time.Time                  `db:"created_at" json:"created_at"`
+	Items           []PurchaseCancellationItem `json:"items"`
+	Refund          *Refund                    `json:"refund,omitempty"`
+}
+
+type PurchaseCancellationItem struct {
+	PurchaseItemID uuid.UUID  `db:"purchase_item_id" json:"purchase_item_id"`
+	ItemID         uuid.UUID  `db:"item_id" json:"item_id"`
+	VariantID      *uuid.UUID `db:"variant_id" json:"variant_id,omitempty"`
+	Quantity       int        `db:"quantity" json:"quantity"`
+}
+
+// CancelPurchaseRequest cancels the listed lines, or every remaining unit of
+// the purchase when Items is empty.
+type CancelPurchaseRequest struct {
+	Reason string                      `json:"reason" validate:"required,max=500"`
+	Items  []CancelPurchaseItemRequest `json:"items" validate:"omitempty,max=50,dive"`
+}
+
+type CancelPurchaseItemRequest struct {
+	PurchaseItemID uuid.UUID `json:"purchase_item_id" validate:"required"`
+	Quantity       int       `json:"quantity" validate:"required,gt=0"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// Cart holds the lines a shopper means to buy. A user's cart belongs to their
+// JWT subject; a guest cart has no UserID and is found by its ID alone.
+type Cart struct {
+	ID        uuid.UUID  `db:"id" json:"id"`
+	UserID    *uuid.UUID `db:"user_id" json:"user_id,omitempty"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// CartLine is an item in a cart with the price it had when it was added.
+type CartLine struct {
+	ID                uuid.UUID    `db:"id" json:"id"`
+	CartID            uuid.UUID    `db:"cart_id" json:"cart_id"`
+	ItemID            uuid.UUID    `db:"item_id" json:"item_id"`
+	VariantID         *uuid.UUID   `db:"variant_id" json:"variant_id,omitempty"`
+	Quantity          int          `db:"quantity" json:"quantity"`
+	PriceWhenAdded    money.Amount `db:"price_when_added" json:"price_when_added"`
+	CurrencyWhenAdded string       `db:"currency_when_added" json:"currency_when_added"`
+	AddedAt           time.Time    `db:"added_at" json:"added_at"`
+}
+
+// CartView is a cart priced against item-service right now.
+type CartView struct {
+	CartID uuid.UUID      `json:"cart_id"`
+	Lines  []CartLineView `json:"lines"`
+	// Totals sums the lines per currency, since items may be priced in
+	// different ones until checkout converts them.
+	Totals map[string]money.Amount `json:"totals"`
+	// HasIssues is set when any line is flagged.
+	HasIssues bool `json:"has_issues"`
+}
+
+// CartLineView is a cart line at its current price. The flags tell the
+// shopper what changed since the line was added.
+type CartLineView struct {
+	ID             uuid.UUID    `json:"id"`
+	ItemID         uuid.UUID    `json:"item_id"`
+	VariantID      *uuid.UUID   `json:"variant_id,omitempty"`
+	SKU            string       `json:"sku,omitempty"`
+	Name           string       `json:"name"`
+	Quantity       int          `json:"quantity"`
+	Price          money.Amount `json:"price"`
+	Currency       string       `json:"currency"`
+	PriceWhenAdded money.Amount `json:"price_when_added"`
+	LineTotal      money.Amount `json:"line_total"`
+	AvailableStock int          `json:"available_stock"`
+
+	// PriceChanged is set when the price or currency differs from when the
+	// line was added.
+	PriceChanged bool `json:"price_changed"`
+	// InsufficientStock is set when fewer units are in stock than the line
+	// asks for.
+	InsufficientStock bool `json:"insufficient_stock"`
+	// Unavailable is set when the item or variant no longer exists.
+	Unavailable bool `json:"unavailable"`
+}
+
+type AddCartItemRequest struct {
+	ItemID    uuid.UUID  `json:"item_id" validate:"required"`
+	VariantID *uuid.UUID `json:"variant_id"` // Required when the item is sold in variants.
+	Quantity  int        `json:"quantity" validate:"required,gt=0,lte=1000"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" validate:"required,gt=0,lte=1000"`
+}
+
+type CheckoutRequest struct {
+	// Currency to charge in, as for CreatePurchaseRequest.
+	Currency string `json:"currency" validate:"omitempty,iso4217"`
+	// CouponCode applies a promotion, as for CreatePurchaseRequest.
+	CouponCode string `json:"coupon_code" validate:"omitempty,max=64"`
+	// PaymentToken pays for the purchase, as for CreatePurchaseRequest.
+	PaymentToken string `json:"payment_token" validate:"omitempty,max=255"`
+	// AddressID and ShippingMethod ship the purchase, as for
+	// CreatePurchaseRequest.
+	AddressID      uuid.UUID `json:"address_id" validate:"required"`
+	ShippingMethod string    `json:"shipping_method" validate:"required,max=32"`
+	// AcceptPriceChanges confirms that the shopper has seen the current
+	// prices of lines flagged with PriceChanged.
+	AcceptPriceChanges bool `json:"accept_price_changes"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// Payment statuses. A payment starts pending, is authorized and then
+// captured. It ends declined if the gateway refuses it, voided if it is
+// abandoned before capture, or refunded if the purchase fails after it.
+const (
+	PaymentPending    = "pending"
+	PaymentAuthorized = "authorized"
+	PaymentCaptured   = "captured"
+	PaymentDeclined   = "declined"
+	PaymentVoided     = "voided"
+	PaymentRefunded   = "refunded"
+)
+
+// Payment is the charge for a purchase at a payment gateway. Reference is the
+// gateway's ID for it, known once it has been authorized.
+type Payment struct {
+	ID            uuid.UUID    `db:"id" json:"id"`
+	PurchaseID    uuid.UUID    `db:"purchase_id" json:"purchase_id"`
+	Provider      string       `db:"provider" json:"provider"`
+	Reference     string       `db:"reference" json:"reference,omitempty"`
+	Amount        money.Amount `db:"amount" json:"amount"`
+	Currency      string       `db:"currency" json:"currency"`
+	Status        string       `db:"status" json:"status"`
+	FailureReason string       `db:"failure_reason" json:"failure_reason,omitempty"`
+	CreatedAt     time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time    `db:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// Promotion types.
+const (
+	// PromotionPercentage takes Value percent off each eligible line.
+	PromotionPercentage = "percentage"
+	// PromotionFixedAmount takes Value, in Currency, off the eligible lines,
+	// split between them by their totals.
+	PromotionFixedAmount = "fixed_amount"
+	// PromotionBuyXGetY gives GetQuantity units free for every BuyQuantity
+	// units bought of the same eligible line.
+	PromotionBuyXGetY = "buy_x_get_y"
+)
+
+// Promotion is a discount redeemed with a coupon code.
+type Promotion struct {
+	ID   uuid.UUID `db:"id" json:"id"`
+	Code string    `db:"code" json:"code"`
+	Name string    `db:"name" json:"name"`
+	Type string    `db:"type" json:"type"`
+	// Value is a percentage for percentage promotions and an amount in
+	// Currency for fixed amounts. Buy-X-get-Y promotions do not use it.
+	Value    money.Amount `db:"value" json:"value"`
+	Currency string       `db:"currency" json:"currency,omitempty"`
+	// BuyQuantity and GetQuantity are only set on buy-X-get-Y promotions.
+	BuyQuantity int `db:"buy_quantity" json:"buy_quantity,omitempty"`
+	GetQuantity int `db:"get_quantity" json:"get_quantity,omitempty"`
+	// MinSpend, in Currency, is the purchase subtotal needed to redeem.
+	MinSpend *money.Amount `db:"min_spend" json:"min_spend,omitempty"`
+	// MaxUses and MaxUsesPerUser limit redemptions overall and per user.
+	MaxUses        *int       `db:"max_uses" json:"max_uses,omitempty"`
+	MaxUsesPerUser *int       `db:"max_uses_per_user" json:"max_uses_per_user,omitempty"`
+	StartsAt       time.Time  `db:"starts_at" json:"starts_at"`
+	EndsAt         *time.Time `db:"ends_at" json:"ends_at,omitempty"`
+	Active         bool       `db:"active" json:"active"`
+	// ItemIDs and CategoryIDs scope the promotion. A line is eligible when
+	// its item is listed or falls under a listed category; with neither set,
+	// every line is.
+	ItemIDs     []uuid.UUID `json:"item_ids"`
+	CategoryIDs []uuid.UUID `json:"category_ids"`
+	// UsedCount counts the purchases that redeemed the promotion.
+	UsedCount int       `json:"used_count"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// PromotionRequest creates a promotion or replaces one in full.
+type PromotionRequest struct {
+	Code           string        `json:"code" validate:"required,min=3,max=64,alphanum"`
+	Name           string        `json:"name" validate:"required,max=255"`
+	Type           string        `json:"type" validate:"required,oneof=percentage fixed_amount buy_x_get_y"`
+	Value          money.Amount  `json:"value" validate:"gte=0"`
+	Currency       string        `json:"currency" validate:"omitempty,iso4217"`
+	BuyQuantity    int           `json:"buy_quantity" validate:"gte=0"`
+	GetQuantity    int           `json:"get_quantity" validate:"gte=0"`
+	MinSpend       *money.Amount `json:"min_spend" validate:"omitempty,gt=0"`
+	MaxUses        *int          `json:"max_uses" validate:"omitempty,gt=0"`
+	MaxUsesPerUser *int          `json:"max_uses_per_user" validate:"omitempty,gt=0"`
+	// StartsAt defaults to now.
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	// Active defaults to true.
+	Active      *bool       `json:"active"`
+	ItemIDs     []uuid.UUID `json:"item_ids" validate:"max=100"`
+	CategoryIDs []uuid.UUID `json:"category_ids" validate:"max=100"`
+}
@@ -2,57 +2,240 @@ package models
 
 import (
 	"time"
+
 	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
 )
 
-
 type Purchase struct {
-	ID          uuid.UUID `db:"id" json:"id"`
-	UserID      uuid.UUID `db:"user_id" json:"user_id"`
-	TotalAmount float64   `db:"total_amount" json:"total_amount"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at"`
-	Items       []PurchaseItemResponse `json:"items"` 
+	ID          uuid.UUID    `db:"id" json:"id"`
+	UserID      uuid.UUID    `db:"user_id" json:"user_id"`
+	TotalAmount money.Amount `db:"total_amount" json:"total_amount"`
+	Currency    string       `db:"currency" json:"currency"`
+	Status      string       `db:"status" json:"status"`
+	CreatedAt   time.Time    `db:"created_at" json:"created_at"`
+	// InvoiceNumber is allocated when the purchase is paid. Purchases made
+	// before invoices were numbered have none.
+	InvoiceNumber string `db:"invoice_number" json:"invoice_number,omitempty"`
+	// DiscountAmount is taken off the sum of the lines by the promotion
+	// behind CouponCode; TotalAmount is what is charged after it.
+	DiscountAmount money.Amount `db:"discount_amount" json:"discount_amount"`
+	CouponCode     string       `db:"coupon_code" json:"coupon_code,omitempty"`
+	PromotionID    *uuid.UUID   `db:"promotion_id" json:"promotion_id,omitempty"`
+	// SubtotalAmount is the lines after discount and before tax, and
+	// TaxAmount the tax on them; TotalAmount is their sum plus ShippingFee.
+	// TaxMode records whether the catalog prices included the tax.
+	SubtotalAmount money.Amount `db:"subtotal_amount" json:"subtotal_amount"`
+	TaxAmount      money.Amount `db:"tax_amount" json:"tax_amount"`
+	TaxMode        string       `db:"tax_mode" json:"tax_mode"`
+	// The purchase ships by ShippingMethod to ShippingAddress, in
+	// ShippingZone, for ShippingFee on ShippingWeightGrams. Purchases made
+	// before shipping was recorded have none.
+	ShippingMethod      string           `db:"shipping_method" json:"shipping_method,omitempty"`
+	ShippingZone        string           `db:"shipping_zone" json:"shipping_zone,omitempty"`
+	ShippingWeightGrams int              `db:"shipping_weight_grams" json:"shipping_weight_grams"`
+	ShippingFee         money.Amount     `db:"shipping_fee" json:"shipping_fee"`
+	ShippingAddress     *ShippingAddress `db:"shipping_address" json:"shipping_address,omitempty"`
+	// When the purchase entered each status; nil until it has.
+	PaidAt        *time.Time             `db:"paid_at" json:"paid_at,omitempty"`
+	FulfilledAt   *time.Time             `db:"fulfilled_at" json:"fulfilled_at,omitempty"`
+	DeliveredAt   *time.Time             `db:"delivered_at" json:"delivered_at,omitempty"`
+	CancelledAt   *time.Time             `db:"cancelled_at" json:"cancelled_at,omitempty"`
+	RefundedAt    *time.Time             `db:"refunded_at" json:"refunded_at,omitempty"`
+	Items         []PurchaseItemResponse `json:"items"`
+	StatusHistory []PurchaseStatusChange `json:"status_history"`
+	Cancellations []PurchaseCancellation `json:"cancellations,omitempty"`
+	// Payment is set on a purchase just created.
+	Payment *Payment `json:"payment,omitempty"`
 }
 
-
 type PurchaseItem struct {
-	ID                uuid.UUID `db:"id"`
-	PurchaseID        uuid.UUID `db:"purchase_id"`
-	ItemID            uuid.UUID `db:"item_id"`
-	Quantity          int       `db:"quantity"`
-	PriceAtPurchase   float64   `db:"price_at_purchase"`
+	ID         uuid.UUID  `db:"id"`
+	PurchaseID uuid.UUID  `db:"purchase_id"`
+	ItemID     uuid.UUID  `db:"item_id"`
+	VariantID  *uuid.UUID `db:"variant_id"`
+	// ItemName and SKU are what the item was called when it was bought, so
+	// the purchase still reads correctly after the item changes or is deleted.
+	ItemName string `db:"item_name"`
+	SKU      string `db:"sku"`
+	Quantity int    `db:"quantity"`
+	// CancelledQuantity counts the units given back by cancellations.
+	CancelledQuantity int `db:"cancelled_quantity"`
+	// ReturnedQuantity counts the units taken back by approved returns.
+	ReturnedQuantity int          `db:"returned_quantity"`
+	PriceAtPurchase  money.Amount `db:"price_at_purchase"`
+	// The item's own price and currency, and the rate locked to convert
+	// them into the purchase currency.
+	OriginalPrice    money.Amount `db:"original_price"`
+	OriginalCurrency string       `db:"original_currency"`
+	ExchangeRate     money.Rate   `db:"exchange_rate"`
+	// DiscountAmount is the line's share of the purchase discount.
+	DiscountAmount money.Amount `db:"discount_amount"`
+	// The line's tax class and rate, and its amount after discount split
+	// into net and tax.
+	TaxClass  string       `db:"tax_class"`
+	TaxRate   money.Rate   `db:"tax_rate"`
+	NetAmount money.Amount `db:"net_amount"`
+	TaxAmount money.Amount `db:"tax_amount"`
 }
 
+// PaidFor is what was paid for units of the line, tax included: their share
+// of NetAmount plus TaxAmount. The share is rounded down, so refunding a line
+// in parts never pays back more than was charged for it.
+func (i PurchaseItem) PaidFor(units int) money.Amount {
+	paid := i.NetAmount.Add(i.TaxAmount)
+	if i.Quantity == 0 || units == i.Quantity {
+		return paid
+	}
+	return money.FromMinor(paid.Minor() * int64(units) / int64(i.Quantity))
+}
 
 type CreatePurchaseRequest struct {
 	Items []PurchaseItemRequest `json:"items" validate:"required,min=1,dive"`
+	// Currency to charge in. Item prices are converted at the current rate.
+	Currency string `json:"currency" validate:"omitempty,iso4217"`
+	// CouponCode applies a promotion to the purchase.
+	CouponCode string `json:"coupon_code" validate:"omitempty,max=64"`
+	// PaymentToken is the payment gateway's token for the shopper's payment
+	// method.
+	PaymentToken string `json:"payment_token" validate:"omitempty,max=255"`
+	// AddressID is an address from the shopper's address book to ship to,
+	// by ShippingMethod.
+	AddressID      uuid.UUID `json:"address_id" validate:"required"`
+	ShippingMethod string    `json:"shipping_method" validate:"required,max=32"`
 }
 
+// ShippingAddress is a copy of the address-book entry a purchase ships to,
+// taken when it was made, so later edits to the entry leave it as it was.
+type ShippingAddress struct {
+	AddressID     uuid.UUID `json:"address_id"`
+	RecipientName string    `json:"recipient_name"`
+	Phone         string    `json:"phone"`
+	Line1         string    `json:"line1"`
+	Line2         string    `json:"line2,omitempty"`
+	City          string    `json:"city"`
+	Province      string    `json:"province,omitempty"`
+	PostalCode    string    `json:"postal_code"`
+	CountryCode   string    `json:"country_code"`
+}
 
 type PurchaseItemRequest struct {
-	ItemID   uuid.UUID `json:"item_id" validate:"required"`
-	Quantity int       `json:"quantity" validate:"required,gt=0"`
+	ItemID    uuid.UUID  `json:"item_id" validate:"required"`
+	VariantID *uuid.UUID `json:"variant_id"` // Required when the item is sold in variants.
+	Quantity  int        `json:"quantity" validate:"required,gt=0"`
+}
+
+// PurchaseQuery describes the filters and paging applied when listing a
+// user's purchases, newest first.
+type PurchaseQuery struct {
+	Limit     int           `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor    string        `query:"cursor"`
+	From      *time.Time    `query:"from"`
+	To        *time.Time    `query:"to"`
+	Status    string        `query:"status" validate:"omitempty,oneof=pending paid fulfilled delivered cancelled refunded"`
+	MinAmount *money.Amount `query:"min_amount" validate:"omitempty,gte=0"`
+	MaxAmount *money.Amount `query:"max_amount" validate:"omitempty,gte=0"`
+	// ItemID keeps only purchases with a line for this item.
+	ItemID *uuid.UUID `query:"item_id"`
+
+	// After is the decoded Cursor, filled in by the usecase.
+	After *PurchaseCursor `query:"-"`
+}
+
+// PurchaseCursor marks the last purchase of a page for keyset pagination.
+type PurchaseCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
 }
 
+// PurchaseListResponse is the paging envelope returned by GET /purchases.
+type PurchaseListResponse struct {
+	Data       []Purchase `json:"data"`
+	Limit      int        `json:"limit"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
 
 type PurchaseItemResponse struct {
-	ItemID   uuid.UUID `json:"item_id"`
-	Quantity int       `json:"quantity"`
-	Name     string    `json:"name"`
-	Price    float64   `json:"price"`
+	ID        uuid.UUID    `json:"id"`
+	ItemID    uuid.UUID    `json:"item_id"`
+	VariantID *uuid.UUID   `json:"variant_id,omitempty"`
+	SKU       string       `json:"sku,omitempty"`
+	Quantity  int          `json:"quantity"`
+	Name      string       `json:"name"`
+	Price     money.Amount `json:"price"`
+	// DiscountAmount is the line's share of the purchase discount.
+	DiscountAmount money.Amount `json:"discount_amount"`
+	// The line's tax, as on PurchaseItem.
+	TaxClass  string       `json:"tax_class"`
+	TaxRate   money.Rate   `json:"tax_rate"`
+	NetAmount money.Amount `json:"net_amount"`
+	TaxAmount money.Amount `json:"tax_amount"`
+
+	CancelledQuantity int          `json:"cancelled_quantity,omitempty"`
+	ReturnedQuantity  int          `json:"returned_quantity,omitempty"`
+	Returns           []LineReturn `json:"returns,omitempty"`
+
+	OriginalPrice    money.Amount `json:"original_price"`
+	OriginalCurrency string       `json:"original_currency"`
+	ExchangeRate     money.Rate   `json:"exchange_rate"`
 }
 
 type PurchaseHistoryResponse struct {
-	PurchaseID   uuid.UUID             `json:"purchase_id"`
-	TotalAmount  float64               `json:"total_amount"`
-	PurchasedAt  time.Time             `json:"purchased_at"`
-	Items        []PurchaseItemHistory `json:"items"`
+	PurchaseID  uuid.UUID             `json:"purchase_id"`
+	TotalAmount money.Amount          `json:"total_amount"`
+	PurchasedAt time.Time             `json:"purchased_at"`
+	Items       []PurchaseItemHistory `json:"items"`
+}
+
+// PurchaseDetailResponse is a single purchase as shown on its detail page.
+type PurchaseDetailResponse struct {
+	ID          uuid.UUID             `json:"id"`
+	UserID      uuid.UUID             `json:"user_id"`
+	Status      string                `json:"status"`
+	TotalAmount money.Amount          `json:"total_amount"`
+	Currency    string                `json:"currency"`
+	CreatedAt   time.Time             `json:"created_at"`
+	PaidAt      *time.Time            `json:"paid_at,omitempty"`
+	FulfilledAt *time.Time            `json:"fulfilled_at,omitempty"`
+	DeliveredAt *time.Time            `json:"delivered_at,omitempty"`
+	CancelledAt *time.Time            `json:"cancelled_at,omitempty"`
+	RefundedAt  *time.Time            `json:"refunded_at,omitempty"`
+	Items       []PurchaseItemHistory `json:"items"`
+
+	// InvoiceNumber is as on Purchase.
+	InvoiceNumber string `json:"invoice_number,omitempty"`
+
+	// DiscountAmount and CouponCode are as on Purchase.
+	DiscountAmount money.Amount `json:"discount_amount"`
+	CouponCode     string       `json:"coupon_code,omitempty"`
+
+	// SubtotalAmount, TaxAmount and TaxMode are as on Purchase.
+	SubtotalAmount money.Amount `json:"subtotal_amount"`
+	TaxAmount      money.Amount `json:"tax_amount"`
+	TaxMode        string       `json:"tax_mode"`
+
+	// The shipping details are as on Purchase.
+	ShippingMethod      string           `json:"shipping_method,omitempty"`
+	ShippingZone        string           `json:"shipping_zone,omitempty"`
+	ShippingWeightGrams int              `json:"shipping_weight_grams"`
+	ShippingFee         money.Amount     `json:"shipping_fee"`
+	ShippingAddress     *ShippingAddress `json:"shipping_address,omitempty"`
 }
 
 type PurchaseItemHistory struct {
-	ItemID          uuid.UUID `json:"item_id"`
-	Name            string    `json:"name"`
-	Quantity        int       `json:"quantity"`
-	PriceAtPurchase float64   `json:"price_at_purchase"`
-	TotalPrice      float64   `json:"total_price"`
-}
\ No newline at end of file
+	ID              uuid.UUID    `json:"id"`
+	ItemID          uuid.UUID    `json:"item_id"`
+	VariantID       *uuid.UUID   `json:"variant_id,omitempty"`
+	SKU             string       `json:"sku,omitempty"`
+	Name            string       `json:"name"`
+	Quantity        int          `json:"quantity"`
+	PriceAtPurchase money.Amount `json:"price_at_purchase"`
+	// DiscountAmount is taken off the line before tax. TotalPrice is what
+	// the line cost: NetAmount plus TaxAmount.
+	DiscountAmount money.Amount `json:"discount_amount"`
+	TaxClass       string       `json:"tax_class"`
+	TaxRate        money.Rate   `json:"tax_rate"`
+	NetAmount      money.Amount `json:"net_amount"`
+	TaxAmount      money.Amount `json:"tax_amount"`
+	TotalPrice     money.Amount `json:"total_price"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purchase statuses. A purchase moves forward through pending, paid,
+// fulfilled and delivered; it may be cancelled before it is fulfilled and
+// refunded once it has been paid. Cancelled and refunded are final.
+const (
+	PurchasePending   = "pending"
+	PurchasePaid      = "paid"
+	PurchaseFulfilled = "fulfilled"
+	PurchaseDelivered = "delivered"
+	PurchaseCancelled = "cancelled"
+	PurchaseRefunded  = "refunded"
+)
+
+// purchaseTransitions lists the statuses each status may move to. A pending
+// purchase becomes paid only when its saga completes, never by a status change.
+var purchaseTransitions = map[string][]string{
+	PurchasePending:   {PurchaseCancelled},
+	PurchasePaid:      {PurchaseFulfilled, PurchaseCancelled, PurchaseRefunded},
+	PurchaseFulfilled: {PurchaseDelivered, PurchaseRefunded},
+	PurchaseDelivered: {PurchaseRefunded},
+}
+
+// CanTransition reports whether a purchase in status from may move to to.
+func CanTransition(from, to string) bool {
+	for _, next := range purchaseTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PurchaseStatusChange is one entry of a purchase's status history. The first
+// entry of every purchase has no FromStatus.
+type PurchaseStatusChange struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	PurchaseID uuid.UUID `db:"purchase_id" json:"-"`
+	FromStatus string    `db:"from_status" json:"from_status,omitempty"`
+	ToStatus   string    `db:"to_status" json:"to_status"`
+	ActorID    string    `db:"actor_id" json:"actor_id,omitempty"`
+	Note       string    `db:"note" json:"note,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+type UpdatePurchaseStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=pending paid fulfilled delivered cancelled refunded"`
+	Note   string `json:"note" validate:"max=500"`
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// ReportQuery selects what a sales report covers: the days From to To,
+// inclusive, in TimeZone, and sales in Currency. Interval and By/Limit only
+// apply to the reports that group by period or rank items.
+type ReportQuery struct {
+	From     string `query:"from" validate:"omitempty,datetime=2006-01-02"`
+	To       string `query:"to" validate:"omitempty,datetime=2006-01-02"`
+	TimeZone string `query:"tz" validate:"omitempty,ne=Local,timezone"`
+	Currency string `query:"currency" validate:"omitempty,iso4217"`
+	Interval string `query:"interval" validate:"omitempty,oneof=day week month"`
+	By       string `query:"by" validate:"omitempty,oneof=quantity revenue"`
+	Limit    int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Format   string `query:"format" validate:"omitempty,oneof=json csv"`
+}
+
+// ReportRange is a normalised ReportQuery: sales from From up to but not
+// including To.
+type ReportRange struct {
+	From     time.Time
+	To       time.Time
+	TimeZone string
+	Currency string
+}
+
+// ReportMeta describes the range a report covers and how fresh its data
+// is. Sales made after RefreshedAt are not in it yet.
+type ReportMeta struct {
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	TimeZone    string     `json:"tz"`
+	Currency    string     `json:"currency"`
+	RefreshedAt *time.Time `json:"refreshed_at"`
+}
+
+// RevenuePeriod is the sales of one day, week or month. Revenue is what was
+// charged for the units not cancelled or returned, after discount and with
+// tax; shipping fees are left out.
+type RevenuePeriod struct {
+	Period  string       `json:"period"`
+	Orders  int          `json:"orders"`
+	Units   int          `json:"units"`
+	Revenue money.Amount `json:"revenue"`
+}
+
+type RevenueReport struct {
+	ReportMeta
+	Interval string          `json:"interval"`
+	Periods  []RevenuePeriod `json:"periods"`
+}
+
+// TopItem is an item's sales over a report's range, with the name it was
+// last sold under.
+type TopItem struct {
+	ItemID   uuid.UUID    `json:"item_id"`
+	Name     string       `json:"name"`
+	Quantity int          `json:"quantity"`
+	Revenue  money.Amount `json:"revenue"`
+}
+
+type TopItemsReport struct {
+	ReportMeta
+	By    string    `json:"by"`
+	Items []TopItem `json:"items"`
+}
+
+type OrderValueReport struct {
+	ReportMeta
+	Orders            int          `json:"orders"`
+	Revenue           money.Amount `json:"revenue"`
+	AverageOrderValue money.Amount `json:"average_order_value"`
+}
+
+// RepeatCustomerReport counts the customers who bought in a report's range
+// and those of them who bought more than once.
+type RepeatCustomerReport struct {
+	ReportMeta
+	Customers          int     `json:"customers"`
+	RepeatCustomers    int     `json:"repeat_customers"`
+	RepeatCustomerRate float64 `json:"repeat_customer_rate"`
+}
+
+// ReportRefreshResponse reports how many 15-minute buckets a refresh rebuilt.
+type ReportRefreshResponse struct {
+	Buckets     int       `json:"buckets"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+// CSV returns the report as rows, a header first.
+func (r *RevenueReport) CSV() [][]string {
+	rows := [][]string{{"period", "currency", "orders", "units", "revenue"}}
+	for _, p := range r.Periods {
+		rows = append(rows, []string{p.Period, r.Currency, strconv.Itoa(p.Orders), strconv.Itoa(p.Units), p.Revenue.String()})
+	}
+	return rows
+}
+
+func (r *TopItemsReport) CSV() [][]string {
+	rows := [][]string{{"rank", "item_id", "name", "currency", "quantity", "revenue"}}
+	for i, item := range r.Items {
+		rows = append(rows, []string{strconv.Itoa(i + 1), item.ItemID.String(), item.Name, r.Currency,
+			strconv.Itoa(item.Quantity), item.Revenue.String()})
+	}
+	return rows
+}
+
+func (r *OrderValueReport) CSV() [][]string {
+	return [][]string{
+		{"from", "to", "currency", "orders", "revenue", "average_order_value"},
+		{r.From, r.To, r.Currency, strconv.Itoa(r.Orders), r.Revenue.String(), r.AverageOrderValue.String()},
+	}
+}
+
+func (r *RepeatCustomerReport) CSV() [][]string {
+	return [][]string{
+		{"from", "to", "currency", "customers", "repeat_customers", "repeat_customer_rate"},
+		{r.From, r.To, r.Currency, strconv.Itoa(r.Customers), strconv.Itoa(r.RepeatCustomers),
+			strconv.FormatFloat(r.RepeatCustomerRate, 'f', 4, 64)},
+	}
+}
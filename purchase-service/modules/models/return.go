@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// Return statuses. A return is requested by the buyer and then approved or
+// rejected by an admin.
+const (
+	ReturnRequested = "requested"
+	ReturnApproved  = "approved"
+	ReturnRejected  = "rejected"
+)
+
+// Refund statuses. A refund is granted pending and paid out through the
+// payment gateway; it ends succeeded once the gateway has paid it, or failed
+// when the gateway refused it.
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+// PurchaseReturn asks to send back units of a delivered purchase. Approving
+// it restocks the units and grants a refund; StockRestoredAt stays nil until
+// item-service has taken the units back.
+type PurchaseReturn struct {
+	ID              uuid.UUID            `db:"id" json:"id"`
+	PurchaseID      uuid.UUID            `db:"purchase_id" json:"purchase_id"`
+	UserID          uuid.UUID            `db:"user_id" json:"user_id"`
+	Status          string               `db:"status" json:"status"`
+	Reason          string               `db:"reason" json:"reason"`
+	DecisionNote    string               `db:"decision_note" json:"decision_note,omitempty"`
+	DecidedBy       string               `db:"decided_by" json:"decided_by,omitempty"`
+	DecidedAt       *time.Time           `db:"decided_at" json:"decided_at,omitempty"`
+	StockRestoredAt *time.Time           `db:"stock_restored_at" json:"stock_restored_at,omitempty"`
+	CreatedAt       time.Time            `db:"created_at" json:"created_at"`
+	Items           []PurchaseReturnItem `json:"items"`
+	Refund          *Refund              `json:"refund,omitempty"`
+}
+
+type PurchaseReturnItem struct {
+	PurchaseItemID uuid.UUID  `db:"purchase_item_id" json:"purchase_item_id"`
+	ItemID         uuid.UUID  `db:"item_id" json:"item_id"`
+	VariantID      *uuid.UUID `db:"variant_id" json:"variant_id,omitempty"`
+	Quantity       int        `db:"quantity" json:"quantity"`
+}
+
+// Refund is the money owed for an approved return or a cancellation of a
+// paid purchase, priced at what the units were bought for. Exactly one of
+// ReturnID and CancellationID is set.
+type Refund struct {
+	ID             uuid.UUID    `db:"id" json:"id"`
+	ReturnID       *uuid.UUID   `db:"return_id" json:"return_id,omitempty"`
+	CancellationID *uuid.UUID   `db:"cancellation_id" json:"cancellation_id,omitempty"`
+	PurchaseID     uuid.UUID    `db:"purchase_id" json:"purchase_id"`
+	Amount         money.Amount `db:"amount" json:"amount"`
+	Currency       string       `db:"currency" json:"currency"`
+	Status         string       `db:"status" json:"status"`
+	FailureReason  string       `db:"failure_reason" json:"failure_reason,omitempty"`
+	CreatedAt      time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time    `db:"updated_at" json:"updated_at"`
+}
+
+// LineReturn is how a purchase line is affected by one return, as shown in
+// the purchase history.
+type LineReturn struct {
+	ReturnID     uuid.UUID     `json:"return_id"`
+	Quantity     int           `json:"quantity"`
+	Status       string        `json:"status"`
+	RefundStatus string        `json:"refund_status,omitempty"`
+	RefundAmount *money.Amount `json:"refund_amount,omitempty"`
+}
+
+type CreateReturnRequest struct {
+	Reason string              `json:"reason" validate:"required,max=500"`
+	Items  []ReturnItemRequest `json:"items" validate:"required,min=1,max=50,dive"`
+}
+
+type ReturnItemRequest struct {
+	PurchaseItemID uuid.UUID `json:"purchase_item_id" validate:"required"`
+	Quantity       int       `json:"quantity" validate:"required,gt=0"`
+}
+
+type ReviewReturnRequest struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+type ReturnQuery struct {
+	Status string `query:"status" validate:"omitempty,oneof=requested approved rejected"`
+}
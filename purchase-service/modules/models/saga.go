@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Purchase saga statuses. A saga starts pending, and ends completed when all
+// stock was taken or failed once every decrement and its reservation have been
+// compensated.
+const (
+	SagaPending      = "pending"
+	SagaCompensating = "compensating"
+	SagaCompleted    = "completed"
+	SagaFailed       = "failed"
+)
+
+// PurchaseSaga is the persisted progress of creating a purchase: the stock of
+// every line is first reserved in item-service, recorded as ReservationID, and
+// then drawn from the reservation one line at a time, with CompletedSteps
+// counting the lines done so far.
+type PurchaseSaga struct {
+	PurchaseID     uuid.UUID  `db:"purchase_id" json:"purchase_id"`
+	Status         string     `db:"status" json:"status"`
+	CompletedSteps int        `db:"completed_steps" json:"completed_steps"`
+	ReservationID  *uuid.UUID `db:"reservation_id" json:"reservation_id,omitempty"`
+	FailureReason  string     `db:"failure_reason" json:"failure_reason,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,201 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// Tokens the fake gateway treats specially; any other token is authorized.
+const (
+	// TokenDecline makes authorization fail with ErrDeclined.
+	TokenDecline = "tok_decline"
+	// TokenTimeout authorizes the payment but answers with ErrTimeout, as
+	// when the gateway's response is lost.
+	TokenTimeout = "tok_timeout"
+)
+
+const (
+	fakeAuthorized = "authorized"
+	fakeCaptured   = "captured"
+	fakeDeclined   = "declined"
+	fakeVoided     = "voided"
+	fakeRefunded   = "refunded"
+)
+
+type fakePayment struct {
+	reference string
+	amount    money.Amount
+	refunded  money.Amount
+	refunds   map[uuid.UUID]bool
+	status    string
+}
+
+type fakeGateway struct {
+	mu            sync.Mutex
+	payments      map[uuid.UUID]*fakePayment
+	webhookURL    string
+	webhookSecret string
+	client        *http.Client
+}
+
+// NewFakeGateway returns an in-memory gateway for local use. It keeps no
+// state across restarts. Each change is reported by a signed webhook to
+// webhookURL, unless it is empty.
+func NewFakeGateway(webhookURL, webhookSecret string) PaymentGateway {
+	return &fakeGateway{
+		payments:      make(map[uuid.UUID]*fakePayment),
+		webhookURL:    webhookURL,
+		webhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (g *fakeGateway) Authorize(ctx context.Context, charge Charge) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if payment, ok := g.payments[charge.PaymentID]; ok {
+		if payment.status == fakeDeclined {
+			return "", ErrDeclined
+		}
+		return payment.reference, nil
+	}
+
+	payment := &fakePayment{reference: "fake_" + uuid.NewString(), amount: charge.Amount, status: fakeAuthorized}
+	if charge.Token == TokenDecline {
+		payment.status = fakeDeclined
+	}
+	g.payments[charge.PaymentID] = payment
+	if payment.status == fakeDeclined {
+		g.notify(EventDeclined, charge.PaymentID, payment, payment.amount)
+	} else {
+		g.notify(EventAuthorized, charge.PaymentID, payment, payment.amount)
+	}
+
+	switch charge.Token {
+	case TokenDecline:
+		return "", ErrDeclined
+	case TokenTimeout:
+		return "", ErrTimeout
+	}
+	return payment.reference, nil
+}
+
+func (g *fakeGateway) Capture(ctx context.Context, paymentID uuid.UUID) error {
+	return g.change(paymentID, func(payment *fakePayment) (string, money.Amount, error) {
+		switch payment.status {
+		case fakeCaptured:
+			return "", 0, nil
+		case fakeAuthorized:
+			payment.status = fakeCaptured
+			return EventCaptured, payment.amount, nil
+		}
+		return "", 0, ErrInvalidOperation
+	})
+}
+
+func (g *fakeGateway) Void(ctx context.Context, paymentID uuid.UUID) error {
+	return g.change(paymentID, func(payment *fakePayment) (string, money.Amount, error) {
+		switch payment.status {
+		case fakeVoided, fakeDeclined:
+			return "", 0, nil
+		case fakeAuthorized:
+			payment.status = fakeVoided
+			return EventVoided, payment.amount, nil
+		}
+		return "", 0, ErrAlreadyCaptured
+	})
+}
+
+// Refund reports the payment refunded only once its whole amount has been
+// paid back.
+func (g *fakeGateway) Refund(ctx context.Context, paymentID, refundID uuid.UUID, amount money.Amount) error {
+	return g.change(paymentID, func(payment *fakePayment) (string, money.Amount, error) {
+		if payment.refunds[refundID] {
+			return "", 0, nil
+		}
+		if payment.status != fakeCaptured || payment.refunded.Add(amount) > payment.amount {
+			return "", 0, ErrInvalidOperation
+		}
+		if payment.refunds == nil {
+			payment.refunds = make(map[uuid.UUID]bool)
+		}
+		payment.refunds[refundID] = true
+		payment.refunded = payment.refunded.Add(amount)
+		if payment.refunded == payment.amount {
+			payment.status = fakeRefunded
+			return EventRefunded, payment.amount, nil
+		}
+		return "", 0, nil
+	})
+}
+
+// change applies apply to a payment and sends the event it returns, if any.
+func (g *fakeGateway) change(paymentID uuid.UUID, apply func(*fakePayment) (string, money.Amount, error)) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	payment, ok := g.payments[paymentID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	event, amount, err := apply(payment)
+	if err != nil {
+		return err
+	}
+	if event != "" {
+		g.notify(event, paymentID, payment, amount)
+	}
+	return nil
+}
+
+// notify sends a webhook event in the background. Delivery is attempted
+// once.
+func (g *fakeGateway) notify(eventType string, paymentID uuid.UUID, payment *fakePayment, amount money.Amount) {
+	if g.webhookURL == "" {
+		return
+	}
+	body, err := json.Marshal(Event{
+		ID:        uuid.New(),
+		Type:      eventType,
+		PaymentID: paymentID,
+		Reference: payment.reference,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("fake payment gateway: encoding webhook: %v", err)
+		return
+	}
+
+	go func() {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req, err := http.NewRequest(http.MethodPost, g.webhookURL, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("fake payment gateway: webhook: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(g.webhookSecret, timestamp, body))
+
+		resp, err := g.client.Do(req)
+		if err != nil {
+			log.Printf("fake payment gateway: webhook for payment %s: %v", paymentID, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("fake payment gateway: webhook for payment %s: status %d", paymentID, resp.StatusCode)
+		}
+	}()
+}
@@ -0,0 +1,59 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+var (
+	ErrDeclined         = errors.New("payment was declined")
+	ErrTimeout          = errors.New("payment gateway did not answer in time")
+	ErrPaymentNotFound  = errors.New("payment gateway has no such payment")
+	ErrAlreadyCaptured  = errors.New("payment has been captured and can only be refunded")
+	ErrInvalidOperation = errors.New("payment is not in a state that allows this operation")
+)
+
+// Charge is a payment to authorize. PaymentID is our own ID for it; every
+// later call names the payment by it, so a call that timed out can be
+// retried or undone without knowing what the gateway made of it.
+type Charge struct {
+	PaymentID uuid.UUID
+	Amount    money.Amount
+	Currency  string
+	// Token is the gateway's token for the shopper's payment method.
+	Token string
+}
+
+// PaymentGateway moves money through a payment provider. Every operation is
+// idempotent per payment. ErrTimeout means the outcome is unknown: the
+// gateway may or may not have done what was asked, and reports it later by
+// webhook.
+type PaymentGateway interface {
+	// Authorize holds the amount on the shopper's payment method and returns
+	// the gateway's reference for the payment.
+	Authorize(ctx context.Context, charge Charge) (string, error)
+	// Capture takes the authorized amount.
+	Capture(ctx context.Context, paymentID uuid.UUID) error
+	// Void releases an authorization that was not captured. A captured
+	// payment fails with ErrAlreadyCaptured.
+	Void(ctx context.Context, paymentID uuid.UUID) error
+	// Refund pays back amount of a captured payment. A payment may be
+	// refunded in several parts; each is named by refundID, our own ID for
+	// it, so retrying a part never pays it twice.
+	Refund(ctx context.Context, paymentID, refundID uuid.UUID, amount money.Amount) error
+}
+
+// NewGateway builds the gateway selected by name. Only "fake" exists so far;
+// it reports to webhookURL, signing with webhookSecret.
+func NewGateway(name, webhookURL, webhookSecret string) (PaymentGateway, error) {
+	switch strings.ToLower(name) {
+	case "fake":
+		return NewFakeGateway(webhookURL, webhookSecret), nil
+	}
+	return nil, fmt.Errorf("unknown payment gateway %q", name)
+}
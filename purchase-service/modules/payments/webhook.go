@@ -0,0 +1,73 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// Webhook headers. The signature is the hex HMAC-SHA256 of the timestamp, a
+// dot and the raw body, keyed with the shared webhook secret.
+const (
+	SignatureHeader = "X-Payment-Signature"
+	TimestampHeader = "X-Payment-Timestamp"
+)
+
+// SignatureTolerance is how far a webhook's timestamp may be from now before
+// it is refused as a replay.
+const SignatureTolerance = 5 * time.Minute
+
+// Webhook event types.
+const (
+	EventAuthorized = "payment.authorized"
+	EventCaptured   = "payment.captured"
+	EventDeclined   = "payment.declined"
+	EventVoided     = "payment.voided"
+	EventRefunded   = "payment.refunded"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Event is a webhook callback reporting what happened to a payment.
+type Event struct {
+	ID        uuid.UUID    `json:"id"`
+	Type      string       `json:"type"`
+	PaymentID uuid.UUID    `json:"payment_id"`
+	Reference string       `json:"reference"`
+	Amount    money.Amount `json:"amount"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// Sign returns the signature of body sent at timestamp, a Unix time.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a webhook's signature and that it was sent within
+// SignatureTolerance of now. An empty secret refuses every webhook.
+func Verify(secret, timestamp, signature string, body []byte, now time.Time) error {
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
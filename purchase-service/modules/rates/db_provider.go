@@ -0,0 +1,46 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"shop-crud/item-service/pkg/money"
+)
+
+type dbProvider struct {
+	db *pgxpool.Pool
+}
+
+// NewDBProvider reads rates from the exchange_rates table, so they can be
+// updated without a restart. A missing pair is derived from its inverse.
+func NewDBProvider(db *pgxpool.Pool) ExchangeRateProvider {
+	return &dbProvider{db: db}
+}
+
+func (p *dbProvider) Rate(ctx context.Context, from, to string) (money.Rate, error) {
+	if from == to {
+		return money.OneRate(), nil
+	}
+
+	query := `SELECT base_currency, rate FROM exchange_rates
+			  WHERE (base_currency = $1 AND quote_currency = $2) OR (base_currency = $2 AND quote_currency = $1)
+			  ORDER BY base_currency = $1 DESC LIMIT 1`
+
+	var base string
+	var rate money.Rate
+	err := p.db.QueryRow(ctx, query, from, to).Scan(&base, &rate)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+		}
+		return money.Rate{}, err
+	}
+
+	if base != from {
+		return rate.Inverse(), nil
+	}
+	return rate, nil
+}
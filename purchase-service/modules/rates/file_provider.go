@@ -0,0 +1,67 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"shop-crud/item-service/pkg/money"
+)
+
+// rateFile is the layout of the exchange rate file. Every rate is the amount
+// of that currency bought by one unit of Base, for example:
+//
+//	{"base": "USD", "rates": {"IDR": "16250", "EUR": "0.92"}}
+type rateFile struct {
+	Base  string                `json:"base"`
+	Rates map[string]money.Rate `json:"rates"`
+}
+
+type fileProvider struct {
+	base  string
+	rates map[string]money.Rate
+}
+
+// NewFileProvider loads a fixed set of rates from a JSON file at startup.
+func NewFileProvider(path string) (ExchangeRateProvider, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading exchange rate file: %w", err)
+	}
+
+	var file rateFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing exchange rate file: %w", err)
+	}
+	if file.Base == "" {
+		return nil, fmt.Errorf("exchange rate file %s has no base currency", path)
+	}
+
+	rates := make(map[string]money.Rate, len(file.Rates)+1)
+	for currency, rate := range file.Rates {
+		if !rate.IsValid() {
+			return nil, fmt.Errorf("exchange rate file %s: %s: %w", path, currency, money.ErrInvalidRate)
+		}
+		rates[strings.ToUpper(currency)] = rate
+	}
+	rates[strings.ToUpper(file.Base)] = money.OneRate()
+
+	return &fileProvider{base: strings.ToUpper(file.Base), rates: rates}, nil
+}
+
+func (p *fileProvider) Rate(ctx context.Context, from, to string) (money.Rate, error) {
+	if from == to {
+		return money.OneRate(), nil
+	}
+	fromRate, ok := p.rates[from]
+	if !ok {
+		return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+	}
+	toRate, ok := p.rates[to]
+	if !ok {
+		return money.Rate{}, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+	}
+	return toRate.Div(fromRate), nil
+}
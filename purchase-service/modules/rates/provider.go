@@ -0,0 +1,30 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"shop-crud/item-service/pkg/money"
+)
+
+var ErrRateNotFound = errors.New("exchange rate not available")
+
+// ExchangeRateProvider quotes the rate for converting between two currencies.
+// Implementations must return the identity rate when from and to are equal.
+type ExchangeRateProvider interface {
+	Rate(ctx context.Context, from, to string) (money.Rate, error)
+}
+
+// NewProvider builds the provider selected by source ("db" or "file").
+func NewProvider(source, file string, db *pgxpool.Pool) (ExchangeRateProvider, error) {
+	switch strings.ToLower(source) {
+	case "db":
+		return NewDBProvider(db), nil
+	case "file":
+		return NewFileProvider(file)
+	}
+	return nil, fmt.Errorf("unknown exchange rate source %q", source)
+}
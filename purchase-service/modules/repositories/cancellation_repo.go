@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"context"
+	purchaseModels "purchase-service/modules/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CancellationRepository interface {
+	Create(ctx context.Context, cancellation *purchaseModels.PurchaseCancellation, purchaseStatus string, refund *purchaseModels.Refund) (bool, error)
+	FindByPurchaseIDs(ctx context.Context, purchaseIDs []uuid.UUID) ([]purchaseModels.PurchaseCancellation, error)
+	FindUnrestored(ctx context.Context, before time.Time, limit int) ([]purchaseModels.PurchaseCancellation, error)
+	MarkStockRestored(ctx context.Context, cancellationID uuid.UUID) (time.Time, error)
+}
+
+type cancellationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewCancellationRepository(db *pgxpool.Pool) CancellationRepository {
+	return &cancellationRepository{db: db}
+}
+
+// Create records the cancellation, counts its units as cancelled on the
+// purchase lines and records refund, unless it is nil, in one transaction.
+// When no units remain, the purchase moves from purchaseStatus to cancelled
+// and true is returned. It returns pgx.ErrNoRows when the purchase is no
+// longer in purchaseStatus, its saga has not completed, or a line has fewer
+// units left than the cancellation asks for.
+func (r *cancellationRepository) Create(ctx context.Context, cancellation *purchaseModels.PurchaseCancellation, purchaseStatus string, refund *purchaseModels.Refund) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the purchase and its saga serializes cancellations of the same
+	// purchase, and keeps the saga from completing or failing meanwhile. Only
+	// a completed saga has taken all the stock a cancellation gives back.
+	var status, sagaStatus string
+	lockQuery := `SELECT p.status, s.status FROM purchases p JOIN purchase_sagas s ON s.purchase_id = p.id
+				  WHERE p.id = $1 FOR UPDATE`
+	err = tx.QueryRow(ctx, lockQuery, cancellation.PurchaseID).Scan(&status, &sagaStatus)
+	if err != nil {
+		return false, err
+	}
+	if status != purchaseStatus || sagaStatus != purchaseModels.SagaCompleted {
+		return false, pgx.ErrNoRows
+	}
+
+	query := `INSERT INTO purchase_cancellations (id, purchase_id, reason, actor_id, amount, created_at) VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)`
+	_, err = tx.Exec(ctx, query, cancellation.ID, cancellation.PurchaseID, cancellation.Reason, cancellation.ActorID, cancellation.Amount, cancellation.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	lineQuery := `UPDATE purchase_items SET cancelled_quantity = cancelled_quantity + $3
+				  WHERE id = $1 AND purchase_id = $2 AND quantity - cancelled_quantity >= $3`
+	itemQuery := `INSERT INTO purchase_cancellation_items (cancellation_id, purchase_item_id, quantity) VALUES ($1, $2, $3)`
+	for _, item := range cancellation.Items {
+		result, err := tx.Exec(ctx, lineQuery, item.PurchaseItemID, cancellation.PurchaseID, item.Quantity)
+		if err != nil {
+			return false, err
+		}
+		if result.RowsAffected() == 0 {
+			return false, pgx.ErrNoRows
+		}
+		if _, err := tx.Exec(ctx, itemQuery, cancellation.ID, item.PurchaseItemID, item.Quantity); err != nil {
+			return false, err
+		}
+	}
+
+	if refund != nil {
+		if err := insertRefund(ctx, tx, refund); err != nil {
+			return false, err
+		}
+	}
+
+	var remaining int
+	err = tx.QueryRow(ctx, `SELECT COALESCE(SUM(quantity - cancelled_quantity), 0) FROM purchase_items WHERE purchase_id = $1`,
+		cancellation.PurchaseID).Scan(&remaining)
+	if err != nil {
+		return false, err
+	}
+	cancelled := remaining == 0
+	if cancelled {
+		err = updateStatus(ctx, tx, cancellation.PurchaseID, purchaseStatus, &purchaseModels.PurchaseStatusChange{
+			ToStatus:  purchaseModels.PurchaseCancelled,
+			ActorID:   cancellation.ActorID,
+			Note:      cancellation.Reason,
+			CreatedAt: cancellation.CreatedAt,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return cancelled, nil
+}
+
+// FindByPurchaseIDs returns the cancellations of several purchases, oldest
+// first.
+func (r *cancellationRepository) FindByPurchaseIDs(ctx context.Context, purchaseIDs []uuid.UUID) ([]purchaseModels.PurchaseCancellation, error) {
+	return r.find(ctx, `c.purchase_id = ANY($1) ORDER BY c.created_at, c.id`, purchaseIDs)
+}
+
+// FindUnrestored returns cancellations recorded before before whose stock has
+// not been given back yet, oldest first.
+func (r *cancellationRepository) FindUnrestored(ctx context.Context, before time.Time, limit int) ([]purchaseModels.PurchaseCancellation, error) {
+	return r.find(ctx, `c.stock_restored_at IS NULL AND c.created_at < $1 ORDER BY c.created_at, c.id LIMIT $2`, before, limit)
+}
+
+func (r *cancellationRepository) MarkStockRestored(ctx context.Context, cancellationID uuid.UUID) (time.Time, error) {
+	var restoredAt time.Time
+	query := `UPDATE purchase_cancellations SET stock_restored_at = COALESCE(stock_restored_at, NOW()) WHERE id = $1 RETURNING stock_restored_at`
+	err := r.db.QueryRow(ctx, query, cancellationID).Scan(&restoredAt)
+	return restoredAt, err
+}
+
+// find loads the cancellations matching condition together with their lines.
+func (r *cancellationRepository) find(ctx context.Context, condition string, args ...interface{}) ([]purchaseModels.PurchaseCancellation, error) {
+	query := `SELECT c.id, c.purchase_id, c.reason, COALESCE(c.actor_id, ''), c.amount, c.stock_restored_at, c.created_at,
+					 ` + refundColumns + `
+			  FROM purchase_cancellations c
+			  LEFT JOIN refunds f ON f.cancellation_id = c.id
+			  WHERE ` + condition
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cancellations := []purchaseModels.PurchaseCancellation{}
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var c purchaseModels.PurchaseCancellation
+		// The refund columns are all NULL for purchases cancelled unpaid.
+		var refund joinedRefund
+		dest := append([]interface{}{&c.ID, &c.PurchaseID, &c.Reason, &c.ActorID, &c.Amount, &c.StockRestoredAt, &c.CreatedAt},
+			refund.dest()...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		c.Refund = refund.refund()
+		c.Items = []purchaseModels.PurchaseCancellationItem{}
+		cancellations = append(cancellations, c)
+		ids = append(ids, c.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(cancellations) == 0 {
+		return cancellations, nil
+	}
+
+	itemQuery := `SELECT ci.cancellation_id, ci.purchase_item_id, pi.item_id, pi.variant_id, ci.quantity
+				  FROM purchase_cancellation_items ci
+				  JOIN purchase_items pi ON pi.id = ci.purchase_item_id
+				  WHERE ci.cancellation_id = ANY($1)
+				  ORDER BY ci.purchase_item_id`
+	itemRows, err := r.db.Query(ctx, itemQuery, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	byID := make(map[uuid.UUID]*purchaseModels.PurchaseCancellation, len(cancellations))
+	for i := range cancellations {
+		byID[cancellations[i].ID] = &cancellations[i]
+	}
+	for itemRows.Next() {
+		var cancellationID uuid.UUID
+		var item purchaseModels.PurchaseCancellationItem
+		if err := itemRows.Scan(&cancellationID, &item.PurchaseItemID, &item.ItemID, &item.VariantID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		byID[cancellationID].Items = append(byID[cancellationID].Items, item)
+	}
+	if err = itemRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cancellations, nil
+}
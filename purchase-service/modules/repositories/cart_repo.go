@@ -0,0 +1,235 @@
+package repositories
+
+import (
+	"context"
+	purchaseModels "purchase-service/modules/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CartRepository interface {
+	FindOrCreateForUser(ctx context.Context, userID uuid.UUID) (*purchaseModels.Cart, error)
+	FindGuest(ctx context.Context, cartID uuid.UUID) (*purchaseModels.Cart, error)
+	CreateGuest(ctx context.Context) (*purchaseModels.Cart, error)
+	FindLines(ctx context.Context, cartID uuid.UUID) ([]purchaseModels.CartLine, error)
+	AddLine(ctx context.Context, line *purchaseModels.CartLine) error
+	UpdateQuantity(ctx context.Context, cartID, lineID uuid.UUID, quantity int) error
+	RemoveLine(ctx context.Context, cartID, lineID uuid.UUID) error
+	RemoveLines(ctx context.Context, cartID uuid.UUID, lineIDs []uuid.UUID) error
+	Merge(ctx context.Context, guestCartID, userCartID uuid.UUID) error
+}
+
+type cartRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewCartRepository(db *pgxpool.Pool) CartRepository {
+	return &cartRepository{db: db}
+}
+
+// cartLineConflict is the unique index a cart line is upserted against: one
+// line per item and variant, with no variant treated as a value of its own.
+const cartLineConflict = `(cart_id, item_id, COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'::uuid))`
+
+const cartLineColumns = `id, cart_id, item_id, variant_id, quantity, price_when_added, currency_when_added, added_at`
+
+func cartLineFields(line *purchaseModels.CartLine) []any {
+	return []any{&line.ID, &line.CartID, &line.ItemID, &line.VariantID, &line.Quantity, &line.PriceWhenAdded,
+		&line.CurrencyWhenAdded, &line.AddedAt}
+}
+
+// FindOrCreateForUser returns the user's cart, creating it on first use.
+func (r *cartRepository) FindOrCreateForUser(ctx context.Context, userID uuid.UUID) (*purchaseModels.Cart, error) {
+	now := time.Now()
+	query := `INSERT INTO carts (id, user_id, created_at, updated_at) VALUES ($1, $2, $3, $3)
+		ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+		RETURNING id, user_id, created_at, updated_at`
+	var cart purchaseModels.Cart
+	err := r.db.QueryRow(ctx, query, uuid.New(), userID, now).Scan(&cart.ID, &cart.UserID, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+// FindGuest returns a guest cart. Carts that belong to a user are never
+// returned, so a cart ID alone cannot reach them.
+func (r *cartRepository) FindGuest(ctx context.Context, cartID uuid.UUID) (*purchaseModels.Cart, error) {
+	query := `SELECT id, user_id, created_at, updated_at FROM carts WHERE id = $1 AND user_id IS NULL`
+	var cart purchaseModels.Cart
+	err := r.db.QueryRow(ctx, query, cartID).Scan(&cart.ID, &cart.UserID, &cart.CreatedAt, &cart.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (r *cartRepository) CreateGuest(ctx context.Context) (*purchaseModels.Cart, error) {
+	now := time.Now()
+	cart := &purchaseModels.Cart{ID: uuid.New(), CreatedAt: now, UpdatedAt: now}
+	query := `INSERT INTO carts (id, user_id, created_at, updated_at) VALUES ($1, NULL, $2, $2)`
+	if _, err := r.db.Exec(ctx, query, cart.ID, now); err != nil {
+		return nil, err
+	}
+	return cart, nil
+}
+
+func (r *cartRepository) FindLines(ctx context.Context, cartID uuid.UUID) ([]purchaseModels.CartLine, error) {
+	query := `SELECT ` + cartLineColumns + ` FROM cart_items WHERE cart_id = $1 ORDER BY added_at, id`
+	rows, err := r.db.Query(ctx, query, cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []purchaseModels.CartLine
+	for rows.Next() {
+		var line purchaseModels.CartLine
+		if err := rows.Scan(cartLineFields(&line)...); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// AddLine adds a line to a cart. When the cart already has the item and
+// variant, the quantities are summed and the price is taken as the one the
+// shopper has just seen. line is updated with the stored row.
+func (r *cartRepository) AddLine(ctx context.Context, line *purchaseModels.CartLine) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO cart_items (` + cartLineColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT ` + cartLineConflict + ` DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity,
+			price_when_added = EXCLUDED.price_when_added, currency_when_added = EXCLUDED.currency_when_added
+		RETURNING ` + cartLineColumns
+	err = tx.QueryRow(ctx, query, line.ID, line.CartID, line.ItemID, line.VariantID, line.Quantity, line.PriceWhenAdded,
+		line.CurrencyWhenAdded, line.AddedAt).Scan(cartLineFields(line)...)
+	if err != nil {
+		return err
+	}
+	if err := touchCart(ctx, tx, line.CartID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UpdateQuantity sets the quantity of a cart line. It returns pgx.ErrNoRows
+// when the cart has no such line.
+func (r *cartRepository) UpdateQuantity(ctx context.Context, cartID, lineID uuid.UUID, quantity int) error {
+	return r.changeLine(ctx, cartID, `UPDATE cart_items SET quantity = $3 WHERE id = $1 AND cart_id = $2`, lineID, cartID, quantity)
+}
+
+// RemoveLine deletes a cart line. It returns pgx.ErrNoRows when the cart has
+// no such line.
+func (r *cartRepository) RemoveLine(ctx context.Context, cartID, lineID uuid.UUID) error {
+	return r.changeLine(ctx, cartID, `DELETE FROM cart_items WHERE id = $1 AND cart_id = $2`, lineID, cartID)
+}
+
+// RemoveLines deletes the given lines, ignoring any already gone.
+func (r *cartRepository) RemoveLines(ctx context.Context, cartID uuid.UUID, lineIDs []uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM cart_items WHERE cart_id = $1 AND id = ANY($2)`, cartID, lineIDs); err != nil {
+		return err
+	}
+	if err := touchCart(ctx, tx, cartID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// changeLine runs a statement against one line of a cart and marks the cart
+// updated, returning pgx.ErrNoRows when no line was affected.
+func (r *cartRepository) changeLine(ctx context.Context, cartID uuid.UUID, query string, args ...any) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	if err := touchCart(ctx, tx, cartID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Merge moves the lines of a guest cart into a user's cart, summing the
+// quantities of lines both carts have, and deletes the guest cart. It returns
+// pgx.ErrNoRows when the guest cart does not exist, e.g. because it was
+// already merged.
+func (r *cartRepository) Merge(ctx context.Context, guestCartID, userCartID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the guest cart keeps two logins from merging it twice.
+	var id uuid.UUID
+	err = tx.QueryRow(ctx, `SELECT id FROM carts WHERE id = $1 AND user_id IS NULL FOR UPDATE`, guestCartID).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `SELECT `+cartLineColumns+` FROM cart_items WHERE cart_id = $1`, guestCartID)
+	if err != nil {
+		return err
+	}
+	var lines []purchaseModels.CartLine
+	for rows.Next() {
+		var line purchaseModels.CartLine
+		if err := rows.Scan(cartLineFields(&line)...); err != nil {
+			rows.Close()
+			return err
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The user's lines keep the price they were added at; they are the older
+	// of the two.
+	query := `INSERT INTO cart_items (` + cartLineColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT ` + cartLineConflict + ` DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity`
+	for _, line := range lines {
+		_, err := tx.Exec(ctx, query, uuid.New(), userCartID, line.ItemID, line.VariantID, line.Quantity, line.PriceWhenAdded,
+			line.CurrencyWhenAdded, line.AddedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM carts WHERE id = $1`, guestCartID); err != nil {
+		return err
+	}
+	if err := touchCart(ctx, tx, userCartID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func touchCart(ctx context.Context, tx pgx.Tx, cartID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `UPDATE carts SET updated_at = $2 WHERE id = $1`, cartID, time.Now())
+	return err
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	purchaseModels "purchase-service/modules/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PaymentRepository interface {
+	FindByPurchaseID(ctx context.Context, purchaseID uuid.UUID) (*purchaseModels.Payment, error)
+	FindByID(ctx context.Context, paymentID uuid.UUID) (*purchaseModels.Payment, error)
+	Transition(ctx context.Context, paymentID uuid.UUID, from []string, to, reference, reason string) (bool, error)
+	FindUnsettled(ctx context.Context, before time.Time, limit int) ([]purchaseModels.Payment, error)
+}
+
+type paymentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPaymentRepository(db *pgxpool.Pool) PaymentRepository {
+	return &paymentRepository{db: db}
+}
+
+const paymentColumns = `pay.id, pay.purchase_id, pay.provider, COALESCE(pay.reference, ''), pay.amount, pay.currency, pay.status,
+						COALESCE(pay.failure_reason, ''), pay.created_at, pay.updated_at`
+
+func scanPayment(row pgx.Row, p *purchaseModels.Payment) error {
+	return row.Scan(&p.ID, &p.PurchaseID, &p.Provider, &p.Reference, &p.Amount, &p.Currency, &p.Status,
+		&p.FailureReason, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// insertPayment writes the pending payment of a purchase being created in tx.
+func insertPayment(ctx context.Context, tx pgx.Tx, payment *purchaseModels.Payment) error {
+	query := `INSERT INTO payments (id, purchase_id, provider, amount, currency, status, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $7)`
+	_, err := tx.Exec(ctx, query, payment.ID, payment.PurchaseID, payment.Provider, payment.Amount, payment.Currency,
+		payment.Status, payment.CreatedAt)
+	return err
+}
+
+func (r *paymentRepository) FindByPurchaseID(ctx context.Context, purchaseID uuid.UUID) (*purchaseModels.Payment, error) {
+	var payment purchaseModels.Payment
+	err := scanPayment(r.db.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments pay WHERE pay.purchase_id = $1`, purchaseID), &payment)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+func (r *paymentRepository) FindByID(ctx context.Context, paymentID uuid.UUID) (*purchaseModels.Payment, error) {
+	var payment purchaseModels.Payment
+	err := scanPayment(r.db.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments pay WHERE pay.id = $1`, paymentID), &payment)
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Transition moves the payment to status to when it is in one of the from
+// statuses. It returns false, changing nothing, otherwise. An empty reference
+// or reason keeps the one recorded.
+func (r *paymentRepository) Transition(ctx context.Context, paymentID uuid.UUID, from []string, to, reference, reason string) (bool, error) {
+	query := `UPDATE payments SET status = $3, reference = COALESCE(NULLIF($4, ''), reference),
+				  failure_reason = COALESCE(NULLIF($5, ''), failure_reason), updated_at = NOW()
+			  WHERE id = $1 AND status = ANY($2)`
+	result, err := r.db.Exec(ctx, query, paymentID, from, to, reference, reason)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// FindUnsettled returns payments, unchanged since before, that were left
+// behind by a purchase that did not finish: ones never captured, and captured
+// ones whose saga failed. Oldest first.
+func (r *paymentRepository) FindUnsettled(ctx context.Context, before time.Time, limit int) ([]purchaseModels.Payment, error) {
+	query := `SELECT ` + paymentColumns + `
+			  FROM payments pay
+			  JOIN purchase_sagas s ON s.purchase_id = pay.purchase_id
+			  WHERE pay.updated_at < $1
+				AND (pay.status IN ($2, $3) OR (pay.status = $4 AND s.status = $5))
+			  ORDER BY pay.updated_at
+			  LIMIT $6`
+
+	rows, err := r.db.Query(ctx, query, before, purchaseModels.PaymentPending, purchaseModels.PaymentAuthorized,
+		purchaseModels.PaymentCaptured, purchaseModels.SagaFailed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []purchaseModels.Payment{}
+	for rows.Next() {
+		var payment purchaseModels.Payment
+		if err := scanPayment(rows, &payment); err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"context"
+	purchaseModels "purchase-service/modules/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *purchaseModels.Promotion) error
+	Update(ctx context.Context, promotion *purchaseModels.Promotion) error
+	Delete(ctx context.Context, promotionID uuid.UUID) error
+	FindByID(ctx context.Context, promotionID uuid.UUID) (*purchaseModels.Promotion, error)
+	FindByCode(ctx context.Context, code string) (*purchaseModels.Promotion, error)
+	FindAll(ctx context.Context) ([]purchaseModels.Promotion, error)
+	CountRedemptions(ctx context.Context, promotionID, userID uuid.UUID) (total int, byUser int, err error)
+}
+
+type promotionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPromotionRepository(db *pgxpool.Pool) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+// redeemedBy is the condition on purchases rp that redeemed the promotion
+// whose ID is promotionID. Cancelled purchases and those whose saga failed
+// give their redemption back.
+func redeemedBy(promotionID string) string {
+	return `rp.promotion_id = ` + promotionID + ` AND rp.status <> '` + purchaseModels.PurchaseCancelled + `'
+		AND NOT EXISTS (SELECT 1 FROM purchase_sagas s WHERE s.purchase_id = rp.id AND s.status = '` + purchaseModels.SagaFailed + `')`
+}
+
+// redeemPromotion checks, inside the transaction creating a purchase, that the
+// promotion still exists and has uses left for the user. Locking the
+// promotion serializes its redemptions, so the limits hold under concurrent
+// purchases. It returns pgx.ErrNoRows when the purchase may not redeem it.
+func redeemPromotion(ctx context.Context, tx pgx.Tx, promotionID, userID uuid.UUID) error {
+	var maxUses, maxUsesPerUser *int
+	err := tx.QueryRow(ctx, `SELECT max_uses, max_uses_per_user FROM promotions WHERE id = $1 FOR UPDATE`, promotionID).
+		Scan(&maxUses, &maxUsesPerUser)
+	if err != nil {
+		return err
+	}
+
+	var used, usedByUser int
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE rp.user_id = $2) FROM purchases rp WHERE ` + redeemedBy("$1")
+	if err := tx.QueryRow(ctx, query, promotionID, userID).Scan(&used, &usedByUser); err != nil {
+		return err
+	}
+	if (maxUses != nil && used >= *maxUses) || (maxUsesPerUser != nil && usedByUser >= *maxUsesPerUser) {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *promotionRepository) Create(ctx context.Context, promotion *purchaseModels.Promotion) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO promotions (id, code, name, type, value, currency, buy_quantity, get_quantity, min_spend, max_uses,
+				  max_uses_per_user, starts_at, ends_at, active, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, $12, $13, $14, $15, $15)`
+	_, err = tx.Exec(ctx, query, promotion.ID, promotion.Code, promotion.Name, promotion.Type, promotion.Value, promotion.Currency,
+		promotion.BuyQuantity, promotion.GetQuantity, promotion.MinSpend, promotion.MaxUses, promotion.MaxUsesPerUser,
+		promotion.StartsAt, promotion.EndsAt, promotion.Active, promotion.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := replacePromotionScope(ctx, tx, promotion); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Update replaces a promotion and its scope. It returns pgx.ErrNoRows when
+// the promotion does not exist.
+func (r *promotionRepository) Update(ctx context.Context, promotion *purchaseModels.Promotion) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `UPDATE promotions SET code = $2, name = $3, type = $4, value = $5, currency = NULLIF($6, ''), buy_quantity = $7,
+				  get_quantity = $8, min_spend = $9, max_uses = $10, max_uses_per_user = $11, starts_at = $12, ends_at = $13,
+				  active = $14, updated_at = $15
+			  WHERE id = $1
+			  RETURNING created_at`
+	err = tx.QueryRow(ctx, query, promotion.ID, promotion.Code, promotion.Name, promotion.Type, promotion.Value, promotion.Currency,
+		promotion.BuyQuantity, promotion.GetQuantity, promotion.MinSpend, promotion.MaxUses, promotion.MaxUsesPerUser,
+		promotion.StartsAt, promotion.EndsAt, promotion.Active, promotion.UpdatedAt).Scan(&promotion.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := replacePromotionScope(ctx, tx, promotion); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func replacePromotionScope(ctx context.Context, tx pgx.Tx, promotion *purchaseModels.Promotion) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM promotion_items WHERE promotion_id = $1`, promotion.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM promotion_categories WHERE promotion_id = $1`, promotion.ID); err != nil {
+		return err
+	}
+	for _, itemID := range promotion.ItemIDs {
+		_, err := tx.Exec(ctx, `INSERT INTO promotion_items (promotion_id, item_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, promotion.ID, itemID)
+		if err != nil {
+			return err
+		}
+	}
+	for _, categoryID := range promotion.CategoryIDs {
+		_, err := tx.Exec(ctx, `INSERT INTO promotion_categories (promotion_id, category_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, promotion.ID, categoryID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a promotion. Purchases that redeemed it keep their coupon
+// code and discounts. It returns pgx.ErrNoRows when there is no such
+// promotion.
+func (r *promotionRepository) Delete(ctx context.Context, promotionID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM promotions WHERE id = $1`, promotionID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+func (r *promotionRepository) FindByID(ctx context.Context, promotionID uuid.UUID) (*purchaseModels.Promotion, error) {
+	return r.findOne(ctx, `p.id = $1`, promotionID)
+}
+
+// FindByCode looks a promotion up by its code, ignoring case.
+func (r *promotionRepository) FindByCode(ctx context.Context, code string) (*purchaseModels.Promotion, error) {
+	return r.findOne(ctx, `p.code = UPPER($1)`, code)
+}
+
+// FindAll lists promotions, newest first.
+func (r *promotionRepository) FindAll(ctx context.Context) ([]purchaseModels.Promotion, error) {
+	return r.find(ctx, `TRUE ORDER BY p.created_at DESC, p.id`)
+}
+
+func (r *promotionRepository) CountRedemptions(ctx context.Context, promotionID, userID uuid.UUID) (int, int, error) {
+	var total, byUser int
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE rp.user_id = $2) FROM purchases rp WHERE ` + redeemedBy("$1")
+	err := r.db.QueryRow(ctx, query, promotionID, userID).Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+func (r *promotionRepository) findOne(ctx context.Context, condition string, args ...interface{}) (*purchaseModels.Promotion, error) {
+	promotions, err := r.find(ctx, condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(promotions) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &promotions[0], nil
+}
+
+func (r *promotionRepository) find(ctx context.Context, condition string, args ...interface{}) ([]purchaseModels.Promotion, error) {
+	query := `SELECT p.id, p.code, p.name, p.type, p.value, COALESCE(p.currency, ''), p.buy_quantity, p.get_quantity, p.min_spend,
+					 p.max_uses, p.max_uses_per_user, p.starts_at, p.ends_at, p.active, p.created_at, p.updated_at,
+					 ARRAY(SELECT item_id FROM promotion_items WHERE promotion_id = p.id ORDER BY item_id),
+					 ARRAY(SELECT category_id FROM promotion_categories WHERE promotion_id = p.id ORDER BY category_id),
+					 (SELECT COUNT(*) FROM purchases rp WHERE ` + redeemedBy("p.id") + `)
+			  FROM promotions p
+			  WHERE ` + condition
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []purchaseModels.Promotion{}
+	for rows.Next() {
+		var p purchaseModels.Promotion
+		err := rows.Scan(&p.ID, &p.Code, &p.Name, &p.Type, &p.Value, &p.Currency, &p.BuyQuantity, &p.GetQuantity, &p.MinSpend,
+			&p.MaxUses, &p.MaxUsesPerUser, &p.StartsAt, &p.EndsAt, &p.Active, &p.CreatedAt, &p.UpdatedAt,
+			&p.ItemIDs, &p.CategoryIDs, &p.UsedCount)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+	return promotions, rows.Err()
+}
@@ -2,7 +2,12 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"purchase-service/modules/invoices"
 	purchaseModels "purchase-service/modules/models"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -10,9 +15,16 @@ import (
 )
 
 type PurchaseRepository interface {
-	CreatePurchaseInTx(ctx context.Context, purchase *purchaseModels.Purchase, items []purchaseModels.PurchaseItem) error
-	FindPurchasesByUserID(ctx context.Context, userID uuid.UUID) ([]purchaseModels.Purchase, error)
+	CreatePurchaseInTx(ctx context.Context, purchase *purchaseModels.Purchase, items []purchaseModels.PurchaseItem, payment *purchaseModels.Payment) error
+	FindPurchaseByID(ctx context.Context, purchaseID uuid.UUID) (*purchaseModels.Purchase, error)
+	FindUserPurchaseByID(ctx context.Context, purchaseID, userID uuid.UUID) (*purchaseModels.Purchase, error)
+	FindPurchasesWithItemsByUserID(ctx context.Context, userID uuid.UUID, query purchaseModels.PurchaseQuery) ([]purchaseModels.Purchase, []purchaseModels.PurchaseItem, error)
 	FindPurchaseItemsByPurchaseID(ctx context.Context, purchaseID uuid.UUID) ([]purchaseModels.PurchaseItem, error)
+	CompletePurchase(ctx context.Context, purchaseID uuid.UUID, change *purchaseModels.PurchaseStatusChange) (string, error)
+	FailPurchase(ctx context.Context, purchaseID uuid.UUID, change *purchaseModels.PurchaseStatusChange) (bool, error)
+	UpdateStatus(ctx context.Context, purchaseID uuid.UUID, from string, change *purchaseModels.PurchaseStatusChange) (bool, error)
+	FindStatusHistoryByPurchaseID(ctx context.Context, purchaseID uuid.UUID) ([]purchaseModels.PurchaseStatusChange, error)
+	FindStatusHistoryByPurchaseIDs(ctx context.Context, purchaseIDs []uuid.UUID) ([]purchaseModels.PurchaseStatusChange, error)
 }
 
 type purchaseRepository struct {
@@ -23,70 +35,310 @@ func NewPurchaseRepository(db *pgxpool.Pool) PurchaseRepository {
 	return &purchaseRepository{db: db}
 }
 
-func (r *purchaseRepository) CreatePurchaseInTx(ctx context.Context, purchase *purchaseModels.Purchase, items []purchaseModels.PurchaseItem) error {
+const purchaseColumns = `p.id, p.user_id, p.total_amount, p.currency, p.status, p.created_at,
+						 p.paid_at, p.fulfilled_at, p.delivered_at, p.cancelled_at, p.refunded_at,
+						 p.discount_amount, COALESCE(p.coupon_code, '') AS coupon_code, p.promotion_id,
+						 p.subtotal_amount, p.tax_amount, p.tax_mode,
+						 COALESCE(p.shipping_method, '') AS shipping_method, COALESCE(p.shipping_zone, '') AS shipping_zone,
+						 p.shipping_weight_grams, p.shipping_fee, p.shipping_address,
+						 COALESCE(p.invoice_number, '') AS invoice_number`
+
+// statusTimestampColumns names the column recording when a purchase entered
+// each status. Pending purchases only have created_at.
+var statusTimestampColumns = map[string]string{
+	purchaseModels.PurchasePaid:      "paid_at",
+	purchaseModels.PurchaseFulfilled: "fulfilled_at",
+	purchaseModels.PurchaseDelivered: "delivered_at",
+	purchaseModels.PurchaseCancelled: "cancelled_at",
+	purchaseModels.PurchaseRefunded:  "refunded_at",
+}
+
+func scanPurchase(row pgx.Row, p *purchaseModels.Purchase) error {
+	return row.Scan(purchaseFields(p)...)
+}
+
+func purchaseFields(p *purchaseModels.Purchase) []interface{} {
+	return []interface{}{&p.ID, &p.UserID, &p.TotalAmount, &p.Currency, &p.Status, &p.CreatedAt,
+		&p.PaidAt, &p.FulfilledAt, &p.DeliveredAt, &p.CancelledAt, &p.RefundedAt,
+		&p.DiscountAmount, &p.CouponCode, &p.PromotionID,
+		&p.SubtotalAmount, &p.TaxAmount, &p.TaxMode,
+		&p.ShippingMethod, &p.ShippingZone, &p.ShippingWeightGrams, &p.ShippingFee, &p.ShippingAddress,
+		&p.InvoiceNumber}
+}
+
+// purchaseItemColumns leaves the name and SKU snapshots empty for lines
+// written before they were recorded.
+const purchaseItemColumns = `pi.id, pi.purchase_id, pi.item_id, pi.variant_id, COALESCE(pi.item_name, ''), COALESCE(pi.sku, ''),
+							 pi.quantity, pi.cancelled_quantity, pi.returned_quantity, pi.price_at_purchase,
+							 pi.original_price, pi.original_currency, pi.exchange_rate, pi.discount_amount,
+							 pi.tax_class, pi.tax_rate, pi.net_amount, pi.tax_amount`
+
+func purchaseItemFields(i *purchaseModels.PurchaseItem) []interface{} {
+	return []interface{}{&i.ID, &i.PurchaseID, &i.ItemID, &i.VariantID, &i.ItemName, &i.SKU,
+		&i.Quantity, &i.CancelledQuantity, &i.ReturnedQuantity, &i.PriceAtPurchase,
+		&i.OriginalPrice, &i.OriginalCurrency, &i.ExchangeRate, &i.DiscountAmount,
+		&i.TaxClass, &i.TaxRate, &i.NetAmount, &i.TaxAmount}
+}
+
+// CreatePurchaseInTx writes a purchase with its lines, history, pending saga
+// and pending payment. A purchase redeeming a promotion returns pgx.ErrNoRows
+// when the promotion is gone or has reached a usage limit.
+func (r *purchaseRepository) CreatePurchaseInTx(ctx context.Context, purchase *purchaseModels.Purchase, items []purchaseModels.PurchaseItem, payment *purchaseModels.Payment) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
-	purchaseQuery := `INSERT INTO purchases (id, user_id, total_amount, created_at) VALUES ($1, $2, $3, $4)`
-	_, err = tx.Exec(ctx, purchaseQuery, purchase.ID, purchase.UserID, purchase.TotalAmount, purchase.CreatedAt)
+	if purchase.PromotionID != nil {
+		if err := redeemPromotion(ctx, tx, *purchase.PromotionID, purchase.UserID); err != nil {
+			return err
+		}
+	}
+
+	purchaseQuery := `INSERT INTO purchases (id, user_id, total_amount, currency, status, created_at, paid_at, discount_amount, coupon_code, promotion_id,
+						  subtotal_amount, tax_amount, tax_mode, shipping_method, shipping_zone, shipping_weight_grams, shipping_fee, shipping_address)
+					  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10, $11, $12, $13, NULLIF($14, ''), NULLIF($15, ''), $16, $17, $18)`
+	_, err = tx.Exec(ctx, purchaseQuery, purchase.ID, purchase.UserID, purchase.TotalAmount, purchase.Currency, purchase.Status,
+		purchase.CreatedAt, purchase.PaidAt, purchase.DiscountAmount, purchase.CouponCode, purchase.PromotionID,
+		purchase.SubtotalAmount, purchase.TaxAmount, purchase.TaxMode,
+		purchase.ShippingMethod, purchase.ShippingZone, purchase.ShippingWeightGrams, purchase.ShippingFee, purchase.ShippingAddress)
 	if err != nil {
 		return err
 	}
 
-	itemQuery := `INSERT INTO purchase_items (id, purchase_id, item_id, quantity, price_at_purchase) VALUES ($1, $2, $3, $4, $5)`
-	updateStockQuery := `UPDATE items SET stock = stock - $1 WHERE id = $2 AND stock >= $1`
-
-	for _, item := range items {
-		_, err = tx.Exec(ctx, itemQuery, uuid.New(), purchase.ID, item.ItemID, item.Quantity, item.PriceAtPurchase)
+	historyQuery := `INSERT INTO purchase_status_history (id, purchase_id, from_status, to_status, actor_id, note, created_at)
+					 VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), NULLIF($6, ''), $7)`
+	for _, change := range purchase.StatusHistory {
+		_, err = tx.Exec(ctx, historyQuery, change.ID, purchase.ID, change.FromStatus, change.ToStatus, change.ActorID, change.Note, change.CreatedAt)
 		if err != nil {
 			return err
 		}
+	}
 
-		result, err := tx.Exec(ctx, updateStockQuery, item.Quantity, item.ItemID)
+	itemQuery := `INSERT INTO purchase_items (id, purchase_id, item_id, variant_id, item_name, sku, quantity, price_at_purchase, original_price, original_currency, exchange_rate, discount_amount,
+					  tax_class, tax_rate, net_amount, tax_amount)
+				  VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
+
+	for _, item := range items {
+		_, err = tx.Exec(ctx, itemQuery, item.ID, purchase.ID, item.ItemID, item.VariantID, item.ItemName, item.SKU, item.Quantity, item.PriceAtPurchase,
+			item.OriginalPrice, item.OriginalCurrency, item.ExchangeRate, item.DiscountAmount,
+			item.TaxClass, item.TaxRate, item.NetAmount, item.TaxAmount)
 		if err != nil {
 			return err
 		}
+	}
 
-		if result.RowsAffected() == 0 {
-			return pgx.ErrNoRows
-		}
+	// The purchase stays pending until the saga has taken its stock.
+	sagaQuery := `INSERT INTO purchase_sagas (purchase_id, status, created_at, updated_at) VALUES ($1, $2, $3, $3)`
+	_, err = tx.Exec(ctx, sagaQuery, purchase.ID, purchaseModels.SagaPending, purchase.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := insertPayment(ctx, tx, payment); err != nil {
+		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (r *purchaseRepository) FindPurchasesByUserID(ctx context.Context, userID uuid.UUID) ([]purchaseModels.Purchase, error) {
-	var purchases []purchaseModels.Purchase
-	query := `SELECT id, user_id, total_amount, created_at FROM purchases WHERE user_id = $1 ORDER BY created_at DESC`
+// CompletePurchase completes the pending saga of a purchase whose stock was
+// taken and payment captured, moves the purchase from pending to paid with
+// change, and gives it the next invoice number, all in one transaction. Only
+// purchases that went through are numbered, so a purchase that fails leaves
+// no gap. It returns the invoice number, or pgx.ErrNoRows when the saga or
+// the purchase is no longer pending.
+func (r *purchaseRepository) CompletePurchase(ctx context.Context, purchaseID uuid.UUID, change *purchaseModels.PurchaseStatusChange) (string, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
 
-	rows, err := r.db.Query(ctx, query, userID)
+	sagaQuery := `UPDATE purchase_sagas SET status = $3, updated_at = NOW() WHERE purchase_id = $1 AND status = $2`
+	result, err := tx.Exec(ctx, sagaQuery, purchaseID, purchaseModels.SagaPending, purchaseModels.SagaCompleted)
+	if err != nil {
+		return "", err
+	}
+	if result.RowsAffected() == 0 {
+		return "", pgx.ErrNoRows
+	}
+	if err := updateStatus(ctx, tx, purchaseID, purchaseModels.PurchasePending, change); err != nil {
+		return "", err
+	}
+	// The number is taken last, as its counter stays locked until commit.
+	number, err := assignInvoiceNumber(ctx, tx, purchaseID, change.CreatedAt)
 	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return number, nil
+}
+
+// FailPurchase marks the compensating saga of a purchase failed and, if the
+// purchase is still pending, moves it to change.ToStatus, in one transaction,
+// so a purchase whose stock was given back never stays pending. It returns
+// false, changing nothing, when the saga is no longer compensating.
+func (r *purchaseRepository) FailPurchase(ctx context.Context, purchaseID uuid.UUID, change *purchaseModels.PurchaseStatusChange) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	sagaQuery := `UPDATE purchase_sagas SET status = $3, updated_at = NOW() WHERE purchase_id = $1 AND status = $2`
+	result, err := tx.Exec(ctx, sagaQuery, purchaseID, purchaseModels.SagaCompensating, purchaseModels.SagaFailed)
+	if err != nil {
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+	if err := updateStatus(ctx, tx, purchaseID, purchaseModels.PurchasePending, change); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// assignInvoiceNumber takes the next number of the year the invoice is issued
+// from invoice_sequences. The counter row is locked until the transaction
+// ends and a rollback gives the number back, so numbers are never shared or
+// skipped.
+func assignInvoiceNumber(ctx context.Context, tx pgx.Tx, purchaseID uuid.UUID, issuedAt time.Time) (string, error) {
+	year := issuedAt.UTC().Year()
+	var seq int64
+	query := `INSERT INTO invoice_sequences (year, last_number) VALUES ($1, 1)
+			  ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+			  RETURNING last_number`
+	if err := tx.QueryRow(ctx, query, year).Scan(&seq); err != nil {
+		return "", err
+	}
+
+	number := invoices.Number(year, seq)
+	if _, err := tx.Exec(ctx, `UPDATE purchases SET invoice_number = $2 WHERE id = $1`, purchaseID, number); err != nil {
+		return "", err
+	}
+	return number, nil
+}
+
+// FindPurchaseByID returns a purchase whose saga has completed; other
+// purchases are reported as pgx.ErrNoRows.
+func (r *purchaseRepository) FindPurchaseByID(ctx context.Context, purchaseID uuid.UUID) (*purchaseModels.Purchase, error) {
+	var p purchaseModels.Purchase
+	query := `SELECT ` + purchaseColumns + `
+			  FROM purchases p
+			  WHERE p.id = $1
+				AND NOT EXISTS (SELECT 1 FROM purchase_sagas s WHERE s.purchase_id = p.id AND s.status <> $2)`
+
+	if err := scanPurchase(r.db.QueryRow(ctx, query, purchaseID, purchaseModels.SagaCompleted), &p); err != nil {
 		return nil, err
 	}
+	return &p, nil
+}
+
+// FindUserPurchaseByID is FindPurchaseByID limited to the purchases of
+// userID; anyone else's purchase is reported as pgx.ErrNoRows.
+func (r *purchaseRepository) FindUserPurchaseByID(ctx context.Context, purchaseID, userID uuid.UUID) (*purchaseModels.Purchase, error) {
+	var p purchaseModels.Purchase
+	query := `SELECT ` + purchaseColumns + `
+			  FROM purchases p
+			  WHERE p.id = $1 AND p.user_id = $2
+				AND NOT EXISTS (SELECT 1 FROM purchase_sagas s WHERE s.purchase_id = p.id AND s.status <> $3)`
+
+	if err := scanPurchase(r.db.QueryRow(ctx, query, purchaseID, userID, purchaseModels.SagaCompleted), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindPurchasesWithItemsByUserID returns a page of the user's purchases
+// matching query, newest first, and the lines of all of them, in one query.
+// The query is expected to be normalised by the usecase.
+func (r *purchaseRepository) FindPurchasesWithItemsByUserID(ctx context.Context, userID uuid.UUID, query purchaseModels.PurchaseQuery) ([]purchaseModels.Purchase, []purchaseModels.PurchaseItem, error) {
+	args := []interface{}{userID, purchaseModels.SagaCompleted}
+	addArg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// Purchases whose saga has not completed are not (or not yet) real orders.
+	conditions := []string{
+		"p.user_id = $1",
+		"NOT EXISTS (SELECT 1 FROM purchase_sagas s WHERE s.purchase_id = p.id AND s.status <> $2)",
+	}
+	if query.From != nil {
+		conditions = append(conditions, "p.created_at >= "+addArg(*query.From))
+	}
+	if query.To != nil {
+		conditions = append(conditions, "p.created_at <= "+addArg(*query.To))
+	}
+	if query.Status != "" {
+		conditions = append(conditions, "p.status = "+addArg(query.Status))
+	}
+	if query.MinAmount != nil {
+		conditions = append(conditions, "p.total_amount >= "+addArg(*query.MinAmount))
+	}
+	if query.MaxAmount != nil {
+		conditions = append(conditions, "p.total_amount <= "+addArg(*query.MaxAmount))
+	}
+	if query.ItemID != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM purchase_items f WHERE f.purchase_id = p.id AND f.item_id = "+addArg(*query.ItemID)+")")
+	}
+	if query.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(p.created_at, p.id) < (%s, %s)", addArg(query.After.CreatedAt), addArg(query.After.ID)))
+	}
+
+	// The page is cut on purchases first so that the limit counts purchases
+	// rather than lines.
+	sqlQuery := `WITH page AS (
+				  SELECT ` + purchaseColumns + `
+				  FROM purchases p
+				  WHERE ` + strings.Join(conditions, " AND ") + `
+				  ORDER BY p.created_at DESC, p.id DESC
+				  LIMIT ` + addArg(query.Limit) + `
+			  )
+			  SELECT ` + purchaseColumns + `, ` + purchaseItemColumns + `
+			  FROM page p
+			  JOIN purchase_items pi ON pi.purchase_id = p.id
+			  ORDER BY p.created_at DESC, p.id DESC, pi.id`
+
+	rows, err := r.db.Query(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer rows.Close()
 
+	purchases := []purchaseModels.Purchase{}
+	var items []purchaseModels.PurchaseItem
 	for rows.Next() {
 		var p purchaseModels.Purchase
-		err := rows.Scan(&p.ID, &p.UserID, &p.TotalAmount, &p.CreatedAt)
-		if err != nil {
-			return nil, err
+		var i purchaseModels.PurchaseItem
+		if err := rows.Scan(append(purchaseFields(&p), purchaseItemFields(&i)...)...); err != nil {
+			return nil, nil, err
+		}
+		if len(purchases) == 0 || purchases[len(purchases)-1].ID != p.ID {
+			purchases = append(purchases, p)
 		}
-		purchases = append(purchases, p)
+		items = append(items, i)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return purchases, nil
+	return purchases, items, nil
 }
 
 func (r *purchaseRepository) FindPurchaseItemsByPurchaseID(ctx context.Context, purchaseID uuid.UUID) ([]purchaseModels.PurchaseItem, error) {
 	var items []purchaseModels.PurchaseItem
-	query := `SELECT id, purchase_id, item_id, quantity, price_at_purchase FROM purchase_items WHERE purchase_id = $1`
+	query := `SELECT ` + purchaseItemColumns + ` FROM purchase_items pi WHERE pi.purchase_id = $1 ORDER BY pi.id`
 
 	rows, err := r.db.Query(ctx, query, purchaseID)
 	if err != nil {
@@ -96,8 +348,7 @@ func (r *purchaseRepository) FindPurchaseItemsByPurchaseID(ctx context.Context,
 
 	for rows.Next() {
 		var i purchaseModels.PurchaseItem
-		err := rows.Scan(&i.ID, &i.PurchaseID, &i.ItemID, &i.Quantity, &i.PriceAtPurchase)
-		if err != nil {
+		if err := rows.Scan(purchaseItemFields(&i)...); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -109,3 +360,94 @@ func (r *purchaseRepository) FindPurchaseItemsByPurchaseID(ctx context.Context,
 
 	return items, nil
 }
+
+// UpdateStatus moves the purchase from status from to change.ToStatus, stamps
+// the time it entered the new status and appends change to its history. It
+// returns false, changing nothing, when the purchase is no longer in from.
+func (r *purchaseRepository) UpdateStatus(ctx context.Context, purchaseID uuid.UUID, from string, change *purchaseModels.PurchaseStatusChange) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updateStatus(ctx, tx, purchaseID, from, change); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// updateStatus is UpdateStatus within tx. It returns pgx.ErrNoRows when the
+// purchase is not in status from.
+func updateStatus(ctx context.Context, tx pgx.Tx, purchaseID uuid.UUID, from string, change *purchaseModels.PurchaseStatusChange) error {
+	if change.ID == uuid.Nil {
+		change.ID = uuid.New()
+	}
+	if change.CreatedAt.IsZero() {
+		change.CreatedAt = time.Now()
+	}
+	change.PurchaseID = purchaseID
+	change.FromStatus = from
+
+	set := "status = $3"
+	args := []interface{}{purchaseID, from, change.ToStatus}
+	if column, ok := statusTimestampColumns[change.ToStatus]; ok {
+		args = append(args, change.CreatedAt)
+		set += fmt.Sprintf(", %s = $%d", column, len(args))
+	}
+	result, err := tx.Exec(ctx, `UPDATE purchases SET `+set+` WHERE id = $1 AND status = $2`, args...)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	historyQuery := `INSERT INTO purchase_status_history (id, purchase_id, from_status, to_status, actor_id, note, created_at)
+					 VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), NULLIF($6, ''), $7)`
+	_, err = tx.Exec(ctx, historyQuery, change.ID, purchaseID, change.FromStatus, change.ToStatus, change.ActorID, change.Note, change.CreatedAt)
+	return err
+}
+
+func (r *purchaseRepository) FindStatusHistoryByPurchaseID(ctx context.Context, purchaseID uuid.UUID) ([]purchaseModels.PurchaseStatusChange, error) {
+	return r.findStatusHistory(ctx, `purchase_id = $1`, purchaseID)
+}
+
+// FindStatusHistoryByPurchaseIDs returns the status changes of several
+// purchases, oldest first.
+func (r *purchaseRepository) FindStatusHistoryByPurchaseIDs(ctx context.Context, purchaseIDs []uuid.UUID) ([]purchaseModels.PurchaseStatusChange, error) {
+	return r.findStatusHistory(ctx, `purchase_id = ANY($1)`, purchaseIDs)
+}
+
+func (r *purchaseRepository) findStatusHistory(ctx context.Context, condition string, arg interface{}) ([]purchaseModels.PurchaseStatusChange, error) {
+	history := []purchaseModels.PurchaseStatusChange{}
+	query := `SELECT id, purchase_id, COALESCE(from_status, ''), to_status, COALESCE(actor_id, ''), COALESCE(note, ''), created_at
+			  FROM purchase_status_history WHERE ` + condition + ` ORDER BY created_at, id`
+
+	rows, err := r.db.Query(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var change purchaseModels.PurchaseStatusChange
+		err := rows.Scan(&change.ID, &change.PurchaseID, &change.FromStatus, &change.ToStatus, &change.ActorID, &change.Note, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
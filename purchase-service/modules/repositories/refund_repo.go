@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	purchaseModels "purchase-service/modules/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"shop-crud/item-service/pkg/money"
+)
+
+type RefundRepository interface {
+	Settle(ctx context.Context, refundID uuid.UUID, status, reason string) (bool, error)
+	FindPending(ctx context.Context, before time.Time, limit int) ([]purchaseModels.Refund, error)
+}
+
+type refundRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRefundRepository(db *pgxpool.Pool) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+const refundColumns = `f.id, f.return_id, f.cancellation_id, f.purchase_id, f.amount, f.currency, f.status,
+					   COALESCE(f.failure_reason, ''), f.created_at, f.updated_at`
+
+// insertRefund writes a pending refund granted in tx.
+func insertRefund(ctx context.Context, tx pgx.Tx, refund *purchaseModels.Refund) error {
+	query := `INSERT INTO refunds (id, return_id, cancellation_id, purchase_id, amount, currency, status, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)`
+	_, err := tx.Exec(ctx, query, refund.ID, refund.ReturnID, refund.CancellationID, refund.PurchaseID, refund.Amount,
+		refund.Currency, refund.Status, refund.CreatedAt)
+	return err
+}
+
+// Settle moves a pending refund to status, recording reason when it failed.
+// It returns false, changing nothing, when the refund is no longer pending.
+func (r *refundRepository) Settle(ctx context.Context, refundID uuid.UUID, status, reason string) (bool, error) {
+	query := `UPDATE refunds SET status = $2, failure_reason = NULLIF($3, ''), updated_at = NOW()
+			  WHERE id = $1 AND status = $4`
+	result, err := r.db.Exec(ctx, query, refundID, status, reason, purchaseModels.RefundPending)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// FindPending returns refunds granted before before that have not been paid
+// out yet, oldest first.
+func (r *refundRepository) FindPending(ctx context.Context, before time.Time, limit int) ([]purchaseModels.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds f
+			  WHERE f.status = $1 AND f.updated_at < $2
+			  ORDER BY f.updated_at, f.id
+			  LIMIT $3`
+	rows, err := r.db.Query(ctx, query, purchaseModels.RefundPending, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []purchaseModels.Refund{}
+	for rows.Next() {
+		var f purchaseModels.Refund
+		err := rows.Scan(&f.ID, &f.ReturnID, &f.CancellationID, &f.PurchaseID, &f.Amount, &f.Currency, &f.Status,
+			&f.FailureReason, &f.CreatedAt, &f.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, f)
+	}
+	return refunds, rows.Err()
+}
+
+// joinedRefund receives the refundColumns of a LEFT JOIN, which are all NULL
+// when there is no refund.
+type joinedRefund struct {
+	id             *uuid.UUID
+	returnID       *uuid.UUID
+	cancellationID *uuid.UUID
+	purchaseID     *uuid.UUID
+	amount         *money.Amount
+	currency       *string
+	status         *string
+	failureReason  string
+	createdAt      *time.Time
+	updatedAt      *time.Time
+}
+
+func (j *joinedRefund) dest() []interface{} {
+	return []interface{}{&j.id, &j.returnID, &j.cancellationID, &j.purchaseID, &j.amount, &j.currency, &j.status,
+		&j.failureReason, &j.createdAt, &j.updatedAt}
+}
+
+// refund returns the scanned refund, or nil when the join found none.
+func (j *joinedRefund) refund() *purchaseModels.Refund {
+	if j.id == nil {
+		return nil
+	}
+	return &purchaseModels.Refund{
+		ID:             *j.id,
+		ReturnID:       j.returnID,
+		CancellationID: j.cancellationID,
+		PurchaseID:     *j.purchaseID,
+		Amount:         *j.amount,
+		Currency:       *j.currency,
+		Status:         *j.status,
+		FailureReason:  j.failureReason,
+		CreatedAt:      *j.createdAt,
+		UpdatedAt:      *j.updatedAt,
+	}
+}
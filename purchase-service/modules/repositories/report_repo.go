@@ -0,0 +1,247 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	purchaseModels "purchase-service/modules/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"shop-crud/item-service/pkg/money"
+)
+
+// salesRefreshName keys the sales summaries in report_refresh_state.
+const salesRefreshName = "sales"
+
+// salesBucket is the width of a summary bucket. Every time zone's offset is
+// a multiple of it, so buckets add up to whole local days.
+const salesBucket = `interval '15 minutes'`
+
+// bucketOf is the start of the UTC bucket a timestamp falls in.
+func bucketOf(column string) string {
+	return `date_bin(` + salesBucket + `, ` + column + `, TIMESTAMPTZ '2000-01-01 00:00:00+00')`
+}
+
+type ReportRepository interface {
+	RefreshSales(ctx context.Context, overlap time.Duration) (int, time.Time, error)
+	SalesRefreshedAt(ctx context.Context) (*time.Time, error)
+	RevenueByPeriod(ctx context.Context, r purchaseModels.ReportRange, interval string) ([]purchaseModels.RevenuePeriod, error)
+	TopItems(ctx context.Context, r purchaseModels.ReportRange, by string, limit int) ([]purchaseModels.TopItem, error)
+	OrderTotals(ctx context.Context, r purchaseModels.ReportRange) (orders int, revenue money.Amount, err error)
+	RepeatCustomers(ctx context.Context, r purchaseModels.ReportRange) (customers, repeat int, err error)
+}
+
+type reportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReportRepository(db *pgxpool.Pool) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+// RefreshSales rebuilds the sales summaries for every bucket holding a
+// purchase that changed since the last refresh, less overlap to catch
+// changes whose transaction committed late. The first refresh rebuilds
+// everything. It returns the number of buckets rebuilt and the time the
+// summaries are now complete up to. Concurrent refreshes wait for each other.
+func (r *reportRepository) RefreshSales(ctx context.Context, overlap time.Duration) (int, time.Time, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `INSERT INTO report_refresh_state (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, salesRefreshName)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var last *time.Time
+	var now time.Time
+	err = tx.QueryRow(ctx, `SELECT refreshed_through, now() FROM report_refresh_state WHERE name = $1 FOR UPDATE`, salesRefreshName).
+		Scan(&last, &now)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var since time.Time
+	if last != nil {
+		since = last.Add(-overlap)
+	}
+
+	_, err = tx.Exec(ctx, `CREATE TEMP TABLE dirty_sales_buckets (bucket_start timestamptz PRIMARY KEY) ON COMMIT DROP`)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	// A purchase changes when it is written, moves status, finishes its
+	// saga, or has units cancelled or returned.
+	dirtyQuery := `INSERT INTO dirty_sales_buckets (bucket_start)
+				   SELECT DISTINCT ` + bucketOf("p.created_at") + `
+				   FROM purchases p
+				   WHERE p.id IN (
+					   SELECT id FROM purchases WHERE created_at >= $1
+					   UNION SELECT purchase_id FROM purchase_status_history WHERE created_at >= $1
+					   UNION SELECT purchase_id FROM purchase_sagas WHERE updated_at >= $1
+					   UNION SELECT purchase_id FROM purchase_cancellations WHERE created_at >= $1
+					   UNION SELECT purchase_id FROM purchase_returns WHERE decided_at >= $1
+				   )`
+	tag, err := tx.Exec(ctx, dirtyQuery, since)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	buckets := int(tag.RowsAffected())
+
+	for _, table := range []string{"sales_item_buckets", "sales_customer_buckets"} {
+		_, err = tx.Exec(ctx, `DELETE FROM `+table+` WHERE bucket_start IN (SELECT bucket_start FROM dirty_sales_buckets)`)
+		if err != nil {
+			return 0, time.Time{}, err
+		}
+	}
+
+	// Only purchases whose saga completed and that were paid count. Each
+	// line counts the units it kept and what was paid for them, rounded
+	// down as refunds are.
+	rebuildQuery := `WITH lines AS (
+						 SELECT b.bucket_start, p.id AS purchase_id, p.user_id, p.currency, pi.item_id,
+								COALESCE(pi.item_name, '') AS item_name,
+								pi.quantity - pi.cancelled_quantity - pi.returned_quantity AS kept,
+								trunc((pi.net_amount + pi.tax_amount) * (pi.quantity - pi.cancelled_quantity - pi.returned_quantity) / pi.quantity, 2) AS revenue
+						 FROM dirty_sales_buckets b
+						 JOIN purchases p ON p.created_at >= b.bucket_start AND p.created_at < b.bucket_start + ` + salesBucket + `
+						 JOIN purchase_items pi ON pi.purchase_id = p.id
+						 WHERE p.status IN ($1, $2, $3)
+						   AND NOT EXISTS (SELECT 1 FROM purchase_sagas s WHERE s.purchase_id = p.id AND s.status <> $4)
+					 ),
+					 items AS (
+						 INSERT INTO sales_item_buckets (bucket_start, item_id, currency, item_name, quantity, revenue)
+						 SELECT bucket_start, item_id, currency, max(item_name), sum(kept), sum(revenue)
+						 FROM lines
+						 GROUP BY bucket_start, item_id, currency
+						 HAVING sum(kept) > 0
+					 )
+					 INSERT INTO sales_customer_buckets (bucket_start, user_id, currency, orders, units, revenue)
+					 SELECT bucket_start, user_id, currency, count(DISTINCT purchase_id) FILTER (WHERE kept > 0), sum(kept), sum(revenue)
+					 FROM lines
+					 GROUP BY bucket_start, user_id, currency
+					 HAVING sum(kept) > 0`
+	_, err = tx.Exec(ctx, rebuildQuery, purchaseModels.PurchasePaid, purchaseModels.PurchaseFulfilled, purchaseModels.PurchaseDelivered,
+		purchaseModels.SagaCompleted)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE report_refresh_state SET refreshed_through = $2 WHERE name = $1`, salesRefreshName, now)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, time.Time{}, err
+	}
+	return buckets, now, nil
+}
+
+// SalesRefreshedAt returns when the sales summaries were last refreshed, or
+// nil if they never were.
+func (r *reportRepository) SalesRefreshedAt(ctx context.Context) (*time.Time, error) {
+	var refreshedAt *time.Time
+	err := r.db.QueryRow(ctx, `SELECT refreshed_through FROM report_refresh_state WHERE name = $1`, salesRefreshName).Scan(&refreshedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return refreshedAt, err
+}
+
+// RevenueByPeriod totals sales per day, week or month in the range's time
+// zone, including periods without sales. Weeks start on Monday.
+func (r *reportRepository) RevenueByPeriod(ctx context.Context, rng purchaseModels.ReportRange, interval string) ([]purchaseModels.RevenuePeriod, error) {
+	query := `WITH periods AS (
+				  SELECT generate_series(date_trunc($1, $2::timestamptz AT TIME ZONE $4),
+										 ($3::timestamptz AT TIME ZONE $4) - interval '1 microsecond',
+										 ('1 ' || $1)::interval) AS period
+			  ),
+			  sales AS (
+				  SELECT date_trunc($1, bucket_start AT TIME ZONE $4) AS period,
+						 sum(orders) AS orders, sum(units) AS units, sum(revenue) AS revenue
+				  FROM sales_customer_buckets
+				  WHERE currency = $5 AND bucket_start >= $2 AND bucket_start < $3
+				  GROUP BY 1
+			  )
+			  SELECT to_char(p.period, 'YYYY-MM-DD'), COALESCE(s.orders, 0), COALESCE(s.units, 0), COALESCE(s.revenue, 0)
+			  FROM periods p
+			  LEFT JOIN sales s ON s.period = p.period
+			  ORDER BY p.period`
+
+	rows, err := r.db.Query(ctx, query, interval, rng.From, rng.To, rng.TimeZone, rng.Currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []purchaseModels.RevenuePeriod{}
+	for rows.Next() {
+		var p purchaseModels.RevenuePeriod
+		if err := rows.Scan(&p.Period, &p.Orders, &p.Units, &p.Revenue); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+// topItemOrder ranks items by quantity or revenue, the other breaking ties.
+var topItemOrder = map[string]string{
+	"quantity": "sum(quantity) DESC, sum(revenue) DESC, item_id",
+	"revenue":  "sum(revenue) DESC, sum(quantity) DESC, item_id",
+}
+
+// TopItems returns the limit best-selling items of the range by quantity or
+// revenue.
+func (r *reportRepository) TopItems(ctx context.Context, rng purchaseModels.ReportRange, by string, limit int) ([]purchaseModels.TopItem, error) {
+	query := `SELECT item_id, (array_agg(item_name ORDER BY bucket_start DESC))[1], sum(quantity), sum(revenue)
+			  FROM sales_item_buckets
+			  WHERE currency = $3 AND bucket_start >= $1 AND bucket_start < $2
+			  GROUP BY item_id
+			  ORDER BY ` + topItemOrder[by] + `
+			  LIMIT $4`
+
+	rows, err := r.db.Query(ctx, query, rng.From, rng.To, rng.Currency, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []purchaseModels.TopItem{}
+	for rows.Next() {
+		var item purchaseModels.TopItem
+		if err := rows.Scan(&item.ItemID, &item.Name, &item.Quantity, &item.Revenue); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// OrderTotals counts the range's orders and adds up their revenue.
+func (r *reportRepository) OrderTotals(ctx context.Context, rng purchaseModels.ReportRange) (int, money.Amount, error) {
+	var orders int
+	var revenue money.Amount
+	query := `SELECT COALESCE(sum(orders), 0), COALESCE(sum(revenue), 0)
+			  FROM sales_customer_buckets
+			  WHERE currency = $3 AND bucket_start >= $1 AND bucket_start < $2`
+	err := r.db.QueryRow(ctx, query, rng.From, rng.To, rng.Currency).Scan(&orders, &revenue)
+	return orders, revenue, err
+}
+
+// RepeatCustomers counts the customers with an order in the range and those
+// with more than one.
+func (r *reportRepository) RepeatCustomers(ctx context.Context, rng purchaseModels.ReportRange) (int, int, error) {
+	var customers, repeat int
+	query := `SELECT count(*), count(*) FILTER (WHERE orders > 1)
+			  FROM (
+				  SELECT user_id, sum(orders) AS orders
+				  FROM sales_customer_buckets
+				  WHERE currency = $3 AND bucket_start >= $1 AND bucket_start < $2
+				  GROUP BY user_id
+			  ) c`
+	err := r.db.QueryRow(ctx, query, rng.From, rng.To, rng.Currency).Scan(&customers, &repeat)
+	return customers, repeat, err
+}
@@ -0,0 +1,276 @@
+package repositories
+
+import (
+	"context"
+	purchaseModels "purchase-service/modules/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReturnRepository interface {
+	Create(ctx context.Context, purchaseReturn *purchaseModels.PurchaseReturn) error
+	FindByID(ctx context.Context, returnID uuid.UUID) (*purchaseModels.PurchaseReturn, error)
+	FindByPurchaseID(ctx context.Context, purchaseID uuid.UUID) ([]purchaseModels.PurchaseReturn, error)
+	FindByPurchaseIDs(ctx context.Context, purchaseIDs []uuid.UUID) ([]purchaseModels.PurchaseReturn, error)
+	FindByStatus(ctx context.Context, status string) ([]purchaseModels.PurchaseReturn, error)
+	Approve(ctx context.Context, purchaseReturn *purchaseModels.PurchaseReturn, refund *purchaseModels.Refund) error
+	Reject(ctx context.Context, purchaseReturn *purchaseModels.PurchaseReturn) error
+	FindUnrestored(ctx context.Context, before time.Time, limit int) ([]purchaseModels.PurchaseReturn, error)
+	MarkStockRestored(ctx context.Context, returnID uuid.UUID) (time.Time, error)
+}
+
+type returnRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReturnRepository(db *pgxpool.Pool) ReturnRepository {
+	return &returnRepository{db: db}
+}
+
+// returnableQuantity is what is left on a purchase line for a new return:
+// the units bought, less those cancelled, returned, or in an open return.
+const returnableQuantity = `pi.quantity - pi.cancelled_quantity - pi.returned_quantity - COALESCE((
+		SELECT SUM(ri.quantity) FROM purchase_return_items ri JOIN purchase_returns r ON r.id = ri.return_id
+		WHERE ri.purchase_item_id = pi.id AND r.status = 'requested'), 0)`
+
+// Create records a requested return. It returns pgx.ErrNoRows when the
+// purchase is no longer delivered or a line has fewer returnable units left
+// than requested.
+func (r *returnRepository) Create(ctx context.Context, purchaseReturn *purchaseModels.PurchaseReturn) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Locking the purchase serializes returns of the same purchase.
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM purchases WHERE id = $1 FOR UPDATE`, purchaseReturn.PurchaseID).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status != purchaseModels.PurchaseDelivered {
+		return pgx.ErrNoRows
+	}
+
+	availableQuery := `SELECT ` + returnableQuantity + ` FROM purchase_items pi WHERE pi.id = $1 AND pi.purchase_id = $2`
+	for _, item := range purchaseReturn.Items {
+		var available int
+		if err := tx.QueryRow(ctx, availableQuery, item.PurchaseItemID, purchaseReturn.PurchaseID).Scan(&available); err != nil {
+			return err
+		}
+		if available < item.Quantity {
+			return pgx.ErrNoRows
+		}
+	}
+
+	query := `INSERT INTO purchase_returns (id, purchase_id, user_id, status, reason, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = tx.Exec(ctx, query, purchaseReturn.ID, purchaseReturn.PurchaseID, purchaseReturn.UserID, purchaseReturn.Status,
+		purchaseReturn.Reason, purchaseReturn.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	itemQuery := `INSERT INTO purchase_return_items (return_id, purchase_item_id, quantity) VALUES ($1, $2, $3)`
+	for _, item := range purchaseReturn.Items {
+		if _, err := tx.Exec(ctx, itemQuery, purchaseReturn.ID, item.PurchaseItemID, item.Quantity); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *returnRepository) FindByID(ctx context.Context, returnID uuid.UUID) (*purchaseModels.PurchaseReturn, error) {
+	returns, err := r.find(ctx, `r.id = $1`, returnID)
+	if err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &returns[0], nil
+}
+
+func (r *returnRepository) FindByPurchaseID(ctx context.Context, purchaseID uuid.UUID) ([]purchaseModels.PurchaseReturn, error) {
+	return r.find(ctx, `r.purchase_id = $1 ORDER BY r.created_at, r.id`, purchaseID)
+}
+
+// FindByPurchaseIDs returns the returns of several purchases, oldest first.
+func (r *returnRepository) FindByPurchaseIDs(ctx context.Context, purchaseIDs []uuid.UUID) ([]purchaseModels.PurchaseReturn, error) {
+	return r.find(ctx, `r.purchase_id = ANY($1) ORDER BY r.created_at, r.id`, purchaseIDs)
+}
+
+// FindByStatus lists returns oldest first, all of them when status is empty.
+func (r *returnRepository) FindByStatus(ctx context.Context, status string) ([]purchaseModels.PurchaseReturn, error) {
+	return r.find(ctx, `($1 = '' OR r.status = $1) ORDER BY r.created_at, r.id`, status)
+}
+
+// Approve marks a requested return approved, counts its units as returned on
+// the purchase lines and records the refund, in one transaction. When no
+// units of a delivered purchase remain, the purchase moves to refunded. It
+// returns pgx.ErrNoRows when the return is no longer requested.
+func (r *returnRepository) Approve(ctx context.Context, purchaseReturn *purchaseModels.PurchaseReturn, refund *purchaseModels.Refund) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM purchases WHERE id = $1 FOR UPDATE`, purchaseReturn.PurchaseID).Scan(&status)
+	if err != nil {
+		return err
+	}
+
+	if err := decideReturn(ctx, tx, purchaseReturn); err != nil {
+		return err
+	}
+
+	lineQuery := `UPDATE purchase_items SET returned_quantity = returned_quantity + $2 WHERE id = $1`
+	for _, item := range purchaseReturn.Items {
+		if _, err := tx.Exec(ctx, lineQuery, item.PurchaseItemID, item.Quantity); err != nil {
+			return err
+		}
+	}
+
+	if err := insertRefund(ctx, tx, refund); err != nil {
+		return err
+	}
+
+	if status == purchaseModels.PurchaseDelivered {
+		var remaining int
+		err = tx.QueryRow(ctx, `SELECT COALESCE(SUM(quantity - cancelled_quantity - returned_quantity), 0) FROM purchase_items WHERE purchase_id = $1`,
+			purchaseReturn.PurchaseID).Scan(&remaining)
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			err = updateStatus(ctx, tx, purchaseReturn.PurchaseID, status, &purchaseModels.PurchaseStatusChange{
+				ToStatus:  purchaseModels.PurchaseRefunded,
+				ActorID:   purchaseReturn.DecidedBy,
+				Note:      "all units returned",
+				CreatedAt: *purchaseReturn.DecidedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Reject marks a requested return rejected. It returns pgx.ErrNoRows when the
+// return is no longer requested.
+func (r *returnRepository) Reject(ctx context.Context, purchaseReturn *purchaseModels.PurchaseReturn) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := decideReturn(ctx, tx, purchaseReturn); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// decideReturn moves a requested return to purchaseReturn.Status.
+func decideReturn(ctx context.Context, tx pgx.Tx, purchaseReturn *purchaseModels.PurchaseReturn) error {
+	query := `UPDATE purchase_returns SET status = $2, decision_note = NULLIF($3, ''), decided_by = NULLIF($4, ''), decided_at = $5
+			  WHERE id = $1 AND status = $6`
+	result, err := tx.Exec(ctx, query, purchaseReturn.ID, purchaseReturn.Status, purchaseReturn.DecisionNote, purchaseReturn.DecidedBy,
+		purchaseReturn.DecidedAt, purchaseModels.ReturnRequested)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// FindUnrestored returns approved returns decided before before whose units
+// have not been restocked yet, oldest first.
+func (r *returnRepository) FindUnrestored(ctx context.Context, before time.Time, limit int) ([]purchaseModels.PurchaseReturn, error) {
+	return r.find(ctx, `r.status = 'approved' AND r.stock_restored_at IS NULL AND r.decided_at < $1 ORDER BY r.decided_at, r.id LIMIT $2`, before, limit)
+}
+
+func (r *returnRepository) MarkStockRestored(ctx context.Context, returnID uuid.UUID) (time.Time, error) {
+	var restoredAt time.Time
+	query := `UPDATE purchase_returns SET stock_restored_at = COALESCE(stock_restored_at, NOW()) WHERE id = $1 RETURNING stock_restored_at`
+	err := r.db.QueryRow(ctx, query, returnID).Scan(&restoredAt)
+	return restoredAt, err
+}
+
+// find loads the returns matching condition together with their lines and
+// refunds.
+func (r *returnRepository) find(ctx context.Context, condition string, args ...interface{}) ([]purchaseModels.PurchaseReturn, error) {
+	query := `SELECT r.id, r.purchase_id, r.user_id, r.status, r.reason, COALESCE(r.decision_note, ''), COALESCE(r.decided_by, ''),
+					 r.decided_at, r.stock_restored_at, r.created_at, ` + refundColumns + `
+			  FROM purchase_returns r
+			  LEFT JOIN refunds f ON f.return_id = r.id
+			  WHERE ` + condition
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	returns := []purchaseModels.PurchaseReturn{}
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var ret purchaseModels.PurchaseReturn
+		// The refund columns are all NULL until the return is approved.
+		var refund joinedRefund
+		dest := append([]interface{}{&ret.ID, &ret.PurchaseID, &ret.UserID, &ret.Status, &ret.Reason, &ret.DecisionNote,
+			&ret.DecidedBy, &ret.DecidedAt, &ret.StockRestoredAt, &ret.CreatedAt}, refund.dest()...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		ret.Refund = refund.refund()
+		ret.Items = []purchaseModels.PurchaseReturnItem{}
+		returns = append(returns, ret)
+		ids = append(ids, ret.ID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(returns) == 0 {
+		return returns, nil
+	}
+
+	itemQuery := `SELECT ri.return_id, ri.purchase_item_id, pi.item_id, pi.variant_id, ri.quantity
+				  FROM purchase_return_items ri
+				  JOIN purchase_items pi ON pi.id = ri.purchase_item_id
+				  WHERE ri.return_id = ANY($1)
+				  ORDER BY ri.purchase_item_id`
+	itemRows, err := r.db.Query(ctx, itemQuery, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	byID := make(map[uuid.UUID]*purchaseModels.PurchaseReturn, len(returns))
+	for i := range returns {
+		byID[returns[i].ID] = &returns[i]
+	}
+	for itemRows.Next() {
+		var returnID uuid.UUID
+		var item purchaseModels.PurchaseReturnItem
+		if err := itemRows.Scan(&returnID, &item.PurchaseItemID, &item.ItemID, &item.VariantID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		byID[returnID].Items = append(byID[returnID].Items, item)
+	}
+	if err = itemRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return returns, nil
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	purchaseModels "purchase-service/modules/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SagaRepository interface {
+	FindByPurchaseID(ctx context.Context, purchaseID uuid.UUID) (*purchaseModels.PurchaseSaga, error)
+	SetReservation(ctx context.Context, purchaseID, reservationID uuid.UUID) (bool, error)
+	CompleteStep(ctx context.Context, purchaseID uuid.UUID, steps int) (bool, error)
+	Transition(ctx context.Context, purchaseID uuid.UUID, from, to, reason string) (bool, error)
+	FindStale(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error)
+}
+
+type sagaRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSagaRepository(db *pgxpool.Pool) SagaRepository {
+	return &sagaRepository{db: db}
+}
+
+func (r *sagaRepository) FindByPurchaseID(ctx context.Context, purchaseID uuid.UUID) (*purchaseModels.PurchaseSaga, error) {
+	var saga purchaseModels.PurchaseSaga
+	query := `SELECT purchase_id, status, completed_steps, reservation_id, COALESCE(failure_reason, ''), created_at, updated_at
+			  FROM purchase_sagas WHERE purchase_id = $1`
+
+	err := r.db.QueryRow(ctx, query, purchaseID).Scan(
+		&saga.PurchaseID,
+		&saga.Status,
+		&saga.CompletedSteps,
+		&saga.ReservationID,
+		&saga.FailureReason,
+		&saga.CreatedAt,
+		&saga.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &saga, nil
+}
+
+// SetReservation records the reservation holding the purchase's stock. It
+// returns false when the saga is no longer pending.
+func (r *sagaRepository) SetReservation(ctx context.Context, purchaseID, reservationID uuid.UUID) (bool, error) {
+	query := `UPDATE purchase_sagas SET reservation_id = $2, updated_at = NOW() WHERE purchase_id = $1 AND status = $3`
+	result, err := r.db.Exec(ctx, query, purchaseID, reservationID, purchaseModels.SagaPending)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// CompleteStep records that the first steps lines have had their stock taken.
+// It returns false when the saga is no longer pending, e.g. because recovery
+// has started compensating it.
+func (r *sagaRepository) CompleteStep(ctx context.Context, purchaseID uuid.UUID, steps int) (bool, error) {
+	query := `UPDATE purchase_sagas SET completed_steps = $2, updated_at = NOW() WHERE purchase_id = $1 AND status = $3`
+	result, err := r.db.Exec(ctx, query, purchaseID, steps, purchaseModels.SagaPending)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// Transition moves the saga from one status to another. It returns false,
+// changing nothing, when the saga is not in the from status. An empty reason
+// keeps the recorded failure reason.
+func (r *sagaRepository) Transition(ctx context.Context, purchaseID uuid.UUID, from, to, reason string) (bool, error) {
+	query := `UPDATE purchase_sagas SET status = $3, failure_reason = COALESCE(NULLIF($4, ''), failure_reason), updated_at = NOW()
+			  WHERE purchase_id = $1 AND status = $2`
+	result, err := r.db.Exec(ctx, query, purchaseID, from, to, reason)
+	if err != nil {
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// FindStale returns unfinished sagas that have made no progress since before,
+// oldest first.
+func (r *sagaRepository) FindStale(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error) {
+	query := `SELECT purchase_id FROM purchase_sagas WHERE status IN ($1, $2) AND updated_at < $3 ORDER BY updated_at LIMIT $4`
+
+	rows, err := r.db.Query(ctx, query, purchaseModels.SagaPending, purchaseModels.SagaCompensating, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}
@@ -0,0 +1,62 @@
+package shipping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type dbProvider struct {
+	db *pgxpool.Pool
+}
+
+// NewDBProvider reads methods from the shipping_methods, shipping_zones and
+// shipping_rates tables, so they can be updated without a restart.
+func NewDBProvider(db *pgxpool.Pool) MethodProvider {
+	return &dbProvider{db: db}
+}
+
+func (p *dbProvider) Method(ctx context.Context, code string) (*Method, error) {
+	method := &Method{Code: code}
+	err := p.db.QueryRow(ctx, `SELECT name, currency FROM shipping_methods WHERE code = $1 AND active`, code).
+		Scan(&method.Name, &method.Currency)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownMethod, code)
+		}
+		return nil, err
+	}
+
+	rows, err := p.db.Query(ctx, `SELECT zone, country_code, COALESCE(province, '') FROM shipping_zones WHERE method_code = $1`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var zone Zone
+		if err := rows.Scan(&zone.Name, &zone.CountryCode, &zone.Province); err != nil {
+			return nil, err
+		}
+		method.Zones = append(method.Zones, zone)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = p.db.Query(ctx, `SELECT zone, COALESCE(max_weight_grams, 0), fee FROM shipping_rates WHERE method_code = $1`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rate WeightRate
+		if err := rows.Scan(&rate.Zone, &rate.MaxWeightGrams, &rate.Fee); err != nil {
+			return nil, err
+		}
+		method.Rates = append(method.Rates, rate)
+	}
+	return method, rows.Err()
+}
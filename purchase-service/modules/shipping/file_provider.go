@@ -0,0 +1,64 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// methodFile is the layout of the shipping method file, keyed by method
+// code, for example:
+//
+//	{"methods": {"regular": {"name": "Regular", "currency": "IDR",
+//	  "zones": [{"zone": "domestic", "country_code": "ID"}],
+//	  "rates": [{"zone": "domestic", "max_weight_grams": 1000, "fee": "10000"}]}}}
+type methodFile struct {
+	Methods map[string]struct {
+		Name     string       `json:"name"`
+		Currency string       `json:"currency"`
+		Zones    []Zone       `json:"zones"`
+		Rates    []WeightRate `json:"rates"`
+	} `json:"methods"`
+}
+
+type fileProvider struct {
+	methods map[string]Method
+}
+
+// NewFileProvider loads a fixed set of methods from a JSON file at startup.
+func NewFileProvider(path string) (MethodProvider, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading shipping method file: %w", err)
+	}
+
+	var file methodFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing shipping method file: %w", err)
+	}
+
+	methods := make(map[string]Method, len(file.Methods))
+	for code, method := range file.Methods {
+		if method.Currency == "" {
+			return nil, fmt.Errorf("shipping method file %s: method %q has no currency", path, code)
+		}
+		methods[code] = Method{
+			Code:     code,
+			Name:     method.Name,
+			Currency: strings.ToUpper(method.Currency),
+			Zones:    method.Zones,
+			Rates:    method.Rates,
+		}
+	}
+	return &fileProvider{methods: methods}, nil
+}
+
+func (p *fileProvider) Method(ctx context.Context, code string) (*Method, error) {
+	method, ok := p.methods[code]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMethod, code)
+	}
+	return &method, nil
+}
@@ -0,0 +1,26 @@
+package shipping
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MethodProvider supplies the shipping methods on offer. An unknown or
+// inactive method is ErrUnknownMethod.
+type MethodProvider interface {
+	Method(ctx context.Context, code string) (*Method, error)
+}
+
+// NewProvider builds the provider selected by source ("db" or "file").
+func NewProvider(source, file string, db *pgxpool.Pool) (MethodProvider, error) {
+	switch strings.ToLower(source) {
+	case "db":
+		return NewDBProvider(db), nil
+	case "file":
+		return NewFileProvider(file)
+	}
+	return nil, fmt.Errorf("unknown shipping method source %q", source)
+}
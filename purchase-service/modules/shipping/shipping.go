@@ -0,0 +1,108 @@
+package shipping
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"shop-crud/item-service/pkg/money"
+)
+
+// AnyCountry is the country code of a zone that takes addresses in every
+// country its method has no more specific zone for.
+const AnyCountry = "*"
+
+var (
+	ErrUnknownMethod = errors.New("unknown shipping method")
+	ErrNoZone        = errors.New("shipping method does not deliver to the address")
+	ErrTooHeavy      = errors.New("purchase is too heavy for the shipping method")
+)
+
+// Method is a way of shipping a purchase. Its zones place an address in a
+// zone, and its rates price a parcel by zone and weight in Currency.
+type Method struct {
+	Code     string
+	Name     string
+	Currency string
+	Zones    []Zone
+	Rates    []WeightRate
+}
+
+// Zone places the addresses in CountryCode, or only those in Province when
+// it is set, in the zone Name.
+type Zone struct {
+	Name        string `json:"zone"`
+	CountryCode string `json:"country_code"`
+	Province    string `json:"province"`
+}
+
+// WeightRate is the fee for a parcel to Zone weighing up to MaxWeightGrams.
+// A MaxWeightGrams of 0 has no limit.
+type WeightRate struct {
+	Zone           string       `json:"zone"`
+	MaxWeightGrams int          `json:"max_weight_grams"`
+	Fee            money.Amount `json:"fee"`
+}
+
+// Quote is the price of shipping a parcel, in the method's currency.
+type Quote struct {
+	Zone        string
+	WeightGrams int
+	Fee         money.Amount
+}
+
+// Quote prices a parcel of weightGrams to an address. The address's zone is
+// the one naming its province, else its country, else AnyCountry; the fee is
+// that of the lightest rate of the zone the parcel fits.
+func (m *Method) Quote(countryCode, province string, weightGrams int) (*Quote, error) {
+	zone, ok := m.zone(countryCode, province)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrNoZone, m.Code, countryCode)
+	}
+
+	var rates []WeightRate
+	for _, rate := range m.Rates {
+		if rate.Zone == zone {
+			rates = append(rates, rate)
+		}
+	}
+	sort.Slice(rates, func(a, b int) bool {
+		return rateLimit(rates[a]) < rateLimit(rates[b])
+	})
+	for _, rate := range rates {
+		if weightGrams <= rateLimit(rate) {
+			return &Quote{Zone: zone, WeightGrams: weightGrams, Fee: rate.Fee}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %d g by %s to zone %s", ErrTooHeavy, weightGrams, m.Code, zone)
+}
+
+func (m *Method) zone(countryCode, province string) (string, bool) {
+	best, bestRank := "", 0
+	for _, zone := range m.Zones {
+		rank := 0
+		switch {
+		case !strings.EqualFold(zone.CountryCode, countryCode):
+			if zone.CountryCode == AnyCountry {
+				rank = 1
+			}
+		case zone.Province == "":
+			rank = 2
+		case strings.EqualFold(strings.TrimSpace(zone.Province), strings.TrimSpace(province)):
+			rank = 3
+		}
+		if rank > bestRank {
+			best, bestRank = zone.Name, rank
+		}
+	}
+	return best, bestRank > 0
+}
+
+// rateLimit is the heaviest parcel a rate takes.
+func rateLimit(rate WeightRate) int {
+	if rate.MaxWeightGrams == 0 {
+		return int(^uint(0) >> 1)
+	}
+	return rate.MaxWeightGrams
+}
@@ -0,0 +1,39 @@
+package tax
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"shop-crud/item-service/pkg/money"
+)
+
+type dbProvider struct {
+	db       *pgxpool.Pool
+	mode     string
+	rounding string
+}
+
+// NewDBProvider reads rates from the tax_rates table, so they can be updated
+// without a restart.
+func NewDBProvider(db *pgxpool.Pool, mode, rounding string) RuleProvider {
+	return &dbProvider{db: db, mode: mode, rounding: rounding}
+}
+
+func (p *dbProvider) Rules(ctx context.Context) (*Rules, error) {
+	rows, err := p.db.Query(ctx, `SELECT tax_class, rate FROM tax_rates`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := &Rules{Mode: p.mode, Rounding: p.rounding, Rates: map[string]money.Rate{}}
+	for rows.Next() {
+		var class string
+		var rate money.Rate
+		if err := rows.Scan(&class, &rate); err != nil {
+			return nil, err
+		}
+		rules.Rates[class] = rate
+	}
+	return rules, rows.Err()
+}
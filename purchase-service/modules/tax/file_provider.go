@@ -0,0 +1,64 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"shop-crud/item-service/pkg/money"
+)
+
+// ruleFile is the layout of the tax rule file. Rates are keyed by tax class;
+// mode and rounding may be left out to use the configured ones, for example:
+//
+//	{"mode": "inclusive", "rounding": "line", "rates": {"standard": "0.11", "exempt": "0"}}
+type ruleFile struct {
+	Mode     string                 `json:"mode"`
+	Rounding string                 `json:"rounding"`
+	Rates    map[string]json.Number `json:"rates"`
+}
+
+type fileProvider struct {
+	rules Rules
+}
+
+// NewFileProvider loads a fixed set of rules from a JSON file at startup.
+func NewFileProvider(path, mode, rounding string) (RuleProvider, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading tax rule file: %w", err)
+	}
+
+	var file ruleFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing tax rule file: %w", err)
+	}
+
+	rules := Rules{Mode: mode, Rounding: rounding, Rates: make(map[string]money.Rate, len(file.Rates))}
+	if file.Mode != "" {
+		rules.Mode = strings.ToLower(file.Mode)
+	}
+	if file.Rounding != "" {
+		rules.Rounding = strings.ToLower(file.Rounding)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("tax rule file %s: %w", path, err)
+	}
+	// Rates are rounded as purchase_items.tax_rate stores them, so the stored
+	// rate reproduces the tax charged.
+	for class, value := range file.Rates {
+		rate, err := money.ParseNonNegativeRate(value.String())
+		if err != nil {
+			return nil, fmt.Errorf("tax rule file %s: class %q: %w", path, class, err)
+		}
+		rules.Rates[class] = rate.RoundTo(RateScale)
+	}
+	return &fileProvider{rules: rules}, nil
+}
+
+func (p *fileProvider) Rules(ctx context.Context) (*Rules, error) {
+	rules := p.rules
+	return &rules, nil
+}
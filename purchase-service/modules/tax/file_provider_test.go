@@ -0,0 +1,44 @@
+package tax
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileProviderRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tax_rules.json")
+	body := `{"rounding": "invoice", "rates": {"standard": "0.11", "reduced": 0.055555, "exempt": "0"}}`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewFileProvider(path, ModeInclusive, RoundPerLine)
+	if err != nil {
+		t.Fatalf("NewFileProvider: %v", err)
+	}
+	rules, err := provider.Rules(context.Background())
+	if err != nil {
+		t.Fatalf("Rules: %v", err)
+	}
+	if rules.Mode != ModeInclusive || rules.Rounding != RoundPerInvoice {
+		t.Errorf("mode/rounding = %s/%s, want %s/%s", rules.Mode, rules.Rounding, ModeInclusive, RoundPerInvoice)
+	}
+	// Rates are rounded to what purchase_items.tax_rate stores.
+	for class, want := range map[string]string{"standard": "0.11", "reduced": "0.0556", "exempt": "0"} {
+		if got := rules.Rates[class].String(); got != want {
+			t.Errorf("rate %s = %s, want %s", class, got, want)
+		}
+	}
+}
+
+func TestFileProviderRejectsNegativeRates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tax_rules.json")
+	if err := os.WriteFile(path, []byte(`{"rates": {"standard": "-0.11"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileProvider(path, ModeInclusive, RoundPerLine); err == nil {
+		t.Fatal("NewFileProvider accepted a negative rate")
+	}
+}
@@ -0,0 +1,31 @@
+package tax
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RuleProvider supplies the tax rules in force.
+type RuleProvider interface {
+	Rules(ctx context.Context) (*Rules, error)
+}
+
+// NewProvider builds the provider selected by source ("db" or "file"). mode
+// and rounding apply to rates read from the database and are the defaults
+// for a rule file that does not set its own.
+func NewProvider(source, file string, db *pgxpool.Pool, mode, rounding string) (RuleProvider, error) {
+	defaults := Rules{Mode: strings.ToLower(mode), Rounding: strings.ToLower(rounding)}
+	if err := defaults.Validate(); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(source) {
+	case "db":
+		return NewDBProvider(db, defaults.Mode, defaults.Rounding), nil
+	case "file":
+		return NewFileProvider(file, defaults.Mode, defaults.Rounding)
+	}
+	return nil, fmt.Errorf("unknown tax rule source %q", source)
+}
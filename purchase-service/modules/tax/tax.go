@@ -0,0 +1,165 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"shop-crud/item-service/pkg/money"
+)
+
+// Pricing modes: whether catalog prices already include tax.
+const (
+	ModeInclusive = "inclusive"
+	ModeExclusive = "exclusive"
+)
+
+// Rounding rules: round the tax of each line, or round the invoice's total
+// tax once and spread it over the lines.
+const (
+	RoundPerLine    = "line"
+	RoundPerInvoice = "invoice"
+)
+
+// DefaultClass is the tax class of items that do not name one.
+const DefaultClass = "standard"
+
+// RateScale is the number of decimal places a tax rate keeps, matching
+// numeric(7,4) columns.
+const RateScale = 4
+
+var ErrUnknownTaxClass = errors.New("no tax rate for tax class")
+
+// Rules is a rate table by tax class with the pricing mode and rounding rule
+// to apply it with.
+type Rules struct {
+	Mode     string
+	Rounding string
+	Rates    map[string]money.Rate
+}
+
+// Line is an amount to tax: a line's price times its quantity, less any
+// discount, in minor units of the purchase currency.
+type Line struct {
+	Class  string
+	Amount money.Amount
+}
+
+// LineTax is the tax breakdown of one line. Gross is what the line costs the
+// shopper: Net plus Tax.
+type LineTax struct {
+	Class string
+	Rate  money.Rate
+	Net   money.Amount
+	Tax   money.Amount
+	Gross money.Amount
+}
+
+// Result is the tax breakdown of an invoice, with its lines in input order.
+type Result struct {
+	Lines    []LineTax
+	Subtotal money.Amount
+	Tax      money.Amount
+	Total    money.Amount
+}
+
+// Validate checks the mode and rounding rule.
+func (r *Rules) Validate() error {
+	if r.Mode != ModeInclusive && r.Mode != ModeExclusive {
+		return fmt.Errorf("unknown tax pricing mode %q", r.Mode)
+	}
+	if r.Rounding != RoundPerLine && r.Rounding != RoundPerInvoice {
+		return fmt.Errorf("unknown tax rounding rule %q", r.Rounding)
+	}
+	return nil
+}
+
+// Calculate taxes the lines. In inclusive mode a line's amount is its gross
+// and the tax is carved out of it as amount*rate/(1+rate); in exclusive mode
+// the amount is its net and amount*rate is added on top. Amounts are rounded
+// half away from zero, either per line or once for the invoice with the
+// leftover minor units going to the lines with the largest remainders.
+func (r *Rules) Calculate(lines []Line) (*Result, error) {
+	result := &Result{Lines: make([]LineTax, len(lines))}
+	exact := make([]*big.Rat, len(lines))
+	total := new(big.Rat)
+	for i, line := range lines {
+		class := line.Class
+		if class == "" {
+			class = DefaultClass
+		}
+		rate, ok := r.Rates[class]
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownTaxClass, class)
+		}
+
+		tax := new(big.Rat).Mul(new(big.Rat).SetInt64(line.Amount.Minor()), rate.Rat())
+		if r.Mode == ModeInclusive {
+			tax.Quo(tax, new(big.Rat).Add(big.NewRat(1, 1), rate.Rat()))
+		}
+		exact[i] = tax
+		total.Add(total, tax)
+		result.Lines[i] = LineTax{Class: class, Rate: rate}
+	}
+
+	taxes := make([]int64, len(lines))
+	if r.Rounding == RoundPerInvoice {
+		taxes = allocate(exact, roundHalfAway(total))
+	} else {
+		for i, tax := range exact {
+			taxes[i] = roundHalfAway(tax)
+		}
+	}
+
+	for i, line := range lines {
+		lineTax := &result.Lines[i]
+		lineTax.Tax = money.FromMinor(taxes[i])
+		if r.Mode == ModeInclusive {
+			lineTax.Gross = line.Amount
+			lineTax.Net = line.Amount.Sub(lineTax.Tax)
+		} else {
+			lineTax.Net = line.Amount
+			lineTax.Gross = line.Amount.Add(lineTax.Tax)
+		}
+		result.Subtotal = result.Subtotal.Add(lineTax.Net)
+		result.Tax = result.Tax.Add(lineTax.Tax)
+		result.Total = result.Total.Add(lineTax.Gross)
+	}
+	return result, nil
+}
+
+// allocate splits total over the exact amounts: each gets its floor, and the
+// minor units left go one each to the largest remainders, earlier lines
+// first on ties.
+func allocate(exact []*big.Rat, total int64) []int64 {
+	shares := make([]int64, len(exact))
+	remainders := make([]*big.Rat, len(exact))
+	order := make([]int, len(exact))
+	left := total
+	for i, amount := range exact {
+		floor := new(big.Int).Div(amount.Num(), amount.Denom())
+		shares[i] = floor.Int64()
+		remainders[i] = new(big.Rat).Sub(amount, new(big.Rat).SetInt(floor))
+		order[i] = i
+		left -= shares[i]
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+	for n := 0; left > 0 && len(order) > 0; n++ {
+		shares[order[n%len(order)]]++
+		left--
+	}
+	return shares
+}
+
+// roundHalfAway rounds to the nearest integer, halves away from zero.
+func roundHalfAway(x *big.Rat) int64 {
+	num, den := x.Num(), x.Denom()
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(den) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+	}
+	return quotient.Int64()
+}
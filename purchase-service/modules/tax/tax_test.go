@@ -0,0 +1,136 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+
+	"shop-crud/item-service/pkg/money"
+)
+
+func testRates(t *testing.T) map[string]money.Rate {
+	t.Helper()
+	rates := make(map[string]money.Rate)
+	for class, value := range map[string]string{"standard": "0.11", "reduced": "0.05", "exempt": "0"} {
+		rate, err := money.ParseNonNegativeRate(value)
+		if err != nil {
+			t.Fatalf("parsing rate %q: %v", value, err)
+		}
+		rates[class] = rate
+	}
+	return rates
+}
+
+// TestCalculateGolden pins the tax of small carts in every pricing mode and
+// rounding rule. The amounts are chosen so that rounding per line and per
+// invoice give different results.
+func TestCalculateGolden(t *testing.T) {
+	threeOf := func(class string, minor int64) []Line {
+		line := Line{Class: class, Amount: money.FromMinor(minor)}
+		return []Line{line, line, line}
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		rounding string
+		lines    []Line
+		// Per line: net, tax and gross in minor units.
+		want                 [][3]int64
+		subtotal, tax, total int64
+	}{
+		{
+			name: "exclusive per line", mode: ModeExclusive, rounding: RoundPerLine,
+			lines: threeOf("standard", 105),
+			// 1.05 * 11% = 0.1155 per line, rounded up on each.
+			want:     [][3]int64{{105, 12, 117}, {105, 12, 117}, {105, 12, 117}},
+			subtotal: 315, tax: 36, total: 351,
+		},
+		{
+			name: "exclusive per invoice", mode: ModeExclusive, rounding: RoundPerInvoice,
+			lines: threeOf("standard", 105),
+			// 0.3465 rounds to 0.35; the two cents left over go to the
+			// first lines on equal remainders.
+			want:     [][3]int64{{105, 12, 117}, {105, 12, 117}, {105, 11, 116}},
+			subtotal: 315, tax: 35, total: 350,
+		},
+		{
+			name: "inclusive per line", mode: ModeInclusive, rounding: RoundPerLine,
+			lines: threeOf("standard", 105),
+			// 1.05 * 0.11 / 1.11 = 0.104054... per line.
+			want:     [][3]int64{{95, 10, 105}, {95, 10, 105}, {95, 10, 105}},
+			subtotal: 285, tax: 30, total: 315,
+		},
+		{
+			name: "inclusive per invoice", mode: ModeInclusive, rounding: RoundPerInvoice,
+			lines: threeOf("standard", 105),
+			// 0.312162... rounds to 0.31, one cent more than per line.
+			want:     [][3]int64{{94, 11, 105}, {95, 10, 105}, {95, 10, 105}},
+			subtotal: 284, tax: 31, total: 315,
+		},
+		{
+			name: "half rounds away from zero per line", mode: ModeExclusive, rounding: RoundPerLine,
+			lines:    []Line{{Class: "reduced", Amount: 10}, {Class: "reduced", Amount: 10}},
+			want:     [][3]int64{{10, 1, 11}, {10, 1, 11}},
+			subtotal: 20, tax: 2, total: 22,
+		},
+		{
+			name: "halves add up per invoice", mode: ModeExclusive, rounding: RoundPerInvoice,
+			lines:    []Line{{Class: "reduced", Amount: 10}, {Class: "reduced", Amount: 10}},
+			want:     [][3]int64{{10, 1, 11}, {10, 0, 10}},
+			subtotal: 20, tax: 1, total: 21,
+		},
+		{
+			name: "default class and exempt lines", mode: ModeExclusive, rounding: RoundPerLine,
+			lines:    []Line{{Amount: 1000}, {Class: "exempt", Amount: 500}},
+			want:     [][3]int64{{1000, 110, 1110}, {500, 0, 500}},
+			subtotal: 1500, tax: 110, total: 1610,
+		},
+		{
+			name: "exempt lines inclusive", mode: ModeInclusive, rounding: RoundPerInvoice,
+			lines:    []Line{{Class: "exempt", Amount: 999}},
+			want:     [][3]int64{{999, 0, 999}},
+			subtotal: 999, tax: 0, total: 999,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := &Rules{Mode: tt.mode, Rounding: tt.rounding, Rates: testRates(t)}
+			result, err := rules.Calculate(tt.lines)
+			if err != nil {
+				t.Fatalf("Calculate: %v", err)
+			}
+
+			for i, want := range tt.want {
+				got := result.Lines[i]
+				if got.Net.Minor() != want[0] || got.Tax.Minor() != want[1] || got.Gross.Minor() != want[2] {
+					t.Errorf("line %d: net/tax/gross = %d/%d/%d, want %d/%d/%d",
+						i, got.Net.Minor(), got.Tax.Minor(), got.Gross.Minor(), want[0], want[1], want[2])
+				}
+			}
+			if result.Subtotal.Minor() != tt.subtotal || result.Tax.Minor() != tt.tax || result.Total.Minor() != tt.total {
+				t.Errorf("subtotal/tax/total = %d/%d/%d, want %d/%d/%d",
+					result.Subtotal.Minor(), result.Tax.Minor(), result.Total.Minor(), tt.subtotal, tt.tax, tt.total)
+			}
+		})
+	}
+}
+
+func TestCalculateReportsClassAndRate(t *testing.T) {
+	rules := &Rules{Mode: ModeExclusive, Rounding: RoundPerLine, Rates: testRates(t)}
+	result, err := rules.Calculate([]Line{{Amount: 100}})
+	if err != nil {
+		t.Fatalf("Calculate: %v", err)
+	}
+	if got := result.Lines[0]; got.Class != DefaultClass || got.Rate.String() != "0.11" {
+		t.Errorf("class/rate = %s/%s, want %s/0.11", got.Class, got.Rate, DefaultClass)
+	}
+}
+
+func TestCalculateUnknownClass(t *testing.T) {
+	rules := &Rules{Mode: ModeExclusive, Rounding: RoundPerLine, Rates: testRates(t)}
+	_, err := rules.Calculate([]Line{{Class: "luxury", Amount: 100}})
+	if !errors.Is(err, ErrUnknownTaxClass) {
+		t.Fatalf("err = %v, want ErrUnknownTaxClass", err)
+	}
+}
@@ -0,0 +1,266 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"purchase-service/modules/clients"
+	purchaseModels "purchase-service/modules/models"
+	purchaseRepos "purchase-service/modules/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"shop-crud/item-service/pkg/money"
+)
+
+var (
+	ErrAlreadyCancelled         = errors.New("purchase is already cancelled")
+	ErrCancellationWindowClosed = errors.New("purchase can no longer be cancelled by its owner")
+	ErrPurchaseItemNotFound     = errors.New("purchase item not found in purchase")
+	ErrCancelQuantityExceeded   = errors.New("cancel quantity exceeds the units left on the purchase item")
+	ErrCancellationConflict     = errors.New("purchase changed while it was being cancelled")
+	ErrPurchaseNotCompleted     = errors.New("purchase has not been completed")
+)
+
+// restockBatchSize bounds how many cancellations one restock pass handles.
+const restockBatchSize = 50
+
+type CancellationUsecase interface {
+	CancelPurchase(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool, req purchaseModels.CancelPurchaseRequest) (*purchaseModels.PurchaseCancellation, error)
+	RestockPending(ctx context.Context, staleAfter time.Duration) (int, error)
+}
+
+type cancellationUsecase struct {
+	purchaseRepo     purchaseRepos.PurchaseRepository
+	cancellationRepo purchaseRepos.CancellationRepository
+	itemClient       clients.ItemClient
+	payments         PaymentUsecase
+	window           time.Duration
+}
+
+// NewCancellationUsecase returns a usecase that lets owners cancel their
+// purchases for window after they were made, and admins at any time.
+func NewCancellationUsecase(purchaseRepo purchaseRepos.PurchaseRepository, cancellationRepo purchaseRepos.CancellationRepository, itemClient clients.ItemClient, payments PaymentUsecase, window time.Duration) CancellationUsecase {
+	return &cancellationUsecase{
+		purchaseRepo:     purchaseRepo,
+		cancellationRepo: cancellationRepo,
+		itemClient:       itemClient,
+		payments:         payments,
+		window:           window,
+	}
+}
+
+// CancelPurchase cancels the requested lines, or everything left on the
+// purchase, and gives their stock back. A paid purchase is refunded what the
+// cancelled units were bought for, and its shipping fee too once nothing is
+// left on it. The cancellation and refund are recorded first; if paying the
+// refund out or giving the stock back fails, payment reconciliation or
+// RestockPending retries it later.
+func (u *cancellationUsecase) CancelPurchase(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool, req purchaseModels.CancelPurchaseRequest) (*purchaseModels.PurchaseCancellation, error) {
+	purchase, err := u.purchaseRepo.FindPurchaseByID(ctx, purchaseID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPurchaseNotFound
+		}
+		return nil, err
+	}
+	// Other users' purchases are reported as missing rather than forbidden.
+	if purchase.UserID != userID && !isAdmin {
+		return nil, ErrPurchaseNotFound
+	}
+	if purchase.Status == purchaseModels.PurchaseCancelled {
+		return nil, ErrAlreadyCancelled
+	}
+	if !purchaseModels.CanTransition(purchase.Status, purchaseModels.PurchaseCancelled) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, purchase.Status, purchaseModels.PurchaseCancelled)
+	}
+	// A pending purchase is still being placed, or failed and is about to be
+	// cancelled by its saga, which gives its stock back itself.
+	if purchase.Status == purchaseModels.PurchasePending {
+		return nil, ErrPurchaseNotCompleted
+	}
+	if !isAdmin && time.Since(purchase.CreatedAt) > u.window {
+		return nil, ErrCancellationWindowClosed
+	}
+
+	items, err := u.purchaseRepo.FindPurchaseItemsByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	lines, amount, err := cancellationLines(items, req.Items)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, ErrAlreadyCancelled
+	}
+
+	cancellation := &purchaseModels.PurchaseCancellation{
+		ID:         uuid.New(),
+		PurchaseID: purchaseID,
+		Reason:     req.Reason,
+		ActorID:    userID.String(),
+		Amount:     amount,
+		CreatedAt:  time.Now(),
+		Items:      lines,
+	}
+	if purchase.PaidAt != nil {
+		refundAmount := amount
+		if cancelsAll(items, lines) {
+			refundAmount = refundAmount.Add(purchase.ShippingFee)
+		}
+		cancellation.Refund = &purchaseModels.Refund{
+			ID:             uuid.New(),
+			CancellationID: &cancellation.ID,
+			PurchaseID:     purchaseID,
+			Amount:         refundAmount,
+			Currency:       purchase.Currency,
+			Status:         purchaseModels.RefundPending,
+			CreatedAt:      cancellation.CreatedAt,
+			UpdatedAt:      cancellation.CreatedAt,
+		}
+	}
+	if _, err := u.cancellationRepo.Create(ctx, cancellation, purchase.Status, cancellation.Refund); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCancellationConflict
+		}
+		return nil, err
+	}
+
+	if cancellation.Refund != nil {
+		if err := u.payments.PayRefund(context.WithoutCancel(ctx), cancellation.Refund); err != nil {
+			log.Printf("cancellation %s: refund: %v", cancellation.ID, err)
+		}
+	}
+	if err := u.restock(context.WithoutCancel(ctx), cancellation); err != nil {
+		log.Printf("cancellation %s: restock: %v", cancellation.ID, err)
+	}
+	return cancellation, nil
+}
+
+// RestockPending gives back the stock of cancellations that have waited for
+// it for longer than staleAfter, typically because item-service was down.
+func (u *cancellationUsecase) RestockPending(ctx context.Context, staleAfter time.Duration) (int, error) {
+	cancellations, err := u.cancellationRepo.FindUnrestored(ctx, time.Now().Add(-staleAfter), restockBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	restocked := 0
+	for i := range cancellations {
+		if err := u.restock(ctx, &cancellations[i]); err != nil {
+			log.Printf("cancellation restock: cancellation %s: %v", cancellations[i].ID, err)
+			continue
+		}
+		restocked++
+	}
+	return restocked, nil
+}
+
+// restock gives the cancelled units back to item-service in one command. The
+// command is keyed by the cancellation, so retries never add stock twice.
+func (u *cancellationUsecase) restock(ctx context.Context, cancellation *purchaseModels.PurchaseCancellation) error {
+	lines := make([]clients.StockLine, 0, len(cancellation.Items))
+	for _, item := range cancellation.Items {
+		lines = append(lines, clients.StockLine{
+			ItemID:    item.ItemID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+	}
+	err := u.itemClient.IncrementStock(ctx, clients.StockIncrement{
+		Key:           "cancellation:" + cancellation.ID.String(),
+		Lines:         lines,
+		ReferenceType: "purchase_cancellation",
+		ReferenceID:   &cancellation.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	restoredAt, err := u.cancellationRepo.MarkStockRestored(ctx, cancellation.ID)
+	if err != nil {
+		return err
+	}
+	cancellation.StockRestoredAt = &restoredAt
+	return nil
+}
+
+// cancellationLines resolves the requested lines against the purchase, or
+// takes every unit left when none are requested, and totals their price.
+// Repeated lines in the request are added together.
+func cancellationLines(items []purchaseModels.PurchaseItem, requested []purchaseModels.CancelPurchaseItemRequest) ([]purchaseModels.PurchaseCancellationItem, money.Amount, error) {
+	quantities := make(map[uuid.UUID]int)
+	if len(requested) == 0 {
+		for _, item := range items {
+			quantities[item.ID] = item.Quantity - item.CancelledQuantity
+		}
+	} else {
+		known := make(map[uuid.UUID]bool, len(items))
+		for _, item := range items {
+			known[item.ID] = true
+		}
+		for _, line := range requested {
+			if !known[line.PurchaseItemID] {
+				return nil, 0, ErrPurchaseItemNotFound
+			}
+			quantities[line.PurchaseItemID] += line.Quantity
+		}
+	}
+
+	var lines []purchaseModels.PurchaseCancellationItem
+	var amount money.Amount
+	for _, item := range items {
+		quantity := quantities[item.ID]
+		if quantity == 0 {
+			continue
+		}
+		if quantity > item.Quantity-item.CancelledQuantity {
+			return nil, 0, ErrCancelQuantityExceeded
+		}
+		lines = append(lines, purchaseModels.PurchaseCancellationItem{
+			PurchaseItemID: item.ID,
+			ItemID:         item.ItemID,
+			VariantID:      item.VariantID,
+			Quantity:       quantity,
+		})
+		amount = amount.Add(item.PaidFor(quantity))
+	}
+	return lines, amount, nil
+}
+
+// cancelsAll reports whether lines take every unit still left on the
+// purchase.
+func cancelsAll(items []purchaseModels.PurchaseItem, lines []purchaseModels.PurchaseCancellationItem) bool {
+	left := 0
+	for _, item := range items {
+		left += item.Quantity - item.CancelledQuantity
+	}
+	for _, line := range lines {
+		left -= line.Quantity
+	}
+	return left == 0
+}
+
+// RunCancellationRestock retries giving back the stock of cancellations every
+// interval until ctx is cancelled.
+func RunCancellationRestock(ctx context.Context, uc CancellationUsecase, interval, staleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			restocked, err := uc.RestockPending(ctx, staleAfter)
+			if err != nil {
+				log.Printf("cancellation restock: %v", err)
+			} else if restocked > 0 {
+				log.Printf("cancellation restock: gave back stock for %d cancellations", restocked)
+			}
+		}
+	}
+}
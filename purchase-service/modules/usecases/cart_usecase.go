@@ -0,0 +1,316 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log"
+	"purchase-service/modules/clients"
+	purchaseModels "purchase-service/modules/models"
+	purchaseRepos "purchase-service/modules/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"shop-crud/item-service/pkg/money"
+)
+
+var (
+	ErrCartNotFound         = errors.New("cart not found")
+	ErrCartLineNotFound     = errors.New("cart line not found")
+	ErrCartEmpty            = errors.New("cart is empty")
+	ErrCartLineUnavailable  = errors.New("cart has lines whose item is no longer available")
+	ErrCartPriceChanged     = errors.New("prices in the cart changed since they were added; review the cart and set accept_price_changes")
+	ErrCartStockUnavailable = errors.New("cart has lines with more units than are in stock")
+)
+
+// CartOwner identifies whose cart a request is for. UserID is set for
+// signed-in shoppers; GuestCartID carries the guest cart token, which for a
+// signed-in shopper is merged into their cart.
+type CartOwner struct {
+	UserID      *uuid.UUID
+	GuestCartID *uuid.UUID
+}
+
+type CartUsecase interface {
+	GetCart(ctx context.Context, owner CartOwner) (*purchaseModels.CartView, error)
+	AddItem(ctx context.Context, owner CartOwner, req purchaseModels.AddCartItemRequest) (*purchaseModels.CartView, error)
+	UpdateItem(ctx context.Context, owner CartOwner, lineID uuid.UUID, req purchaseModels.UpdateCartItemRequest) (*purchaseModels.CartView, error)
+	RemoveItem(ctx context.Context, owner CartOwner, lineID uuid.UUID) (*purchaseModels.CartView, error)
+	Checkout(ctx context.Context, owner CartOwner, req purchaseModels.CheckoutRequest) (*purchaseModels.Purchase, error)
+}
+
+type cartUsecase struct {
+	cartRepo        purchaseRepos.CartRepository
+	itemClient      clients.ItemClient
+	purchaseUsecase PurchaseUsecase
+}
+
+func NewCartUsecase(cartRepo purchaseRepos.CartRepository, itemClient clients.ItemClient, purchaseUsecase PurchaseUsecase) CartUsecase {
+	return &cartUsecase{
+		cartRepo:        cartRepo,
+		itemClient:      itemClient,
+		purchaseUsecase: purchaseUsecase,
+	}
+}
+
+func (u *cartUsecase) GetCart(ctx context.Context, owner CartOwner) (*purchaseModels.CartView, error) {
+	cart, err := u.cart(ctx, owner, false)
+	if err != nil {
+		return nil, err
+	}
+	return u.view(ctx, cart.ID)
+}
+
+// AddItem adds units of an item at its current price. Items sold in variants
+// must name one. Stock is not reserved; a line with more units than are in
+// stock is flagged when the cart is viewed.
+func (u *cartUsecase) AddItem(ctx context.Context, owner CartOwner, req purchaseModels.AddCartItemRequest) (*purchaseModels.CartView, error) {
+	catalog, err := u.itemClient.GetItemsByIDs(ctx, []uuid.UUID{req.ItemID})
+	if err != nil {
+		return nil, itemServiceError(err)
+	}
+	item, ok := catalog[req.ItemID]
+	if !ok {
+		return nil, ErrItemNotFound
+	}
+
+	price := item.Price
+	if len(item.Variants) > 0 {
+		if req.VariantID == nil {
+			return nil, ErrVariantRequired
+		}
+		variant := item.FindVariant(*req.VariantID)
+		if variant == nil {
+			return nil, ErrVariantNotFound
+		}
+		if variant.Price != nil {
+			price = *variant.Price
+		}
+	} else if req.VariantID != nil {
+		return nil, ErrVariantNotFound
+	}
+
+	cart, err := u.cart(ctx, owner, true)
+	if err != nil {
+		return nil, err
+	}
+	line := &purchaseModels.CartLine{
+		ID:                uuid.New(),
+		CartID:            cart.ID,
+		ItemID:            item.ID,
+		VariantID:         req.VariantID,
+		Quantity:          req.Quantity,
+		PriceWhenAdded:    price,
+		CurrencyWhenAdded: currencyOrDefault(item.Currency),
+		AddedAt:           time.Now(),
+	}
+	if err := u.cartRepo.AddLine(ctx, line); err != nil {
+		return nil, err
+	}
+	return u.view(ctx, cart.ID)
+}
+
+func (u *cartUsecase) UpdateItem(ctx context.Context, owner CartOwner, lineID uuid.UUID, req purchaseModels.UpdateCartItemRequest) (*purchaseModels.CartView, error) {
+	cart, err := u.cart(ctx, owner, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.cartRepo.UpdateQuantity(ctx, cart.ID, lineID, req.Quantity); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCartLineNotFound
+		}
+		return nil, err
+	}
+	return u.view(ctx, cart.ID)
+}
+
+func (u *cartUsecase) RemoveItem(ctx context.Context, owner CartOwner, lineID uuid.UUID) (*purchaseModels.CartView, error) {
+	cart, err := u.cart(ctx, owner, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.cartRepo.RemoveLine(ctx, cart.ID, lineID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCartLineNotFound
+		}
+		return nil, err
+	}
+	return u.view(ctx, cart.ID)
+}
+
+// Checkout turns a signed-in shopper's cart into a purchase through
+// CreatePurchase and empties it. A cart with unavailable lines or too little
+// stock is refused, as is one whose prices changed unless the shopper
+// accepts them. CreatePurchase prices the lines again, so a price that
+// changes after the check is charged as it is then.
+func (u *cartUsecase) Checkout(ctx context.Context, owner CartOwner, req purchaseModels.CheckoutRequest) (*purchaseModels.Purchase, error) {
+	if owner.UserID == nil {
+		return nil, ErrCartNotFound
+	}
+	cart, err := u.cart(ctx, owner, false)
+	if err != nil {
+		return nil, err
+	}
+	view, err := u.view(ctx, cart.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(view.Lines) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	purchaseReq := purchaseModels.CreatePurchaseRequest{
+		Currency:       req.Currency,
+		CouponCode:     req.CouponCode,
+		PaymentToken:   req.PaymentToken,
+		AddressID:      req.AddressID,
+		ShippingMethod: req.ShippingMethod,
+	}
+	lineIDs := make([]uuid.UUID, 0, len(view.Lines))
+	for _, line := range view.Lines {
+		switch {
+		case line.Unavailable:
+			return nil, ErrCartLineUnavailable
+		case line.InsufficientStock:
+			return nil, ErrCartStockUnavailable
+		case line.PriceChanged && !req.AcceptPriceChanges:
+			return nil, ErrCartPriceChanged
+		}
+		purchaseReq.Items = append(purchaseReq.Items, purchaseModels.PurchaseItemRequest{
+			ItemID:    line.ItemID,
+			VariantID: line.VariantID,
+			Quantity:  line.Quantity,
+		})
+		lineIDs = append(lineIDs, line.ID)
+	}
+
+	purchase, err := u.purchaseUsecase.CreatePurchase(ctx, *owner.UserID, purchaseReq)
+	if err != nil {
+		return nil, err
+	}
+	// The purchase stands even if the cart cannot be emptied; the shopper
+	// can remove the lines themselves.
+	if err := u.cartRepo.RemoveLines(ctx, cart.ID, lineIDs); err != nil {
+		log.Printf("cart %s: emptying after purchase %s: %v", cart.ID, purchase.ID, err)
+	}
+	return purchase, nil
+}
+
+// cart resolves the owner's cart. A signed-in shopper always has one, with
+// any guest cart they bring merged in. A guest cart is created only when
+// create is set; otherwise an unknown or missing guest cart is
+// ErrCartNotFound.
+func (u *cartUsecase) cart(ctx context.Context, owner CartOwner, create bool) (*purchaseModels.Cart, error) {
+	if owner.UserID != nil {
+		cart, err := u.cartRepo.FindOrCreateForUser(ctx, *owner.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if owner.GuestCartID != nil {
+			// A guest cart that is gone was most likely merged already.
+			err := u.cartRepo.Merge(ctx, *owner.GuestCartID, cart.ID)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return nil, err
+			}
+		}
+		return cart, nil
+	}
+
+	if owner.GuestCartID != nil {
+		cart, err := u.cartRepo.FindGuest(ctx, *owner.GuestCartID)
+		if err == nil {
+			return cart, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+	}
+	if !create {
+		return nil, ErrCartNotFound
+	}
+	return u.cartRepo.CreateGuest(ctx)
+}
+
+// view prices the cart's lines against item-service in one call and flags
+// what changed since each line was added.
+func (u *cartUsecase) view(ctx context.Context, cartID uuid.UUID) (*purchaseModels.CartView, error) {
+	lines, err := u.cartRepo.FindLines(ctx, cartID)
+	if err != nil {
+		return nil, err
+	}
+	view := &purchaseModels.CartView{
+		CartID: cartID,
+		Lines:  []purchaseModels.CartLineView{},
+		Totals: map[string]money.Amount{},
+	}
+	if len(lines) == 0 {
+		return view, nil
+	}
+
+	itemIDs := make([]uuid.UUID, 0, len(lines))
+	for _, line := range lines {
+		itemIDs = append(itemIDs, line.ItemID)
+	}
+	catalog, err := u.itemClient.GetItemsByIDs(ctx, uniqueIDs(itemIDs))
+	if err != nil {
+		return nil, itemServiceError(err)
+	}
+
+	for _, line := range lines {
+		lineView := priceCartLine(line, catalog[line.ItemID])
+		if !lineView.Unavailable {
+			view.Totals[lineView.Currency] = view.Totals[lineView.Currency].Add(lineView.LineTotal)
+		}
+		if lineView.Unavailable || lineView.PriceChanged || lineView.InsufficientStock {
+			view.HasIssues = true
+		}
+		view.Lines = append(view.Lines, lineView)
+	}
+	return view, nil
+}
+
+// priceCartLine prices a line at the item's current price. A nil item, a
+// variant the item no longer has, or an item that has since been split into
+// variants makes the line unavailable at the price it was added at.
+func priceCartLine(line purchaseModels.CartLine, item *clients.ItemResponse) purchaseModels.CartLineView {
+	view := purchaseModels.CartLineView{
+		ID:             line.ID,
+		ItemID:         line.ItemID,
+		VariantID:      line.VariantID,
+		Quantity:       line.Quantity,
+		Price:          line.PriceWhenAdded,
+		Currency:       line.CurrencyWhenAdded,
+		PriceWhenAdded: line.PriceWhenAdded,
+	}
+	if item == nil {
+		view.Unavailable = true
+		return view
+	}
+	view.Name = item.Name
+
+	price, stock := item.Price, item.Stock
+	switch {
+	case line.VariantID != nil:
+		variant := item.FindVariant(*line.VariantID)
+		if variant == nil {
+			view.Unavailable = true
+			return view
+		}
+		if variant.Price != nil {
+			price = *variant.Price
+		}
+		stock = variant.Stock
+		view.SKU = variant.SKU
+	case len(item.Variants) > 0:
+		view.Unavailable = true
+		return view
+	}
+
+	view.Price = price
+	view.Currency = currencyOrDefault(item.Currency)
+	view.LineTotal = price.Mul(line.Quantity)
+	view.AvailableStock = stock
+	view.PriceChanged = price != line.PriceWhenAdded || view.Currency != line.CurrencyWhenAdded
+	view.InsufficientStock = stock < line.Quantity
+	return view
+}
@@ -0,0 +1,124 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"purchase-service/modules/invoices"
+	purchaseModels "purchase-service/modules/models"
+	"purchase-service/modules/tax"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvoiceNotFound = errors.New("purchase has no invoice")
+
+type InvoiceUsecase interface {
+	GetInvoice(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) (*invoices.Invoice, error)
+}
+
+type invoiceUsecase struct {
+	purchaseUsecase PurchaseUsecase
+	seller          invoices.Party
+}
+
+// NewInvoiceUsecase returns a usecase that draws up invoices from seller for
+// the purchases purchaseUsecase shows.
+func NewInvoiceUsecase(purchaseUsecase PurchaseUsecase, seller invoices.Party) InvoiceUsecase {
+	return &invoiceUsecase{
+		purchaseUsecase: purchaseUsecase,
+		seller:          seller,
+	}
+}
+
+// GetInvoice draws up the invoice of a purchase the caller may see, from what
+// was recorded when it was made. Purchases made before invoices were numbered
+// have none.
+func (u *invoiceUsecase) GetInvoice(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) (*invoices.Invoice, error) {
+	purchase, err := u.purchaseUsecase.GetPurchase(ctx, purchaseID, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if purchase.InvoiceNumber == "" {
+		return nil, ErrInvoiceNotFound
+	}
+
+	// The number was issued when the purchase was paid.
+	issuedAt := purchase.CreatedAt
+	if purchase.PaidAt != nil {
+		issuedAt = *purchase.PaidAt
+	}
+	invoice := &invoices.Invoice{
+		Number:      purchase.InvoiceNumber,
+		IssuedAt:    issuedAt,
+		PurchaseID:  purchase.ID.String(),
+		Status:      purchase.Status,
+		Currency:    purchase.Currency,
+		Seller:      u.seller,
+		Buyer:       invoiceBuyer(purchase),
+		Discount:    purchase.DiscountAmount,
+		CouponCode:  purchase.CouponCode,
+		Subtotal:    purchase.SubtotalAmount,
+		TaxIncluded: purchase.TaxMode == tax.ModeInclusive,
+		Tax:         purchase.TaxAmount,
+		Shipping:    purchase.ShippingFee,
+		Total:       purchase.TotalAmount,
+
+		ShippingMethod: purchase.ShippingMethod,
+	}
+
+	// Tax is shown once per rate, in the order the rates first appear.
+	taxByRate := map[string]int{}
+	for _, item := range purchase.Items {
+		description := item.Name
+		if description == "" {
+			description = item.ItemID.String()
+		}
+		invoice.Lines = append(invoice.Lines, invoices.Line{
+			Description: description,
+			SKU:         item.SKU,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.PriceAtPurchase,
+			Discount:    item.DiscountAmount,
+			TaxRate:     item.TaxRate.Percent(),
+			Net:         item.NetAmount,
+			Tax:         item.TaxAmount,
+			Amount:      item.TotalPrice,
+		})
+		invoice.LinesTotal = invoice.LinesTotal.Add(item.PriceAtPurchase.Mul(item.Quantity))
+
+		label := "Tax " + item.TaxRate.Percent()
+		i, ok := taxByRate[label]
+		if !ok {
+			i = len(invoice.Taxes)
+			taxByRate[label] = i
+			invoice.Taxes = append(invoice.Taxes, invoices.TaxLine{Label: label})
+		}
+		invoice.Taxes[i].Amount = invoice.Taxes[i].Amount.Add(item.TaxAmount)
+	}
+	return invoice, nil
+}
+
+// invoiceBuyer names the buyer by the address the purchase shipped to, or by
+// their user ID for purchases made before addresses were recorded.
+func invoiceBuyer(purchase *purchaseModels.PurchaseDetailResponse) invoices.Party {
+	address := purchase.ShippingAddress
+	if address == nil {
+		return invoices.Party{Name: "Customer " + purchase.UserID.String()}
+	}
+
+	lines := []string{address.Line1}
+	if address.Line2 != "" {
+		lines = append(lines, address.Line2)
+	}
+	city := address.City
+	if address.Province != "" {
+		city += ", " + address.Province
+	}
+	lines = append(lines, strings.TrimSpace(city+" "+address.PostalCode), address.CountryCode)
+	return invoices.Party{
+		Name:    address.RecipientName,
+		Address: lines,
+		Phone:   address.Phone,
+	}
+}
@@ -0,0 +1,390 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	purchaseModels "purchase-service/modules/models"
+	"purchase-service/modules/payments"
+	purchaseRepos "purchase-service/modules/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrPaymentDeclined    = errors.New("payment was declined")
+	ErrPaymentUnavailable = errors.New("payment could not be confirmed; any amount taken will be given back")
+	ErrPaymentNotFound    = errors.New("payment not found")
+	ErrInvalidWebhook     = errors.New("invalid payment webhook")
+)
+
+// errSagaUnfinished leaves a captured payment for a later reconciliation pass
+// while saga recovery decides the fate of its purchase.
+var errSagaUnfinished = errors.New("purchase saga has not finished")
+
+// paymentReconcileBatchSize bounds how many payments one reconciliation pass
+// handles.
+const paymentReconcileBatchSize = 50
+
+// webhookTransitions maps each webhook event to the payment statuses it may
+// move a payment from, and the status it moves it to.
+var webhookTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	payments.EventAuthorized: {[]string{purchaseModels.PaymentPending}, purchaseModels.PaymentAuthorized},
+	payments.EventCaptured:   {[]string{purchaseModels.PaymentPending, purchaseModels.PaymentAuthorized}, purchaseModels.PaymentCaptured},
+	payments.EventDeclined:   {[]string{purchaseModels.PaymentPending}, purchaseModels.PaymentDeclined},
+	payments.EventVoided:     {[]string{purchaseModels.PaymentPending, purchaseModels.PaymentAuthorized}, purchaseModels.PaymentVoided},
+	payments.EventRefunded:   {[]string{purchaseModels.PaymentCaptured}, purchaseModels.PaymentRefunded},
+}
+
+type PaymentUsecase interface {
+	NewPayment(purchase *purchaseModels.Purchase) *purchaseModels.Payment
+	Authorize(ctx context.Context, payment *purchaseModels.Payment, token string) error
+	Capture(ctx context.Context, payment *purchaseModels.Payment) error
+	Void(ctx context.Context, payment *purchaseModels.Payment, reason string) error
+	Refund(ctx context.Context, payment *purchaseModels.Payment, reason string) error
+	PayRefund(ctx context.Context, refund *purchaseModels.Refund) error
+	GetPurchasePayment(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) (*purchaseModels.Payment, error)
+	HandleWebhook(ctx context.Context, body []byte, timestamp, signature string) error
+	ReconcileStale(ctx context.Context, staleAfter time.Duration) (int, error)
+}
+
+type paymentUsecase struct {
+	paymentRepo   purchaseRepos.PaymentRepository
+	refundRepo    purchaseRepos.RefundRepository
+	purchaseRepo  purchaseRepos.PurchaseRepository
+	sagaRepo      purchaseRepos.SagaRepository
+	saga          PurchaseSaga
+	gateway       payments.PaymentGateway
+	provider      string
+	webhookSecret string
+}
+
+// NewPaymentUsecase returns a usecase that charges purchases through gateway,
+// recorded as provider, and accepts its webhooks signed with webhookSecret.
+func NewPaymentUsecase(paymentRepo purchaseRepos.PaymentRepository, refundRepo purchaseRepos.RefundRepository, purchaseRepo purchaseRepos.PurchaseRepository, sagaRepo purchaseRepos.SagaRepository, saga PurchaseSaga, gateway payments.PaymentGateway, provider, webhookSecret string) PaymentUsecase {
+	return &paymentUsecase{
+		paymentRepo:   paymentRepo,
+		refundRepo:    refundRepo,
+		purchaseRepo:  purchaseRepo,
+		sagaRepo:      sagaRepo,
+		saga:          saga,
+		gateway:       gateway,
+		provider:      provider,
+		webhookSecret: webhookSecret,
+	}
+}
+
+// NewPayment returns the pending payment for the total of a purchase about
+// to be written.
+func (u *paymentUsecase) NewPayment(purchase *purchaseModels.Purchase) *purchaseModels.Payment {
+	return &purchaseModels.Payment{
+		ID:         uuid.New(),
+		PurchaseID: purchase.ID,
+		Provider:   u.provider,
+		Amount:     purchase.TotalAmount,
+		Currency:   purchase.Currency,
+		Status:     purchaseModels.PaymentPending,
+		CreatedAt:  purchase.CreatedAt,
+		UpdatedAt:  purchase.CreatedAt,
+	}
+}
+
+// Authorize has the gateway hold the amount of a pending payment without
+// taking it. A decline is ErrPaymentDeclined. Any other failure leaves it
+// unknown what the gateway did, so the payment is reversed straight away, or
+// by ReconcileStale if that fails too, and ErrPaymentUnavailable is returned.
+func (u *paymentUsecase) Authorize(ctx context.Context, payment *purchaseModels.Payment, token string) error {
+	reference, err := u.gateway.Authorize(ctx, payments.Charge{
+		PaymentID: payment.ID,
+		Amount:    payment.Amount,
+		Currency:  payment.Currency,
+		Token:     token,
+	})
+	if err == nil {
+		payment.Reference = reference
+		err = u.advance(ctx, payment, []string{purchaseModels.PaymentPending}, purchaseModels.PaymentAuthorized, "")
+	}
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, payments.ErrDeclined) {
+		if err := u.advance(ctx, payment, []string{purchaseModels.PaymentPending}, purchaseModels.PaymentDeclined, err.Error()); err != nil {
+			log.Printf("payment %s: recording decline: %v", payment.ID, err)
+		}
+		return ErrPaymentDeclined
+	}
+	return u.unavailable(ctx, payment, err)
+}
+
+// Capture takes the amount of an authorized payment. A failure leaves it
+// unknown whether the gateway took it, so, as with Authorize, the payment is
+// reversed and ErrPaymentUnavailable is returned.
+func (u *paymentUsecase) Capture(ctx context.Context, payment *purchaseModels.Payment) error {
+	err := u.gateway.Capture(ctx, payment.ID)
+	if err == nil {
+		err = u.advance(ctx, payment, []string{purchaseModels.PaymentPending, purchaseModels.PaymentAuthorized}, purchaseModels.PaymentCaptured, "")
+	}
+	if err == nil {
+		return nil
+	}
+	return u.unavailable(ctx, payment, err)
+}
+
+// Void gives up an authorized payment for a purchase that failed before it
+// was captured. A payment the gateway captured after all is refunded.
+func (u *paymentUsecase) Void(ctx context.Context, payment *purchaseModels.Payment, reason string) error {
+	return u.reverse(ctx, payment, reason)
+}
+
+// unavailable reverses a payment after the gateway failed in an unknown way.
+// The reversal runs even when the request has been cancelled.
+func (u *paymentUsecase) unavailable(ctx context.Context, payment *purchaseModels.Payment, cause error) error {
+	log.Printf("payment %s: %v", payment.ID, cause)
+	if err := u.reverse(context.WithoutCancel(ctx), payment, cause.Error()); err != nil {
+		log.Printf("payment %s: reversing: %v", payment.ID, err)
+	}
+	return ErrPaymentUnavailable
+}
+
+// Refund pays a payment back in full, for a purchase that failed after it
+// was captured. The gateway knows the refund by the payment's own ID.
+func (u *paymentUsecase) Refund(ctx context.Context, payment *purchaseModels.Payment, reason string) error {
+	if err := u.gateway.Refund(ctx, payment.ID, payment.ID, payment.Amount); err != nil {
+		return err
+	}
+	// The gateway may have captured a payment we never saw authorized.
+	from := []string{purchaseModels.PaymentPending, purchaseModels.PaymentAuthorized, purchaseModels.PaymentCaptured}
+	return u.advance(ctx, payment, from, purchaseModels.PaymentRefunded, reason)
+}
+
+// PayRefund pays a granted refund out of its purchase's payment and records
+// the outcome: succeeded, or failed when the gateway refuses it or the
+// purchase has no payment to refund. When the gateway cannot be reached the
+// refund stays pending, the error is returned, and ReconcileStale retries it.
+func (u *paymentUsecase) PayRefund(ctx context.Context, refund *purchaseModels.Refund) error {
+	payment, err := u.paymentRepo.FindByPurchaseID(ctx, refund.PurchaseID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return u.settleRefund(ctx, refund, purchaseModels.RefundFailed, "purchase has no payment to refund")
+	}
+	if err != nil {
+		return err
+	}
+
+	if refund.Amount > 0 {
+		err := u.gateway.Refund(ctx, payment.ID, refund.ID, refund.Amount)
+		if errors.Is(err, payments.ErrInvalidOperation) || errors.Is(err, payments.ErrPaymentNotFound) {
+			return u.settleRefund(ctx, refund, purchaseModels.RefundFailed, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return u.settleRefund(ctx, refund, purchaseModels.RefundSucceeded, "")
+}
+
+// settleRefund records the outcome of a pending refund. A refund another
+// caller settled first is left as it is.
+func (u *paymentUsecase) settleRefund(ctx context.Context, refund *purchaseModels.Refund, status, reason string) error {
+	settled, err := u.refundRepo.Settle(ctx, refund.ID, status, reason)
+	if err != nil {
+		return err
+	}
+	if settled {
+		refund.Status = status
+		refund.FailureReason = reason
+		refund.UpdatedAt = time.Now()
+		if status == purchaseModels.RefundFailed {
+			log.Printf("refund %s: failed: %s", refund.ID, reason)
+		}
+	}
+	return nil
+}
+
+// GetPurchasePayment returns the payment of a purchase the caller may see.
+// Purchases made before payments were recorded have none.
+func (u *paymentUsecase) GetPurchasePayment(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) (*purchaseModels.Payment, error) {
+	var err error
+	if isAdmin {
+		_, err = u.purchaseRepo.FindPurchaseByID(ctx, purchaseID)
+	} else {
+		_, err = u.purchaseRepo.FindUserPurchaseByID(ctx, purchaseID, userID)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPurchaseNotFound
+		}
+		return nil, err
+	}
+
+	payment, err := u.paymentRepo.FindByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return payment, nil
+}
+
+// HandleWebhook applies a signed gateway callback to its payment. Events are
+// applied only when they move the payment forward, so repeated, late and
+// unknown events are accepted and ignored.
+func (u *paymentUsecase) HandleWebhook(ctx context.Context, body []byte, timestamp, signature string) error {
+	if err := payments.Verify(u.webhookSecret, timestamp, signature, body, time.Now()); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	var event payments.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+
+	transition, ok := webhookTransitions[event.Type]
+	if !ok {
+		return nil
+	}
+	reason := ""
+	if transition.to == purchaseModels.PaymentDeclined {
+		reason = payments.ErrDeclined.Error()
+	}
+	moved, err := u.paymentRepo.Transition(ctx, event.PaymentID, transition.from, transition.to, event.Reference, reason)
+	if err != nil {
+		return err
+	}
+	if moved {
+		log.Printf("payment %s: %s by webhook %s", event.PaymentID, transition.to, event.ID)
+	}
+	return nil
+}
+
+// ReconcileStale settles payments that purchases left behind, typically
+// because the instance creating the purchase stopped or the gateway timed
+// out. Like unfinished sagas they are rolled back: payments never captured
+// are voided and their purchase abandoned, and captured payments whose saga
+// failed are refunded. Completing a saga marks its purchase paid in the same
+// transaction, so a captured payment whose saga completed needs nothing; one
+// whose saga is still running is left until saga recovery has failed it.
+// Refunds of returns and cancellations still pending are paid out again.
+func (u *paymentUsecase) ReconcileStale(ctx context.Context, staleAfter time.Duration) (int, error) {
+	before := time.Now().Add(-staleAfter)
+	unsettled, err := u.paymentRepo.FindUnsettled(ctx, before, paymentReconcileBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for i := range unsettled {
+		err := u.settle(ctx, &unsettled[i])
+		if errors.Is(err, errSagaUnfinished) {
+			continue
+		}
+		if err != nil {
+			log.Printf("payment reconciliation: payment %s: %v", unsettled[i].ID, err)
+			continue
+		}
+		settled++
+	}
+
+	refunds, err := u.refundRepo.FindPending(ctx, before, paymentReconcileBatchSize)
+	if err != nil {
+		return settled, err
+	}
+	for i := range refunds {
+		if err := u.PayRefund(ctx, &refunds[i]); err != nil {
+			log.Printf("payment reconciliation: refund %s: %v", refunds[i].ID, err)
+			continue
+		}
+		settled++
+	}
+	return settled, nil
+}
+
+func (u *paymentUsecase) settle(ctx context.Context, payment *purchaseModels.Payment) error {
+	if payment.Status != purchaseModels.PaymentCaptured {
+		const reason = "payment was not completed in time"
+		if err := u.reverse(ctx, payment, reason); err != nil {
+			return err
+		}
+		items, err := u.purchaseRepo.FindPurchaseItemsByPurchaseID(ctx, payment.PurchaseID)
+		if err != nil {
+			return err
+		}
+		return u.saga.Compensate(ctx, payment.PurchaseID, items, reason)
+	}
+
+	saga, err := u.sagaRepo.FindByPurchaseID(ctx, payment.PurchaseID)
+	if err != nil {
+		return err
+	}
+	switch saga.Status {
+	case purchaseModels.SagaFailed:
+		return u.Refund(ctx, payment, "purchase failed after payment")
+	case purchaseModels.SagaPending, purchaseModels.SagaCompensating:
+		return errSagaUnfinished
+	}
+	return nil
+}
+
+// reverse voids a payment, or refunds it if the gateway captured it after
+// all. A payment the gateway never received has nothing to void.
+func (u *paymentUsecase) reverse(ctx context.Context, payment *purchaseModels.Payment, reason string) error {
+	err := u.gateway.Void(ctx, payment.ID)
+	switch {
+	case err == nil, errors.Is(err, payments.ErrPaymentNotFound):
+		return u.advance(ctx, payment, []string{purchaseModels.PaymentPending, purchaseModels.PaymentAuthorized}, purchaseModels.PaymentVoided, reason)
+	case errors.Is(err, payments.ErrAlreadyCaptured):
+		return u.Refund(ctx, payment, reason)
+	}
+	return err
+}
+
+// advance records a payment status change. When the payment has already
+// moved on, e.g. by webhook, it is reloaded instead.
+func (u *paymentUsecase) advance(ctx context.Context, payment *purchaseModels.Payment, from []string, to, reason string) error {
+	moved, err := u.paymentRepo.Transition(ctx, payment.ID, from, to, payment.Reference, reason)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		current, err := u.paymentRepo.FindByID(ctx, payment.ID)
+		if err != nil {
+			return err
+		}
+		*payment = *current
+		return nil
+	}
+	payment.Status = to
+	if reason != "" {
+		payment.FailureReason = reason
+	}
+	payment.UpdatedAt = time.Now()
+	return nil
+}
+
+// RunPaymentReconciliation settles stale payments and refunds every interval
+// until ctx is cancelled.
+func RunPaymentReconciliation(ctx context.Context, uc PaymentUsecase, interval, staleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			settled, err := uc.ReconcileStale(ctx, staleAfter)
+			if err != nil {
+				log.Printf("payment reconciliation: %v", err)
+			} else if settled > 0 {
+				log.Printf("payment reconciliation: settled %d payments and refunds", settled)
+			}
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	purchaseModels "purchase-service/modules/models"
+	purchaseRepos "purchase-service/modules/repositories"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"shop-crud/item-service/pkg/money"
+)
+
+var (
+	ErrPromotionNotFound   = errors.New("promotion not found")
+	ErrInvalidPromotion    = errors.New("invalid promotion")
+	ErrPromotionCodeExists = errors.New("a promotion with this code already exists")
+	ErrCouponInvalid       = errors.New("coupon code is not valid")
+	ErrCouponExpired       = errors.New("coupon is not valid at this time")
+	ErrCouponMinSpend      = errors.New("purchase does not reach the coupon's minimum spend")
+	ErrCouponNotApplicable = errors.New("coupon does not apply to any item in the purchase")
+	ErrCouponUsedUp        = errors.New("coupon has reached its usage limit")
+)
+
+type PromotionUsecase interface {
+	CreatePromotion(ctx context.Context, req purchaseModels.PromotionRequest) (*purchaseModels.Promotion, error)
+	UpdatePromotion(ctx context.Context, promotionID uuid.UUID, req purchaseModels.PromotionRequest) (*purchaseModels.Promotion, error)
+	DeletePromotion(ctx context.Context, promotionID uuid.UUID) error
+	GetPromotion(ctx context.Context, promotionID uuid.UUID) (*purchaseModels.Promotion, error)
+	ListPromotions(ctx context.Context) ([]purchaseModels.Promotion, error)
+}
+
+type promotionUsecase struct {
+	promotionRepo purchaseRepos.PromotionRepository
+}
+
+func NewPromotionUsecase(promotionRepo purchaseRepos.PromotionRepository) PromotionUsecase {
+	return &promotionUsecase{promotionRepo: promotionRepo}
+}
+
+func (u *promotionUsecase) CreatePromotion(ctx context.Context, req purchaseModels.PromotionRequest) (*purchaseModels.Promotion, error) {
+	now := time.Now()
+	promotion := &purchaseModels.Promotion{ID: uuid.New(), CreatedAt: now, UpdatedAt: now}
+	if err := fillPromotion(promotion, req, now); err != nil {
+		return nil, err
+	}
+	if err := u.promotionRepo.Create(ctx, promotion); err != nil {
+		return nil, mapPromotionWriteError(err)
+	}
+	return promotion, nil
+}
+
+// UpdatePromotion replaces a promotion in full. Purchases that already
+// redeemed it keep the discount they were given.
+func (u *promotionUsecase) UpdatePromotion(ctx context.Context, promotionID uuid.UUID, req purchaseModels.PromotionRequest) (*purchaseModels.Promotion, error) {
+	now := time.Now()
+	promotion := &purchaseModels.Promotion{ID: promotionID, UpdatedAt: now}
+	if err := fillPromotion(promotion, req, now); err != nil {
+		return nil, err
+	}
+	if err := u.promotionRepo.Update(ctx, promotion); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, mapPromotionWriteError(err)
+	}
+	return u.GetPromotion(ctx, promotionID)
+}
+
+func (u *promotionUsecase) DeletePromotion(ctx context.Context, promotionID uuid.UUID) error {
+	if err := u.promotionRepo.Delete(ctx, promotionID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPromotionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (u *promotionUsecase) GetPromotion(ctx context.Context, promotionID uuid.UUID) (*purchaseModels.Promotion, error) {
+	promotion, err := u.promotionRepo.FindByID(ctx, promotionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, err
+	}
+	return promotion, nil
+}
+
+func (u *promotionUsecase) ListPromotions(ctx context.Context) ([]purchaseModels.Promotion, error) {
+	return u.promotionRepo.FindAll(ctx)
+}
+
+// fillPromotion copies a request onto a promotion, checking the fields each
+// type needs. Codes are stored upper-case and matched without case.
+func fillPromotion(promotion *purchaseModels.Promotion, req purchaseModels.PromotionRequest, now time.Time) error {
+	switch req.Type {
+	case purchaseModels.PromotionPercentage:
+		if req.Value.IsZero() || req.Value > money.FromUnits(100) {
+			return fmt.Errorf("%w: value must be a percentage above 0 and at most 100", ErrInvalidPromotion)
+		}
+	case purchaseModels.PromotionFixedAmount:
+		if req.Value.IsZero() || req.Currency == "" {
+			return fmt.Errorf("%w: value and currency are required", ErrInvalidPromotion)
+		}
+	case purchaseModels.PromotionBuyXGetY:
+		if req.BuyQuantity == 0 || req.GetQuantity == 0 {
+			return fmt.Errorf("%w: buy_quantity and get_quantity are required", ErrInvalidPromotion)
+		}
+		req.Value = 0
+	}
+	if req.Type != purchaseModels.PromotionBuyXGetY {
+		req.BuyQuantity, req.GetQuantity = 0, 0
+	}
+	if req.MinSpend != nil && req.Currency == "" {
+		return fmt.Errorf("%w: currency is required with min_spend", ErrInvalidPromotion)
+	}
+
+	startsAt := now
+	if req.StartsAt != nil {
+		startsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(startsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	promotion.Code = strings.ToUpper(req.Code)
+	promotion.Name = req.Name
+	promotion.Type = req.Type
+	promotion.Value = req.Value
+	promotion.Currency = req.Currency
+	promotion.BuyQuantity = req.BuyQuantity
+	promotion.GetQuantity = req.GetQuantity
+	promotion.MinSpend = req.MinSpend
+	promotion.MaxUses = req.MaxUses
+	promotion.MaxUsesPerUser = req.MaxUsesPerUser
+	promotion.StartsAt = startsAt
+	promotion.EndsAt = req.EndsAt
+	promotion.Active = active
+	promotion.ItemIDs = uniqueIDs(req.ItemIDs)
+	promotion.CategoryIDs = uniqueIDs(req.CategoryIDs)
+	return nil
+}
+
+func mapPromotionWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrPromotionCodeExists
+	}
+	return err
+}
+
+// redeemableCoupon finds the promotion behind a coupon code and checks that
+// the user may redeem it now. The usage limits are checked again when the
+// purchase is written.
+func redeemableCoupon(ctx context.Context, promotionRepo purchaseRepos.PromotionRepository, code string, userID uuid.UUID, now time.Time) (*purchaseModels.Promotion, error) {
+	promotion, err := promotionRepo.FindByCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCouponInvalid
+		}
+		return nil, err
+	}
+	if !promotion.Active {
+		return nil, ErrCouponInvalid
+	}
+	if now.Before(promotion.StartsAt) || (promotion.EndsAt != nil && !now.Before(*promotion.EndsAt)) {
+		return nil, ErrCouponExpired
+	}
+
+	used, usedByUser, err := promotionRepo.CountRedemptions(ctx, promotion.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if (promotion.MaxUses != nil && used >= *promotion.MaxUses) ||
+		(promotion.MaxUsesPerUser != nil && usedByUser >= *promotion.MaxUsesPerUser) {
+		return nil, ErrCouponUsedUp
+	}
+	return promotion, nil
+}
+
+// applyPromotion sets the DiscountAmount of each purchase line the promotion
+// covers and returns the total discount. Lines are priced in the purchase
+// currency; convert turns amounts in the promotion's currency into it.
+// categories holds the category IDs of each item, ancestors included.
+func applyPromotion(promotion *purchaseModels.Promotion, items []purchaseModels.PurchaseItem, categories map[uuid.UUID][]uuid.UUID, convert func(money.Amount) money.Amount) (money.Amount, error) {
+	var subtotal, eligibleTotal money.Amount
+	var eligible []int
+	for i, item := range items {
+		lineTotal := item.PriceAtPurchase.Mul(item.Quantity)
+		subtotal = subtotal.Add(lineTotal)
+		if promotionCovers(promotion, item.ItemID, categories[item.ItemID]) {
+			eligible = append(eligible, i)
+			eligibleTotal = eligibleTotal.Add(lineTotal)
+		}
+	}
+	if promotion.MinSpend != nil && subtotal < convert(*promotion.MinSpend) {
+		return 0, ErrCouponMinSpend
+	}
+
+	switch promotion.Type {
+	case purchaseModels.PromotionPercentage:
+		// Value is a percentage with two decimals, so 100% is 10000 minor units.
+		for _, i := range eligible {
+			lineTotal := items[i].PriceAtPurchase.Mul(items[i].Quantity)
+			items[i].DiscountAmount = money.FromMinor((lineTotal.Minor()*promotion.Value.Minor() + 5000) / 10000)
+		}
+	case purchaseModels.PromotionFixedAmount:
+		amount := convert(promotion.Value)
+		if amount > eligibleTotal {
+			amount = eligibleTotal
+		}
+		// Split by line total; the last line takes what rounding leaves.
+		var allocated money.Amount
+		for n, i := range eligible {
+			share := amount.Sub(allocated)
+			if n < len(eligible)-1 {
+				lineTotal := items[i].PriceAtPurchase.Mul(items[i].Quantity)
+				share = money.FromMinor(amount.Minor() * lineTotal.Minor() / eligibleTotal.Minor())
+			}
+			items[i].DiscountAmount = share
+			allocated = allocated.Add(share)
+		}
+	case purchaseModels.PromotionBuyXGetY:
+		group := promotion.BuyQuantity + promotion.GetQuantity
+		for _, i := range eligible {
+			free := items[i].Quantity / group * promotion.GetQuantity
+			items[i].DiscountAmount = items[i].PriceAtPurchase.Mul(free)
+		}
+	}
+
+	var discount money.Amount
+	for _, i := range eligible {
+		discount = discount.Add(items[i].DiscountAmount)
+	}
+	if discount.IsZero() {
+		return 0, ErrCouponNotApplicable
+	}
+	return discount, nil
+}
+
+func promotionCovers(promotion *purchaseModels.Promotion, itemID uuid.UUID, categoryIDs []uuid.UUID) bool {
+	if len(promotion.ItemIDs) == 0 && len(promotion.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range promotion.ItemIDs {
+		if id == itemID {
+			return true
+		}
+	}
+	for _, id := range promotion.CategoryIDs {
+		for _, categoryID := range categoryIDs {
+			if id == categoryID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"purchase-service/modules/clients"
+	purchaseModels "purchase-service/modules/models"
+	purchaseRepos "purchase-service/modules/repositories"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrPurchaseAborted is returned when recovery gave up on a saga while it was
+// still running.
+var ErrPurchaseAborted = errors.New("purchase was aborted before it completed")
+
+// sagaRecoveryBatchSize bounds how many stale sagas one recovery pass handles.
+const sagaRecoveryBatchSize = 50
+
+// PurchaseSaga takes the stock for a pending purchase from item-service and
+// undoes what it took when a step fails. The first step reserves the stock of
+// every line at once, so that a line lacking stock fails the purchase before
+// anything else is taken. Each following step draws one line from the
+// reservation with an idempotent item-service command keyed by purchase line,
+// so any of them can be retried, including after a restart.
+type PurchaseSaga interface {
+	Execute(ctx context.Context, purchaseID uuid.UUID, items []purchaseModels.PurchaseItem) error
+	Compensate(ctx context.Context, purchaseID uuid.UUID, items []purchaseModels.PurchaseItem, reason string) error
+	RecoverStale(ctx context.Context, staleAfter time.Duration) (int, error)
+}
+
+type purchaseSaga struct {
+	sagaRepo     purchaseRepos.SagaRepository
+	purchaseRepo purchaseRepos.PurchaseRepository
+	itemClient   clients.ItemClient
+}
+
+func NewPurchaseSaga(sagaRepo purchaseRepos.SagaRepository, purchaseRepo purchaseRepos.PurchaseRepository, itemClient clients.ItemClient) PurchaseSaga {
+	return &purchaseSaga{
+		sagaRepo:     sagaRepo,
+		purchaseRepo: purchaseRepo,
+		itemClient:   itemClient,
+	}
+}
+
+// Execute reserves the stock of every line, draws each line from the
+// reservation and confirms it. The saga stays pending until the purchase is
+// completed with PurchaseRepository.CompletePurchase, so that what was taken
+// can still be compensated if the purchase fails afterwards.
+// When a step fails, what was already taken is compensated and the error of
+// the failed step is returned.
+func (s *purchaseSaga) Execute(ctx context.Context, purchaseID uuid.UUID, items []purchaseModels.PurchaseItem) error {
+	reservation, err := s.itemClient.ReserveStock(ctx, reservationLines(items))
+	if err != nil {
+		// Nothing is held, or a hold whose answer was lost expires on its
+		// own. Failing the saga keeps recovery from picking it up.
+		s.abort(ctx, purchaseID, items, err)
+		return err
+	}
+	recorded, err := s.sagaRepo.SetReservation(ctx, purchaseID, reservation.ID)
+	if err != nil || !recorded {
+		// Without a record of it, compensation cannot release the hold,
+		// so it is released here, or left to expire.
+		s.releaseReservation(ctx, reservation.ID)
+		if err != nil {
+			s.abort(ctx, purchaseID, items, err)
+			return err
+		}
+		return ErrPurchaseAborted
+	}
+
+	for i, item := range items {
+		err := s.itemClient.DecrementStock(ctx, clients.StockCommand{
+			Key:           stockCommandKey(purchaseID, item.ID),
+			ItemID:        item.ItemID,
+			VariantID:     item.VariantID,
+			Quantity:      item.Quantity,
+			ReferenceType: "purchase",
+			ReferenceID:   &purchaseID,
+			ReservationID: &reservation.ID,
+		})
+		if err != nil {
+			s.abort(ctx, purchaseID, items, err)
+			return err
+		}
+
+		advanced, err := s.sagaRepo.CompleteStep(ctx, purchaseID, i+1)
+		if err != nil {
+			s.abort(ctx, purchaseID, items, err)
+			return err
+		}
+		if !advanced {
+			return ErrPurchaseAborted
+		}
+	}
+
+	// Every unit has been drawn, so confirming only records the purchase
+	// on the reservation and ends its hold.
+	if err := s.itemClient.ConfirmReservation(ctx, reservation.ID, "purchase", purchaseID); err != nil {
+		s.abort(ctx, purchaseID, items, err)
+		return err
+	}
+	return nil
+}
+
+// Compensate restores the stock of every line, newest first, releases the
+// reservation, then marks the saga failed and cancels the purchase. Restoring a line whose decrement
+// never happened is a no-op, so it is safe to compensate lines the saga never
+// reached. If a restore fails the saga stays compensating and recovery
+// retries it later.
+func (s *purchaseSaga) Compensate(ctx context.Context, purchaseID uuid.UUID, items []purchaseModels.PurchaseItem, reason string) error {
+	if _, err := s.sagaRepo.Transition(ctx, purchaseID, purchaseModels.SagaPending, purchaseModels.SagaCompensating, reason); err != nil {
+		return err
+	}
+	// A saga that is already compensating is resumed; one that has
+	// completed or failed in the meantime is left alone.
+	saga, err := s.sagaRepo.FindByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		return err
+	}
+	if saga.Status != purchaseModels.SagaCompensating {
+		return nil
+	}
+
+	for i := len(items) - 1; i >= 0; i-- {
+		if err := s.itemClient.RestoreStock(ctx, stockCommandKey(purchaseID, items[i].ID)); err != nil {
+			return fmt.Errorf("restore stock for purchase item %s: %w", items[i].ID, err)
+		}
+	}
+	// The restores gave back what was drawn; releasing gives back the rest.
+	// A confirmed reservation had every unit drawn, so has nothing left.
+	if saga.ReservationID != nil {
+		err := s.itemClient.ReleaseReservation(ctx, *saga.ReservationID)
+		if err != nil && !errors.Is(err, clients.ErrReservationInactive) {
+			return fmt.Errorf("release reservation %s: %w", *saga.ReservationID, err)
+		}
+	}
+
+	change := &purchaseModels.PurchaseStatusChange{
+		ToStatus: purchaseModels.PurchaseCancelled,
+		Note:     "Purchase failed: " + saga.FailureReason,
+	}
+	_, err = s.purchaseRepo.FailPurchase(ctx, purchaseID, change)
+	return err
+}
+
+// RecoverStale compensates sagas that have made no progress for staleAfter,
+// typically because the instance running them stopped. A purchase is only
+// reported as successful once its saga completed, so unfinished ones are
+// rolled back rather than driven forward.
+func (s *purchaseSaga) RecoverStale(ctx context.Context, staleAfter time.Duration) (int, error) {
+	ids, err := s.sagaRepo.FindStale(ctx, time.Now().Add(-staleAfter), sagaRecoveryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for _, id := range ids {
+		items, err := s.purchaseRepo.FindPurchaseItemsByPurchaseID(ctx, id)
+		if err != nil {
+			return recovered, err
+		}
+		if err := s.Compensate(ctx, id, items, "saga did not finish in time"); err != nil {
+			log.Printf("purchase saga recovery: purchase %s: %v", id, err)
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+// abort compensates after a failed step. The compensation runs even when the
+// request has been cancelled; if it fails, recovery finishes it.
+func (s *purchaseSaga) abort(ctx context.Context, purchaseID uuid.UUID, items []purchaseModels.PurchaseItem, cause error) {
+	if err := s.Compensate(context.WithoutCancel(ctx), purchaseID, items, cause.Error()); err != nil {
+		log.Printf("purchase saga: compensate purchase %s: %v", purchaseID, err)
+	}
+}
+
+// releaseReservation gives back a hold the saga could not record. It runs
+// even when the request has been cancelled; if it fails, the reservation
+// expires on its own.
+func (s *purchaseSaga) releaseReservation(ctx context.Context, reservationID uuid.UUID) {
+	if err := s.itemClient.ReleaseReservation(context.WithoutCancel(ctx), reservationID); err != nil {
+		log.Printf("purchase saga: release reservation %s: %v", reservationID, err)
+	}
+}
+
+func reservationLines(items []purchaseModels.PurchaseItem) []clients.ReservationLine {
+	lines := make([]clients.ReservationLine, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, clients.ReservationLine{
+			ItemID:    item.ItemID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+	}
+	return lines
+}
+
+// stockCommandKey identifies the stock decrement of one purchase line.
+func stockCommandKey(purchaseID, purchaseItemID uuid.UUID) string {
+	return fmt.Sprintf("purchase:%s:%s", purchaseID, purchaseItemID)
+}
+
+// RunSagaRecovery compensates stale sagas every interval until ctx is
+// cancelled. The first pass runs immediately so that sagas interrupted by a
+// restart are cleaned up on startup.
+func RunSagaRecovery(ctx context.Context, saga PurchaseSaga, interval, staleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		recovered, err := saga.RecoverStale(ctx, staleAfter)
+		if err != nil {
+			log.Printf("purchase saga recovery: %v", err)
+		} else if recovered > 0 {
+			log.Printf("purchase saga recovery: compensated %d sagas", recovered)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -2,129 +2,773 @@ package usecases
 
 import (
 	"context"
-	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
-	//itemRepos "shop-crud/item-service/modules/repositories"
+	"fmt"
+	"log"
 	"purchase-service/modules/clients"
 	purchaseModels "purchase-service/modules/models"
+	"purchase-service/modules/rates"
 	purchaseRepos "purchase-service/modules/repositories"
+	"purchase-service/modules/shipping"
+	"purchase-service/modules/tax"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"shop-crud/item-service/pkg/money"
 )
 
 var (
-	ErrStockNotSufficient = errors.New("stock for an item is not sufficient")
-	ErrItemNotFound       = errors.New("one or more items not found")
+	ErrStockNotSufficient   = errors.New("stock for an item is not sufficient")
+	ErrItemNotFound         = errors.New("one or more items not found")
+	ErrVariantRequired      = errors.New("variant_id is required for items sold in variants")
+	ErrVariantNotFound      = errors.New("variant not found for item")
+	ErrCurrencyNotSupported = errors.New("no exchange rate available for the requested currency")
+	ErrPurchaseNotFound     = errors.New("purchase not found")
+	ErrInvalidTransition    = errors.New("purchase cannot move to the requested status")
+	ErrUseCancelEndpoint    = errors.New("purchases are cancelled through POST /purchases/:id/cancel")
+	ErrInvalidCursor        = errors.New("invalid or expired cursor")
+	ErrInvalidRange         = errors.New("range lower bound is greater than its upper bound")
+	ErrItemServiceDown      = errors.New("item service is temporarily unavailable")
+	ErrReservationLost      = errors.New("stock reservation ended before the purchase completed")
 )
 
+var (
+	ErrUserServiceDown       = errors.New("user service is temporarily unavailable")
+	ErrAddressNotFound       = errors.New("address not found in the address book")
+	ErrUnknownShippingMethod = errors.New("unknown shipping method")
+	ErrShippingUnavailable   = errors.New("shipping method cannot deliver this purchase to the address")
+	ErrTaxClassNotSupported  = errors.New("no tax rate is set for an item's tax class")
+)
+
+const defaultPurchasePageSize = 20
+
 type PurchaseUsecase interface {
 	CreatePurchase(ctx context.Context, userID uuid.UUID, req purchaseModels.CreatePurchaseRequest) (*purchaseModels.Purchase, error)
-	GetPurchaseHistory(ctx context.Context, userID uuid.UUID) ([]purchaseModels.Purchase, error)
+	GetPurchaseHistory(ctx context.Context, userID uuid.UUID, query purchaseModels.PurchaseQuery) (*purchaseModels.PurchaseListResponse, error)
+	GetPurchase(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) (*purchaseModels.PurchaseDetailResponse, error)
+	UpdatePurchaseStatus(ctx context.Context, purchaseID uuid.UUID, req purchaseModels.UpdatePurchaseStatusRequest, actorID string) (*purchaseModels.Purchase, error)
 }
 
 type purchaseUsecase struct {
-	purchaseRepo purchaseRepos.PurchaseRepository
-	itemClient   clients.ItemClient 
+	purchaseRepo     purchaseRepos.PurchaseRepository
+	cancellationRepo purchaseRepos.CancellationRepository
+	returnRepo       purchaseRepos.ReturnRepository
+	promotionRepo    purchaseRepos.PromotionRepository
+	itemClient       clients.ItemClient
+	userClient       clients.UserClient
+	saga             PurchaseSaga
+	payments         PaymentUsecase
+	rateProvider     rates.ExchangeRateProvider
+	taxProvider      tax.RuleProvider
+	shippingProvider shipping.MethodProvider
+	defaultCurrency  string
 }
 
-func NewPurchaseUsecase(purchaseRepo purchaseRepos.PurchaseRepository, itemClient clients.ItemClient) PurchaseUsecase {
+func NewPurchaseUsecase(purchaseRepo purchaseRepos.PurchaseRepository, cancellationRepo purchaseRepos.CancellationRepository, returnRepo purchaseRepos.ReturnRepository, promotionRepo purchaseRepos.PromotionRepository, itemClient clients.ItemClient, userClient clients.UserClient, saga PurchaseSaga, payments PaymentUsecase, rateProvider rates.ExchangeRateProvider, taxProvider tax.RuleProvider, shippingProvider shipping.MethodProvider, defaultCurrency string) PurchaseUsecase {
 	return &purchaseUsecase{
-		purchaseRepo: purchaseRepo,
-		itemClient:   itemClient,
+		purchaseRepo:     purchaseRepo,
+		cancellationRepo: cancellationRepo,
+		returnRepo:       returnRepo,
+		promotionRepo:    promotionRepo,
+		itemClient:       itemClient,
+		userClient:       userClient,
+		saga:             saga,
+		payments:         payments,
+		rateProvider:     rateProvider,
+		taxProvider:      taxProvider,
+		shippingProvider: shippingProvider,
+		defaultCurrency:  defaultCurrency,
 	}
 }
 
 func (u *purchaseUsecase) CreatePurchase(ctx context.Context, userID uuid.UUID, req purchaseModels.CreatePurchaseRequest) (*purchaseModels.Purchase, error) {
-	var totalAmount float64
 	var purchaseItems []purchaseModels.PurchaseItem
 	var purchaseItemResponses []purchaseModels.PurchaseItemResponse
 	tr := otel.Tracer("purchase-usecase")
 	ctx, span := tr.Start(ctx, "PurchaseUsecase")
 	defer span.End()
 
-
 	span.SetAttributes(
 		attribute.String("user.id", userID.String()),
 		attribute.Int("item.count", len(req.Items)),
 	)
 
-	for _, reqItem := range req.Items {
-		item, err := u.itemClient.GetItemByID(ctx, reqItem.ItemID)
+	// A coupon is checked before anything else so a bad code fails fast.
+	var promotion *purchaseModels.Promotion
+	if req.CouponCode != "" {
+		var err error
+		promotion, err = redeemableCoupon(ctx, u.promotionRepo, req.CouponCode, userID, time.Now())
 		if err != nil {
-			if err == sql.ErrNoRows {
-				return nil, ErrItemNotFound
-			}
 			return nil, err
 		}
-		if item.Stock< reqItem.Quantity {
-			return nil, ErrStockNotSufficient
+	}
+
+	// So is where the purchase ships to and how.
+	address, err := u.userClient.GetAddress(ctx, userID, req.AddressID)
+	if err != nil {
+		switch {
+		case errors.Is(err, clients.ErrAddressNotFound):
+			return nil, ErrAddressNotFound
+		case errors.Is(err, clients.ErrUserServiceDown):
+			return nil, ErrUserServiceDown
+		}
+		return nil, err
+	}
+	method, err := u.shippingProvider.Method(ctx, req.ShippingMethod)
+	if err != nil {
+		if errors.Is(err, shipping.ErrUnknownMethod) {
+			return nil, ErrUnknownShippingMethod
+		}
+		return nil, err
+	}
+
+	itemIDs := make([]uuid.UUID, 0, len(req.Items))
+	for _, reqItem := range req.Items {
+		itemIDs = append(itemIDs, reqItem.ItemID)
+	}
+	catalog, err := u.itemClient.GetItemsByIDs(ctx, uniqueIDs(itemIDs))
+	if err != nil {
+		return nil, itemServiceError(err)
+	}
+
+	weightGrams := 0
+	for _, reqItem := range req.Items {
+		item, ok := catalog[reqItem.ItemID]
+		if !ok {
+			return nil, ErrItemNotFound
+		}
+		weightGrams += item.WeightGrams * reqItem.Quantity
+
+		// Items sold in variants are priced and stocked per variant.
+		price, sku := item.Price, ""
+		if len(item.Variants) > 0 {
+			if reqItem.VariantID == nil {
+				return nil, ErrVariantRequired
+			}
+			variant := item.FindVariant(*reqItem.VariantID)
+			if variant == nil {
+				return nil, ErrVariantNotFound
+			}
+			if variant.Price != nil {
+				price = *variant.Price
+			}
+			sku = variant.SKU
+		} else if reqItem.VariantID != nil {
+			return nil, ErrVariantNotFound
 		}
 
-		totalAmount += float64(reqItem.Quantity) * item.Price
 		purchaseItems = append(purchaseItems, purchaseModels.PurchaseItem{
-			ItemID:          item.ID,
-			Quantity:        reqItem.Quantity,
-			PriceAtPurchase: item.Price,
+			ID:               uuid.New(),
+			ItemID:           item.ID,
+			VariantID:        reqItem.VariantID,
+			ItemName:         item.Name,
+			SKU:              sku,
+			Quantity:         reqItem.Quantity,
+			OriginalPrice:    price,
+			OriginalCurrency: currencyOrDefault(item.Currency),
+			TaxClass:         item.TaxClass,
 		})
 		purchaseItemResponses = append(purchaseItemResponses, purchaseModels.PurchaseItemResponse{
-			ItemID: item.ID,
-			Quantity: reqItem.Quantity,
-			Name: item.Name,
-			Price: item.Price,
+			ID:        purchaseItems[len(purchaseItems)-1].ID,
+			ItemID:    item.ID,
+			VariantID: reqItem.VariantID,
+			SKU:       sku,
+			Quantity:  reqItem.Quantity,
+			Name:      item.Name,
 		})
 	}
-	
+
+	currency := u.purchaseCurrency(req.Currency, purchaseItems)
+	span.SetAttributes(attribute.String("purchase.currency", currency))
+
+	// Lock one rate per source currency so every line converts consistently.
+	lockedRates := make(map[string]money.Rate)
+	lockRate := func(from string) (money.Rate, error) {
+		if rate, ok := lockedRates[from]; ok {
+			return rate, nil
+		}
+		rate, err := u.rateProvider.Rate(ctx, from, currency)
+		if err != nil {
+			if errors.Is(err, rates.ErrRateNotFound) {
+				return money.Rate{}, ErrCurrencyNotSupported
+			}
+			return money.Rate{}, err
+		}
+		// Round first so the stored rate reproduces the converted prices.
+		rate = rate.Round()
+		lockedRates[from] = rate
+		return rate, nil
+	}
+	for i := range purchaseItems {
+		line := &purchaseItems[i]
+		rate, err := lockRate(line.OriginalCurrency)
+		if err != nil {
+			return nil, err
+		}
+
+		line.ExchangeRate = rate
+		line.PriceAtPurchase = rate.Convert(line.OriginalPrice)
+
+		purchaseItemResponses[i].Price = line.PriceAtPurchase
+		purchaseItemResponses[i].OriginalPrice = line.OriginalPrice
+		purchaseItemResponses[i].OriginalCurrency = line.OriginalCurrency
+		purchaseItemResponses[i].ExchangeRate = rate
+	}
+
+	var discount money.Amount
+	if promotion != nil {
+		convert := func(amount money.Amount) money.Amount { return amount }
+		if promotion.Currency != "" {
+			rate, err := lockRate(promotion.Currency)
+			if err != nil {
+				return nil, err
+			}
+			convert = rate.Convert
+		}
+		categories := make(map[uuid.UUID][]uuid.UUID, len(catalog))
+		for id, item := range catalog {
+			categories[id] = item.CategoryIDs
+		}
+		discount, err = applyPromotion(promotion, purchaseItems, categories, convert)
+		if err != nil {
+			return nil, err
+		}
+		for i := range purchaseItems {
+			purchaseItemResponses[i].DiscountAmount = purchaseItems[i].DiscountAmount
+		}
+		span.SetAttributes(attribute.String("purchase.coupon", promotion.Code))
+	}
+
+	// Tax is charged on what each line costs after its discount.
+	rules, err := u.taxProvider.Rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taxLines := make([]tax.Line, len(purchaseItems))
+	for i, line := range purchaseItems {
+		taxLines[i] = tax.Line{Class: line.TaxClass, Amount: line.PriceAtPurchase.Mul(line.Quantity).Sub(line.DiscountAmount)}
+	}
+	taxes, err := rules.Calculate(taxLines)
+	if err != nil {
+		if errors.Is(err, tax.ErrUnknownTaxClass) {
+			return nil, fmt.Errorf("%w: %v", ErrTaxClassNotSupported, err)
+		}
+		return nil, err
+	}
+	for i, lineTax := range taxes.Lines {
+		line := &purchaseItems[i]
+		line.TaxClass = lineTax.Class
+		line.TaxRate = lineTax.Rate
+		line.NetAmount = lineTax.Net
+		line.TaxAmount = lineTax.Tax
+
+		purchaseItemResponses[i].TaxClass = lineTax.Class
+		purchaseItemResponses[i].TaxRate = lineTax.Rate
+		purchaseItemResponses[i].NetAmount = lineTax.Net
+		purchaseItemResponses[i].TaxAmount = lineTax.Tax
+	}
+
+	// Shipping is priced in the method's currency and charged untaxed.
+	quote, err := method.Quote(address.CountryCode, address.Province, weightGrams)
+	if err != nil {
+		if errors.Is(err, shipping.ErrNoZone) || errors.Is(err, shipping.ErrTooHeavy) {
+			return nil, fmt.Errorf("%w: %v", ErrShippingUnavailable, err)
+		}
+		return nil, err
+	}
+	shippingRate, err := lockRate(method.Currency)
+	if err != nil {
+		return nil, err
+	}
+	shippingFee := shippingRate.Convert(quote.Fee)
+	span.SetAttributes(attribute.String("purchase.shipping_method", method.Code))
+
+	// A purchase is pending until it has been paid for and its stock taken.
+	now := time.Now()
 	newPurchase := &purchaseModels.Purchase{
 		ID:          uuid.New(),
 		UserID:      userID,
-		TotalAmount: totalAmount,
-		CreatedAt:   time.Now(),
+		TotalAmount: taxes.Total.Add(shippingFee),
+		Currency:    currency,
+		Status:      purchaseModels.PurchasePending,
+		CreatedAt:   now,
+
+		SubtotalAmount: taxes.Subtotal,
+		TaxAmount:      taxes.Tax,
+		TaxMode:        rules.Mode,
+
+		ShippingMethod:      method.Code,
+		ShippingZone:        quote.Zone,
+		ShippingWeightGrams: weightGrams,
+		ShippingFee:         shippingFee,
+		ShippingAddress: &purchaseModels.ShippingAddress{
+			AddressID:     address.ID,
+			RecipientName: address.RecipientName,
+			Phone:         address.Phone,
+			Line1:         address.Line1,
+			Line2:         address.Line2,
+			City:          address.City,
+			Province:      address.Province,
+			PostalCode:    address.PostalCode,
+			CountryCode:   address.CountryCode,
+		},
+		StatusHistory: []purchaseModels.PurchaseStatusChange{{
+			ID:        uuid.New(),
+			ToStatus:  purchaseModels.PurchasePending,
+			ActorID:   userID.String(),
+			CreatedAt: now,
+		}},
 	}
+	span.SetAttributes(attribute.String("purchase.id", newPurchase.ID.String()))
 
-	err := u.purchaseRepo.CreatePurchaseInTx(ctx, newPurchase, purchaseItems)
-	if err != nil {
-		if err == sql.ErrNoRows {
+	// The purchase is written first with a pending saga and payment, so that a
+	// crash while it is paid for or its stock taken leaves a record recovery
+	// and payment reconciliation can roll back.
+	if promotion != nil {
+		newPurchase.DiscountAmount = discount
+		newPurchase.CouponCode = promotion.Code
+		newPurchase.PromotionID = &promotion.ID
+	}
+	payment := u.payments.NewPayment(newPurchase)
+	if err := u.purchaseRepo.CreatePurchaseInTx(ctx, newPurchase, purchaseItems, payment); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCouponUsedUp
+		}
+		return nil, err
+	}
+
+	// The payment is only authorized before stock is taken, and captured
+	// once it has been, so a purchase that fails on stock never takes the
+	// shopper's money. A purchase whose payment fails is abandoned by failing
+	// its saga, which takes nothing.
+	if err := u.payments.Authorize(ctx, payment, req.PaymentToken); err != nil {
+		u.abandon(ctx, newPurchase.ID, purchaseItems, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("payment.id", payment.ID.String()))
+
+	if err := u.saga.Execute(ctx, newPurchase.ID, purchaseItems); err != nil {
+		// If the void fails, payment reconciliation retries it.
+		if err := u.payments.Void(context.WithoutCancel(ctx), payment, "purchase failed: "+err.Error()); err != nil {
+			log.Printf("purchase %s: voiding payment %s: %v", newPurchase.ID, payment.ID, err)
+		}
+		switch {
+		case errors.Is(err, clients.ErrInsufficientStock):
 			return nil, ErrStockNotSufficient
+		case errors.Is(err, clients.ErrItemNotFound):
+			return nil, ErrItemNotFound
+		case errors.Is(err, clients.ErrStockCommandClosed), errors.Is(err, clients.ErrReservationExpired), errors.Is(err, clients.ErrReservationInactive):
+			return nil, ErrReservationLost
+		case errors.Is(err, clients.ErrCircuitOpen), errors.Is(err, ErrPurchaseAborted):
+			return nil, itemServiceError(err)
+		}
+		return nil, fmt.Errorf("purchase saga: %w", err)
+	}
+
+	// Capture reverses the payment itself when it fails; the stock goes back
+	// by compensating the saga.
+	if err := u.payments.Capture(ctx, payment); err != nil {
+		u.abandon(ctx, newPurchase.ID, purchaseItems, err)
+		return nil, err
+	}
+	// The saga completes, the purchase is marked paid and its invoice
+	// numbered together, so only purchases that went through are numbered.
+	change := &purchaseModels.PurchaseStatusChange{
+		ToStatus: purchaseModels.PurchasePaid,
+		ActorID:  userID.String(),
+		Note:     "Payment captured",
+	}
+	invoiceNumber, err := u.purchaseRepo.CompletePurchase(ctx, newPurchase.ID, change)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Recovery has given the stock back, so the money goes too. If
+			// the refund fails, payment reconciliation retries it.
+			if err := u.payments.Refund(context.WithoutCancel(ctx), payment, "purchase failed: "+ErrPurchaseAborted.Error()); err != nil {
+				log.Printf("purchase %s: refunding payment %s: %v", newPurchase.ID, payment.ID, err)
+			}
+			return nil, itemServiceError(ErrPurchaseAborted)
 		}
+		// The saga is still pending: recovery fails it and payment
+		// reconciliation then refunds the payment.
 		return nil, err
 	}
-	
+	newPurchase.Status = change.ToStatus
+	newPurchase.PaidAt = &change.CreatedAt
+	newPurchase.InvoiceNumber = invoiceNumber
+	newPurchase.StatusHistory = append(newPurchase.StatusHistory, *change)
+
 	newPurchase.Items = purchaseItemResponses
+	newPurchase.Payment = payment
 	return newPurchase, nil
 }
 
-func (u *purchaseUsecase) GetPurchaseHistory(ctx context.Context, userID uuid.UUID) ([]purchaseModels.Purchase, error) {
-	purchases, err := u.purchaseRepo.FindPurchasesByUserID(ctx, userID)
+// abandon fails the saga of a purchase whose payment failed, giving back any
+// stock it took. It runs even when the request has been cancelled; if it
+// fails, saga recovery finishes it.
+func (u *purchaseUsecase) abandon(ctx context.Context, purchaseID uuid.UUID, items []purchaseModels.PurchaseItem, cause error) {
+	if err := u.saga.Compensate(context.WithoutCancel(ctx), purchaseID, items, cause.Error()); err != nil {
+		log.Printf("purchase %s: abandoning after failed payment: %v", purchaseID, err)
+	}
+}
+
+// GetPurchaseHistory loads a page of the user's purchases with their lines in
+// one query and their history, cancellations and returns in one query each.
+// Line names come from the snapshot taken at purchase time, so the history
+// renders even when items have since been deleted.
+func (u *purchaseUsecase) GetPurchaseHistory(ctx context.Context, userID uuid.UUID, query purchaseModels.PurchaseQuery) (*purchaseModels.PurchaseListResponse, error) {
+	if query.Limit == 0 {
+		query.Limit = defaultPurchasePageSize
+	}
+	if query.From != nil && query.To != nil && query.From.After(*query.To) {
+		return nil, ErrInvalidRange
+	}
+	if query.MinAmount != nil && query.MaxAmount != nil && *query.MinAmount > *query.MaxAmount {
+		return nil, ErrInvalidRange
+	}
+	if query.Cursor != "" {
+		cursor, err := decodePurchaseCursor(query.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		query.After = cursor
+	}
+
+	// Fetch one extra purchase to find out whether another page follows.
+	pageSize := query.Limit
+	query.Limit = pageSize + 1
+	purchases, items, err := u.purchaseRepo.FindPurchasesWithItemsByUserID(ctx, userID, query)
 	if err != nil {
 		return nil, err
 	}
 
-	for i, p := range purchases {
-		items, err := u.purchaseRepo.FindPurchaseItemsByPurchaseID(ctx, p.ID)
-		if err != nil {
-			return nil, err
+	res := &purchaseModels.PurchaseListResponse{Data: purchases, Limit: pageSize}
+	if len(purchases) > pageSize {
+		res.Data = purchases[:pageSize]
+		res.NextCursor = encodePurchaseCursor(res.Data[pageSize-1])
+	}
+	if len(res.Data) == 0 {
+		return res, nil
+	}
+	purchases = res.Data
+	items = itemsOfPurchases(items, purchases)
+	u.fillItemNames(ctx, items)
+
+	purchaseIDs := make([]uuid.UUID, len(purchases))
+	byID := make(map[uuid.UUID]*purchaseModels.Purchase, len(purchases))
+	for i := range purchases {
+		purchaseIDs[i] = purchases[i].ID
+		purchases[i].StatusHistory = []purchaseModels.PurchaseStatusChange{}
+		byID[purchases[i].ID] = &purchases[i]
+	}
+
+	returns, err := u.returnRepo.FindByPurchaseIDs(ctx, purchaseIDs)
+	if err != nil {
+		return nil, err
+	}
+	lineReturns := lineReturnsByItem(returns, items)
+
+	for _, item := range items {
+		purchase := byID[item.PurchaseID]
+		purchase.Items = append(purchase.Items, purchaseModels.PurchaseItemResponse{
+			ID:                item.ID,
+			ItemID:            item.ItemID,
+			VariantID:         item.VariantID,
+			SKU:               item.SKU,
+			Quantity:          item.Quantity,
+			Name:              item.ItemName,
+			Price:             item.PriceAtPurchase,
+			DiscountAmount:    item.DiscountAmount,
+			TaxClass:          item.TaxClass,
+			TaxRate:           item.TaxRate,
+			NetAmount:         item.NetAmount,
+			TaxAmount:         item.TaxAmount,
+			CancelledQuantity: item.CancelledQuantity,
+			ReturnedQuantity:  item.ReturnedQuantity,
+			Returns:           lineReturns[item.ID],
+			OriginalPrice:     item.OriginalPrice,
+			OriginalCurrency:  item.OriginalCurrency,
+			ExchangeRate:      item.ExchangeRate,
+		})
+	}
+
+	history, err := u.purchaseRepo.FindStatusHistoryByPurchaseIDs(ctx, purchaseIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, change := range history {
+		purchase := byID[change.PurchaseID]
+		purchase.StatusHistory = append(purchase.StatusHistory, change)
+	}
+
+	cancellations, err := u.cancellationRepo.FindByPurchaseIDs(ctx, purchaseIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, cancellation := range cancellations {
+		purchase := byID[cancellation.PurchaseID]
+		purchase.Cancellations = append(purchase.Cancellations, cancellation)
+	}
+
+	return res, nil
+}
+
+// GetPurchase returns one purchase with its lines priced as they were bought.
+// Only admins can see other users' purchases; for anyone else they are
+// reported as ErrPurchaseNotFound.
+func (u *purchaseUsecase) GetPurchase(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) (*purchaseModels.PurchaseDetailResponse, error) {
+	var purchase *purchaseModels.Purchase
+	var err error
+	if isAdmin {
+		purchase, err = u.purchaseRepo.FindPurchaseByID(ctx, purchaseID)
+	} else {
+		purchase, err = u.purchaseRepo.FindUserPurchaseByID(ctx, purchaseID, userID)
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPurchaseNotFound
 		}
+		return nil, err
+	}
 
-		var itemResponses []purchaseModels.PurchaseItemResponse
-		for _, item := range items {
-			itemDetail, err := u.itemClient.GetItemByID(ctx, item.ItemID)
-			if err != nil {
-				return nil, err
+	items, err := u.purchaseRepo.FindPurchaseItemsByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	u.fillItemNames(ctx, items)
+
+	itemHistories := make([]purchaseModels.PurchaseItemHistory, 0, len(items))
+	for _, item := range items {
+		itemHistories = append(itemHistories, purchaseModels.PurchaseItemHistory{
+			ID:              item.ID,
+			ItemID:          item.ItemID,
+			VariantID:       item.VariantID,
+			SKU:             item.SKU,
+			Name:            item.ItemName,
+			Quantity:        item.Quantity,
+			PriceAtPurchase: item.PriceAtPurchase,
+			DiscountAmount:  item.DiscountAmount,
+			TaxClass:        item.TaxClass,
+			TaxRate:         item.TaxRate,
+			NetAmount:       item.NetAmount,
+			TaxAmount:       item.TaxAmount,
+			TotalPrice:      item.NetAmount.Add(item.TaxAmount),
+		})
+	}
+
+	return &purchaseModels.PurchaseDetailResponse{
+		ID:          purchase.ID,
+		UserID:      purchase.UserID,
+		Status:      purchase.Status,
+		TotalAmount: purchase.TotalAmount,
+		Currency:    purchase.Currency,
+		CreatedAt:   purchase.CreatedAt,
+		PaidAt:      purchase.PaidAt,
+		FulfilledAt: purchase.FulfilledAt,
+		DeliveredAt: purchase.DeliveredAt,
+		CancelledAt: purchase.CancelledAt,
+		RefundedAt:  purchase.RefundedAt,
+		Items:       itemHistories,
+
+		InvoiceNumber: purchase.InvoiceNumber,
+
+		DiscountAmount: purchase.DiscountAmount,
+		CouponCode:     purchase.CouponCode,
+
+		SubtotalAmount: purchase.SubtotalAmount,
+		TaxAmount:      purchase.TaxAmount,
+		TaxMode:        purchase.TaxMode,
+
+		ShippingMethod:      purchase.ShippingMethod,
+		ShippingZone:        purchase.ShippingZone,
+		ShippingWeightGrams: purchase.ShippingWeightGrams,
+		ShippingFee:         purchase.ShippingFee,
+		ShippingAddress:     purchase.ShippingAddress,
+	}, nil
+}
+
+// fillItemNames looks up, in one batch, the current name and SKU of lines
+// bought before names were snapshotted. Lines whose item is gone, or that
+// cannot be looked up right now, keep an empty name.
+func (u *purchaseUsecase) fillItemNames(ctx context.Context, items []purchaseModels.PurchaseItem) {
+	var missing []uuid.UUID
+	for _, item := range items {
+		if item.ItemName == "" {
+			missing = append(missing, item.ItemID)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	catalog, err := u.itemClient.GetItemsByIDs(ctx, uniqueIDs(missing))
+	if err != nil {
+		log.Printf("purchase items: looking up names: %v", err)
+		return
+	}
+	for i := range items {
+		item := &items[i]
+		detail, ok := catalog[item.ItemID]
+		if item.ItemName != "" || !ok {
+			continue
+		}
+		item.ItemName = detail.Name
+		if item.VariantID != nil {
+			if variant := detail.FindVariant(*item.VariantID); variant != nil {
+				item.SKU = variant.SKU
+			}
+		}
+	}
+}
+
+// UpdatePurchaseStatus moves a purchase along its lifecycle. Moves the state
+// machine does not allow, including ones lost to a concurrent change, fail
+// with ErrInvalidTransition.
+func (u *purchaseUsecase) UpdatePurchaseStatus(ctx context.Context, purchaseID uuid.UUID, req purchaseModels.UpdatePurchaseStatusRequest, actorID string) (*purchaseModels.Purchase, error) {
+	purchase, err := u.purchaseRepo.FindPurchaseByID(ctx, purchaseID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPurchaseNotFound
+		}
+		return nil, err
+	}
+	// Cancelling gives stock back, which a bare status change would skip.
+	if req.Status == purchaseModels.PurchaseCancelled {
+		return nil, ErrUseCancelEndpoint
+	}
+	if !purchaseModels.CanTransition(purchase.Status, req.Status) {
+		return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, purchase.Status, req.Status)
+	}
+
+	change := &purchaseModels.PurchaseStatusChange{
+		ToStatus: req.Status,
+		ActorID:  actorID,
+		Note:     req.Note,
+	}
+	updated, err := u.purchaseRepo.UpdateStatus(ctx, purchaseID, purchase.Status, change)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("%w: status changed concurrently", ErrInvalidTransition)
+	}
+
+	purchase, err = u.purchaseRepo.FindPurchaseByID(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	purchase.StatusHistory, err = u.purchaseRepo.FindStatusHistoryByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	return purchase, nil
+}
+
+// lineReturnsByItem splits returns by purchase line. A refund covers a whole
+// return, so each line shows its own share of it.
+func lineReturnsByItem(returns []purchaseModels.PurchaseReturn, items []purchaseModels.PurchaseItem) map[uuid.UUID][]purchaseModels.LineReturn {
+	lines := make(map[uuid.UUID]purchaseModels.PurchaseItem, len(items))
+	for _, item := range items {
+		lines[item.ID] = item
+	}
+
+	byItem := make(map[uuid.UUID][]purchaseModels.LineReturn)
+	for _, ret := range returns {
+		for _, line := range ret.Items {
+			lineReturn := purchaseModels.LineReturn{
+				ReturnID: ret.ID,
+				Quantity: line.Quantity,
+				Status:   ret.Status,
+			}
+			if ret.Refund != nil {
+				amount := lines[line.PurchaseItemID].PaidFor(line.Quantity)
+				lineReturn.RefundStatus = ret.Refund.Status
+				lineReturn.RefundAmount = &amount
 			}
+			byItem[line.PurchaseItemID] = append(byItem[line.PurchaseItemID], lineReturn)
+		}
+	}
+	return byItem
+}
 
-			itemResponses = append(itemResponses, purchaseModels.PurchaseItemResponse{
-				ItemID:   item.ItemID,
-				Quantity: item.Quantity,
-				Name:     itemDetail.Name,
-				Price:    item.PriceAtPurchase,
-			})
+// purchaseCurrency picks the currency a purchase is charged in: the requested
+// one, else the items' own currency when they share one, else the default.
+func (u *purchaseUsecase) purchaseCurrency(requested string, items []purchaseModels.PurchaseItem) string {
+	if requested != "" {
+		return requested
+	}
+	for _, item := range items[1:] {
+		if item.OriginalCurrency != items[0].OriginalCurrency {
+			return u.defaultCurrency
 		}
+	}
+	return items[0].OriginalCurrency
+}
+
+// itemsOfPurchases keeps the lines that belong to one of purchases.
+func itemsOfPurchases(items []purchaseModels.PurchaseItem, purchases []purchaseModels.Purchase) []purchaseModels.PurchaseItem {
+	kept := make(map[uuid.UUID]bool, len(purchases))
+	for _, p := range purchases {
+		kept[p.ID] = true
+	}
+	filtered := items[:0]
+	for _, item := range items {
+		if kept[item.PurchaseID] {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// encodePurchaseCursor builds an opaque cursor pointing just past purchase.
+func encodePurchaseCursor(purchase purchaseModels.Purchase) string {
+	raw, _ := json.Marshal(purchaseModels.PurchaseCursor{CreatedAt: purchase.CreatedAt, ID: purchase.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodePurchaseCursor(token string) (*purchaseModels.PurchaseCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	var cursor purchaseModels.PurchaseCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == uuid.Nil || cursor.CreatedAt.IsZero() {
+		return nil, errors.New("incomplete cursor")
+	}
+	return &cursor, nil
+}
 
-		purchases[i].Items = itemResponses
+// itemServiceError reports an open circuit as ErrItemServiceDown.
+func itemServiceError(err error) error {
+	if errors.Is(err, clients.ErrCircuitOpen) {
+		return ErrItemServiceDown
 	}
+	return err
+}
+
+// uniqueIDs drops repeated IDs, keeping the first occurrence of each.
+func uniqueIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
 
-	return purchases, nil
+func currencyOrDefault(currency string) string {
+	if currency == "" {
+		return money.DefaultCurrency
+	}
+	return currency
 }
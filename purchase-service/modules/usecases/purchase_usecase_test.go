@@ -0,0 +1,490 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"purchase-service/modules/clients"
+	purchaseModels "purchase-service/modules/models"
+	purchaseRepos "purchase-service/modules/repositories"
+	"purchase-service/modules/shipping"
+	"purchase-service/modules/tax"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"shop-crud/item-service/pkg/money"
+)
+
+// The fakes embed the interface they stand in for and implement only what
+// CreatePurchase calls; any other call panics on the nil embedded value.
+
+type fakePurchaseRepo struct {
+	purchaseRepos.PurchaseRepository
+	created  *purchaseModels.Purchase
+	items    []purchaseModels.PurchaseItem
+	payment  *purchaseModels.Payment
+	complete bool
+}
+
+func (r *fakePurchaseRepo) CreatePurchaseInTx(ctx context.Context, purchase *purchaseModels.Purchase, items []purchaseModels.PurchaseItem, payment *purchaseModels.Payment) error {
+	r.created, r.items, r.payment = purchase, items, payment
+	return nil
+}
+
+func (r *fakePurchaseRepo) CompletePurchase(ctx context.Context, purchaseID uuid.UUID, change *purchaseModels.PurchaseStatusChange) (string, error) {
+	change.ID = uuid.New()
+	change.CreatedAt = time.Now()
+	r.complete = true
+	return "INV-2026-000001", nil
+}
+
+type fakeItemClient struct {
+	clients.ItemClient
+	items map[uuid.UUID]*clients.ItemResponse
+}
+
+func (c *fakeItemClient) GetItemsByIDs(ctx context.Context, itemIDs []uuid.UUID) (map[uuid.UUID]*clients.ItemResponse, error) {
+	found := make(map[uuid.UUID]*clients.ItemResponse)
+	for _, id := range itemIDs {
+		if item, ok := c.items[id]; ok {
+			found[id] = item
+		}
+	}
+	return found, nil
+}
+
+type fakeUserClient struct {
+	address *clients.AddressResponse
+}
+
+func (c *fakeUserClient) GetAddress(ctx context.Context, userID, addressID uuid.UUID) (*clients.AddressResponse, error) {
+	return c.address, nil
+}
+
+type fakeSaga struct {
+	PurchaseSaga
+	executed []purchaseModels.PurchaseItem
+}
+
+func (s *fakeSaga) Execute(ctx context.Context, purchaseID uuid.UUID, items []purchaseModels.PurchaseItem) error {
+	s.executed = items
+	return nil
+}
+
+type fakePayments struct {
+	PaymentUsecase
+	authorized, captured money.Amount
+}
+
+func (p *fakePayments) NewPayment(purchase *purchaseModels.Purchase) *purchaseModels.Payment {
+	return &purchaseModels.Payment{
+		ID:         uuid.New(),
+		PurchaseID: purchase.ID,
+		Amount:     purchase.TotalAmount,
+		Currency:   purchase.Currency,
+		Status:     purchaseModels.PaymentPending,
+	}
+}
+
+func (p *fakePayments) Authorize(ctx context.Context, payment *purchaseModels.Payment, token string) error {
+	p.authorized = payment.Amount
+	payment.Status = purchaseModels.PaymentAuthorized
+	return nil
+}
+
+func (p *fakePayments) Capture(ctx context.Context, payment *purchaseModels.Payment) error {
+	p.captured = payment.Amount
+	payment.Status = purchaseModels.PaymentCaptured
+	return nil
+}
+
+type fakePromotionRepo struct {
+	purchaseRepos.PromotionRepository
+	promotion *purchaseModels.Promotion
+}
+
+func (r *fakePromotionRepo) FindByCode(ctx context.Context, code string) (*purchaseModels.Promotion, error) {
+	return r.promotion, nil
+}
+
+func (r *fakePromotionRepo) CountRedemptions(ctx context.Context, promotionID, userID uuid.UUID) (int, int, error) {
+	return 0, 0, nil
+}
+
+// fakeRates quotes rates by "FROM>TO", and the identity rate between a
+// currency and itself.
+type fakeRates map[string]string
+
+func (r fakeRates) Rate(ctx context.Context, from, to string) (money.Rate, error) {
+	if from == to {
+		return money.OneRate(), nil
+	}
+	value, ok := r[from+">"+to]
+	if !ok {
+		return money.Rate{}, fmt.Errorf("no rate for %s>%s", from, to)
+	}
+	return money.ParseRate(value)
+}
+
+type fakeTaxRules struct {
+	rules *tax.Rules
+}
+
+func (p *fakeTaxRules) Rules(ctx context.Context) (*tax.Rules, error) {
+	return p.rules, nil
+}
+
+type fakeShipping struct {
+	method *shipping.Method
+}
+
+func (p *fakeShipping) Method(ctx context.Context, code string) (*shipping.Method, error) {
+	if code != p.method.Code {
+		return nil, shipping.ErrUnknownMethod
+	}
+	return p.method, nil
+}
+
+// testShippingMethod ships within Indonesia, priced in IDR by weight.
+func testShippingMethod() *shipping.Method {
+	return &shipping.Method{
+		Code:     "standard",
+		Currency: "IDR",
+		Zones:    []shipping.Zone{{Name: "domestic", CountryCode: "ID"}},
+		Rates: []shipping.WeightRate{
+			{Zone: "domestic", MaxWeightGrams: 1000, Fee: money.MustParse("20000")},
+			{Zone: "domestic", MaxWeightGrams: 5000, Fee: money.MustParse("50000")},
+			{Zone: "domestic", Fee: money.MustParse("150000")},
+		},
+	}
+}
+
+// testPurchaseUsecase wires CreatePurchase to the fakes, converting IDR to
+// USD at 0.0000615.
+func testPurchaseUsecase(repo *fakePurchaseRepo, promotion *purchaseModels.Promotion, catalog map[uuid.UUID]*clients.ItemResponse,
+	saga *fakeSaga, payments *fakePayments, rules *tax.Rules) PurchaseUsecase {
+	return NewPurchaseUsecase(repo, nil, nil, &fakePromotionRepo{promotion: promotion},
+		&fakeItemClient{items: catalog},
+		&fakeUserClient{address: &clients.AddressResponse{ID: uuid.New(), City: "Bandung", Province: "Jawa Barat", CountryCode: "ID"}},
+		saga, payments, fakeRates{"IDR>USD": "0.0000615"}, &fakeTaxRules{rules: rules}, &fakeShipping{method: testShippingMethod()}, "IDR")
+}
+
+func mustRate(t *testing.T, value string) money.Rate {
+	t.Helper()
+	rate, err := money.ParseNonNegativeRate(value)
+	if err != nil {
+		t.Fatalf("parsing rate %q: %v", value, err)
+	}
+	return rate
+}
+
+// TestCreatePurchaseTotals pins the totals of a USD purchase of an item
+// priced in IDR and a variant priced in USD: each line is converted at the
+// locked rate, taxed per line in exclusive mode after its discount, and the
+// shipping fee, quoted in IDR by weight, is converted and added untaxed.
+func TestCreatePurchaseTotals(t *testing.T) {
+	itemA, itemB, variantB := uuid.New(), uuid.New(), uuid.New()
+	variantPrice := money.MustParse("24.99")
+	catalog := map[uuid.UUID]*clients.ItemResponse{
+		itemA: {ID: itemA, Name: "Batik shirt", Price: money.MustParse("100000"), Currency: "IDR", WeightGrams: 500},
+		itemB: {ID: itemB, Name: "Coffee", Price: money.MustParse("19.99"), Currency: "USD", TaxClass: "reduced", WeightGrams: 250,
+			Variants: []clients.VariantResponse{{ID: variantB, SKU: "COF-1KG", Price: &variantPrice}}},
+	}
+
+	// Per line: price, discount, net, tax; all in USD minor units.
+	type line struct{ price, discount, net, tax int64 }
+	tests := []struct {
+		name      string
+		promotion *purchaseModels.Promotion
+		lines     []line
+		discount  int64
+		subtotal  int64
+		tax       int64
+		shipping  int64
+		total     int64
+	}{
+		{
+			name: "no coupon",
+			// 100000 IDR at 0.0000615 is 6.15, three of them 18.45 taxed
+			// 11% = 2.0295; two variants at 24.99 are 49.98 taxed 5% = 2.499.
+			lines:    []line{{615, 0, 1845, 203}, {2499, 0, 4998, 250}},
+			subtotal: 6843, tax: 453,
+			// 2000 g costs 50000 IDR, 3.075 USD.
+			shipping: 308, total: 7604,
+		},
+		{
+			name: "coupon on one line",
+			promotion: &purchaseModels.Promotion{
+				ID: uuid.New(), Code: "COFFEE10", Type: purchaseModels.PromotionPercentage, Value: money.MustParse("10"),
+				ItemIDs: []uuid.UUID{itemB}, Active: true, StartsAt: time.Now().Add(-time.Hour),
+			},
+			// 10% of 49.98 is 4.998; the 44.98 left is taxed 2.249.
+			lines:    []line{{615, 0, 1845, 203}, {2499, 500, 4498, 225}},
+			discount: 500, subtotal: 6343, tax: 428,
+			shipping: 308, total: 7079,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakePurchaseRepo{}
+			saga := &fakeSaga{}
+			payments := &fakePayments{}
+			rules := &tax.Rules{
+				Mode:     tax.ModeExclusive,
+				Rounding: tax.RoundPerLine,
+				Rates:    map[string]money.Rate{"standard": mustRate(t, "0.11"), "reduced": mustRate(t, "0.05")},
+			}
+			uc := testPurchaseUsecase(repo, tt.promotion, catalog, saga, payments, rules)
+
+			req := purchaseModels.CreatePurchaseRequest{
+				Items: []purchaseModels.PurchaseItemRequest{
+					{ItemID: itemA, Quantity: 3},
+					{ItemID: itemB, VariantID: &variantB, Quantity: 2},
+				},
+				Currency:       "USD",
+				AddressID:      uuid.New(),
+				ShippingMethod: "standard",
+			}
+			if tt.promotion != nil {
+				req.CouponCode = tt.promotion.Code
+			}
+
+			purchase, err := uc.CreatePurchase(context.Background(), uuid.New(), req)
+			if err != nil {
+				t.Fatalf("CreatePurchase: %v", err)
+			}
+
+			if len(repo.items) != len(tt.lines) {
+				t.Fatalf("stored %d lines, want %d", len(repo.items), len(tt.lines))
+			}
+			for i, want := range tt.lines {
+				got := repo.items[i]
+				if got.PriceAtPurchase.Minor() != want.price || got.DiscountAmount.Minor() != want.discount ||
+					got.NetAmount.Minor() != want.net || got.TaxAmount.Minor() != want.tax {
+					t.Errorf("line %d: price %s, discount %s, net %s, tax %s; want %d, %d, %d, %d minor units", i,
+						got.PriceAtPurchase, got.DiscountAmount, got.NetAmount, got.TaxAmount, want.price, want.discount, want.net, want.tax)
+				}
+			}
+			if rate := repo.items[0].ExchangeRate.String(); rate != "0.0000615" {
+				t.Errorf("line 0 exchange rate = %s, want 0.0000615", rate)
+			}
+
+			got := [][2]int64{
+				{purchase.DiscountAmount.Minor(), tt.discount},
+				{purchase.SubtotalAmount.Minor(), tt.subtotal},
+				{purchase.TaxAmount.Minor(), tt.tax},
+				{purchase.ShippingFee.Minor(), tt.shipping},
+				{purchase.TotalAmount.Minor(), tt.total},
+			}
+			for i, name := range []string{"discount", "subtotal", "tax", "shipping fee", "total"} {
+				if got[i][0] != got[i][1] {
+					t.Errorf("%s = %s, want %s", name, money.FromMinor(got[i][0]), money.FromMinor(got[i][1]))
+				}
+			}
+			if purchase.Currency != "USD" || purchase.ShippingWeightGrams != 2000 || purchase.ShippingZone != "domestic" {
+				t.Errorf("currency %s, weight %d g, zone %q; want USD, 2000 g, domestic",
+					purchase.Currency, purchase.ShippingWeightGrams, purchase.ShippingZone)
+			}
+
+			// The amount charged is the total, and it is taken only after the
+			// saga has run.
+			if repo.payment.Amount != purchase.TotalAmount || payments.authorized != purchase.TotalAmount || payments.captured != purchase.TotalAmount {
+				t.Errorf("payment %s, authorized %s, captured %s; want %s",
+					repo.payment.Amount, payments.authorized, payments.captured, purchase.TotalAmount)
+			}
+			if len(saga.executed) != len(tt.lines) {
+				t.Errorf("saga ran for %d lines, want %d", len(saga.executed), len(tt.lines))
+			}
+			if !repo.complete || purchase.Status != purchaseModels.PurchasePaid || purchase.PaidAt == nil || purchase.InvoiceNumber == "" {
+				t.Errorf("purchase status %s, paid at %v, invoice %q; want it completed and paid",
+					purchase.Status, purchase.PaidAt, purchase.InvoiceNumber)
+			}
+		})
+	}
+}
+
+// TestCreatePurchaseLargeCartTotals pins the totals of a cart of many lines
+// in large quantities, mixing IDR and USD prices and standard (11%), reduced
+// (5%) and exempt items, under each pricing mode and rounding rule. The
+// expected amounts were worked out independently with exact fractions.
+func TestCreatePurchaseLargeCartTotals(t *testing.T) {
+	cart := []struct {
+		name     string
+		price    string
+		currency string
+		class    string
+		weight   int
+		quantity int
+	}{
+		{"Batik shirt", "100000", "IDR", "", 500, 40},   // 6.15 USD
+		{"Sarong", "87500", "IDR", "standard", 300, 25}, // 5.38125, so 5.38
+		{"Coffee beans", "12.49", "USD", "reduced", 250, 37},
+		{"Tea", "3.33", "USD", "reduced", 100, 99},
+		{"Rice", "75000", "IDR", "exempt", 5000, 12}, // 4.6125, so 4.61
+		{"Book", "9.99", "USD", "exempt", 400, 7},
+		{"Headphones", "59.95", "USD", "standard", 350, 3},
+		{"Phone case", "45000", "IDR", "standard", 80, 150}, // 2.7675, so 2.77
+		{"Sambal", "1.17", "USD", "reduced", 200, 250},
+		{"Notebook", "0.99", "USD", "standard", 50, 500},
+		{"Medicine", "13.37", "USD", "exempt", 30, 11},
+		{"Candles", "4.49", "USD", "standard", 150, 33},
+	}
+	catalog := make(map[uuid.UUID]*clients.ItemResponse, len(cart))
+	var items []purchaseModels.PurchaseItemRequest
+	for _, line := range cart {
+		id := uuid.New()
+		catalog[id] = &clients.ItemResponse{ID: id, Name: line.name, Price: money.MustParse(line.price), Currency: line.currency,
+			TaxClass: line.class, WeightGrams: line.weight}
+		items = append(items, purchaseModels.PurchaseItemRequest{ItemID: id, Quantity: line.quantity})
+	}
+
+	percentage := &purchaseModels.Promotion{
+		ID: uuid.New(), Code: "SAVE12", Type: purchaseModels.PromotionPercentage, Value: money.MustParse("12.5"),
+		Active: true, StartsAt: time.Now().Add(-time.Hour),
+	}
+	fixed := &purchaseModels.Promotion{
+		ID: uuid.New(), Code: "MINUS25", Type: purchaseModels.PromotionFixedAmount, Value: money.MustParse("25"),
+		Active: true, StartsAt: time.Now().Add(-time.Hour),
+	}
+	fixedDiscounts := []int64{206, 113, 388, 276, 46, 58, 151, 349, 245, 415, 123, 130}
+
+	tests := []struct {
+		name      string
+		mode      string
+		rounding  string
+		promotion *purchaseModels.Promotion
+		// Per line, in minor units.
+		discounts []int64
+		taxes     []int64
+		discount  int64
+		subtotal  int64
+		tax       int64
+		total     int64
+	}{
+		{
+			name: "exclusive, per line", mode: tax.ModeExclusive, rounding: tax.RoundPerLine,
+			// Sarong, Phone case and Sambal are taxed 14.795, 45.705 and
+			// 14.625, each rounded up.
+			taxes:    []int64{2706, 1480, 2311, 1648, 0, 0, 1978, 4571, 1463, 5445, 0, 1630},
+			subtotal: 297564, tax: 23232, total: 321719,
+		},
+		{
+			name: "exclusive, per invoice", mode: tax.ModeExclusive, rounding: tax.RoundPerInvoice,
+			// The exact tax, 232.3072, rounds to a cent less than per line:
+			// of the three halves, Sambal's comes last and is rounded down.
+			taxes:    []int64{2706, 1480, 2311, 1648, 0, 0, 1978, 4571, 1462, 5445, 0, 1630},
+			subtotal: 297564, tax: 23231, total: 321718,
+		},
+		{
+			name: "exclusive, per invoice, percentage coupon", mode: tax.ModeExclusive, rounding: tax.RoundPerInvoice,
+			promotion: percentage,
+			discounts: []int64{3075, 1681, 5777, 4121, 692, 874, 2248, 5194, 3656, 6188, 1838, 1852},
+			taxes:     []int64{2368, 1295, 2022, 1442, 0, 0, 1731, 3999, 1280, 4764, 0, 1426},
+			discount:  37196, subtotal: 260368, tax: 20327, total: 281618,
+		},
+		{
+			name: "inclusive, per line, fixed coupon", mode: tax.ModeInclusive, rounding: tax.RoundPerLine,
+			promotion: fixed,
+			discounts: fixedDiscounts,
+			taxes:     []int64{2417, 1322, 2182, 1557, 0, 0, 1767, 4083, 1381, 4864, 0, 1455},
+			discount:  2500, subtotal: 274036, tax: 21028, total: 295987,
+		},
+		{
+			name: "inclusive, per invoice, fixed coupon", mode: tax.ModeInclusive, rounding: tax.RoundPerInvoice,
+			promotion: fixed,
+			// The tax is carved out of the same gross, so only the split
+			// between net and tax moves.
+			discounts: fixedDiscounts,
+			taxes:     []int64{2417, 1322, 2182, 1557, 0, 0, 1767, 4083, 1381, 4864, 0, 1456},
+			discount:  2500, subtotal: 274035, tax: 21029, total: 295987,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakePurchaseRepo{}
+			rules := &tax.Rules{
+				Mode:     tt.mode,
+				Rounding: tt.rounding,
+				Rates: map[string]money.Rate{
+					"standard": mustRate(t, "0.11"), "reduced": mustRate(t, "0.05"), "exempt": mustRate(t, "0"),
+				},
+			}
+			uc := testPurchaseUsecase(repo, tt.promotion, catalog, &fakeSaga{}, &fakePayments{}, rules)
+
+			req := purchaseModels.CreatePurchaseRequest{
+				Items:          items,
+				Currency:       "USD",
+				AddressID:      uuid.New(),
+				ShippingMethod: "standard",
+			}
+			if tt.promotion != nil {
+				req.CouponCode = tt.promotion.Code
+			}
+
+			purchase, err := uc.CreatePurchase(context.Background(), uuid.New(), req)
+			if err != nil {
+				t.Fatalf("CreatePurchase: %v", err)
+			}
+
+			if len(repo.items) != len(cart) {
+				t.Fatalf("stored %d lines, want %d", len(repo.items), len(cart))
+			}
+			for i, got := range repo.items {
+				var discount int64
+				if tt.discounts != nil {
+					discount = tt.discounts[i]
+				}
+				if got.DiscountAmount.Minor() != discount || got.TaxAmount.Minor() != tt.taxes[i] {
+					t.Errorf("%s: discount %s, tax %s; want %s, %s", cart[i].name,
+						got.DiscountAmount, got.TaxAmount, money.FromMinor(discount), money.FromMinor(tt.taxes[i]))
+				}
+			}
+
+			// 202.78 kg falls in the open-ended bracket, 150000 IDR or 9.225 USD.
+			got := [][2]int64{
+				{purchase.DiscountAmount.Minor(), tt.discount},
+				{purchase.SubtotalAmount.Minor(), tt.subtotal},
+				{purchase.TaxAmount.Minor(), tt.tax},
+				{purchase.ShippingFee.Minor(), 923},
+				{purchase.TotalAmount.Minor(), tt.total},
+			}
+			for i, name := range []string{"discount", "subtotal", "tax", "shipping fee", "total"} {
+				if got[i][0] != got[i][1] {
+					t.Errorf("%s = %s, want %s", name, money.FromMinor(got[i][0]), money.FromMinor(got[i][1]))
+				}
+			}
+			if purchase.ShippingWeightGrams != 202780 {
+				t.Errorf("weight = %d g, want 202780 g", purchase.ShippingWeightGrams)
+			}
+		})
+	}
+}
+
+// TestCreatePurchaseUnknownTaxClass checks that an item whose tax class has
+// no rate is rejected before anything is written or charged.
+func TestCreatePurchaseUnknownTaxClass(t *testing.T) {
+	itemID := uuid.New()
+	catalog := map[uuid.UUID]*clients.ItemResponse{
+		itemID: {ID: itemID, Name: "Wine", Price: money.MustParse("30"), Currency: "USD", TaxClass: "alcohol", WeightGrams: 1200},
+	}
+	rules := &tax.Rules{Mode: tax.ModeExclusive, Rounding: tax.RoundPerLine, Rates: map[string]money.Rate{"standard": mustRate(t, "0.11")}}
+	repo := &fakePurchaseRepo{}
+	payments := &fakePayments{}
+	uc := testPurchaseUsecase(repo, nil, catalog, &fakeSaga{}, payments, rules)
+
+	_, err := uc.CreatePurchase(context.Background(), uuid.New(), purchaseModels.CreatePurchaseRequest{
+		Items:          []purchaseModels.PurchaseItemRequest{{ItemID: itemID, Quantity: 1}},
+		Currency:       "USD",
+		AddressID:      uuid.New(),
+		ShippingMethod: "standard",
+	})
+	if !errors.Is(err, ErrTaxClassNotSupported) {
+		t.Fatalf("CreatePurchase error = %v, want %v", err, ErrTaxClassNotSupported)
+	}
+	if repo.created != nil || !payments.authorized.IsZero() {
+		t.Error("purchase was written or charged despite the unknown tax class")
+	}
+}
@@ -0,0 +1,212 @@
+package usecases
+
+import (
+	"context"
+	"log"
+	"math"
+	purchaseModels "purchase-service/modules/models"
+	"purchase-service/modules/repositories"
+	"time"
+
+	"shop-crud/item-service/pkg/money"
+)
+
+const (
+	// defaultReportDays is how many days, ending today, a report covers when
+	// it is not given a range.
+	defaultReportDays = 30
+	defaultTopItems   = 10
+	// reportRefreshOverlap is how far before the last refresh changes are
+	// looked for again, to catch purchases whose transaction was still open.
+	reportRefreshOverlap = 5 * time.Minute
+)
+
+const reportDate = "2006-01-02"
+
+type ReportUsecase interface {
+	Revenue(ctx context.Context, query purchaseModels.ReportQuery) (*purchaseModels.RevenueReport, error)
+	TopItems(ctx context.Context, query purchaseModels.ReportQuery) (*purchaseModels.TopItemsReport, error)
+	AverageOrderValue(ctx context.Context, query purchaseModels.ReportQuery) (*purchaseModels.OrderValueReport, error)
+	RepeatCustomers(ctx context.Context, query purchaseModels.ReportQuery) (*purchaseModels.RepeatCustomerReport, error)
+	Refresh(ctx context.Context) (*purchaseModels.ReportRefreshResponse, error)
+}
+
+type reportUsecase struct {
+	reportRepo      repositories.ReportRepository
+	defaultCurrency string
+}
+
+// NewReportUsecase returns a usecase that reports sales from the summaries
+// reportRepo keeps, in defaultCurrency unless a report asks for another.
+func NewReportUsecase(reportRepo repositories.ReportRepository, defaultCurrency string) ReportUsecase {
+	return &reportUsecase{
+		reportRepo:      reportRepo,
+		defaultCurrency: defaultCurrency,
+	}
+}
+
+// Revenue totals sales per day, week or month.
+func (u *reportUsecase) Revenue(ctx context.Context, query purchaseModels.ReportQuery) (*purchaseModels.RevenueReport, error) {
+	rng, meta, err := u.reportRange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	interval := query.Interval
+	if interval == "" {
+		interval = "day"
+	}
+
+	periods, err := u.reportRepo.RevenueByPeriod(ctx, rng, interval)
+	if err != nil {
+		return nil, err
+	}
+	return &purchaseModels.RevenueReport{ReportMeta: meta, Interval: interval, Periods: periods}, nil
+}
+
+// TopItems ranks the best-selling items by quantity or by revenue.
+func (u *reportUsecase) TopItems(ctx context.Context, query purchaseModels.ReportQuery) (*purchaseModels.TopItemsReport, error) {
+	rng, meta, err := u.reportRange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	by := query.By
+	if by == "" {
+		by = "quantity"
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultTopItems
+	}
+
+	items, err := u.reportRepo.TopItems(ctx, rng, by, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &purchaseModels.TopItemsReport{ReportMeta: meta, By: by, Items: items}, nil
+}
+
+// AverageOrderValue divides the range's revenue by its orders, rounding half
+// up to the minor unit.
+func (u *reportUsecase) AverageOrderValue(ctx context.Context, query purchaseModels.ReportQuery) (*purchaseModels.OrderValueReport, error) {
+	rng, meta, err := u.reportRange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, revenue, err := u.reportRepo.OrderTotals(ctx, rng)
+	if err != nil {
+		return nil, err
+	}
+	report := &purchaseModels.OrderValueReport{ReportMeta: meta, Orders: orders, Revenue: revenue}
+	if orders > 0 {
+		n := int64(orders)
+		report.AverageOrderValue = money.FromMinor((revenue.Minor()*2 + n) / (2 * n))
+	}
+	return report, nil
+}
+
+// RepeatCustomers reports the share of the range's customers who ordered more
+// than once in it, rounded to four places.
+func (u *reportUsecase) RepeatCustomers(ctx context.Context, query purchaseModels.ReportQuery) (*purchaseModels.RepeatCustomerReport, error) {
+	rng, meta, err := u.reportRange(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	customers, repeat, err := u.reportRepo.RepeatCustomers(ctx, rng)
+	if err != nil {
+		return nil, err
+	}
+	report := &purchaseModels.RepeatCustomerReport{ReportMeta: meta, Customers: customers, RepeatCustomers: repeat}
+	if customers > 0 {
+		report.RepeatCustomerRate = math.Round(float64(repeat)/float64(customers)*10000) / 10000
+	}
+	return report, nil
+}
+
+// Refresh brings the sales summaries up to date now rather than at the next
+// scheduled refresh.
+func (u *reportUsecase) Refresh(ctx context.Context) (*purchaseModels.ReportRefreshResponse, error) {
+	buckets, refreshedAt, err := u.reportRepo.RefreshSales(ctx, reportRefreshOverlap)
+	if err != nil {
+		return nil, err
+	}
+	return &purchaseModels.ReportRefreshResponse{Buckets: buckets, RefreshedAt: refreshedAt}, nil
+}
+
+// reportRange turns the days a query asks for into the instants they start
+// and end at in its time zone. Without a range it covers the last
+// defaultReportDays days, today included.
+func (u *reportUsecase) reportRange(ctx context.Context, query purchaseModels.ReportQuery) (purchaseModels.ReportRange, purchaseModels.ReportMeta, error) {
+	tz := query.TimeZone
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return purchaseModels.ReportRange{}, purchaseModels.ReportMeta{}, err
+	}
+	currency := query.Currency
+	if currency == "" {
+		currency = u.defaultCurrency
+	}
+
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if query.To != "" {
+		if to, err = time.ParseInLocation(reportDate, query.To, loc); err != nil {
+			return purchaseModels.ReportRange{}, purchaseModels.ReportMeta{}, err
+		}
+	}
+	from := to.AddDate(0, 0, 1-defaultReportDays)
+	if query.From != "" {
+		if from, err = time.ParseInLocation(reportDate, query.From, loc); err != nil {
+			return purchaseModels.ReportRange{}, purchaseModels.ReportMeta{}, err
+		}
+	}
+	if from.After(to) {
+		return purchaseModels.ReportRange{}, purchaseModels.ReportMeta{}, ErrInvalidRange
+	}
+
+	refreshedAt, err := u.reportRepo.SalesRefreshedAt(ctx)
+	if err != nil {
+		return purchaseModels.ReportRange{}, purchaseModels.ReportMeta{}, err
+	}
+	rng := purchaseModels.ReportRange{
+		From:     from,
+		To:       to.AddDate(0, 0, 1),
+		TimeZone: tz,
+		Currency: currency,
+	}
+	meta := purchaseModels.ReportMeta{
+		From:        from.Format(reportDate),
+		To:          to.Format(reportDate),
+		TimeZone:    tz,
+		Currency:    currency,
+		RefreshedAt: refreshedAt,
+	}
+	return rng, meta, nil
+}
+
+// RunReportRefresh brings the sales summaries up to date every interval until
+// ctx is cancelled, starting straight away so reports have data after a
+// deploy.
+func RunReportRefresh(ctx context.Context, uc ReportUsecase, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		refreshed, err := uc.Refresh(ctx)
+		if err != nil {
+			log.Printf("report refresh: %v", err)
+		} else if refreshed.Buckets > 0 {
+			log.Printf("report refresh: rebuilt %d buckets", refreshed.Buckets)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"log"
+	"purchase-service/modules/clients"
+	purchaseModels "purchase-service/modules/models"
+	purchaseRepos "purchase-service/modules/repositories"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"shop-crud/item-service/pkg/money"
+)
+
+var (
+	ErrReturnNotFound         = errors.New("return not found")
+	ErrReturnNotAllowed       = errors.New("only delivered purchases can be returned")
+	ErrReturnQuantityExceeded = errors.New("return quantity exceeds the units left to return on the purchase item")
+	ErrReturnAlreadyDecided   = errors.New("return has already been approved or rejected")
+	ErrReturnConflict         = errors.New("purchase changed while the return was being requested")
+)
+
+type ReturnUsecase interface {
+	RequestReturn(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool, req purchaseModels.CreateReturnRequest) (*purchaseModels.PurchaseReturn, error)
+	ListPurchaseReturns(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) ([]purchaseModels.PurchaseReturn, error)
+	ListReturns(ctx context.Context, query purchaseModels.ReturnQuery) ([]purchaseModels.PurchaseReturn, error)
+	ApproveReturn(ctx context.Context, returnID uuid.UUID, actorID string, req purchaseModels.ReviewReturnRequest) (*purchaseModels.PurchaseReturn, error)
+	RejectReturn(ctx context.Context, returnID uuid.UUID, actorID string, req purchaseModels.ReviewReturnRequest) (*purchaseModels.PurchaseReturn, error)
+	RestockPending(ctx context.Context, staleAfter time.Duration) (int, error)
+}
+
+type returnUsecase struct {
+	purchaseRepo purchaseRepos.PurchaseRepository
+	returnRepo   purchaseRepos.ReturnRepository
+	itemClient   clients.ItemClient
+	payments     PaymentUsecase
+}
+
+func NewReturnUsecase(purchaseRepo purchaseRepos.PurchaseRepository, returnRepo purchaseRepos.ReturnRepository, itemClient clients.ItemClient, payments PaymentUsecase) ReturnUsecase {
+	return &returnUsecase{
+		purchaseRepo: purchaseRepo,
+		returnRepo:   returnRepo,
+		itemClient:   itemClient,
+		payments:     payments,
+	}
+}
+
+// RequestReturn opens a return for units of a delivered purchase. Units that
+// were cancelled, already returned or are in another open return cannot be
+// returned again.
+func (u *returnUsecase) RequestReturn(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool, req purchaseModels.CreateReturnRequest) (*purchaseModels.PurchaseReturn, error) {
+	purchase, err := u.ownedPurchase(ctx, purchaseID, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if purchase.Status != purchaseModels.PurchaseDelivered {
+		return nil, ErrReturnNotAllowed
+	}
+
+	items, err := u.purchaseRepo.FindPurchaseItemsByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	open, err := u.returnRepo.FindByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := returnLines(items, open, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	purchaseReturn := &purchaseModels.PurchaseReturn{
+		ID:         uuid.New(),
+		PurchaseID: purchaseID,
+		UserID:     purchase.UserID,
+		Status:     purchaseModels.ReturnRequested,
+		Reason:     req.Reason,
+		CreatedAt:  time.Now(),
+		Items:      lines,
+	}
+	if err := u.returnRepo.Create(ctx, purchaseReturn); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrReturnConflict
+		}
+		return nil, err
+	}
+	return purchaseReturn, nil
+}
+
+func (u *returnUsecase) ListPurchaseReturns(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) ([]purchaseModels.PurchaseReturn, error) {
+	if _, err := u.ownedPurchase(ctx, purchaseID, userID, isAdmin); err != nil {
+		return nil, err
+	}
+	return u.returnRepo.FindByPurchaseID(ctx, purchaseID)
+}
+
+func (u *returnUsecase) ListReturns(ctx context.Context, query purchaseModels.ReturnQuery) ([]purchaseModels.PurchaseReturn, error) {
+	return u.returnRepo.FindByStatus(ctx, query.Status)
+}
+
+// ApproveReturn accepts a requested return, refunds the returned units at the
+// price they were bought for and gives their stock back. The approval and
+// refund are recorded first; if paying the refund out or giving the stock
+// back fails, payment reconciliation or RestockPending retries it later.
+func (u *returnUsecase) ApproveReturn(ctx context.Context, returnID uuid.UUID, actorID string, req purchaseModels.ReviewReturnRequest) (*purchaseModels.PurchaseReturn, error) {
+	purchaseReturn, err := u.requestedReturn(ctx, returnID)
+	if err != nil {
+		return nil, err
+	}
+	purchase, err := u.purchaseRepo.FindPurchaseByID(ctx, purchaseReturn.PurchaseID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPurchaseNotFound
+		}
+		return nil, err
+	}
+	items, err := u.purchaseRepo.FindPurchaseItemsByPurchaseID(ctx, purchaseReturn.PurchaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(map[uuid.UUID]purchaseModels.PurchaseItem, len(items))
+	for _, item := range items {
+		lines[item.ID] = item
+	}
+	var amount money.Amount
+	for _, line := range purchaseReturn.Items {
+		amount = amount.Add(lines[line.PurchaseItemID].PaidFor(line.Quantity))
+	}
+
+	now := time.Now()
+	purchaseReturn.Status = purchaseModels.ReturnApproved
+	purchaseReturn.DecisionNote = req.Note
+	purchaseReturn.DecidedBy = actorID
+	purchaseReturn.DecidedAt = &now
+	refund := &purchaseModels.Refund{
+		ID:         uuid.New(),
+		ReturnID:   &purchaseReturn.ID,
+		PurchaseID: purchaseReturn.PurchaseID,
+		Amount:     amount,
+		Currency:   purchase.Currency,
+		Status:     purchaseModels.RefundPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := u.returnRepo.Approve(ctx, purchaseReturn, refund); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrReturnAlreadyDecided
+		}
+		return nil, err
+	}
+	purchaseReturn.Refund = refund
+
+	if err := u.payments.PayRefund(context.WithoutCancel(ctx), refund); err != nil {
+		log.Printf("return %s: refund: %v", purchaseReturn.ID, err)
+	}
+	if err := u.restock(context.WithoutCancel(ctx), purchaseReturn); err != nil {
+		log.Printf("return %s: restock: %v", purchaseReturn.ID, err)
+	}
+	return purchaseReturn, nil
+}
+
+func (u *returnUsecase) RejectReturn(ctx context.Context, returnID uuid.UUID, actorID string, req purchaseModels.ReviewReturnRequest) (*purchaseModels.PurchaseReturn, error) {
+	purchaseReturn, err := u.requestedReturn(ctx, returnID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	purchaseReturn.Status = purchaseModels.ReturnRejected
+	purchaseReturn.DecisionNote = req.Note
+	purchaseReturn.DecidedBy = actorID
+	purchaseReturn.DecidedAt = &now
+	if err := u.returnRepo.Reject(ctx, purchaseReturn); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrReturnAlreadyDecided
+		}
+		return nil, err
+	}
+	return purchaseReturn, nil
+}
+
+// RestockPending gives back the stock of approved returns that have waited
+// for it for longer than staleAfter.
+func (u *returnUsecase) RestockPending(ctx context.Context, staleAfter time.Duration) (int, error) {
+	returns, err := u.returnRepo.FindUnrestored(ctx, time.Now().Add(-staleAfter), restockBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	restocked := 0
+	for i := range returns {
+		if err := u.restock(ctx, &returns[i]); err != nil {
+			log.Printf("return restock: return %s: %v", returns[i].ID, err)
+			continue
+		}
+		restocked++
+	}
+	return restocked, nil
+}
+
+// ownedPurchase loads a purchase the caller may act on. Other users'
+// purchases are reported as missing rather than forbidden.
+func (u *returnUsecase) ownedPurchase(ctx context.Context, purchaseID, userID uuid.UUID, isAdmin bool) (*purchaseModels.Purchase, error) {
+	purchase, err := u.purchaseRepo.FindPurchaseByID(ctx, purchaseID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPurchaseNotFound
+		}
+		return nil, err
+	}
+	if purchase.UserID != userID && !isAdmin {
+		return nil, ErrPurchaseNotFound
+	}
+	return purchase, nil
+}
+
+func (u *returnUsecase) requestedReturn(ctx context.Context, returnID uuid.UUID) (*purchaseModels.PurchaseReturn, error) {
+	purchaseReturn, err := u.returnRepo.FindByID(ctx, returnID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrReturnNotFound
+		}
+		return nil, err
+	}
+	if purchaseReturn.Status != purchaseModels.ReturnRequested {
+		return nil, ErrReturnAlreadyDecided
+	}
+	return purchaseReturn, nil
+}
+
+// restock gives the returned units back to item-service in one command keyed
+// by the return, so retries never add stock twice.
+func (u *returnUsecase) restock(ctx context.Context, purchaseReturn *purchaseModels.PurchaseReturn) error {
+	lines := make([]clients.StockLine, 0, len(purchaseReturn.Items))
+	for _, item := range purchaseReturn.Items {
+		lines = append(lines, clients.StockLine{
+			ItemID:    item.ItemID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+	}
+	err := u.itemClient.IncrementStock(ctx, clients.StockIncrement{
+		Key:           "return:" + purchaseReturn.ID.String(),
+		Lines:         lines,
+		ReferenceType: "purchase_return",
+		ReferenceID:   &purchaseReturn.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	restoredAt, err := u.returnRepo.MarkStockRestored(ctx, purchaseReturn.ID)
+	if err != nil {
+		return err
+	}
+	purchaseReturn.StockRestoredAt = &restoredAt
+	return nil
+}
+
+// returnLines resolves the requested lines against the purchase and checks
+// each against the units still returnable. Repeated lines in the request are
+// added together.
+func returnLines(items []purchaseModels.PurchaseItem, returns []purchaseModels.PurchaseReturn, requested []purchaseModels.ReturnItemRequest) ([]purchaseModels.PurchaseReturnItem, error) {
+	known := make(map[uuid.UUID]bool, len(items))
+	for _, item := range items {
+		known[item.ID] = true
+	}
+	quantities := make(map[uuid.UUID]int)
+	for _, line := range requested {
+		if !known[line.PurchaseItemID] {
+			return nil, ErrPurchaseItemNotFound
+		}
+		quantities[line.PurchaseItemID] += line.Quantity
+	}
+
+	pending := make(map[uuid.UUID]int)
+	for _, ret := range returns {
+		if ret.Status != purchaseModels.ReturnRequested {
+			continue
+		}
+		for _, line := range ret.Items {
+			pending[line.PurchaseItemID] += line.Quantity
+		}
+	}
+
+	var lines []purchaseModels.PurchaseReturnItem
+	for _, item := range items {
+		quantity := quantities[item.ID]
+		if quantity == 0 {
+			continue
+		}
+		if quantity > item.Quantity-item.CancelledQuantity-item.ReturnedQuantity-pending[item.ID] {
+			return nil, ErrReturnQuantityExceeded
+		}
+		lines = append(lines, purchaseModels.PurchaseReturnItem{
+			PurchaseItemID: item.ID,
+			ItemID:         item.ItemID,
+			VariantID:      item.VariantID,
+			Quantity:       quantity,
+		})
+	}
+	return lines, nil
+}
+
+// RunReturnRestock retries giving back the stock of approved returns every
+// interval until ctx is cancelled.
+func RunReturnRestock(ctx context.Context, uc ReturnUsecase, interval, staleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			restocked, err := uc.RestockPending(ctx, staleAfter)
+			if err != nil {
+				log.Printf("return restock: %v", err)
+			} else if restocked > 0 {
+				log.Printf("return restock: gave back stock for %d returns", restocked)
+			}
+		}
+	}
+}
@@ -12,6 +12,11 @@ type Config struct {
 	DBUrl     string
 	AppPort	  string
 	JWTSecret string
+
+	// BootstrapAdminEmail is the registered user made admin at startup while
+	// there is no admin, so the first admin can be created without touching
+	// the database.
+	BootstrapAdminEmail string
 }
 
 var (
@@ -35,6 +40,8 @@ func LoadConfig() *Config {
 			DBUrl:     getEnv("DB_URL"),
 			AppPort:   getEnv("APP_PORT"),
 			JWTSecret: getEnv("JWT_SECRET"),
+
+			BootstrapAdminEmail: getEnvOrDefault("BOOTSTRAP_ADMIN_EMAIL", ""),
 		}
 	})
 	return config
@@ -53,3 +60,11 @@ func getEnv(key string) string {
 	}
 	return value
 }
+
+// getEnvOrDefault retrieves an optional environment variable, falling back to a default.
+func getEnvOrDefault(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists && value != "" {
+		return value
+	}
+	return fallback
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	authmiddle "user-service/middleware"
 )
 
 type CustomValidator struct {
@@ -50,10 +52,26 @@ func main() {
 
 	v1 := e.Group("/api/v1")
 
+	authMiddleware := authmiddle.JWTAuthMiddleware(jwtSecret)
 	userRepo := repositories.NewUserRepository(config.DBPool)
-	userUsecase := usecases.NewUserUsecase(userRepo, jwtSecret)
+	userUsecase := usecases.NewUserUsecase(userRepo, jwtSecret, config.GetConfig().BootstrapAdminEmail)
 	userHandler := handlers.NewUserHandler(userUsecase)
-	userHandler.RegisterRoutes(v1)
+	userHandler.RegisterRoutes(v1, authMiddleware)
+
+	// Promote the bootstrap admin. This only happens at startup, once they
+	// have registered.
+	promoted, err := userUsecase.BootstrapAdmin(context.Background())
+	if err != nil {
+		log.Fatalf("❌ Gagal menyiapkan admin pertama: %v", err)
+	}
+	if promoted {
+		log.Printf("✅ Admin pertama dibuat untuk %s", config.GetConfig().BootstrapAdminEmail)
+	}
+
+	addressRepo := repositories.NewAddressRepository(config.DBPool)
+	addressUsecase := usecases.NewAddressUsecase(addressRepo)
+	addressHandler := handlers.NewAddressHandler(addressUsecase)
+	addressHandler.RegisterRoutes(v1, authMiddleware)
 	
 	addr := fmt.Sprintf(":%s", appPort)
 	log.Printf("✅ User service berjalan di port %s", appPort)
@@ -0,0 +1,155 @@
+// File: middleware/auth.go
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// Standardized errors for consistent responses.
+var (
+	// Returned if the Authorization header is missing or malformed.
+	ErrMissingAuthHeader = echo.NewHTTPError(http.StatusUnauthorized, "Missing or malformed JWT")
+	// Returned if the token is invalid or expired.
+	ErrInvalidJWT = echo.NewHTTPError(http.StatusUnauthorized, "Invalid or expired JWT")
+)
+
+// Roles a user can hold, carried in the token's "role" claim.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+// Permissions checked by this service.
+const (
+	PermManageUsers = "users:manage"
+)
+
+// rolePermissions lists what each role may do in this service.
+var rolePermissions = map[string][]string{
+	RoleAdmin: {PermManageUsers},
+}
+
+// JWTAuthMiddleware returns an Echo middleware that validates JWT tokens.
+func JWTAuthMiddleware(jwtSecret string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Get the Authorization header.
+			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" {
+				return ErrMissingAuthHeader
+			}
+
+			// Check header format: must be "Bearer <token>".
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				return ErrMissingAuthHeader
+			}
+			tokenString := parts[1]
+
+			// Parse and validate the token.
+			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				// Ensure HMAC signing method to prevent downgrade attacks.
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, errors.New("unexpected signing method")
+				}
+				return []byte(jwtSecret), nil
+			})
+
+			if err != nil || !token.Valid {
+				return ErrInvalidJWT
+			}
+
+			// Extract claims from the token.
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				return ErrInvalidJWT
+			}
+
+			// Store claims in the context for later use.
+			c.Set("user", claims)
+
+			return next(c)
+		}
+	}
+}
+
+// GetUserFromContext retrieves JWT claims from the Echo context.
+func GetUserFromContext(c echo.Context) (jwt.MapClaims, bool) {
+	user := c.Get("user")
+	if user == nil {
+		return nil, false
+	}
+	claims, ok := user.(jwt.MapClaims)
+	return claims, ok
+}
+
+// SubjectFromContext returns the "sub" claim of the authenticated user, or an
+// empty string when the request is not authenticated.
+func SubjectFromContext(c echo.Context) string {
+	claims, ok := GetUserFromContext(c)
+	if !ok {
+		return ""
+	}
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// RoleFromContext returns the role claim of the authenticated user. Tokens
+// without one, such as those issued before roles existed, act as a customer.
+// It returns an empty string when the request is not authenticated.
+func RoleFromContext(c echo.Context) string {
+	claims, ok := GetUserFromContext(c)
+	if !ok {
+		return ""
+	}
+	role, _ := claims["role"].(string)
+	if role == "" {
+		return RoleCustomer
+	}
+	return role
+}
+
+// HasPermission reports whether the authenticated user's role grants
+// permission in this service.
+func HasPermission(c echo.Context, permission string) bool {
+	return slices.Contains(rolePermissions[RoleFromContext(c)], permission)
+}
+
+// RequireRole returns a middleware that lets a request through only when the
+// authenticated user has one of roles. It must run after JWTAuthMiddleware.
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !slices.Contains(roles, RoleFromContext(c)) {
+				return forbidden(c)
+			}
+			return next(c)
+		}
+	}
+}
+
+// forbidden refuses a request the caller's role does not allow.
+func forbidden(c echo.Context) error {
+	return c.JSON(http.StatusForbidden, map[string]string{"error": "Insufficient permissions"})
+}
+
+// RequirePermission returns a middleware that lets a request through only
+// when the authenticated user's role grants permission. It must run after
+// JWTAuthMiddleware.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !HasPermission(c, permission) {
+				return forbidden(c)
+			}
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"user-service/middleware"
+	"user-service/module/models"
+	"user-service/module/usecases"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type AddressHandler struct {
+	addressUsecase usecases.AddressUsecase
+}
+
+func NewAddressHandler(addressUsecase usecases.AddressUsecase) *AddressHandler {
+	return &AddressHandler{addressUsecase: addressUsecase}
+}
+
+// RegisterRoutes exposes the signed-in user's address book.
+func (h *AddressHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
+	addressGroup := router.Group("/users/me/addresses", authMiddleware)
+	addressGroup.GET("", h.ListAddresses)
+	addressGroup.POST("", h.CreateAddress)
+	addressGroup.GET("/:id", h.GetAddress)
+	addressGroup.PUT("/:id", h.UpdateAddress)
+	addressGroup.DELETE("/:id", h.DeleteAddress)
+}
+
+func (h *AddressHandler) ListAddresses(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+
+	addresses, err := h.addressUsecase.ListAddresses(c.Request().Context(), userID)
+	if err != nil {
+		return addressError(c, err, "Failed to list addresses")
+	}
+	return c.JSON(http.StatusOK, addresses)
+}
+
+func (h *AddressHandler) CreateAddress(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	var req models.AddressRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	address, err := h.addressUsecase.CreateAddress(c.Request().Context(), userID, req)
+	if err != nil {
+		return addressError(c, err, "Failed to create address")
+	}
+	return c.JSON(http.StatusCreated, address)
+}
+
+func (h *AddressHandler) GetAddress(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	addressID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid address ID"})
+	}
+
+	address, err := h.addressUsecase.GetAddress(c.Request().Context(), userID, addressID)
+	if err != nil {
+		return addressError(c, err, "Failed to get address")
+	}
+	return c.JSON(http.StatusOK, address)
+}
+
+func (h *AddressHandler) UpdateAddress(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	addressID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid address ID"})
+	}
+	var req models.AddressRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	address, err := h.addressUsecase.UpdateAddress(c.Request().Context(), userID, addressID, req)
+	if err != nil {
+		return addressError(c, err, "Failed to update address")
+	}
+	return c.JSON(http.StatusOK, address)
+}
+
+func (h *AddressHandler) DeleteAddress(c echo.Context) error {
+	userID, err := uuid.Parse(middleware.SubjectFromContext(c))
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid user ID in token"})
+	}
+	addressID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid address ID"})
+	}
+
+	if err := h.addressUsecase.DeleteAddress(c.Request().Context(), userID, addressID); err != nil {
+		return addressError(c, err, "Failed to delete address")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func addressError(c echo.Context, err error, message string) error {
+	if errors.Is(err, usecases.ErrAddressNotFound) || errors.Is(err, usecases.ErrUserNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	c.Logger().Errorf("Address error: %v", err)
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": message})
+}
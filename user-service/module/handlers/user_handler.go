@@ -3,9 +3,11 @@ package handlers
 import (
 	"errors"
 	"net/http"
+	"user-service/middleware"
 	"user-service/module/models"
 	"user-service/module/usecases"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
@@ -20,11 +22,12 @@ func NewUserHandler(userUsecase usecases.UserUsecase) *UserHandler {
 }
 
 // RegisterRoutes mendaftarkan semua endpoint yang berhubungan dengan user ke router Echo.
-func (h *UserHandler) RegisterRoutes(router *echo.Group) {
+func (h *UserHandler) RegisterRoutes(router *echo.Group, authMiddleware echo.MiddlewareFunc) {
 	userGroup := router.Group("/users")
 	{
 		userGroup.POST("/register", h.Register)
 		userGroup.POST("/login", h.Login)
+		userGroup.PUT("/:id/role", h.AssignRole, authMiddleware, middleware.RequirePermission(middleware.PermManageUsers))
 	}
 }
 
@@ -78,3 +81,30 @@ func (h *UserHandler) Login(c echo.Context) error {
 	return c.JSON(http.StatusOK, res) // 200 OK
 }
 
+// AssignRole gives a user a new role. Only admins may do this.
+func (h *UserHandler) AssignRole(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+	var req models.AssignRoleRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := c.Validate(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	user, err := h.userUsecase.AssignRole(c.Request().Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, usecases.ErrUserNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, usecases.ErrLastAdmin) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		c.Logger().Errorf("Error assigning role: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to assign role"})
+	}
+	return c.JSON(http.StatusOK, user)
+}
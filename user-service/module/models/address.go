@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Address is an entry in a user's address book. At most one address per
+// user is the default.
+type Address struct {
+	ID            uuid.UUID `db:"id" json:"id"`
+	UserID        uuid.UUID `db:"user_id" json:"user_id"`
+	Label         string    `db:"label" json:"label,omitempty"`
+	RecipientName string    `db:"recipient_name" json:"recipient_name"`
+	Phone         string    `db:"phone" json:"phone"`
+	Line1         string    `db:"line1" json:"line1"`
+	Line2         string    `db:"line2" json:"line2,omitempty"`
+	City          string    `db:"city" json:"city"`
+	Province      string    `db:"province" json:"province,omitempty"`
+	PostalCode    string    `db:"postal_code" json:"postal_code"`
+	CountryCode   string    `db:"country_code" json:"country_code"`
+	IsDefault     bool      `db:"is_default" json:"is_default"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// AddressRequest creates or replaces an address. IsDefault makes it the
+// default address; the default only moves when another address takes it.
+type AddressRequest struct {
+	Label         string `json:"label" validate:"omitempty,max=64"`
+	RecipientName string `json:"recipient_name" validate:"required,max=255"`
+	Phone         string `json:"phone" validate:"required,max=32"`
+	Line1         string `json:"line1" validate:"required,max=255"`
+	Line2         string `json:"line2" validate:"omitempty,max=255"`
+	City          string `json:"city" validate:"required,max=128"`
+	Province      string `json:"province" validate:"omitempty,max=128"`
+	PostalCode    string `json:"postal_code" validate:"required,max=16"`
+	CountryCode   string `json:"country_code" validate:"required,iso3166_1_alpha2"`
+	IsDefault     bool   `json:"is_default"`
+}
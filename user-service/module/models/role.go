@@ -0,0 +1,15 @@
+package models
+
+// Roles a user can hold. Every new user is a customer; admins assign the
+// other roles. Login puts the role in the token's "role" claim, and each
+// service decides what it lets a role do.
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+// AssignRoleRequest changes the role of a user.
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=customer staff admin"`
+}
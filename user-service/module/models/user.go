@@ -11,6 +11,7 @@ type User struct {
 	ID           uuid.UUID `db:"id" json:"id"`
 	Name         string    `db:"name" json:"name"`
 	Email        string    `db:"email" json:"email"`
+	Role         string    `db:"role" json:"role"`
 	PasswordHash string    `db:"password_hash" json:"-"` // Tanda `-` berarti jangan pernah kirim field ini dalam response JSON.
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
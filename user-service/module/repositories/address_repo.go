@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"context"
+	"user-service/module/models"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AddressRepository stores users' address books. Writes lock the owning user
+// so that concurrent changes keep exactly one default address.
+type AddressRepository interface {
+	FindByUserID(ctx context.Context, userID uuid.UUID) ([]models.Address, error)
+	FindByID(ctx context.Context, userID, addressID uuid.UUID) (*models.Address, error)
+	Create(ctx context.Context, address *models.Address) error
+	Update(ctx context.Context, address *models.Address) error
+	Delete(ctx context.Context, userID, addressID uuid.UUID) error
+}
+
+type addressRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAddressRepository(db *pgxpool.Pool) AddressRepository {
+	return &addressRepository{db: db}
+}
+
+const addressColumns = `id, user_id, COALESCE(label, ''), recipient_name, phone, line1, COALESCE(line2, ''), city,
+						COALESCE(province, ''), postal_code, country_code, is_default, created_at, updated_at`
+
+func scanAddress(row pgx.Row, a *models.Address) error {
+	return row.Scan(&a.ID, &a.UserID, &a.Label, &a.RecipientName, &a.Phone, &a.Line1, &a.Line2, &a.City,
+		&a.Province, &a.PostalCode, &a.CountryCode, &a.IsDefault, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// FindByUserID lists a user's addresses, the default first and then the
+// newest.
+func (r *addressRepository) FindByUserID(ctx context.Context, userID uuid.UUID) ([]models.Address, error) {
+	query := `SELECT ` + addressColumns + ` FROM user_addresses WHERE user_id = $1 ORDER BY is_default DESC, created_at DESC, id`
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []models.Address{}
+	for rows.Next() {
+		var address models.Address
+		if err := scanAddress(rows, &address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, rows.Err()
+}
+
+func (r *addressRepository) FindByID(ctx context.Context, userID, addressID uuid.UUID) (*models.Address, error) {
+	var address models.Address
+	query := `SELECT ` + addressColumns + ` FROM user_addresses WHERE id = $1 AND user_id = $2`
+	if err := scanAddress(r.db.QueryRow(ctx, query, addressID, userID), &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// Create saves a new address. A user's first address always becomes the
+// default, and IsDefault is set to match.
+func (r *addressRepository) Create(ctx context.Context, address *models.Address) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUser(ctx, tx, address.UserID); err != nil {
+		return err
+	}
+	var hasDefault bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM user_addresses WHERE user_id = $1 AND is_default)`, address.UserID).Scan(&hasDefault)
+	if err != nil {
+		return err
+	}
+	if !hasDefault {
+		address.IsDefault = true
+	}
+	if address.IsDefault {
+		if err := clearDefaultAddress(ctx, tx, address.UserID); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO user_addresses (id, user_id, label, recipient_name, phone, line1, line2, city, province, postal_code, country_code,
+				  is_default, created_at, updated_at)
+			  VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, NULLIF($7, ''), $8, NULLIF($9, ''), $10, $11, $12, $13, $14)`
+	_, err = tx.Exec(ctx, query, address.ID, address.UserID, address.Label, address.RecipientName, address.Phone, address.Line1,
+		address.Line2, address.City, address.Province, address.PostalCode, address.CountryCode,
+		address.IsDefault, address.CreatedAt, address.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Update saves an address's details, making it the default when IsDefault
+// is set. Clearing IsDefault does not unset the default; IsDefault is set to
+// whether the address is the default afterwards. An address the user does
+// not have is pgx.ErrNoRows.
+func (r *addressRepository) Update(ctx context.Context, address *models.Address) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUser(ctx, tx, address.UserID); err != nil {
+		return err
+	}
+	if address.IsDefault {
+		if err := clearDefaultAddress(ctx, tx, address.UserID); err != nil {
+			return err
+		}
+	}
+
+	query := `UPDATE user_addresses SET label = NULLIF($3, ''), recipient_name = $4, phone = $5, line1 = $6, line2 = NULLIF($7, ''),
+				  city = $8, province = NULLIF($9, ''), postal_code = $10, country_code = $11, is_default = is_default OR $12, updated_at = $13
+			  WHERE id = $1 AND user_id = $2
+			  RETURNING is_default, created_at`
+	err = tx.QueryRow(ctx, query, address.ID, address.UserID, address.Label, address.RecipientName, address.Phone, address.Line1,
+		address.Line2, address.City, address.Province, address.PostalCode, address.CountryCode,
+		address.IsDefault, address.UpdatedAt).Scan(&address.IsDefault, &address.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Delete removes an address. When it was the default, the user's newest
+// remaining address becomes the default. An address the user does not have
+// is pgx.ErrNoRows.
+func (r *addressRepository) Delete(ctx context.Context, userID, addressID uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockUser(ctx, tx, userID); err != nil {
+		return err
+	}
+	var wasDefault bool
+	err = tx.QueryRow(ctx, `DELETE FROM user_addresses WHERE id = $1 AND user_id = $2 RETURNING is_default`, addressID, userID).Scan(&wasDefault)
+	if err != nil {
+		return err
+	}
+	if wasDefault {
+		query := `UPDATE user_addresses SET is_default = TRUE
+				  WHERE id = (SELECT id FROM user_addresses WHERE user_id = $1 ORDER BY created_at DESC, id LIMIT 1)`
+		if _, err := tx.Exec(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// lockUser serializes address book changes of one user.
+func lockUser(ctx context.Context, tx pgx.Tx, userID uuid.UUID) error {
+	var id uuid.UUID
+	return tx.QueryRow(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+}
+
+func clearDefaultAddress(ctx context.Context, tx pgx.Tx, userID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `UPDATE user_addresses SET is_default = FALSE WHERE user_id = $1 AND is_default`, userID)
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"user-service/module/models"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -12,6 +14,9 @@ import (
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) error
 	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	UpdateRole(ctx context.Context, id uuid.UUID, role string) (*models.User, error)
+	PromoteFirstAdmin(ctx context.Context, email string) (bool, error)
 }
 
 // Struct ini adalah implementasi konkret dari interface di atas.
@@ -26,23 +31,24 @@ func NewUserRepository(db *pgxpool.Pool) UserRepository {
 
 // Create menyimpan user baru ke dalam database.
 func (r *userRepository) Create(ctx context.Context, user *models.User) error {
-	query := `INSERT INTO users (id, name, email, password_hash, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4, $5, $6)`
+	query := `INSERT INTO users (id, name, email, password_hash, role, created_at, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
 	// ExecContext digunakan untuk query yang tidak mengembalikan baris data (INSERT, UPDATE, DELETE).
-	_, err := r.db.Exec(ctx, query, user.ID, user.Name, user.Email, user.PasswordHash, user.CreatedAt, user.UpdatedAt)
+	_, err := r.db.Exec(ctx, query, user.ID, user.Name, user.Email, user.PasswordHash, user.Role, user.CreatedAt, user.UpdatedAt)
 	return err
 }
 
 // FindByEmail mencari user berdasarkan alamat email.
 func (r *userRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, name, email, password_hash, created_at, updated_at FROM users WHERE email = $1`
+	query := `SELECT id, name, email, password_hash, role, created_at, updated_at FROM users WHERE email = $1`
 	// GetContext digunakan untuk query yang diharapkan mengembalikan satu baris data.
 	err := r.db.QueryRow(ctx, query, email).Scan(
 		&user.ID,
 		&user.Name,
 		&user.Email,
 		&user.PasswordHash,
+		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -53,3 +59,63 @@ func (r *userRepository) FindByEmail(ctx context.Context, email string) (*models
 	}
 	return &user, nil
 }
+
+// FindByID returns the user with the given ID, or pgx.ErrNoRows.
+func (r *userRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	query := `SELECT id, name, email, password_hash, role, created_at, updated_at FROM users WHERE id = $1`
+	err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateRole gives a user a new role and returns them. It returns
+// pgx.ErrNoRows when the user does not exist or is the last admin and would
+// stop being one.
+func (r *userRepository) UpdateRole(ctx context.Context, id uuid.UUID, role string) (*models.User, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the admins, so two of them cannot demote each other at once and
+	// leave none.
+	rows, err := tx.Query(ctx, `SELECT id FROM users WHERE role = $1 FOR UPDATE`, models.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	admins, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, err
+	}
+	if role != models.RoleAdmin && len(admins) == 1 && admins[0] == id {
+		return nil, pgx.ErrNoRows
+	}
+
+	var user models.User
+	query := `UPDATE users SET role = $2, updated_at = now() WHERE id = $1
+			  RETURNING id, name, email, password_hash, role, created_at, updated_at`
+	err = tx.QueryRow(ctx, query, id, role).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// PromoteFirstAdmin makes the user with the given email an admin, provided
+// there is no admin yet. It reports whether the user was promoted.
+func (r *userRepository) PromoteFirstAdmin(ctx context.Context, email string) (bool, error) {
+	query := `UPDATE users SET role = $2, updated_at = now()
+			  WHERE email = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE role = $2)`
+	tag, err := r.db.Exec(ctx, query, email, models.RoleAdmin)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
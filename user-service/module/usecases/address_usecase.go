@@ -0,0 +1,103 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"user-service/module/models"
+	"user-service/module/repositories"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrAddressNotFound = errors.New("address not found")
+	ErrUserNotFound    = errors.New("user not found")
+)
+
+// AddressUsecase manages the address book of the signed-in user.
+type AddressUsecase interface {
+	ListAddresses(ctx context.Context, userID uuid.UUID) ([]models.Address, error)
+	GetAddress(ctx context.Context, userID, addressID uuid.UUID) (*models.Address, error)
+	CreateAddress(ctx context.Context, userID uuid.UUID, req models.AddressRequest) (*models.Address, error)
+	UpdateAddress(ctx context.Context, userID, addressID uuid.UUID, req models.AddressRequest) (*models.Address, error)
+	DeleteAddress(ctx context.Context, userID, addressID uuid.UUID) error
+}
+
+type addressUsecase struct {
+	addressRepo repositories.AddressRepository
+}
+
+func NewAddressUsecase(addressRepo repositories.AddressRepository) AddressUsecase {
+	return &addressUsecase{addressRepo: addressRepo}
+}
+
+func (u *addressUsecase) ListAddresses(ctx context.Context, userID uuid.UUID) ([]models.Address, error) {
+	return u.addressRepo.FindByUserID(ctx, userID)
+}
+
+func (u *addressUsecase) GetAddress(ctx context.Context, userID, addressID uuid.UUID) (*models.Address, error) {
+	address, err := u.addressRepo.FindByID(ctx, userID, addressID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAddressNotFound
+		}
+		return nil, err
+	}
+	return address, nil
+}
+
+func (u *addressUsecase) CreateAddress(ctx context.Context, userID uuid.UUID, req models.AddressRequest) (*models.Address, error) {
+	now := time.Now()
+	address := &models.Address{ID: uuid.New(), UserID: userID, CreatedAt: now, UpdatedAt: now}
+	applyAddressRequest(address, req)
+
+	if err := u.addressRepo.Create(ctx, address); err != nil {
+		// The user behind a valid token may have been deleted.
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return address, nil
+}
+
+func (u *addressUsecase) UpdateAddress(ctx context.Context, userID, addressID uuid.UUID, req models.AddressRequest) (*models.Address, error) {
+	address := &models.Address{ID: addressID, UserID: userID, UpdatedAt: time.Now()}
+	applyAddressRequest(address, req)
+
+	if err := u.addressRepo.Update(ctx, address); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAddressNotFound
+		}
+		return nil, err
+	}
+	return address, nil
+}
+
+func (u *addressUsecase) DeleteAddress(ctx context.Context, userID, addressID uuid.UUID) error {
+	if err := u.addressRepo.Delete(ctx, userID, addressID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrAddressNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// applyAddressRequest copies the request onto the address, trimming
+// whitespace and upper-casing the country code.
+func applyAddressRequest(address *models.Address, req models.AddressRequest) {
+	address.Label = strings.TrimSpace(req.Label)
+	address.RecipientName = strings.TrimSpace(req.RecipientName)
+	address.Phone = strings.TrimSpace(req.Phone)
+	address.Line1 = strings.TrimSpace(req.Line1)
+	address.Line2 = strings.TrimSpace(req.Line2)
+	address.City = strings.TrimSpace(req.City)
+	address.Province = strings.TrimSpace(req.Province)
+	address.PostalCode = strings.TrimSpace(req.PostalCode)
+	address.CountryCode = strings.ToUpper(req.CountryCode)
+	address.IsDefault = req.IsDefault
+}
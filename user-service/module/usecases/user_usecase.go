@@ -10,6 +10,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -19,22 +20,34 @@ var (
 	ErrInvalidCredentials = errors.New("invalid email or password")
 )
 
+// ErrLastAdmin is returned when a role change would leave no admin.
+var ErrLastAdmin = errors.New("the last admin cannot be given another role")
+
 // UserUsecase mendefinisikan logika bisnis untuk user.
 type UserUsecase interface {
 	Register(ctx context.Context, req models.RegisterRequest) (*models.User, error)
 	Login(ctx context.Context, req models.LoginRequest) (*models.LoginResponse, error)
+	AssignRole(ctx context.Context, userID uuid.UUID, req models.AssignRoleRequest) (*models.User, error)
+	BootstrapAdmin(ctx context.Context) (bool, error)
 }
 
 type userUsecase struct {
 	userRepo  repositories.UserRepository
 	jwtSecret string
+
+	// bootstrapAdminEmail is the user made admin at startup while there is
+	// none.
+	bootstrapAdminEmail string
 }
 
-// NewUserUsecase adalah constructor untuk usecase.
-func NewUserUsecase(userRepo repositories.UserRepository, jwtSecret string) UserUsecase {
+// NewUserUsecase adalah constructor untuk usecase. While no admin exists,
+// BootstrapAdmin makes the user with bootstrapAdminEmail one; an empty email
+// turns this off.
+func NewUserUsecase(userRepo repositories.UserRepository, jwtSecret, bootstrapAdminEmail string) UserUsecase {
 	return &userUsecase{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:            userRepo,
+		jwtSecret:           jwtSecret,
+		bootstrapAdminEmail: bootstrapAdminEmail,
 	}
 }
 
@@ -61,6 +74,7 @@ func (u *userUsecase) Register(ctx context.Context, req models.RegisterRequest)
 		Name:         req.Name,
 		Email:        req.Email,
 		PasswordHash: string(hashedPassword),
+		Role:         models.RoleCustomer,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
@@ -95,6 +109,7 @@ func (u *userUsecase) Login(ctx context.Context, req models.LoginRequest) (*mode
 		"sub":   user.ID, // Subject (standard claim), diisi user ID.
 		"name":  user.Name,
 		"email": user.Email,
+		"role":  user.Role,
 		"exp":   time.Now().Add(time.Hour * 72).Unix(), // Token berlaku 72 jam.
 		"iat":   time.Now().Unix(),                      // Issued At (standard claim).
 	}
@@ -108,3 +123,29 @@ func (u *userUsecase) Login(ctx context.Context, req models.LoginRequest) (*mode
 
 	return &models.LoginResponse{AccessToken: tokenString}, nil
 }
+
+// AssignRole gives a user a new role. It takes effect at their next login,
+// since tokens already issued keep the role they were issued with.
+func (u *userUsecase) AssignRole(ctx context.Context, userID uuid.UUID, req models.AssignRoleRequest) (*models.User, error) {
+	user, err := u.userRepo.UpdateRole(ctx, userID, req.Role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := u.userRepo.FindByID(ctx, userID); errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, ErrLastAdmin
+	}
+	return user, err
+}
+
+// BootstrapAdmin makes the bootstrap admin, if they have registered, an admin
+// when there is none yet. It reports whether it did. It runs only at startup:
+// promoting on registration would hand admin to whoever first signs up with
+// the address.
+func (u *userUsecase) BootstrapAdmin(ctx context.Context) (bool, error) {
+	if u.bootstrapAdminEmail == "" {
+		return false, nil
+	}
+	return u.userRepo.PromoteFirstAdmin(ctx, u.bootstrapAdminEmail)
+}